@@ -76,6 +76,12 @@ func (b *recordBuffer) WriteDashOrStringValue(s string) {
 	}
 }
 
+// The zone selections of the router, when it prefers the endpoints in its zone.
+const (
+	ZoneLocal     = "local"
+	ZoneSpillover = "spillover"
+)
+
 // AccessLogRecord represents a single access log line
 type AccessLogRecord struct {
 	Request              *http.Request
@@ -87,6 +93,9 @@ type AccessLogRecord struct {
 	BodyBytesSent        int
 	RequestBytesReceived int
 	ExtraHeadersToLog    *[]string
+	RouterError          string
+	ClientIP             string
+	ZoneSelection        string
 	record               []byte
 }
 
@@ -151,6 +160,21 @@ func (r *AccessLogRecord) makeRecord() []byte {
 	b.WriteString(`app_index:`)
 	b.WriteDashOrStringValue(appIndex)
 
+	if r.ClientIP != "" {
+		b.WriteString(` client_ip:`)
+		b.WriteDashOrStringValue(r.ClientIP)
+	}
+
+	if r.ZoneSelection != "" {
+		b.WriteString(` zone_selection:`)
+		b.WriteDashOrStringValue(r.ZoneSelection)
+	}
+
+	if r.RouterError != "" {
+		b.WriteString(` router_error:`)
+		b.WriteDashOrStringValue(r.RouterError)
+	}
+
 	r.addExtraHeaders(b)
 
 	b.WriteByte('\n')
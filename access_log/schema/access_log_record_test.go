@@ -107,6 +107,93 @@ var _ = Describe("AccessLogRecord", func() {
 			})
 		})
 
+		Context("with a router error", func() {
+			BeforeEach(func() {
+				record.StatusCode = 504
+				record.RouterError = "dial_timeout"
+			})
+			It("appends the error type", func() {
+				recordString := "FakeRequestHost - " +
+					"[2000-01-01T00:00:00.000+0000] " +
+					`"FakeRequestMethod http://example.com/request FakeRequestProto" ` +
+					"504 " +
+					"30 " +
+					"23 " +
+					`"FakeReferer" ` +
+					`"FakeUserAgent" ` +
+					`"FakeRemoteAddr" ` +
+					`"1.2.3.4:1234" ` +
+					`x_forwarded_for:"FakeProxy1, FakeProxy2" ` +
+					`x_forwarded_proto:"FakeOriginalRequestProto" ` +
+					`vcap_request_id:"abc-123-xyz-pdq" ` +
+					`response_time:60 ` +
+					`app_id:"FakeApplicationId" ` +
+					`app_index:"3" ` +
+					`router_error:"dial_timeout"` +
+					"\n"
+
+				Expect(record.LogMessage()).To(Equal(recordString))
+			})
+		})
+
+		Context("with a client IP", func() {
+			BeforeEach(func() {
+				record.ClientIP = "5.6.7.8"
+				record.RouterError = "dial_timeout"
+			})
+			It("appends the client IP", func() {
+				recordString := "FakeRequestHost - " +
+					"[2000-01-01T00:00:00.000+0000] " +
+					`"FakeRequestMethod http://example.com/request FakeRequestProto" ` +
+					"200 " +
+					"30 " +
+					"23 " +
+					`"FakeReferer" ` +
+					`"FakeUserAgent" ` +
+					`"FakeRemoteAddr" ` +
+					`"1.2.3.4:1234" ` +
+					`x_forwarded_for:"FakeProxy1, FakeProxy2" ` +
+					`x_forwarded_proto:"FakeOriginalRequestProto" ` +
+					`vcap_request_id:"abc-123-xyz-pdq" ` +
+					`response_time:60 ` +
+					`app_id:"FakeApplicationId" ` +
+					`app_index:"3" ` +
+					`client_ip:"5.6.7.8" ` +
+					`router_error:"dial_timeout"` +
+					"\n"
+
+				Expect(record.LogMessage()).To(Equal(recordString))
+			})
+		})
+
+		Context("with a zone selection", func() {
+			BeforeEach(func() {
+				record.ZoneSelection = schema.ZoneSpillover
+			})
+			It("appends the zone selection", func() {
+				recordString := "FakeRequestHost - " +
+					"[2000-01-01T00:00:00.000+0000] " +
+					`"FakeRequestMethod http://example.com/request FakeRequestProto" ` +
+					"200 " +
+					"30 " +
+					"23 " +
+					`"FakeReferer" ` +
+					`"FakeUserAgent" ` +
+					`"FakeRemoteAddr" ` +
+					`"1.2.3.4:1234" ` +
+					`x_forwarded_for:"FakeProxy1, FakeProxy2" ` +
+					`x_forwarded_proto:"FakeOriginalRequestProto" ` +
+					`vcap_request_id:"abc-123-xyz-pdq" ` +
+					`response_time:60 ` +
+					`app_id:"FakeApplicationId" ` +
+					`app_index:"3" ` +
+					`zone_selection:"spillover"` +
+					"\n"
+
+				Expect(record.LogMessage()).To(Equal(recordString))
+			})
+		})
+
 		Context("with route endpoint missing", func() {
 			BeforeEach(func() {
 				record = &schema.AccessLogRecord{}
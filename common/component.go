@@ -83,12 +83,13 @@ func (p *ProcessStatus) StopUpdate() {
 var procStat *ProcessStatus
 
 type VcapComponent struct {
-	Config     interface{}     `json:"-"`
-	Varz       *health.Varz    `json:"-"`
-	Healthz    *health.Healthz `json:"-"`
-	Health     negroni.Handler
-	InfoRoutes map[string]json.Marshaler `json:"-"`
-	Logger     lager.Logger              `json:"-"`
+	Config       interface{}     `json:"-"`
+	Varz         *health.Varz    `json:"-"`
+	Healthz      *health.Healthz `json:"-"`
+	Health       negroni.Handler
+	InfoRoutes   map[string]json.Marshaler `json:"-"`
+	InfoHandlers map[string]http.Handler   `json:"-"`
+	Logger       lager.Logger              `json:"-"`
 
 	listener net.Listener
 	statusCh chan error
@@ -243,6 +244,10 @@ func (c *VcapComponent) ListenAndServe() {
 		})
 	}
 
+	for path, handler := range c.InfoHandlers {
+		hs.Handle(path, handler)
+	}
+
 	f := func(user, password string) bool {
 		return user == c.Varz.Credentials[0] && password == c.Varz.Credentials[1]
 	}
@@ -102,6 +102,30 @@ var _ = Describe("Component", func() {
 		Expect(body).To(Equal(`{"key":"value2"}` + "\n"))
 	})
 
+	It("serves info handlers", func() {
+		path := "/handler"
+
+		component.InfoHandlers = map[string]http.Handler{
+			path: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				w.Write([]byte(req.Method))
+			}),
+		}
+		serveComponent(component)
+
+		req := buildGetRequest(component, path)
+		code, _, _ := doGetRequest(req)
+		Expect(code).To(Equal(401))
+
+		req, err := http.NewRequest("DELETE", "http://"+component.Varz.Host+path, nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.SetBasicAuth("username", "password")
+
+		code, _, body := doGetRequest(req)
+		Expect(code).To(Equal(http.StatusAccepted))
+		Expect(body).To(Equal("DELETE"))
+	})
+
 	It("allows authorized access", func() {
 		path := "/test"
 
@@ -0,0 +1,138 @@
+package http
+
+import (
+	"net"
+	"strings"
+)
+
+// ForwardedElement is one element of a Forwarded header (RFC 7239), which is
+// added by each proxy a request goes through.
+type ForwardedElement struct {
+	For   string
+	By    string
+	Proto string
+	Host  string
+}
+
+// ParseForwarded parses the elements of Forwarded headers, in the order they
+// were added. Parameters other than for, by, proto and host are ignored.
+func ParseForwarded(values []string) []ForwardedElement {
+	var elements []ForwardedElement
+	for _, value := range values {
+		for _, element := range splitQuoted(value, ',') {
+			if strings.TrimSpace(element) == "" {
+				continue
+			}
+
+			var e ForwardedElement
+			for _, pair := range splitQuoted(element, ';') {
+				eq := strings.IndexByte(pair, '=')
+				if eq < 0 {
+					continue
+				}
+				v := unquote(strings.TrimSpace(pair[eq+1:]))
+				switch strings.ToLower(strings.TrimSpace(pair[:eq])) {
+				case "for":
+					e.For = v
+				case "by":
+					e.By = v
+				case "proto":
+					e.Proto = v
+				case "host":
+					e.Host = v
+				}
+			}
+			elements = append(elements, e)
+		}
+	}
+	return elements
+}
+
+// String formats the element as it is sent in a Forwarded header, quoting the
+// values that are not tokens.
+func (e ForwardedElement) String() string {
+	var pairs []string
+	for _, p := range []struct{ key, value string }{
+		{"for", e.For},
+		{"proto", e.Proto},
+		{"host", e.Host},
+		{"by", e.By},
+	} {
+		if p.value != "" {
+			pairs = append(pairs, p.key+"="+quote(p.value))
+		}
+	}
+	return strings.Join(pairs, ";")
+}
+
+// ForwardedNode formats an IP as the node of a for or by parameter.
+func ForwardedNode(ip net.IP) string {
+	if ip.To4() == nil {
+		return "[" + ip.String() + "]"
+	}
+	return ip.String()
+}
+
+// ForwardedNodeIP returns the IP of the node of a for or by parameter, or nil
+// for nodes that are unknown or obfuscated.
+func ForwardedNodeIP(node string) net.IP {
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		node = host
+	}
+	return net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(node, "["), "]"))
+}
+
+// splitQuoted splits s at each sep that is not inside a quoted string.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted, escaped := false, false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && s[i] == '\\':
+			escaped = true
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && s[i] == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+
+	s = s[1 : len(s)-1]
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func quote(s string) string {
+	if isToken(s) {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func isToken(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+			strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0) {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,69 @@
+package http_test
+
+import (
+	"net"
+
+	commonhttp "code.cloudfoundry.org/gorouter/common/http"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Forwarded", func() {
+	Describe("ParseForwarded", func() {
+		It("parses the elements of all headers in order", func() {
+			elements := commonhttp.ParseForwarded([]string{
+				`for=192.0.2.60;proto=http;by=203.0.113.43, For="[2001:db8:cafe::17]:4711"`,
+				`for=unknown;host="example.com:8080";secret=x`,
+			})
+			Expect(elements).To(Equal([]commonhttp.ForwardedElement{
+				{For: "192.0.2.60", Proto: "http", By: "203.0.113.43"},
+				{For: "[2001:db8:cafe::17]:4711"},
+				{For: "unknown", Host: "example.com:8080"},
+			}))
+		})
+
+		It("does not split quoted values", func() {
+			elements := commonhttp.ParseForwarded([]string{`for="a,b;c\"d", for=e`})
+			Expect(elements).To(HaveLen(2))
+			Expect(elements[0].For).To(Equal(`a,b;c"d`))
+			Expect(elements[1].For).To(Equal("e"))
+		})
+
+		It("skips empty elements", func() {
+			Expect(commonhttp.ParseForwarded([]string{" , for=a,"})).To(HaveLen(1))
+		})
+	})
+
+	Describe("String", func() {
+		It("formats the parameters that are set, quoting those that are not tokens", func() {
+			e := commonhttp.ForwardedElement{For: "[2001:db8::1]", Proto: "https", Host: "example.com:8080"}
+			Expect(e.String()).To(Equal(`for="[2001:db8::1]";proto=https;host="example.com:8080"`))
+		})
+
+		It("is parsed back", func() {
+			e := commonhttp.ForwardedElement{For: `a"b`, By: "10.0.0.1", Proto: "http", Host: "example.com"}
+			Expect(commonhttp.ParseForwarded([]string{e.String()})).To(Equal([]commonhttp.ForwardedElement{e}))
+		})
+	})
+
+	Describe("ForwardedNode", func() {
+		It("brackets IPv6 addresses", func() {
+			Expect(commonhttp.ForwardedNode(net.ParseIP("192.0.2.1"))).To(Equal("192.0.2.1"))
+			Expect(commonhttp.ForwardedNode(net.ParseIP("2001:db8::1"))).To(Equal("[2001:db8::1]"))
+		})
+	})
+
+	Describe("ForwardedNodeIP", func() {
+		It("returns the IP of nodes with or without a port", func() {
+			Expect(commonhttp.ForwardedNodeIP("192.0.2.1").String()).To(Equal("192.0.2.1"))
+			Expect(commonhttp.ForwardedNodeIP("192.0.2.1:80").String()).To(Equal("192.0.2.1"))
+			Expect(commonhttp.ForwardedNodeIP("[2001:db8::1]").String()).To(Equal("2001:db8::1"))
+			Expect(commonhttp.ForwardedNodeIP("[2001:db8::1]:4711").String()).To(Equal("2001:db8::1"))
+		})
+
+		It("returns nil for unknown and obfuscated nodes", func() {
+			Expect(commonhttp.ForwardedNodeIP("unknown")).To(BeNil())
+			Expect(commonhttp.ForwardedNodeIP("_hidden")).To(BeNil())
+		})
+	})
+})
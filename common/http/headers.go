@@ -22,6 +22,7 @@ const (
 	B3SpanIdHeader        = "X-B3-SpanId"
 	B3ParentSpanIdHeader  = "X-B3-ParentSpanId"
 	CfAppInstance         = "X-CF-APP-INSTANCE"
+	ForwardedHeader       = "Forwarded"
 )
 
 func SetVcapRequestIdHeader(request *http.Request, logger lager.Logger) {
@@ -0,0 +1,299 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// TLV types of the PROXY protocol v2.
+const (
+	TLVTypeALPN      = 0x01
+	TLVTypeAuthority = 0x02
+	TLVTypeUniqueID  = 0x05
+	TLVTypeSSL       = 0x20
+	TLVTypeNetNS     = 0x30
+
+	// TLVTypeAWS carries the AWS extensions, whose first byte is a subtype.
+	TLVTypeAWS = 0xEA
+
+	awsSubtypeVPCEndpointID = 0x01
+)
+
+const (
+	v1MaxLength = 107
+
+	v2CommandLocal = 0x20
+	v2CommandProxy = 0x21
+
+	v2FamilyUnspec = 0x00
+	v2FamilyTCP4   = 0x11
+	v2FamilyTCP6   = 0x21
+)
+
+var (
+	v1Prefix    = []byte("PROXY ")
+	v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	ErrNoProxyHeader      = errors.New("no PROXY protocol header")
+	ErrInvalidProxyHeader = errors.New("invalid PROXY protocol header")
+)
+
+// TLV is a type-length-value field of a PROXY protocol v2 header.
+type TLV struct {
+	Type  byte
+	Value []byte
+}
+
+// Header is a PROXY protocol header. The addresses are nil when the sender
+// does not know them, such as for health checks of a load balancer.
+type Header struct {
+	Version     int
+	Source      *net.TCPAddr
+	Destination *net.TCPAddr
+	TLVs        []TLV
+}
+
+// AWSVPCEndpointID returns the id of the VPC endpoint the connection came
+// through, as sent by AWS Network Load Balancers, or an empty string.
+func (h *Header) AWSVPCEndpointID() string {
+	for _, tlv := range h.TLVs {
+		if tlv.Type == TLVTypeAWS && len(tlv.Value) > 0 && tlv.Value[0] == awsSubtypeVPCEndpointID {
+			return string(tlv.Value[1:])
+		}
+	}
+	return ""
+}
+
+// Write writes the header in a version of the protocol. The TLVs are only
+// written in version 2.
+func (h *Header) Write(w io.Writer, version int) error {
+	var buf []byte
+	switch version {
+	case 1:
+		buf = h.formatV1()
+	case 2:
+		buf = h.formatV2()
+	default:
+		return fmt.Errorf("unsupported PROXY protocol version %d", version)
+	}
+
+	_, err := w.Write(buf)
+	return err
+}
+
+func (h *Header) known() bool {
+	return h.Source != nil && h.Destination != nil &&
+		(h.Source.IP.To4() == nil) == (h.Destination.IP.To4() == nil)
+}
+
+func (h *Header) formatV1() []byte {
+	if !h.known() {
+		return []byte("PROXY UNKNOWN\r\n")
+	}
+
+	family := "TCP4"
+	if h.Source.IP.To4() == nil {
+		family = "TCP6"
+	}
+	return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n",
+		family, h.Source.IP, h.Destination.IP, h.Source.Port, h.Destination.Port))
+}
+
+func (h *Header) formatV2() []byte {
+	var addrs []byte
+	command, family := byte(v2CommandLocal), byte(v2FamilyUnspec)
+	if h.known() {
+		command = v2CommandProxy
+		if src := h.Source.IP.To4(); src != nil {
+			family = v2FamilyTCP4
+			addrs = append(addrs, src...)
+			addrs = append(addrs, h.Destination.IP.To4()...)
+		} else {
+			family = v2FamilyTCP6
+			addrs = append(addrs, h.Source.IP.To16()...)
+			addrs = append(addrs, h.Destination.IP.To16()...)
+		}
+		addrs = appendUint16(addrs, uint16(h.Source.Port))
+		addrs = appendUint16(addrs, uint16(h.Destination.Port))
+	}
+
+	for _, tlv := range h.TLVs {
+		addrs = append(addrs, tlv.Type)
+		addrs = appendUint16(addrs, uint16(len(tlv.Value)))
+		addrs = append(addrs, tlv.Value...)
+	}
+
+	buf := append([]byte{}, v2Signature...)
+	buf = append(buf, command, family)
+	buf = appendUint16(buf, uint16(len(addrs)))
+	return append(buf, addrs...)
+}
+
+func appendUint16(buf []byte, v uint16) []byte {
+	return append(buf, byte(v>>8), byte(v))
+}
+
+// ReadHeader reads a PROXY protocol header of either version. It returns
+// ErrNoProxyHeader, having consumed nothing, when the reader does not start
+// with one, or times out before it can tell.
+func ReadHeader(r *bufio.Reader) (*Header, error) {
+	version, err := detect(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if version == 1 {
+		return readV1(r)
+	}
+	return readV2(r)
+}
+
+// detect peeks at as few bytes as it takes to tell whether the reader starts
+// with a header, so protocols whose clients send little before the server
+// answers are not held up.
+func detect(r *bufio.Reader) (int, error) {
+	v1, v2 := true, true
+	for n := 1; v1 || v2; n++ {
+		b, err := r.Peek(n)
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return 0, ErrNoProxyHeader
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		v1 = v1 && n <= len(v1Prefix) && b[n-1] == v1Prefix[n-1]
+		v2 = v2 && n <= len(v2Signature) && b[n-1] == v2Signature[n-1]
+
+		if v1 && n == len(v1Prefix) {
+			return 1, nil
+		}
+		if v2 && n == len(v2Signature) {
+			return 2, nil
+		}
+	}
+	return 0, ErrNoProxyHeader
+}
+
+func readV1(r *bufio.Reader) (*Header, error) {
+	var line []byte
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= v1MaxLength {
+			return nil, ErrInvalidProxyHeader
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+	}
+
+	fields := strings.Split(strings.TrimSuffix(string(line), "\r\n"), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return &Header{Version: 1}, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, ErrInvalidProxyHeader
+	}
+
+	src, err := parseV1Addr(fields[2], fields[4])
+	if err != nil {
+		return nil, err
+	}
+	dst, err := parseV1Addr(fields[3], fields[5])
+	if err != nil {
+		return nil, err
+	}
+	return &Header{Version: 1, Source: src, Destination: dst}, nil
+}
+
+func parseV1Addr(ip, port string) (*net.TCPAddr, error) {
+	addr := &net.TCPAddr{IP: net.ParseIP(ip)}
+	if addr.IP == nil {
+		return nil, ErrInvalidProxyHeader
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, ErrInvalidProxyHeader
+	}
+	addr.Port = int(p)
+	return addr, nil
+}
+
+func readV2(r *bufio.Reader) (*Header, error) {
+	fixed := make([]byte, len(v2Signature)+4)
+	_, err := io.ReadFull(r, fixed)
+	if err != nil {
+		return nil, err
+	}
+
+	command, family := fixed[12], fixed[13]
+	rest := make([]byte, binary.BigEndian.Uint16(fixed[14:16]))
+	_, err = io.ReadFull(r, rest)
+	if err != nil {
+		return nil, err
+	}
+
+	if command != v2CommandLocal && command != v2CommandProxy {
+		return nil, ErrInvalidProxyHeader
+	}
+
+	header := &Header{Version: 2}
+
+	var size int
+	switch family >> 4 {
+	case 0x1:
+		size = 12
+	case 0x2:
+		size = 36
+	case 0x3:
+		size = 216
+	}
+	if len(rest) < size {
+		return nil, ErrInvalidProxyHeader
+	}
+
+	// the addresses of a LOCAL command, and of transports other than TCP, are
+	// skipped
+	if command == v2CommandProxy && (family == v2FamilyTCP4 || family == v2FamilyTCP6) {
+		ipLen := (size - 4) / 2
+		header.Source = &net.TCPAddr{
+			IP:   net.IP(append([]byte{}, rest[:ipLen]...)),
+			Port: int(binary.BigEndian.Uint16(rest[2*ipLen:])),
+		}
+		header.Destination = &net.TCPAddr{
+			IP:   net.IP(append([]byte{}, rest[ipLen:2*ipLen]...)),
+			Port: int(binary.BigEndian.Uint16(rest[2*ipLen+2:])),
+		}
+	}
+
+	header.TLVs, err = parseTLVs(rest[size:])
+	if err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+func parseTLVs(b []byte) ([]TLV, error) {
+	var tlvs []TLV
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return nil, ErrInvalidProxyHeader
+		}
+		length := int(binary.BigEndian.Uint16(b[1:3]))
+		if len(b) < 3+length {
+			return nil, ErrInvalidProxyHeader
+		}
+		tlvs = append(tlvs, TLV{Type: b[0], Value: append([]byte{}, b[3:3+length]...)})
+		b = b[3+length:]
+	}
+	return tlvs, nil
+}
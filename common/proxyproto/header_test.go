@@ -0,0 +1,141 @@
+package proxyproto_test
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/common/proxyproto"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Header", func() {
+	var header *proxyproto.Header
+
+	BeforeEach(func() {
+		header = &proxyproto.Header{
+			Source:      &net.TCPAddr{IP: net.ParseIP("192.168.0.1"), Port: 12345},
+			Destination: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 443},
+			TLVs: []proxyproto.TLV{
+				{Type: proxyproto.TLVTypeAWS, Value: append([]byte{0x01}, "vpce-0123456789"...)},
+			},
+		}
+	})
+
+	read := func(data []byte) (*proxyproto.Header, string, error) {
+		r := bufio.NewReader(bytes.NewReader(data))
+		h, err := proxyproto.ReadHeader(r)
+		rest, _ := ioutil.ReadAll(r)
+		return h, string(rest), err
+	}
+
+	Context("version 1", func() {
+		It("writes the addresses", func() {
+			buf := &bytes.Buffer{}
+			Expect(header.Write(buf, 1)).To(Succeed())
+			Expect(buf.String()).To(Equal("PROXY TCP4 192.168.0.1 10.0.0.1 12345 443\r\n"))
+		})
+
+		It("writes unknown addresses", func() {
+			buf := &bytes.Buffer{}
+			Expect((&proxyproto.Header{}).Write(buf, 1)).To(Succeed())
+			Expect(buf.String()).To(Equal("PROXY UNKNOWN\r\n"))
+		})
+
+		It("reads the addresses", func() {
+			h, rest, err := read([]byte("PROXY TCP6 2001:db8::1 2001:db8::2 12345 80\r\nGET / HTTP/1.1\r\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(h.Version).To(Equal(1))
+			Expect(h.Source.String()).To(Equal("[2001:db8::1]:12345"))
+			Expect(h.Destination.String()).To(Equal("[2001:db8::2]:80"))
+			Expect(rest).To(Equal("GET / HTTP/1.1\r\n"))
+		})
+
+		It("reads unknown addresses", func() {
+			h, _, err := read([]byte("PROXY UNKNOWN\r\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(h.Source).To(BeNil())
+		})
+
+		It("rejects malformed headers", func() {
+			_, _, err := read([]byte("PROXY TCP4 192.168.0.1 12345 443\r\n"))
+			Expect(err).To(Equal(proxyproto.ErrInvalidProxyHeader))
+
+			_, _, err = read([]byte("PROXY " + strings.Repeat("x", 120)))
+			Expect(err).To(Equal(proxyproto.ErrInvalidProxyHeader))
+		})
+	})
+
+	Context("version 2", func() {
+		It("reads the header it writes", func() {
+			buf := &bytes.Buffer{}
+			Expect(header.Write(buf, 2)).To(Succeed())
+			buf.WriteString("GET / HTTP/1.1\r\n")
+
+			h, rest, err := read(buf.Bytes())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(h.Version).To(Equal(2))
+			Expect(h.Source.String()).To(Equal("192.168.0.1:12345"))
+			Expect(h.Destination.String()).To(Equal("10.0.0.1:443"))
+			Expect(h.TLVs).To(Equal(header.TLVs))
+			Expect(rest).To(Equal("GET / HTTP/1.1\r\n"))
+		})
+
+		It("reads ipv6 addresses", func() {
+			header.Source = &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 12345}
+			header.Destination = &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 443}
+
+			buf := &bytes.Buffer{}
+			Expect(header.Write(buf, 2)).To(Succeed())
+
+			h, _, err := read(buf.Bytes())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(h.Source.String()).To(Equal("[2001:db8::1]:12345"))
+			Expect(h.Destination.String()).To(Equal("[2001:db8::2]:443"))
+		})
+
+		It("reads the header of a LOCAL command without addresses", func() {
+			buf := &bytes.Buffer{}
+			Expect((&proxyproto.Header{}).Write(buf, 2)).To(Succeed())
+
+			h, _, err := read(buf.Bytes())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(h.Source).To(BeNil())
+			Expect(h.Destination).To(BeNil())
+		})
+
+		It("rejects truncated TLVs", func() {
+			buf := &bytes.Buffer{}
+			Expect(header.Write(buf, 2)).To(Succeed())
+			data := buf.Bytes()
+			// claim a longer value for the last TLV than is sent
+			data[len(data)-len(header.TLVs[0].Value)-1]++
+
+			_, _, err := read(data)
+			Expect(err).To(Equal(proxyproto.ErrInvalidProxyHeader))
+		})
+	})
+
+	It("returns the AWS VPC endpoint id", func() {
+		Expect(header.AWSVPCEndpointID()).To(Equal("vpce-0123456789"))
+		Expect((&proxyproto.Header{}).AWSVPCEndpointID()).To(BeEmpty())
+	})
+
+	It("consumes nothing when there is no header", func() {
+		_, rest, err := read([]byte("PROXZ"))
+		Expect(err).To(Equal(proxyproto.ErrNoProxyHeader))
+		Expect(rest).To(Equal("PROXZ"))
+
+		_, rest, err = read([]byte("\r\n\r\nGET"))
+		Expect(err).To(Equal(proxyproto.ErrNoProxyHeader))
+		Expect(rest).To(Equal("\r\n\r\nGET"))
+	})
+
+	It("does not write unsupported versions", func() {
+		Expect(header.Write(&bytes.Buffer{}, 3)).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,191 @@
+package proxyproto
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+var ErrUntrustedProxyHeader = errors.New("PROXY protocol header from an untrusted source")
+
+// Listener reads the PROXY protocol header, of either version, that a load
+// balancer sends ahead of each connection. Only the headers of sources in the
+// trusted networks, or of any source when there are none, are used; the headers
+// of other sources are dropped, or refused in strict mode by closing the
+// connection. Connections without a header are accepted as they are.
+type Listener struct {
+	net.Listener
+
+	// ProxyHeaderTimeout bounds the time to wait for a header. A connection
+	// that sends nothing for that long is taken to have no header.
+	ProxyHeaderTimeout time.Duration
+	TrustedNets        []*net.IPNet
+	Strict             bool
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conn{
+		Conn:    conn,
+		reader:  bufio.NewReader(conn),
+		timeout: l.ProxyHeaderTimeout,
+		trusted: l.trusts(conn.RemoteAddr()),
+		strict:  l.Strict,
+	}, nil
+}
+
+func (l *Listener) trusts(addr net.Addr) bool {
+	if len(l.TrustedNets) == 0 {
+		return true
+	}
+
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, n := range l.TrustedNets {
+		if n.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn is a connection whose addresses are those of its PROXY protocol header.
+// The header is read on first use, so a slow client does not hold up Accept.
+type Conn struct {
+	net.Conn
+
+	reader  *bufio.Reader
+	timeout time.Duration
+	trusted bool
+	strict  bool
+
+	once   sync.Once
+	header *Header
+	err    error
+}
+
+func (c *Conn) readHeader() {
+	c.once.Do(func() {
+		if c.timeout > 0 {
+			c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+		}
+		header, err := ReadHeader(c.reader)
+		if c.timeout > 0 {
+			c.Conn.SetReadDeadline(time.Time{})
+		}
+
+		switch {
+		case err == ErrNoProxyHeader:
+		case err != nil:
+			c.err = err
+		case c.trusted:
+			c.header = header
+		case c.strict:
+			c.err = ErrUntrustedProxyHeader
+		}
+
+		if c.err != nil {
+			c.Conn.Close()
+		}
+	})
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(p)
+}
+
+// RemoteAddr returns the source address of the header, or that of the
+// connection when there is none.
+func (c *Conn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.header != nil && c.header.Source != nil {
+		return c.header.Source
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the destination address of the header, or that of the
+// connection when there is none.
+func (c *Conn) LocalAddr() net.Addr {
+	c.readHeader()
+	if c.header != nil && c.header.Destination != nil {
+		return c.header.Destination
+	}
+	return c.Conn.LocalAddr()
+}
+
+// Header returns the PROXY protocol header of the connection, or nil if it had
+// none or its source is not trusted.
+func (c *Conn) Header() *Header {
+	c.readHeader()
+	return c.header
+}
+
+func (c *Conn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface {
+		CloseWrite() error
+	}); ok {
+		return cw.CloseWrite()
+	}
+	return errors.New("connection cannot be closed for writing")
+}
+
+// HeaderOf returns the header to send an endpoint for a client connection: the
+// addresses of the connection, with the TLVs of its own header if it had one.
+func HeaderOf(conn net.Conn) *Header {
+	header := &Header{}
+	header.Source, _ = conn.RemoteAddr().(*net.TCPAddr)
+	header.Destination, _ = conn.LocalAddr().(*net.TCPAddr)
+	if c := unwrap(conn); c != nil && c.Header() != nil {
+		header.TLVs = c.Header().TLVs
+	}
+	return header
+}
+
+// unwrap returns the Conn under connections that wrap it, such as TLS
+// connections, or nil.
+func unwrap(conn net.Conn) *Conn {
+	for {
+		switch c := conn.(type) {
+		case *Conn:
+			return c
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return nil
+		}
+	}
+}
+
+type connKey struct{}
+
+// NewContext returns a context carrying a connection, whose PROXY protocol
+// header FromContext returns once it has been read.
+func NewContext(ctx context.Context, conn net.Conn) context.Context {
+	if c := unwrap(conn); c != nil {
+		return context.WithValue(ctx, connKey{}, c)
+	}
+	return ctx
+}
+
+// FromContext returns the PROXY protocol header of the connection carried by a
+// context, or nil.
+func FromContext(ctx context.Context) *Header {
+	if c, ok := ctx.Value(connKey{}).(*Conn); ok {
+		return c.Header()
+	}
+	return nil
+}
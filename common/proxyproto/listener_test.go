@@ -0,0 +1,194 @@
+package proxyproto_test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/common/proxyproto"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Listener", func() {
+	var (
+		listener *proxyproto.Listener
+		header   *proxyproto.Header
+	)
+
+	dial := func(data []byte) net.Conn {
+		client, err := net.Dial("tcp", listener.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		_, err = client.Write(data)
+		Expect(err).NotTo(HaveOccurred())
+
+		conn, err := listener.Accept()
+		Expect(err).NotTo(HaveOccurred())
+		return conn
+	}
+
+	withHeader := func(version int, data string) []byte {
+		buf := &bytes.Buffer{}
+		Expect(header.Write(buf, version)).To(Succeed())
+		buf.WriteString(data)
+		return buf.Bytes()
+	}
+
+	read := func(conn net.Conn, n int) (string, error) {
+		buf := make([]byte, n)
+		_, err := conn.Read(buf)
+		return string(buf), err
+	}
+
+	BeforeEach(func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		listener = &proxyproto.Listener{Listener: l, ProxyHeaderTimeout: 100 * time.Millisecond}
+
+		header = &proxyproto.Header{
+			Source:      &net.TCPAddr{IP: net.ParseIP("192.168.0.1"), Port: 12345},
+			Destination: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 443},
+			TLVs: []proxyproto.TLV{
+				{Type: proxyproto.TLVTypeAWS, Value: append([]byte{0x01}, "vpce-0123456789"...)},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		listener.Close()
+	})
+
+	It("uses the addresses of a version 1 header", func() {
+		conn := dial(withHeader(1, "hello"))
+		defer conn.Close()
+
+		Expect(read(conn, 5)).To(Equal("hello"))
+		Expect(conn.RemoteAddr().String()).To(Equal("192.168.0.1:12345"))
+		Expect(conn.LocalAddr().String()).To(Equal("10.0.0.1:443"))
+	})
+
+	It("uses the addresses and TLVs of a version 2 header", func() {
+		conn := dial(withHeader(2, "hello"))
+		defer conn.Close()
+
+		Expect(read(conn, 5)).To(Equal("hello"))
+		Expect(conn.RemoteAddr().String()).To(Equal("192.168.0.1:12345"))
+		Expect(conn.(*proxyproto.Conn).Header().AWSVPCEndpointID()).To(Equal("vpce-0123456789"))
+	})
+
+	It("accepts connections without a header", func() {
+		conn := dial([]byte("hello"))
+		defer conn.Close()
+
+		Expect(read(conn, 5)).To(Equal("hello"))
+		Expect(conn.RemoteAddr().(*net.TCPAddr).IP.String()).To(Equal("127.0.0.1"))
+		Expect(conn.(*proxyproto.Conn).Header()).To(BeNil())
+	})
+
+	It("accepts connections that send nothing before the timeout", func() {
+		conn := dial(nil)
+		defer conn.Close()
+
+		Expect(conn.RemoteAddr().(*net.TCPAddr).IP.String()).To(Equal("127.0.0.1"))
+	})
+
+	It("closes connections with an invalid header", func() {
+		conn := dial([]byte("PROXY TCP4 nonsense\r\n"))
+		defer conn.Close()
+
+		_, err := read(conn, 5)
+		Expect(err).To(Equal(proxyproto.ErrInvalidProxyHeader))
+	})
+
+	Context("with trusted networks", func() {
+		BeforeEach(func() {
+			_, trusted, err := net.ParseCIDR("10.0.0.0/8")
+			Expect(err).NotTo(HaveOccurred())
+			listener.TrustedNets = []*net.IPNet{trusted}
+		})
+
+		It("drops the headers of untrusted sources", func() {
+			conn := dial(withHeader(2, "hello"))
+			defer conn.Close()
+
+			Expect(read(conn, 5)).To(Equal("hello"))
+			Expect(conn.RemoteAddr().(*net.TCPAddr).IP.String()).To(Equal("127.0.0.1"))
+			Expect(conn.(*proxyproto.Conn).Header()).To(BeNil())
+		})
+
+		It("uses the headers of trusted sources", func() {
+			_, trusted, err := net.ParseCIDR("127.0.0.0/8")
+			Expect(err).NotTo(HaveOccurred())
+			listener.TrustedNets = append(listener.TrustedNets, trusted)
+
+			conn := dial(withHeader(2, "hello"))
+			defer conn.Close()
+
+			Expect(conn.RemoteAddr().String()).To(Equal("192.168.0.1:12345"))
+		})
+
+		Context("in strict mode", func() {
+			BeforeEach(func() {
+				listener.Strict = true
+			})
+
+			It("closes connections with headers from untrusted sources", func() {
+				conn := dial(withHeader(2, "hello"))
+				defer conn.Close()
+
+				_, err := read(conn, 5)
+				Expect(err).To(Equal(proxyproto.ErrUntrustedProxyHeader))
+			})
+
+			It("accepts connections without a header from untrusted sources", func() {
+				conn := dial([]byte("hello"))
+				defer conn.Close()
+
+				Expect(read(conn, 5)).To(Equal("hello"))
+			})
+		})
+	})
+
+	Describe("HeaderOf", func() {
+		It("returns the addresses of the connection with the TLVs of its header", func() {
+			conn := dial(withHeader(2, ""))
+			defer conn.Close()
+
+			h := proxyproto.HeaderOf(conn)
+			Expect(h.Source.String()).To(Equal("192.168.0.1:12345"))
+			Expect(h.Destination.String()).To(Equal("10.0.0.1:443"))
+			Expect(h.TLVs).To(Equal(header.TLVs))
+		})
+
+		It("returns the addresses of plain connections", func() {
+			conn := dial([]byte("hello"))
+			defer conn.Close()
+
+			h := proxyproto.HeaderOf(conn.(*proxyproto.Conn).Conn)
+			Expect(h.Source.String()).To(Equal(conn.RemoteAddr().String()))
+			Expect(h.TLVs).To(BeNil())
+		})
+	})
+
+	Describe("FromContext", func() {
+		It("returns the header of the connection of a context", func() {
+			conn := dial(withHeader(2, ""))
+			defer conn.Close()
+
+			ctx := proxyproto.NewContext(context.Background(), conn)
+			Expect(proxyproto.FromContext(ctx).AWSVPCEndpointID()).To(Equal("vpce-0123456789"))
+		})
+
+		It("returns nil for contexts without a connection", func() {
+			Expect(proxyproto.FromContext(context.Background())).To(BeNil())
+		})
+	})
+
+	It("closes the underlying listener", func() {
+		Expect(listener.Close()).To(Succeed())
+		_, err := listener.Accept()
+		Expect(err).To(HaveOccurred())
+	})
+})
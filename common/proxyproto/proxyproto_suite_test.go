@@ -0,0 +1,13 @@
+package proxyproto_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestProxyproto(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Proxyproto Suite")
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"crypto/tls"
 	"fmt"
+	"net"
 	"net/url"
 
 	"io/ioutil"
@@ -16,8 +17,43 @@ import (
 
 const LOAD_BALANCE_RR string = "round-robin"
 const LOAD_BALANCE_LC string = "least-connection"
-
-var LoadBalancingStrategies = []string{LOAD_BALANCE_RR, LOAD_BALANCE_LC}
+const LOAD_BALANCE_CH string = "consistent-hash"
+const LOAD_BALANCE_P2C string = "p2c-ewma"
+
+var LoadBalancingStrategies = []string{LOAD_BALANCE_RR, LOAD_BALANCE_LC, LOAD_BALANCE_CH, LOAD_BALANCE_P2C}
+
+const ENCODING_GZIP string = "gzip"
+const ENCODING_BROTLI string = "br"
+
+var CompressionEncodings = []string{ENCODING_GZIP, ENCODING_BROTLI}
+
+const FORWARDED_LEGACY string = "legacy"
+const FORWARDED_BOTH string = "both"
+const FORWARDED_RFC7239 string = "rfc7239"
+
+var ForwardedHeaderModes = []string{FORWARDED_LEGACY, FORWARDED_BOTH, FORWARDED_RFC7239}
+
+const TRUSTED_PROXY_HEADER_XFF string = "x-forwarded-for"
+const TRUSTED_PROXY_HEADER_FORWARDED string = "forwarded"
+
+var TrustedProxyHeaders = []string{TRUSTED_PROXY_HEADER_XFF, TRUSTED_PROXY_HEADER_FORWARDED}
+
+var ErrorPageTypes = []string{
+	"unknown_route",
+	"endpoint_failure",
+	"route_service_unsupported",
+	"route_service_failure",
+	"bad_signature",
+	"unsupported_protocol",
+	"ip_denied",
+	"dial_refused",
+	"dial_timeout",
+	"response_timeout",
+	"connection_reset",
+	"tls_handshake_failure",
+	"tls_handshake_timeout",
+	"no_endpoints",
+}
 
 type StatusConfig struct {
 	Host string `yaml:"host"`
@@ -81,6 +117,149 @@ type Tracing struct {
 	EnableZipkin bool `yaml:"enable_zipkin"`
 }
 
+type CompressionConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	Encodings    []string `yaml:"encodings"`
+	ContentTypes []string `yaml:"content_types"`
+	MinSize      int64    `yaml:"min_size"`
+}
+
+var defaultCompressionConfig = CompressionConfig{
+	Enabled:   false,
+	Encodings: []string{ENCODING_BROTLI, ENCODING_GZIP},
+	ContentTypes: []string{
+		"text/html",
+		"text/css",
+		"text/plain",
+		"text/xml",
+		"text/javascript",
+		"application/javascript",
+		"application/json",
+		"application/xml",
+		"image/svg+xml",
+	},
+	MinSize: 1024,
+}
+
+type CacheConfig struct {
+	Enabled      bool  `yaml:"enabled"`
+	MaxSize      int64 `yaml:"max_size"`
+	MaxEntrySize int64 `yaml:"max_entry_size"`
+}
+
+// WebSocketConfig limits the connections tunneled after a websocket upgrade. A
+// zero timeout is disabled.
+type WebSocketConfig struct {
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+	MaxLifetime time.Duration `yaml:"max_lifetime"`
+}
+
+// TCPListenerConfig routes the connections accepted on a port to the TCP routes
+// registered for the port in a router group.
+type TCPListenerConfig struct {
+	Port          uint16        `yaml:"port"`
+	RouterGroup   string        `yaml:"router_group"`
+	IdleTimeout   time.Duration `yaml:"idle_timeout"`
+	ProxyProtocol bool          `yaml:"proxy_protocol"`
+}
+
+// TLSPassthroughConfig lets routes that opt in terminate TLS themselves. The TLS
+// listener reads the server name of each connection and passes the connections
+// to those routes through unterminated. A zero timeout is disabled.
+type TLSPassthroughConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+}
+
+// ProxyProtocolConfig controls the PROXY protocol headers read by listeners
+// with enable_proxy, and those sent to endpoints. Headers are only used from the
+// trusted networks, or from anywhere when there are none; strict mode refuses
+// the connections of other sources that send one. Upgraded and TCP connections
+// send a header of the backend version to endpoints with send_to_backends.
+type ProxyProtocolConfig struct {
+	TrustedCIDRs   []string `yaml:"trusted_cidrs"`
+	Strict         bool     `yaml:"strict"`
+	SendToBackends bool     `yaml:"send_to_backends"`
+	BackendVersion int      `yaml:"backend_version"`
+
+	TrustedNets []*net.IPNet `yaml:"-"`
+}
+
+var defaultProxyProtocolConfig = ProxyProtocolConfig{
+	BackendVersion: 1,
+}
+
+// IPAccessConfig restricts the clients of all routes by IP, as resolved through
+// the trusted proxies. A client in the deny list is refused. When the allow list
+// is not empty, a client must also be in it. Routes can register lists of their
+// own, which apply as well.
+type IPAccessConfig struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+
+	AllowNets []*net.IPNet `yaml:"-"`
+	DenyNets  []*net.IPNet `yaml:"-"`
+}
+
+// StickySessionConfig controls the affinity cookie that keeps sessions on one
+// endpoint. By default the router sets it when the response of an endpoint sets
+// one of the session cookies, which routes can override. With router_issued the
+// router sets a signed affinity cookie on every response, which expires after
+// the TTL.
+type StickySessionConfig struct {
+	CookieNames  []string      `yaml:"cookie_names"`
+	RouterIssued bool          `yaml:"router_issued"`
+	Secret       string        `yaml:"secret"`
+	TTL          time.Duration `yaml:"ttl"`
+	SameSite     string        `yaml:"same_site"`
+	Domain       string        `yaml:"domain"`
+}
+
+var defaultStickySessionConfig = StickySessionConfig{
+	CookieNames: []string{"JSESSIONID"},
+	TTL:         24 * time.Hour,
+}
+
+var SameSiteModes = []string{"", "lax", "strict", "none"}
+
+// ZoneAwareConfig keeps requests on the endpoints in the zone of the router,
+// which endpoints advertise with the zone tag. Requests spill over to all
+// zones when less than the healthy percentage of the local endpoints are
+// available.
+type ZoneAwareConfig struct {
+	Enabled           bool `yaml:"enabled"`
+	HealthyPercentage int  `yaml:"healthy_percentage"`
+}
+
+var defaultZoneAwareConfig = ZoneAwareConfig{
+	HealthyPercentage: 70,
+}
+
+// SlowStartConfig ramps up the share of traffic of new endpoints over the
+// window, from the min weight percent of a full share. The aggression bends
+// the ramp: 1 is linear, and higher values ramp up faster at first.
+type SlowStartConfig struct {
+	Window           time.Duration `yaml:"window"`
+	Aggression       float64       `yaml:"aggression"`
+	MinWeightPercent int           `yaml:"min_weight_percent"`
+}
+
+var defaultSlowStartConfig = SlowStartConfig{
+	Aggression:       1,
+	MinWeightPercent: 10,
+}
+
+type ErrorPageConfig struct {
+	HTML string `yaml:"html"`
+	JSON string `yaml:"json"`
+}
+
+var defaultCacheConfig = CacheConfig{
+	Enabled:      false,
+	MaxSize:      64 * 1024 * 1024,
+	MaxEntrySize: 1024 * 1024,
+}
+
 var defaultLoggingConfig = LoggingConfig{
 	Level:         "debug",
 	MetronAddress: "localhost:3457",
@@ -121,10 +300,23 @@ type Config struct {
 	EndpointTimeout                 time.Duration `yaml:"endpoint_timeout"`
 	RouteServiceTimeout             time.Duration `yaml:"route_services_timeout"`
 
+	// Timeouts of the phases of a request to an endpoint. The response header
+	// and idle body timeouts default to the endpoint timeout when not set.
+	EndpointDialTimeout           time.Duration `yaml:"endpoint_dial_timeout"`
+	EndpointTLSHandshakeTimeout   time.Duration `yaml:"endpoint_tls_handshake_timeout"`
+	EndpointResponseHeaderTimeout time.Duration `yaml:"endpoint_response_header_timeout"`
+	EndpointIdleBodyTimeout       time.Duration `yaml:"endpoint_idle_body_timeout"`
+
 	DrainWait     time.Duration `yaml:"drain_wait,omitempty"`
 	DrainTimeout  time.Duration `yaml:"drain_timeout,omitempty"`
 	SecureCookies bool          `yaml:"secure_cookies"`
 
+	StickySessions StickySessionConfig `yaml:"sticky_sessions"`
+
+	// How long a drain waits for websocket and TCP connections after the
+	// requests have completed. The endpoint timeout is used when not set.
+	DrainHijackedTimeout time.Duration `yaml:"drain_hijacked_timeout,omitempty"`
+
 	HealthCheckUserAgent string `yaml:"healthcheck_user_agent,omitempty"`
 
 	OAuth                      OAuthConfig      `yaml:"oauth"`
@@ -143,12 +335,46 @@ type Config struct {
 	TokenFetcherRetryInterval                 time.Duration `yaml:"token_fetcher_retry_interval"`
 	TokenFetcherExpirationBufferTimeInSeconds int64         `yaml:"token_fetcher_expiration_buffer_time"`
 
-	PidFile     string `yaml:"pid_file"`
-	LoadBalance string `yaml:"balancing_algorithm"`
-
-	DisableKeepAlives   bool `yaml:"disable_keep_alives"`
-	MaxIdleConns        int  `yaml:"max_idle_conns"`
-	MaxIdleConnsPerHost int  `yaml:"max_idle_conns_per_host"`
+	PidFile     string          `yaml:"pid_file"`
+	LoadBalance string          `yaml:"balancing_algorithm"`
+	HashOn      string          `yaml:"hash_on"`
+	ZoneAware   ZoneAwareConfig `yaml:"zone_aware_routing"`
+	SlowStart   SlowStartConfig `yaml:"slow_start"`
+
+	DisableKeepAlives   bool          `yaml:"disable_keep_alives"`
+	MaxIdleConns        int           `yaml:"max_idle_conns"`
+	MaxIdleConnsPerHost int           `yaml:"max_idle_conns_per_host"`
+	IdleConnTimeout     time.Duration `yaml:"idle_conn_timeout"`
+
+	MirrorMaxBodySize int64         `yaml:"mirror_max_body_size"`
+	MirrorTimeout     time.Duration `yaml:"mirror_timeout"`
+	MirrorMaxInFlight int           `yaml:"mirror_max_in_flight"`
+
+	Compression CompressionConfig `yaml:"compression"`
+	Cache       CacheConfig       `yaml:"cache"`
+	WebSocket   WebSocketConfig   `yaml:"websocket"`
+
+	TCPListeners   []TCPListenerConfig  `yaml:"tcp_listeners"`
+	TLSPassthrough TLSPassthroughConfig `yaml:"tls_passthrough"`
+	ProxyProtocol  ProxyProtocolConfig  `yaml:"proxy_protocol"`
+
+	// The Forwarded and X-Forwarded headers are only taken from trusted
+	// proxies, whose addresses they are followed through to find the client.
+	// Without any, the headers of all clients are kept.
+	TrustedProxyCIDRs []string     `yaml:"trusted_proxy_cidrs"`
+	TrustedProxyNets  []*net.IPNet `yaml:"-"`
+	// The header the trusted proxies add the addresses they forward for to;
+	// the other is passed through from the client as is, so it is not followed.
+	TrustedProxyHeader string `yaml:"trusted_proxy_header"`
+
+	// The headers that tell endpoints about the client and the request as it
+	// was received: the X-Forwarded headers, the Forwarded header of RFC 7239
+	// or both.
+	ForwardedHeaders string `yaml:"forwarded_headers"`
+
+	IPAccess IPAccessConfig `yaml:"ip_access"`
+
+	ErrorPages map[string]ErrorPageConfig `yaml:"error_pages"`
 }
 
 var defaultConfig = Config{
@@ -163,9 +389,15 @@ var defaultConfig = Config{
 	EnableSSL:   false,
 	SSLPort:     443,
 
+	ProxyProtocol:  defaultProxyProtocolConfig,
+	StickySessions: defaultStickySessionConfig,
+
 	EndpointTimeout:     60 * time.Second,
 	RouteServiceTimeout: 60 * time.Second,
 
+	EndpointDialTimeout:         5 * time.Second,
+	EndpointTLSHandshakeTimeout: 10 * time.Second,
+
 	PublishStartMessageInterval:               30 * time.Second,
 	PruneStaleDropletsInterval:                30 * time.Second,
 	DropletStaleThreshold:                     120 * time.Second,
@@ -177,10 +409,23 @@ var defaultConfig = Config{
 
 	HealthCheckUserAgent: "HTTP-Monitor/1.1",
 	LoadBalance:          LOAD_BALANCE_RR,
+	HashOn:               "client_ip",
+	ZoneAware:            defaultZoneAwareConfig,
+	SlowStart:            defaultSlowStartConfig,
+	ForwardedHeaders:     FORWARDED_LEGACY,
+	TrustedProxyHeader:   TRUSTED_PROXY_HEADER_XFF,
 
 	DisableKeepAlives:   true,
 	MaxIdleConns:        100,
 	MaxIdleConnsPerHost: 2,
+	IdleConnTimeout:     90 * time.Second,
+
+	MirrorMaxBodySize: 64 * 1024,
+	MirrorTimeout:     10 * time.Second,
+	MirrorMaxInFlight: 100,
+
+	Compression: defaultCompressionConfig,
+	Cache:       defaultCacheConfig,
 }
 
 func DefaultConfig() *Config {
@@ -243,6 +488,135 @@ func (c *Config) Process() {
 		errMsg := fmt.Sprintf("Invalid load balancing algorithm %s. Allowed values are %s", c.LoadBalance, LoadBalancingStrategies)
 		panic(errMsg)
 	}
+
+	if c.ZoneAware.Enabled && c.Zone == "" {
+		panic("Zone aware routing requires a zone")
+	}
+	if c.ZoneAware.HealthyPercentage < 0 || c.ZoneAware.HealthyPercentage > 100 {
+		errMsg := fmt.Sprintf("Invalid zone healthy percentage %d. Allowed values are 0 to 100", c.ZoneAware.HealthyPercentage)
+		panic(errMsg)
+	}
+
+	if c.SlowStart.Window < 0 || c.SlowStart.Aggression <= 0 {
+		panic("Slow start requires a window of at least zero and an aggression above zero")
+	}
+	if c.SlowStart.MinWeightPercent < 1 || c.SlowStart.MinWeightPercent > 100 {
+		errMsg := fmt.Sprintf("Invalid slow start min weight percent %d. Allowed values are 1 to 100", c.SlowStart.MinWeightPercent)
+		panic(errMsg)
+	}
+
+	validForwarded := false
+	for _, mode := range ForwardedHeaderModes {
+		if c.ForwardedHeaders == mode {
+			validForwarded = true
+			break
+		}
+	}
+	if !validForwarded {
+		errMsg := fmt.Sprintf("Invalid forwarded headers %s. Allowed values are %s", c.ForwardedHeaders, ForwardedHeaderModes)
+		panic(errMsg)
+	}
+
+	if c.MirrorMaxInFlight < 1 {
+		errMsg := fmt.Sprintf("Invalid mirror max in flight %d. Allowed values are 1 and above", c.MirrorMaxInFlight)
+		panic(errMsg)
+	}
+
+	validTrustedProxyHeader := false
+	for _, header := range TrustedProxyHeaders {
+		if c.TrustedProxyHeader == header {
+			validTrustedProxyHeader = true
+			break
+		}
+	}
+	if !validTrustedProxyHeader {
+		errMsg := fmt.Sprintf("Invalid trusted proxy header %s. Allowed values are %s", c.TrustedProxyHeader, TrustedProxyHeaders)
+		panic(errMsg)
+	}
+
+	for _, encoding := range c.Compression.Encodings {
+		validEncoding := false
+		for _, e := range CompressionEncodings {
+			if encoding == e {
+				validEncoding = true
+				break
+			}
+		}
+		if !validEncoding {
+			errMsg := fmt.Sprintf("Invalid compression encoding %s. Allowed values are %s", encoding, CompressionEncodings)
+			panic(errMsg)
+		}
+	}
+
+	ports := map[uint16]bool{c.Port: true}
+	if c.EnableSSL {
+		ports[c.SSLPort] = true
+	}
+	for _, l := range c.TCPListeners {
+		if l.Port == 0 || l.RouterGroup == "" {
+			panic("TCP listeners must have a port and a router group")
+		}
+		if ports[l.Port] {
+			errMsg := fmt.Sprintf("Invalid TCP listener port %d. The port is already in use", l.Port)
+			panic(errMsg)
+		}
+		ports[l.Port] = true
+	}
+
+	c.ProxyProtocol.TrustedNets = parseCIDRs(c.ProxyProtocol.TrustedCIDRs, "PROXY protocol trusted")
+	c.TrustedProxyNets = parseCIDRs(c.TrustedProxyCIDRs, "trusted proxy")
+	c.IPAccess.AllowNets = parseCIDRs(c.IPAccess.Allow, "IP allow list")
+	c.IPAccess.DenyNets = parseCIDRs(c.IPAccess.Deny, "IP deny list")
+	if c.ProxyProtocol.BackendVersion != 1 && c.ProxyProtocol.BackendVersion != 2 {
+		errMsg := fmt.Sprintf("Invalid PROXY protocol backend version %d. Allowed values are 1 and 2", c.ProxyProtocol.BackendVersion)
+		panic(errMsg)
+	}
+
+	validSameSite := false
+	for _, mode := range SameSiteModes {
+		if c.StickySessions.SameSite == mode {
+			validSameSite = true
+			break
+		}
+	}
+	if !validSameSite {
+		errMsg := fmt.Sprintf("Invalid sticky session same site %s. Allowed values are %q", c.StickySessions.SameSite, SameSiteModes)
+		panic(errMsg)
+	}
+	if c.StickySessions.RouterIssued && (c.StickySessions.Secret == "" || c.StickySessions.TTL <= 0) {
+		panic("Router issued sticky sessions require a secret and a TTL")
+	}
+
+	if c.TLSPassthrough.Enabled && !c.EnableSSL {
+		panic("TLS passthrough requires enable_ssl")
+	}
+
+	for errorType := range c.ErrorPages {
+		validType := false
+		for _, t := range ErrorPageTypes {
+			if errorType == t {
+				validType = true
+				break
+			}
+		}
+		if !validType {
+			errMsg := fmt.Sprintf("Invalid error page type %s. Allowed values are %s", errorType, ErrorPageTypes)
+			panic(errMsg)
+		}
+	}
+}
+
+func parseCIDRs(cidrs []string, kind string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			errMsg := fmt.Sprintf("Invalid %s CIDR %s: %s", kind, cidr, err)
+			panic(errMsg)
+		}
+		nets = append(nets, n)
+	}
+	return nets
 }
 
 func (c *Config) processCipherSuites() []uint16 {
@@ -35,6 +35,30 @@ balancing_algorithm: least-connection
 				Expect(cfg.LoadBalance).To(Equal(LOAD_BALANCE_LC))
 			})
 
+			It("can balance by consistent hashing", func() {
+				cfg := DefaultConfig()
+				Expect(cfg.HashOn).To(Equal("client_ip"))
+
+				var b = []byte(`
+balancing_algorithm: consistent-hash
+hash_on: header:X-User-Id
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.LoadBalance).To(Equal(LOAD_BALANCE_CH))
+				Expect(cfg.HashOn).To(Equal("header:X-User-Id"))
+			})
+
+			It("can balance by latency", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+balancing_algorithm: p2c-ewma
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.LoadBalance).To(Equal(LOAD_BALANCE_P2C))
+			})
+
 			It("does not allow an invalid load balance strategy", func() {
 				cfg := DefaultConfig()
 				var b = []byte(`
@@ -45,6 +69,497 @@ balancing_algorithm: foo-bar
 			})
 		})
 
+		Context("zone aware routing", func() {
+			It("is disabled by default", func() {
+				cfg := DefaultConfig()
+				Expect(cfg.ZoneAware.Enabled).To(BeFalse())
+				Expect(cfg.ZoneAware.HealthyPercentage).To(Equal(70))
+			})
+
+			It("sets the zone aware routing config", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+zone: z1
+zone_aware_routing:
+  enabled: true
+  healthy_percentage: 50
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.ZoneAware).To(Equal(ZoneAwareConfig{Enabled: true, HealthyPercentage: 50}))
+			})
+
+			It("requires a zone", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+zone_aware_routing:
+  enabled: true
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("does not allow an invalid healthy percentage", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+zone: z1
+zone_aware_routing:
+  enabled: true
+  healthy_percentage: 150
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+		})
+
+		Context("slow start", func() {
+			It("is disabled by default", func() {
+				cfg := DefaultConfig()
+				Expect(cfg.SlowStart).To(Equal(SlowStartConfig{Aggression: 1, MinWeightPercent: 10}))
+			})
+
+			It("sets the slow start config", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+slow_start:
+  window: 2m
+  aggression: 2
+  min_weight_percent: 5
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.SlowStart).To(Equal(SlowStartConfig{Window: 2 * time.Minute, Aggression: 2, MinWeightPercent: 5}))
+			})
+
+			It("does not allow an aggression of zero", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+slow_start:
+  window: 2m
+  aggression: 0
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("does not allow an invalid min weight percent", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+slow_start:
+  window: 2m
+  min_weight_percent: 0
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+		})
+
+		Context("sticky sessions", func() {
+			It("uses JSESSIONID by default", func() {
+				cfg := DefaultConfig()
+				Expect(cfg.StickySessions.CookieNames).To(Equal([]string{"JSESSIONID"}))
+				Expect(cfg.StickySessions.RouterIssued).To(BeFalse())
+			})
+
+			It("sets the sticky session config", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+sticky_sessions:
+  cookie_names: [PHPSESSID, connect.sid]
+  router_issued: true
+  secret: shhh
+  ttl: 1h
+  same_site: lax
+  domain: example.com
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.StickySessions).To(Equal(StickySessionConfig{
+					CookieNames:  []string{"PHPSESSID", "connect.sid"},
+					RouterIssued: true,
+					Secret:       "shhh",
+					TTL:          time.Hour,
+					SameSite:     "lax",
+					Domain:       "example.com",
+				}))
+			})
+
+			It("does not allow an invalid same site mode", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+sticky_sessions:
+  same_site: sometimes
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("requires a secret for router issued affinity cookies", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+sticky_sessions:
+  router_issued: true
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+		})
+
+		Context("forwarded headers", func() {
+			It("sends the X-Forwarded headers by default", func() {
+				cfg := DefaultConfig()
+				Expect(cfg.ForwardedHeaders).To(Equal(FORWARDED_LEGACY))
+			})
+
+			It("can send the Forwarded header", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+forwarded_headers: rfc7239
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.ForwardedHeaders).To(Equal(FORWARDED_RFC7239))
+			})
+
+			It("does not allow an invalid mode", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+forwarded_headers: rfc1234
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+		})
+
+		Context("compression", func() {
+			It("is disabled by default", func() {
+				cfg := DefaultConfig()
+				Expect(cfg.Compression.Enabled).To(BeFalse())
+				Expect(cfg.Compression.Encodings).To(Equal([]string{ENCODING_BROTLI, ENCODING_GZIP}))
+				Expect(cfg.Compression.ContentTypes).To(ContainElement("text/html"))
+				Expect(cfg.Compression.MinSize).To(Equal(int64(1024)))
+			})
+
+			It("sets compression config", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+compression:
+  enabled: true
+  encodings: [gzip]
+  content_types: [application/json]
+  min_size: 512
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.Compression.Enabled).To(BeTrue())
+				Expect(cfg.Compression.Encodings).To(Equal([]string{ENCODING_GZIP}))
+				Expect(cfg.Compression.ContentTypes).To(Equal([]string{"application/json"}))
+				Expect(cfg.Compression.MinSize).To(Equal(int64(512)))
+			})
+
+			It("does not allow an invalid encoding", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+compression:
+  encodings: [deflate]
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+		})
+
+		Context("cache", func() {
+			It("is disabled by default", func() {
+				cfg := DefaultConfig()
+				Expect(cfg.Cache.Enabled).To(BeFalse())
+				Expect(cfg.Cache.MaxSize).To(Equal(int64(64 * 1024 * 1024)))
+				Expect(cfg.Cache.MaxEntrySize).To(Equal(int64(1024 * 1024)))
+			})
+
+			It("sets cache config", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+cache:
+  enabled: true
+  max_size: 1048576
+  max_entry_size: 4096
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.Cache.Enabled).To(BeTrue())
+				Expect(cfg.Cache.MaxSize).To(Equal(int64(1048576)))
+				Expect(cfg.Cache.MaxEntrySize).To(Equal(int64(4096)))
+			})
+		})
+
+		Context("websocket", func() {
+			It("does not limit websocket connections by default", func() {
+				cfg := DefaultConfig()
+				Expect(cfg.WebSocket.IdleTimeout).To(BeZero())
+				Expect(cfg.WebSocket.MaxLifetime).To(BeZero())
+			})
+
+			It("sets websocket config", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+websocket:
+  idle_timeout: 5m
+  max_lifetime: 24h
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.WebSocket.IdleTimeout).To(Equal(5 * time.Minute))
+				Expect(cfg.WebSocket.MaxLifetime).To(Equal(24 * time.Hour))
+			})
+		})
+
+		Context("tcp listeners", func() {
+			It("has no tcp listeners by default", func() {
+				cfg := DefaultConfig()
+				Expect(cfg.TCPListeners).To(BeEmpty())
+			})
+
+			It("sets tcp listener config", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+tcp_listeners:
+- port: 1024
+  router_group: default-tcp
+  idle_timeout: 5m
+  proxy_protocol: true
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.TCPListeners).To(Equal([]TCPListenerConfig{{
+					Port:          1024,
+					RouterGroup:   "default-tcp",
+					IdleTimeout:   5 * time.Minute,
+					ProxyProtocol: true,
+				}}))
+			})
+
+			It("does not allow a tcp listener without a router group", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+tcp_listeners:
+- port: 1024
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("does not allow a tcp listener on a port in use", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+port: 8080
+tcp_listeners:
+- port: 8080
+  router_group: default-tcp
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+		})
+
+		Context("tls passthrough", func() {
+			It("disables tls passthrough by default", func() {
+				cfg := DefaultConfig()
+				Expect(cfg.TLSPassthrough).To(Equal(TLSPassthroughConfig{}))
+			})
+
+			It("sets tls passthrough config", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+enable_ssl: true
+ssl_cert_path: ../test/assets/certs/server.pem
+ssl_key_path: ../test/assets/certs/server.key
+cipher_suites: TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+tls_passthrough:
+  enabled: true
+  idle_timeout: 5m
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.TLSPassthrough).To(Equal(TLSPassthroughConfig{
+					Enabled:     true,
+					IdleTimeout: 5 * time.Minute,
+				}))
+			})
+
+			It("does not allow tls passthrough without ssl", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+tls_passthrough:
+  enabled: true
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+		})
+
+		Context("proxy protocol", func() {
+			It("defaults the proxy protocol config", func() {
+				cfg := DefaultConfig()
+				Expect(cfg.ProxyProtocol.TrustedNets).To(BeEmpty())
+				Expect(cfg.ProxyProtocol.Strict).To(BeFalse())
+				Expect(cfg.ProxyProtocol.SendToBackends).To(BeFalse())
+				Expect(cfg.ProxyProtocol.BackendVersion).To(Equal(1))
+			})
+
+			It("sets proxy protocol config", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+proxy_protocol:
+  trusted_cidrs:
+  - 10.0.0.0/8
+  - 2001:db8::/32
+  strict: true
+  send_to_backends: true
+  backend_version: 2
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.ProxyProtocol.Strict).To(BeTrue())
+				Expect(cfg.ProxyProtocol.SendToBackends).To(BeTrue())
+				Expect(cfg.ProxyProtocol.BackendVersion).To(Equal(2))
+				Expect(cfg.ProxyProtocol.TrustedNets).To(HaveLen(2))
+				Expect(cfg.ProxyProtocol.TrustedNets[0].String()).To(Equal("10.0.0.0/8"))
+				Expect(cfg.ProxyProtocol.TrustedNets[1].String()).To(Equal("2001:db8::/32"))
+			})
+
+			It("does not allow an invalid trusted cidr", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+proxy_protocol:
+  trusted_cidrs:
+  - 10.0.0.1
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("does not allow an invalid backend version", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+proxy_protocol:
+  backend_version: 3
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+		})
+
+		Context("trusted proxies", func() {
+			It("sets the trusted proxy networks", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+trusted_proxy_cidrs:
+- 10.0.0.0/8
+- 192.168.1.1/32
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.TrustedProxyNets).To(HaveLen(2))
+				Expect(cfg.TrustedProxyNets[0].String()).To(Equal("10.0.0.0/8"))
+				Expect(cfg.TrustedProxyNets[1].String()).To(Equal("192.168.1.1/32"))
+			})
+
+			It("does not allow an invalid trusted proxy cidr", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+trusted_proxy_cidrs:
+- not-a-cidr
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+
+			It("follows X-Forwarded-For by default", func() {
+				cfg := DefaultConfig()
+				cfg.Initialize([]byte{})
+				cfg.Process()
+				Expect(cfg.TrustedProxyHeader).To(Equal(TRUSTED_PROXY_HEADER_XFF))
+			})
+
+			It("sets the header to follow", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+trusted_proxy_header: forwarded
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.TrustedProxyHeader).To(Equal(TRUSTED_PROXY_HEADER_FORWARDED))
+			})
+
+			It("does not allow an invalid header to follow", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+trusted_proxy_header: x-real-ip
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+		})
+
+		Context("ip access", func() {
+			It("sets the ip access lists", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+ip_access:
+  allow:
+  - 10.0.0.0/8
+  deny:
+  - 10.1.0.0/16
+  - 10.2.0.0/16
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.IPAccess.AllowNets).To(HaveLen(1))
+				Expect(cfg.IPAccess.AllowNets[0].String()).To(Equal("10.0.0.0/8"))
+				Expect(cfg.IPAccess.DenyNets).To(HaveLen(2))
+				Expect(cfg.IPAccess.DenyNets[1].String()).To(Equal("10.2.0.0/16"))
+			})
+
+			It("does not allow an invalid cidr", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+ip_access:
+  deny:
+  - 10.1.0.0/99
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+		})
+
+		Context("error pages", func() {
+			It("sets error page config", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+error_pages:
+  unknown_route:
+    html: /var/vcap/jobs/gorouter/config/unknown_route.html
+    json: /var/vcap/jobs/gorouter/config/unknown_route.json
+`)
+				cfg.Initialize(b)
+				cfg.Process()
+				Expect(cfg.ErrorPages).To(HaveLen(1))
+				Expect(cfg.ErrorPages["unknown_route"].HTML).To(Equal("/var/vcap/jobs/gorouter/config/unknown_route.html"))
+				Expect(cfg.ErrorPages["unknown_route"].JSON).To(Equal("/var/vcap/jobs/gorouter/config/unknown_route.json"))
+			})
+
+			It("does not allow an invalid error type", func() {
+				cfg := DefaultConfig()
+				var b = []byte(`
+error_pages:
+  teapot:
+    html: /var/vcap/jobs/gorouter/config/teapot.html
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process).To(Panic())
+			})
+		})
+
 		It("sets status config", func() {
 			var b = []byte(`
 status:
@@ -408,6 +923,14 @@ enable_proxy: true
 			Expect(config.MaxIdleConnsPerHost).To(Equal(2))
 		})
 
+		It("defaults IdleConnTimeout to 90 seconds", func() {
+			var b = []byte("")
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.IdleConnTimeout).To(Equal(90 * time.Second))
+		})
+
 		It("sets DisableKeepAlives", func() {
 			var b = []byte("disable_keep_alives: false")
 			err := config.Initialize(b)
@@ -424,6 +947,14 @@ enable_proxy: true
 			Expect(config.MaxIdleConns).To(Equal(200))
 		})
 
+		It("sets IdleConnTimeout", func() {
+			var b = []byte("idle_conn_timeout: 30s")
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.IdleConnTimeout).To(Equal(30 * time.Second))
+		})
+
 		It("sets MaxIdleConnsPerHost", func() {
 			var b = []byte("max_idle_conns_per_host: 10")
 			err := config.Initialize(b)
@@ -431,6 +962,40 @@ enable_proxy: true
 
 			Expect(config.MaxIdleConnsPerHost).To(Equal(10))
 		})
+
+		It("defaults the mirror limits", func() {
+			var b = []byte("")
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.MirrorMaxBodySize).To(Equal(int64(64 * 1024)))
+			Expect(config.MirrorTimeout).To(Equal(10 * time.Second))
+			Expect(config.MirrorMaxInFlight).To(Equal(100))
+		})
+
+		It("sets the mirror limits", func() {
+			var b = []byte(`
+mirror_max_body_size: 1024
+mirror_timeout: 2s
+mirror_max_in_flight: 5
+`)
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.MirrorMaxBodySize).To(Equal(int64(1024)))
+			Expect(config.MirrorTimeout).To(Equal(2 * time.Second))
+			Expect(config.MirrorMaxInFlight).To(Equal(5))
+		})
+
+		It("does not allow no mirrors in flight", func() {
+			var b = []byte(`
+mirror_max_in_flight: 0
+`)
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.Process).To(Panic())
+		})
 	})
 
 	Describe("Process", func() {
@@ -765,6 +1330,73 @@ endpoint_timeout: 10s
 				Expect(config.EndpointTimeout).To(Equal(10 * time.Second))
 				Expect(config.DrainTimeout).To(Equal(10 * time.Second))
 			})
+
+			It("sets the drain timeout of hijacked connections", func() {
+				var b = []byte(`
+drain_timeout: 15s
+drain_hijacked_timeout: 5m
+`)
+
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				config.Process()
+
+				Expect(config.DrainHijackedTimeout).To(Equal(5 * time.Minute))
+			})
+
+			It("keeps a drain timeout of hijacked connections equal to the default endpoint timeout", func() {
+				var b = []byte(`
+drain_timeout: 15s
+drain_hijacked_timeout: 60s
+`)
+
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				config.Process()
+
+				Expect(config.DrainHijackedTimeout).To(Equal(60 * time.Second))
+			})
+
+			It("leaves the drain timeout of hijacked connections unset", func() {
+				var b = []byte(`
+drain_timeout: 15s
+`)
+
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				config.Process()
+
+				Expect(config.DrainHijackedTimeout).To(BeZero())
+			})
+
+			It("sets the timeouts of the phases of endpoint requests", func() {
+				var b = []byte(`
+endpoint_dial_timeout: 1s
+endpoint_tls_handshake_timeout: 2s
+endpoint_response_header_timeout: 3s
+endpoint_idle_body_timeout: 4s
+`)
+
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				config.Process()
+
+				Expect(config.EndpointDialTimeout).To(Equal(1 * time.Second))
+				Expect(config.EndpointTLSHandshakeTimeout).To(Equal(2 * time.Second))
+				Expect(config.EndpointResponseHeaderTimeout).To(Equal(3 * time.Second))
+				Expect(config.EndpointIdleBodyTimeout).To(Equal(4 * time.Second))
+			})
+
+			It("defaults the dial and TLS handshake timeouts", func() {
+				Expect(config.EndpointDialTimeout).To(Equal(5 * time.Second))
+				Expect(config.EndpointTLSHandshakeTimeout).To(Equal(10 * time.Second))
+				Expect(config.EndpointResponseHeaderTimeout).To(BeZero())
+				Expect(config.EndpointIdleBodyTimeout).To(BeZero())
+			})
 		})
 	})
 })
@@ -40,8 +40,9 @@ func (a *accessLog) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http
 
 	next(rw, r)
 
-	alr.RequestBytesReceived = requestBodyCounter.GetCount()
-	alr.BodyBytesSent = proxyWriter.Size()
+	// upgraded connections record the bytes they tunnel themselves
+	alr.RequestBytesReceived += requestBodyCounter.GetCount()
+	alr.BodyBytesSent += proxyWriter.Size()
 	alr.FinishedAt = time.Now()
 	a.accessLogger.Log(*alr)
 }
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/urfave/negroni"
+
+	"code.cloudfoundry.org/gorouter/access_log/schema"
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+)
+
+type clientIPKey struct{}
+
+// ClientIP returns the IP of the client that sent a request, as resolved by the
+// client IP handler, or nil if it has not been.
+func ClientIP(r *http.Request) net.IP {
+	ip, _ := r.Context().Value(clientIPKey{}).(net.IP)
+	return ip
+}
+
+type clientIP struct {
+	trustedNets []*net.IPNet
+	header      string
+}
+
+// NewClientIP creates a handler that resolves the IP of the client of each
+// request. The address a request comes from, which is that of the PROXY
+// protocol header when there is one, is the client unless it is a trusted
+// proxy. The header the trusted proxies add to, Forwarded or X-Forwarded-For,
+// is then followed back past them to the first address that is not one; the
+// other header may have been sent by the client, so it is removed. Without
+// trusted proxies the forwarding headers of all requests are kept, otherwise
+// those of other clients are removed so they cannot be spoofed.
+func NewClientIP(trustedNets []*net.IPNet, header string) negroni.Handler {
+	return &clientIP{trustedNets: trustedNets, header: http.CanonicalHeaderKey(header)}
+}
+
+func (c *clientIP) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	ip := c.resolve(r)
+
+	if proxyWriter, ok := rw.(utils.ProxyResponseWriter); ok && ip != nil {
+		if alr, ok := proxyWriter.Context().Value("AccessLogRecord").(*schema.AccessLogRecord); ok {
+			alr.ClientIP = ip.String()
+		}
+	}
+
+	next(rw, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
+}
+
+func (c *clientIP) resolve(r *http.Request) net.IP {
+	ip := parseIP(r.RemoteAddr)
+	if len(c.trustedNets) == 0 {
+		return ip
+	}
+
+	if ip == nil || !c.trusts(ip) {
+		r.Header.Del(router_http.ForwardedHeader)
+		delXForwarded(r)
+		return ip
+	}
+
+	// the router adds to the header that is not followed as well, which would
+	// pass it on as if the trusted proxies had
+	if c.header == router_http.ForwardedHeader {
+		delXForwarded(r)
+	} else {
+		r.Header.Del(router_http.ForwardedHeader)
+	}
+
+	forwarded := forwardedFor(r, c.header)
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := forwarded[i]
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !c.trusts(ip) {
+			break
+		}
+	}
+	return ip
+}
+
+func (c *clientIP) trusts(ip net.IP) bool {
+	for _, n := range c.trustedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns the addresses a request has been forwarded for by each
+// proxy, from the Forwarded or the X-Forwarded-For header. Addresses that
+// cannot be parsed are nil.
+func forwardedFor(r *http.Request, header string) []net.IP {
+	var ips []net.IP
+	if header == router_http.ForwardedHeader {
+		for _, e := range router_http.ParseForwarded(r.Header[header]) {
+			ips = append(ips, router_http.ForwardedNodeIP(e.For))
+		}
+		return ips
+	}
+
+	for _, hop := range strings.Split(strings.Join(r.Header[header], ","), ",") {
+		ips = append(ips, parseIP(strings.TrimSpace(hop)))
+	}
+	return ips
+}
+
+func delXForwarded(r *http.Request) {
+	r.Header.Del("X-Forwarded-For")
+	r.Header.Del("X-Forwarded-Proto")
+	r.Header.Del("X-Forwarded-Host")
+	r.Header.Del("X-Forwarded-Port")
+}
+
+// parseIP parses an IP with or without a port.
+func parseIP(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(addr)
+}
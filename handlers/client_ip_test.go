@@ -0,0 +1,172 @@
+package handlers_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/access_log/schema"
+	"code.cloudfoundry.org/gorouter/handlers"
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+	"code.cloudfoundry.org/gorouter/test_util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("ClientIP", func() {
+	var (
+		handler     negroni.Handler
+		trustedNets []*net.IPNet
+		header      string
+		proxyWriter utils.ProxyResponseWriter
+		req         *http.Request
+		nextReq     *http.Request
+		alr         *schema.AccessLogRecord
+	)
+
+	nextHandler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		nextReq = r
+	})
+
+	trust := func(cidr string) {
+		_, n, err := net.ParseCIDR(cidr)
+		Expect(err).ToNot(HaveOccurred())
+		trustedNets = append(trustedNets, n)
+	}
+
+	serve := func() string {
+		handler = handlers.NewClientIP(trustedNets, header)
+		handler.ServeHTTP(proxyWriter, req, nextHandler)
+		Expect(nextReq).ToNot(BeNil())
+		return handlers.ClientIP(nextReq).String()
+	}
+
+	BeforeEach(func() {
+		trustedNets = nil
+		header = "x-forwarded-for"
+		nextReq = nil
+		req = test_util.NewRequest("GET", "example.com", "/", nil)
+		req.RemoteAddr = "10.0.0.1:12345"
+		req.Header.Set("X-Forwarded-For", "1.1.1.1, 2.2.2.2")
+		req.Header.Set("X-Forwarded-Proto", "https")
+		proxyWriter = utils.NewProxyResponseWriter(httptest.NewRecorder())
+		alr = &schema.AccessLogRecord{
+			Request: req,
+		}
+		proxyWriter.AddToContext("AccessLogRecord", alr)
+	})
+
+	It("returns nil for requests it has not handled", func() {
+		Expect(handlers.ClientIP(req)).To(BeNil())
+	})
+
+	Context("without trusted proxies", func() {
+		It("resolves the address the request comes from and keeps the forwarding headers", func() {
+			Expect(serve()).To(Equal("10.0.0.1"))
+			Expect(req.Header.Get("X-Forwarded-For")).To(Equal("1.1.1.1, 2.2.2.2"))
+			Expect(req.Header.Get("X-Forwarded-Proto")).To(Equal("https"))
+		})
+
+		It("sets the client IP of the access log record", func() {
+			serve()
+			Expect(alr.ClientIP).To(Equal("10.0.0.1"))
+		})
+	})
+
+	Context("with trusted proxies", func() {
+		BeforeEach(func() {
+			trust("10.0.0.0/8")
+		})
+
+		It("resolves the last address forwarded by the trusted proxy", func() {
+			Expect(serve()).To(Equal("2.2.2.2"))
+			Expect(req.Header.Get("X-Forwarded-For")).To(Equal("1.1.1.1, 2.2.2.2"))
+			Expect(req.Header.Get("X-Forwarded-Proto")).To(Equal("https"))
+		})
+
+		It("follows the addresses forwarded through trusted proxies", func() {
+			req.Header.Set("X-Forwarded-For", "1.1.1.1, 2.2.2.2, 10.1.1.1")
+			req.Header.Add("X-Forwarded-For", "10.2.2.2")
+			Expect(serve()).To(Equal("2.2.2.2"))
+		})
+
+		It("resolves the first address when all are trusted proxies", func() {
+			req.Header.Set("X-Forwarded-For", "10.2.2.2, 10.1.1.1")
+			Expect(serve()).To(Equal("10.2.2.2"))
+		})
+
+		It("stops at an address that cannot be parsed", func() {
+			req.Header.Set("X-Forwarded-For", "1.1.1.1, unknown, 10.1.1.1")
+			Expect(serve()).To(Equal("10.1.1.1"))
+		})
+
+		It("does not follow a Forwarded header sent by the client", func() {
+			req.Header.Set("Forwarded", "for=1.2.3.4")
+			Expect(serve()).To(Equal("2.2.2.2"))
+			Expect(alr.ClientIP).To(Equal("2.2.2.2"))
+		})
+
+		It("removes a Forwarded header sent by the client", func() {
+			req.Header.Set("Forwarded", "for=10.0.0.1")
+			serve()
+			Expect(req.Header["Forwarded"]).To(BeEmpty())
+			Expect(req.Header.Get("X-Forwarded-For")).To(Equal("1.1.1.1, 2.2.2.2"))
+		})
+
+		Context("when the trusted proxies add to the Forwarded header", func() {
+			BeforeEach(func() {
+				header = "forwarded"
+			})
+
+			It("follows the Forwarded header in place of X-Forwarded-For", func() {
+				req.Header.Set("Forwarded", `for=3.3.3.3, for="[2001:db8::1]:4711";proto=https`)
+				req.Header.Add("Forwarded", "for=10.1.1.1")
+				Expect(serve()).To(Equal("2001:db8::1"))
+			})
+
+			It("stops at an unknown node of the Forwarded header", func() {
+				req.Header.Set("Forwarded", "for=3.3.3.3, for=unknown, for=10.1.1.1")
+				Expect(serve()).To(Equal("10.1.1.1"))
+			})
+
+			It("does not follow an X-Forwarded-For header sent by the client", func() {
+				req.Header.Set("X-Forwarded-For", "1.2.3.4")
+				req.Header.Set("Forwarded", "for=3.3.3.3")
+				Expect(serve()).To(Equal("3.3.3.3"))
+			})
+
+			It("removes the X-Forwarded headers sent by the client", func() {
+				req.Header.Set("Forwarded", "for=3.3.3.3")
+				serve()
+				Expect(req.Header["X-Forwarded-For"]).To(BeEmpty())
+				Expect(req.Header["X-Forwarded-Proto"]).To(BeEmpty())
+				Expect(req.Header.Get("Forwarded")).To(Equal("for=3.3.3.3"))
+			})
+		})
+
+		It("resolves the address of the trusted proxy without X-Forwarded-For", func() {
+			req.Header.Del("X-Forwarded-For")
+			Expect(serve()).To(Equal("10.0.0.1"))
+		})
+
+		Context("when the request does not come from a trusted proxy", func() {
+			BeforeEach(func() {
+				req.RemoteAddr = "3.3.3.3:12345"
+				req.Header.Set("Forwarded", "for=1.1.1.1")
+				req.Header.Set("X-Forwarded-Host", "example.com")
+				req.Header.Set("X-Forwarded-Port", "443")
+			})
+
+			It("resolves the address the request comes from and removes the forwarding headers", func() {
+				Expect(serve()).To(Equal("3.3.3.3"))
+				Expect(req.Header["Forwarded"]).To(BeEmpty())
+				Expect(req.Header["X-Forwarded-For"]).To(BeEmpty())
+				Expect(req.Header["X-Forwarded-Proto"]).To(BeEmpty())
+				Expect(req.Header["X-Forwarded-Host"]).To(BeEmpty())
+				Expect(req.Header["X-Forwarded-Port"]).To(BeEmpty())
+				Expect(alr.ClientIP).To(Equal("3.3.3.3"))
+			})
+		})
+	})
+})
@@ -32,6 +32,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"runtime"
 	"syscall"
 	"time"
@@ -90,7 +91,7 @@ func main() {
 		registry.SuspendPruning(func() bool { return !(natsClient.Status() == nats.CONNECTED) })
 	}
 
-	subscriber := createSubscriber(logger, c, natsClient, registry, startMsgChan)
+	subscriber := createSubscriber(logger, c, natsClient, registry, metricsReporter, startMsgChan)
 
 	varz := rvarz.NewVarz(registry)
 	compositeReporter := metrics.NewCompositeReporter(varz, metricsReporter)
@@ -110,6 +111,10 @@ func main() {
 	}
 
 	proxy := buildProxy(logger.Session("proxy"), c, registry, accessLogger, compositeReporter, crypto, cryptoPrev)
+	if configFile != "" {
+		go reloadIPAccess(logger.Session("ip-access"), proxy)
+	}
+
 	healthCheck = 0
 	router, err := router.NewRouter(logger.Session("router"), c, proxy, natsClient, registry, varz, &healthCheck, logCounter, nil)
 	if err != nil {
@@ -145,6 +150,33 @@ func main() {
 	os.Exit(0)
 }
 
+// reloadIPAccess reloads the IP allow and deny lists from the config file each
+// time the process receives SIGHUP.
+func reloadIPAccess(logger lager.Logger, p proxy.Proxy) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+
+	for range hangups {
+		c, err := loadConfig(configFile)
+		if err != nil {
+			logger.Error("reload-failed", err)
+			continue
+		}
+		p.SetIPAccess(c.IPAccess)
+		logger.Info("reloaded", lager.Data{"allow": c.IPAccess.Allow, "deny": c.IPAccess.Deny})
+	}
+}
+
+// loadConfig loads a config file, returning the panics of invalid ones as errors.
+func loadConfig(path string) (c *config.Config, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return config.InitConfigFromFile(path), nil
+}
+
 func createCrypto(logger lager.Logger, secret string) *secure.AesGCM {
 	// generate secure encryption key using key derivation function (pbkdf2)
 	secretPbkdf2 := secure.NewPbkdf2([]byte(secret), 16)
@@ -297,6 +329,7 @@ func createSubscriber(
 	c *config.Config,
 	natsClient *nats.Conn,
 	registry rregistry.RegistryInterface,
+	reporter reporter.RouteRegistryReporter,
 	startMsgChan chan struct{},
 ) ifrit.Runner {
 
@@ -310,5 +343,5 @@ func createSubscriber(
 		MinimumRegisterIntervalInSeconds: int(c.StartResponseDelayInterval.Seconds()),
 		PruneThresholdInSeconds:          int(c.DropletStaleThreshold.Seconds()),
 	}
-	return mbus.NewSubscriber(logger.Session("subscriber"), natsClient, registry, startMsgChan, opts)
+	return mbus.NewSubscriber(logger.Session("subscriber"), natsClient, registry, reporter, startMsgChan, opts)
 }
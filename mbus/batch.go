@@ -0,0 +1,62 @@
+package mbus
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// batch collects the registry changes of route messages until they are
+// applied, and coalesces the heartbeats that register an endpoint again.
+type batch struct {
+	changes []registry.Change
+	// index in changes of the last change of each endpoint of a route
+	last map[changeKey]int
+	// when the oldest message of the batch was received
+	received time.Time
+}
+
+type changeKey struct {
+	uri         route.Uri
+	routerGroup string
+	port        uint16
+	addr        string
+}
+
+func newBatch() *batch {
+	return &batch{last: make(map[changeKey]int)}
+}
+
+// add appends a change, unless it registers an endpoint with the same
+// modification tag as the last change of the endpoint in the batch. Then only
+// the endpoint that the registry would keep of the two is registered, as the
+// routes end up the same, and add reports that the change was coalesced.
+func (b *batch) add(c registry.Change) bool {
+	key := changeKey{
+		uri:         c.Uri,
+		routerGroup: c.RouterGroup,
+		port:        c.Port,
+		addr:        c.Endpoint.CanonicalAddr(),
+	}
+
+	if i, ok := b.last[key]; ok {
+		prev := b.changes[i]
+		if prev.Register && c.Register && prev.Endpoint.ModificationTag == c.Endpoint.ModificationTag {
+			if prev.Endpoint.ModificationTag.SucceededBy(&c.Endpoint.ModificationTag) {
+				b.changes[i].Endpoint = c.Endpoint
+			}
+			return true
+		}
+	}
+
+	b.last[key] = len(b.changes)
+	b.changes = append(b.changes, c)
+	return false
+}
+
+func (b *batch) reset() {
+	b.changes = nil
+	b.last = make(map[changeKey]int)
+	b.received = time.Time{}
+}
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"os"
 	"strings"
+	"time"
 
 	"code.cloudfoundry.org/gorouter/common"
+	"code.cloudfoundry.org/gorouter/metrics/reporter"
 	"code.cloudfoundry.org/gorouter/registry"
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/lager"
@@ -27,10 +29,21 @@ type RegistryMessage struct {
 	RouteServiceURL         string            `json:"route_service_url"`
 	PrivateInstanceID       string            `json:"private_instance_id"`
 	PrivateInstanceIndex    string            `json:"private_instance_index"`
+	Match                   *route.Predicates `json:"match"`
+	Mirror                  *route.Mirror     `json:"mirror"`
+	DisableCompression      bool              `json:"disable_compression"`
+	EnableCache             bool              `json:"enable_cache"`
+	Timeouts                *route.Timeouts   `json:"timeouts"`
+	TLSPassthrough          bool              `json:"tls_passthrough"`
+	IPAccess                *route.IPAccess   `json:"ip_access"`
+	StickyCookieNames       []string          `json:"sticky_cookie_names"`
+	HashOn                  *route.HashKey    `json:"hash_on"`
+	RouterGroup             string            `json:"router_group"`
+	ExternalPort            uint16            `json:"external_port"`
 }
 
 func (rm *RegistryMessage) makeEndpoint() *route.Endpoint {
-	return route.NewEndpoint(
+	endpoint := route.NewEndpoint(
 		rm.App,
 		rm.Host,
 		rm.Port,
@@ -40,6 +53,18 @@ func (rm *RegistryMessage) makeEndpoint() *route.Endpoint {
 		rm.StaleThresholdInSeconds,
 		rm.RouteServiceURL,
 		models.ModificationTag{})
+	if !rm.Match.IsEmpty() {
+		endpoint.Predicates = rm.Match
+	}
+	endpoint.Mirror = rm.Mirror
+	endpoint.DisableCompression = rm.DisableCompression
+	endpoint.EnableCache = rm.EnableCache
+	endpoint.Timeouts = rm.Timeouts
+	endpoint.TLSPassthrough = rm.TLSPassthrough
+	endpoint.IPAccess = rm.IPAccess
+	endpoint.StickyCookieNames = rm.StickyCookieNames
+	endpoint.HashKey = rm.HashOn
+	return endpoint
 }
 
 // ValidateMessage checks to ensure the registry message is valid
@@ -47,6 +72,31 @@ func (rm *RegistryMessage) ValidateMessage() bool {
 	return rm.RouteServiceURL == "" || strings.HasPrefix(rm.RouteServiceURL, "https")
 }
 
+// SplitMessage declares how the traffic of routes is split between applications
+type SplitMessage struct {
+	Uris    []route.Uri    `json:"uris"`
+	Weights map[string]int `json:"weights"`
+}
+
+// ValidateMessage checks to ensure the split message is valid
+func (sm *SplitMessage) ValidateMessage() bool {
+	for _, weight := range sm.Weights {
+		if weight < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+const (
+	// goroutines decoding route messages
+	decodeWorkers = 4
+	// route messages received and not yet applied before receiving blocks
+	queueSize = 4096
+	// route messages applied to the registry at once at most
+	maxBatchSize = 512
+)
+
 // Subscriber subscribes to NATS for all router.* messages and handles them
 type Subscriber struct {
 	logger        lager.Logger
@@ -54,6 +104,25 @@ type Subscriber struct {
 	startMsgChan  <-chan struct{}
 	opts          *SubscriberOpts
 	routeRegistry registry.RegistryInterface
+	reporter      reporter.RouteRegistryReporter
+
+	// route messages to decode, and the same messages in the order received
+	// to apply to the registry
+	decode chan *routeMessage
+	queue  chan *routeMessage
+	done   chan struct{}
+}
+
+// routeMessage is a route message received, which is decoded on a worker
+// while the messages received before it are applied.
+type routeMessage struct {
+	message  *nats.Msg
+	received time.Time
+
+	registryMsg *RegistryMessage
+	splitMsg    *SplitMessage
+	err         error
+	decoded     chan struct{}
 }
 
 // SubscriberOpts contains configuration for Subscriber struct
@@ -68,6 +137,7 @@ func NewSubscriber(
 	logger lager.Logger,
 	natsClient *nats.Conn,
 	routeRegistry registry.RegistryInterface,
+	reporter reporter.RouteRegistryReporter,
 	startMsgChan <-chan struct{},
 	opts *SubscriberOpts,
 ) *Subscriber {
@@ -75,14 +145,20 @@ func NewSubscriber(
 		logger:        logger,
 		natsClient:    natsClient,
 		routeRegistry: routeRegistry,
+		reporter:      reporter,
 		startMsgChan:  startMsgChan,
 		opts:          opts,
+		decode:        make(chan *routeMessage, queueSize),
+		queue:         make(chan *routeMessage, queueSize),
+		done:          make(chan struct{}),
 	}
 }
 
 // Run manages the lifecycle of the subscriber process
 func (s *Subscriber) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 	s.logger.Info("subscriber-starting")
+	defer close(s.done)
+
 	err := s.sendStartMessage()
 	if err != nil {
 		return err
@@ -121,50 +197,163 @@ func (s *Subscriber) subscribeToGreetMessage() error {
 	return err
 }
 
+// subscribeRoutes handles route messages in a pipeline: they are decoded on
+// workers, and applied to the registry in the order received, in batches of
+// the messages waiting.
 func (s *Subscriber) subscribeRoutes() error {
+	for i := 0; i < decodeWorkers; i++ {
+		go s.decodeRoutes()
+	}
+	go s.applyRoutes()
+
 	_, err := s.natsClient.Subscribe("router.*", func(message *nats.Msg) {
 		switch message.Subject {
-		case "router.register":
-			s.registerRoute(message)
-		case "router.unregister":
-			s.unregisterRoute(message)
+		case "router.register", "router.unregister", "router.split":
 		default:
+			return
+		}
+
+		m := &routeMessage{
+			message:  message,
+			received: time.Now(),
+			decoded:  make(chan struct{}),
+		}
+		select {
+		case s.queue <- m:
+		case <-s.done:
+			return
+		}
+		select {
+		case s.decode <- m:
+		case <-s.done:
 		}
 	})
 	return err
 }
 
-func (s *Subscriber) unregisterRoute(message *nats.Msg) {
-	s.logger.Info("unregister-route", lager.Data{"message": string(message.Data)})
+func (s *Subscriber) decodeRoutes() {
+	for {
+		select {
+		case m := <-s.decode:
+			if m.message.Subject == "router.split" {
+				m.splitMsg, m.err = createSplitMessage(m.message.Data)
+			} else {
+				m.registryMsg, m.err = createRegistryMessage(m.message.Data)
+			}
+			close(m.decoded)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *Subscriber) applyRoutes() {
+	for {
+		select {
+		case m := <-s.queue:
+			s.applyBatch(m)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// applyBatch applies a message and the messages waiting after it, up to
+// maxBatchSize, to the registry at once. Splits are applied in order between
+// the changes of the messages before and after them.
+func (s *Subscriber) applyBatch(first *routeMessage) {
+	b := newBatch()
+	for m, n := first, 1; m != nil; n++ {
+		select {
+		case <-m.decoded:
+		case <-s.done:
+			return
+		}
+		s.handleRouteMessage(m, b)
+
+		m = nil
+		if n < maxBatchSize {
+			select {
+			case m = <-s.queue:
+			default:
+			}
+		}
+	}
+	s.flush(b)
+}
+
+func (s *Subscriber) handleRouteMessage(m *routeMessage, b *batch) {
+	if b.received.IsZero() {
+		b.received = m.received
+	}
 
-	msg, regErr := createRegistryMessage(message.Data)
-	if regErr != nil {
-		s.logger.Error("validation-error", regErr, lager.Data{
-			"payload": string(message.Data),
-			"subject": message.Subject,
+	if m.err != nil {
+		s.logger.Error("validation-error", m.err, lager.Data{
+			"payload": string(m.message.Data),
+			"subject": m.message.Subject,
 		})
 		return
 	}
 
-	endpoint := msg.makeEndpoint()
-	for _, uri := range msg.Uris {
-		s.routeRegistry.Unregister(uri, endpoint)
+	switch m.message.Subject {
+	case "router.register":
+		s.registerRoute(m.registryMsg, b)
+	case "router.unregister":
+		s.logger.Debug("unregister-route", lager.Data{"message": string(m.message.Data)})
+		s.unregisterRoute(m.registryMsg, b)
+	case "router.split":
+		s.logger.Info("split-route", lager.Data{"message": string(m.message.Data)})
+		// the weights apply to the routes as registered by the messages before
+		s.flush(b)
+		s.splitRoute(m.splitMsg)
 	}
 }
 
-func (s *Subscriber) registerRoute(message *nats.Msg) {
-	msg, regErr := createRegistryMessage(message.Data)
-	if regErr != nil {
-		s.logger.Error("validation-error", regErr, lager.Data{
-			"payload": string(message.Data),
-			"subject": message.Subject,
-		})
+// flush applies the changes of a batch to the registry, and reports the
+// messages waiting and the time since the oldest message of the batch was
+// received.
+func (s *Subscriber) flush(b *batch) {
+	if len(b.changes) == 0 {
+		b.reset()
 		return
 	}
 
+	s.routeRegistry.Apply(b.changes)
+	s.reporter.CaptureRegistryQueue(len(s.queue), time.Since(b.received))
+	s.logger.Debug("route-changes-applied", lager.Data{"changes": len(b.changes)})
+	b.reset()
+}
+
+func (s *Subscriber) unregisterRoute(msg *RegistryMessage, b *batch) {
+	endpoint := msg.makeEndpoint()
+	for _, uri := range msg.Uris {
+		b.add(registry.Change{Uri: uri, Endpoint: endpoint})
+	}
+	if msg.ExternalPort != 0 {
+		b.add(registry.Change{RouterGroup: msg.RouterGroup, Port: msg.ExternalPort, Endpoint: endpoint})
+	}
+}
+
+// registerRoute adds the changes registering the endpoint of a message. The
+// registry counts the messages of the changes it applies, so the heartbeats
+// coalesced here are counted as they are received.
+func (s *Subscriber) registerRoute(msg *RegistryMessage, b *batch) {
 	endpoint := msg.makeEndpoint()
 	for _, uri := range msg.Uris {
-		s.routeRegistry.Register(uri, endpoint)
+		if b.add(registry.Change{Register: true, Uri: uri, Endpoint: endpoint}) {
+			s.reporter.CaptureRegistryMessage(endpoint)
+		}
+	}
+	if msg.ExternalPort != 0 {
+		if b.add(registry.Change{Register: true, RouterGroup: msg.RouterGroup, Port: msg.ExternalPort, Endpoint: endpoint}) {
+			s.reporter.CaptureRegistryMessage(endpoint)
+		}
+	}
+}
+
+func (s *Subscriber) splitRoute(msg *SplitMessage) {
+	for _, uri := range msg.Uris {
+		s.routeRegistry.SetWeights(uri, msg.Weights)
 	}
 }
 
@@ -209,5 +398,32 @@ func createRegistryMessage(data []byte) (*RegistryMessage, error) {
 		return nil, errors.New("Unable to validate message. route_service_url must be https")
 	}
 
+	if msg.Mirror != nil && !msg.Mirror.IsValid() {
+		return nil, errors.New("Unable to validate message. mirror requires a uri or app and a sample_rate between 0 and 1")
+	}
+
+	if msg.Timeouts != nil && !msg.Timeouts.IsValid() {
+		return nil, errors.New("Unable to validate message. timeouts must not be negative")
+	}
+
+	if msg.ExternalPort != 0 && msg.RouterGroup == "" {
+		return nil, errors.New("Unable to validate message. external_port requires a router_group")
+	}
+
+	return &msg, nil
+}
+
+func createSplitMessage(data []byte) (*SplitMessage, error) {
+	var msg SplitMessage
+
+	jsonErr := json.Unmarshal(data, &msg)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	if !msg.ValidateMessage() {
+		return nil, errors.New("Unable to validate message. weights must not be negative")
+	}
+
 	return &msg, nil
 }
@@ -6,7 +6,11 @@ import (
 	"sync/atomic"
 
 	"code.cloudfoundry.org/gorouter/common"
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/mbus"
+	rreporter "code.cloudfoundry.org/gorouter/metrics/reporter"
+	fakereporter "code.cloudfoundry.org/gorouter/metrics/reporter/fakes"
+	rregistry "code.cloudfoundry.org/gorouter/registry"
 	"code.cloudfoundry.org/gorouter/registry/fakes"
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/gorouter/test_util"
@@ -26,6 +30,7 @@ var _ = Describe("Subscriber", func() {
 		process ifrit.Process
 
 		registry *fakes.FakeRegistryInterface
+		reporter *fakereporter.FakeRouteRegistryReporter
 
 		natsRunner   *test_util.NATSRunner
 		natsPort     uint16
@@ -43,6 +48,22 @@ var _ = Describe("Subscriber", func() {
 		natsClient = natsRunner.MessageBus
 
 		registry = new(fakes.FakeRegistryInterface)
+		// the changes applied are recorded as the calls that make them one by one
+		registry.ApplyStub = func(changes []rregistry.Change) {
+			for _, c := range changes {
+				switch {
+				case c.RouterGroup != "" && c.Register:
+					registry.RegisterTcp(c.RouterGroup, c.Port, c.Endpoint)
+				case c.RouterGroup != "":
+					registry.UnregisterTcp(c.RouterGroup, c.Port, c.Endpoint)
+				case c.Register:
+					registry.Register(c.Uri, c.Endpoint)
+				default:
+					registry.Unregister(c.Uri, c.Endpoint)
+				}
+			}
+		}
+		reporter = new(fakereporter.FakeRouteRegistryReporter)
 
 		logger = lagertest.NewTestLogger("mbus-test")
 
@@ -54,7 +75,7 @@ var _ = Describe("Subscriber", func() {
 			PruneThresholdInSeconds:          120,
 		}
 
-		sub = mbus.NewSubscriber(logger, natsClient, registry, startMsgChan, subOpts)
+		sub = mbus.NewSubscriber(logger, natsClient, registry, reporter, startMsgChan, subOpts)
 	})
 
 	AfterEach(func() {
@@ -103,7 +124,7 @@ var _ = Describe("Subscriber", func() {
 	})
 
 	It("errors when publish start message fails", func() {
-		sub = mbus.NewSubscriber(logger, nil, registry, startMsgChan, subOpts)
+		sub = mbus.NewSubscriber(logger, nil, registry, reporter, startMsgChan, subOpts)
 		process = ifrit.Invoke(sub)
 
 		var err error
@@ -111,6 +132,33 @@ var _ = Describe("Subscriber", func() {
 		Expect(err).To(HaveOccurred())
 	})
 
+	It("counts the coalesced heartbeats as registry messages", func() {
+		release := make(chan struct{})
+		var blocked int32
+		reporter.CaptureRegistryMessageStub = func(rreporter.ComponentTagged) {
+			if atomic.CompareAndSwapInt32(&blocked, 0, 1) {
+				<-release
+			}
+		}
+		sub = mbus.NewSubscriber(logger, natsClient, rregistry.NewRouteRegistry(logger, config.DefaultConfig(), reporter), reporter, startMsgChan, subOpts)
+		process = ifrit.Invoke(sub)
+		Eventually(process.Ready()).Should(BeClosed())
+
+		err := natsClient.Publish("router.register", []byte(`{"host":"host","port":1111,"uris":["first.example.com"]}`))
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(func() int32 { return atomic.LoadInt32(&blocked) }).Should(Equal(int32(1)))
+
+		for i := 0; i < 100; i++ {
+			err = natsClient.Publish("router.register", []byte(`{"host":"host","port":1112,"uris":["test.example.com"]}`))
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(natsClient.Flush()).To(Succeed())
+		close(release)
+
+		Eventually(reporter.CaptureRegistryMessageCallCount).Should(Equal(101))
+		Consistently(reporter.CaptureRegistryMessageCallCount).Should(Equal(101))
+	})
+
 	Context("when reconnecting", func() {
 		BeforeEach(func() {
 			process = ifrit.Invoke(sub)
@@ -227,6 +275,147 @@ var _ = Describe("Subscriber", func() {
 			}
 		})
 
+		It("registers the match predicates of the endpoint", func() {
+			data := []byte(`{
+				"host": "host",
+				"port": 1111,
+				"uris": ["test.example.com"],
+				"match": {"method": "GET", "headers": {"X-Canary": "true"}}
+			}`)
+
+			err := natsClient.Publish("router.register", data)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(registry.RegisterCallCount).Should(Equal(1))
+			_, endpoint := registry.RegisterArgsForCall(0)
+			Expect(endpoint.Predicates).To(Equal(&route.Predicates{
+				Method:  "GET",
+				Headers: map[string]string{"X-Canary": "true"},
+			}))
+		})
+
+		It("registers whether the endpoint terminates tls", func() {
+			data := []byte(`{"host":"host","port":1111,"uris":["test.example.com"],"tls_passthrough":true}`)
+
+			err := natsClient.Publish("router.register", data)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(registry.RegisterCallCount).Should(Equal(1))
+			_, endpoint := registry.RegisterArgsForCall(0)
+			Expect(endpoint.TLSPassthrough).To(BeTrue())
+		})
+
+		It("registers the ip access lists of the endpoint", func() {
+			data := []byte(`{"host":"host","port":1111,"uris":["test.example.com"],"ip_access":{"allow":["10.0.0.0/8"]}}`)
+
+			err := natsClient.Publish("router.register", data)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(registry.RegisterCallCount).Should(Equal(1))
+			_, endpoint := registry.RegisterArgsForCall(0)
+			Expect(endpoint.IPAccess.Allow).To(HaveLen(1))
+			Expect(endpoint.IPAccess.Allow[0].String()).To(Equal("10.0.0.0/8"))
+		})
+
+		It("registers the sticky session cookies of the endpoint", func() {
+			data := []byte(`{"host":"host","port":1111,"uris":["test.example.com"],"sticky_cookie_names":["PHPSESSID"]}`)
+
+			err := natsClient.Publish("router.register", data)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(registry.RegisterCallCount).Should(Equal(1))
+			_, endpoint := registry.RegisterArgsForCall(0)
+			Expect(endpoint.StickyCookieNames).To(Equal([]string{"PHPSESSID"}))
+		})
+
+		It("registers the hash key of the endpoint", func() {
+			data := []byte(`{"host":"host","port":1111,"uris":["test.example.com"],"hash_on":"header:X-User-Id"}`)
+
+			err := natsClient.Publish("router.register", data)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(registry.RegisterCallCount).Should(Equal(1))
+			_, endpoint := registry.RegisterArgsForCall(0)
+			Expect(endpoint.HashKey).To(Equal(&route.HashKey{Header: "X-User-Id"}))
+		})
+
+		It("registers the tcp route of the endpoint", func() {
+			data := []byte(`{
+				"host": "host",
+				"port": 1111,
+				"router_group": "default-tcp",
+				"external_port": 1024
+			}`)
+
+			err := natsClient.Publish("router.register", data)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(registry.RegisterTcpCallCount).Should(Equal(1))
+			routerGroup, port, endpoint := registry.RegisterTcpArgsForCall(0)
+			Expect(routerGroup).To(Equal("default-tcp"))
+			Expect(port).To(Equal(uint16(1024)))
+			Expect(endpoint.CanonicalAddr()).To(Equal("host:1111"))
+			Expect(registry.RegisterCallCount()).To(BeZero())
+		})
+
+		It("coalesces the heartbeats of an endpoint waiting to be applied", func() {
+			release := make(chan struct{})
+			registry.ApplyStub = func([]rregistry.Change) {
+				if registry.ApplyCallCount() == 1 {
+					<-release
+				}
+			}
+
+			err := natsClient.Publish("router.register", []byte(`{"host":"host","port":1111,"uris":["first.example.com"]}`))
+			Expect(err).ToNot(HaveOccurred())
+			Eventually(registry.ApplyCallCount).Should(Equal(1))
+
+			for i := 0; i < 100; i++ {
+				err = natsClient.Publish("router.register", []byte(`{"host":"host","port":1112,"uris":["test.example.com"]}`))
+				Expect(err).ToNot(HaveOccurred())
+			}
+			err = natsClient.Publish("router.register", []byte(`{"host":"host","port":1113,"uris":["last.example.com"]}`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(natsClient.Flush()).To(Succeed())
+			close(release)
+
+			Eventually(func() route.Uri {
+				changes := registry.ApplyArgsForCall(registry.ApplyCallCount() - 1)
+				return changes[len(changes)-1].Uri
+			}).Should(Equal(route.Uri("last.example.com")))
+
+			heartbeats := 0
+			for i := 1; i < registry.ApplyCallCount(); i++ {
+				for _, c := range registry.ApplyArgsForCall(i) {
+					if c.Uri == "test.example.com" {
+						Expect(c.Register).To(BeTrue())
+						Expect(c.Endpoint.CanonicalAddr()).To(Equal("host:1112"))
+						heartbeats++
+					}
+				}
+			}
+			Expect(heartbeats).To(BeNumerically(">", 0))
+			Expect(heartbeats).To(BeNumerically("<", 100))
+		})
+
+		It("reports the depth of the queue and the latency of the messages", func() {
+			err := natsClient.Publish("router.register", []byte(`{"host":"host","port":1111,"uris":["test.example.com"]}`))
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(reporter.CaptureRegistryQueueCallCount).Should(Equal(1))
+			depth, latency := reporter.CaptureRegistryQueueArgsForCall(0)
+			Expect(depth).To(BeZero())
+			Expect(latency).To(BeNumerically(">", 0))
+		})
+
+		Context("when the message has an external port without a router group", func() {
+			It("does not update the registry", func() {
+				err := natsClient.Publish("router.register", []byte(`{"host":"host","port":1111,"external_port":1024}`))
+				Expect(err).ToNot(HaveOccurred())
+				Consistently(registry.RegisterTcpCallCount).Should(BeZero())
+			})
+		})
+
 		Context("when the message cannot be unmarshaled", func() {
 			It("does not update the registry", func() {
 				err := natsClient.Publish("router.register", []byte(` `))
@@ -260,6 +449,42 @@ var _ = Describe("Subscriber", func() {
 		})
 	})
 
+	Context("when a route split is published through NATS", func() {
+		BeforeEach(func() {
+			process = ifrit.Invoke(sub)
+			Eventually(process.Ready()).Should(BeClosed())
+		})
+
+		It("updates the weights of the routes", func() {
+			msg := mbus.SplitMessage{
+				Uris:    []route.Uri{"test.example.com", "test2.example.com"},
+				Weights: map[string]int{"app-a": 90, "app-b": 10},
+			}
+
+			data, err := json.Marshal(msg)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = natsClient.Publish("router.split", data)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(registry.SetWeightsCallCount).Should(Equal(2))
+			for i := 0; i < registry.SetWeightsCallCount(); i++ {
+				uri, weights := registry.SetWeightsArgsForCall(i)
+
+				Expect(msg.Uris).To(ContainElement(uri))
+				Expect(weights).To(Equal(msg.Weights))
+			}
+		})
+
+		Context("when a weight is negative", func() {
+			It("does not update the registry", func() {
+				err := natsClient.Publish("router.split", []byte(`{"uris":["test.example.com"],"weights":{"app-a":-1}}`))
+				Expect(err).ToNot(HaveOccurred())
+				Consistently(registry.SetWeightsCallCount).Should(BeZero())
+			})
+		})
+	})
+
 	Context("when a route is unregistered through NATS", func() {
 		BeforeEach(func() {
 			process = ifrit.Invoke(sub)
@@ -363,5 +588,18 @@ var _ = Describe("Subscriber", func() {
 				Expect(endpoint.CanonicalAddr()).To(ContainSubstring(msg.Host))
 			}
 		})
+
+		It("unregisters the tcp route", func() {
+			data := []byte(`{"host":"host","port":1111,"router_group":"default-tcp","external_port":1024}`)
+
+			err := natsClient.Publish("router.unregister", data)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(registry.UnregisterTcpCallCount).Should(Equal(1))
+			routerGroup, port, endpoint := registry.UnregisterTcpArgsForCall(0)
+			Expect(routerGroup).To(Equal("default-tcp"))
+			Expect(port).To(Equal(uint16(1024)))
+			Expect(endpoint.CanonicalAddr()).To(Equal("host:1111"))
+		})
 	})
 })
@@ -30,6 +30,16 @@ func (c *CompositeReporter) CaptureBadGateway(req *http.Request) {
 	c.second.CaptureBadGateway(req)
 }
 
+func (c *CompositeReporter) CaptureIPDenied(req *http.Request) {
+	c.first.CaptureIPDenied(req)
+	c.second.CaptureIPDenied(req)
+}
+
+func (c *CompositeReporter) CaptureBackendError(req *http.Request, errorType string) {
+	c.first.CaptureBackendError(req, errorType)
+	c.second.CaptureBackendError(req, errorType)
+}
+
 func (c *CompositeReporter) CaptureRoutingRequest(b *route.Endpoint, req *http.Request) {
 	c.first.CaptureRoutingRequest(b, req)
 	c.second.CaptureRoutingRequest(b, req)
@@ -44,3 +54,43 @@ func (c *CompositeReporter) CaptureRoutingResponse(b *route.Endpoint, res *http.
 	c.first.CaptureRoutingResponse(b, res, t, d)
 	c.second.CaptureRoutingResponse(b, res, t, d)
 }
+
+func (c *CompositeReporter) CaptureMirrorResponse(b *route.Endpoint, res *http.Response, d time.Duration) {
+	c.first.CaptureMirrorResponse(b, res, d)
+	c.second.CaptureMirrorResponse(b, res, d)
+}
+
+func (c *CompositeReporter) CaptureMirrorSkipped(req *http.Request) {
+	c.first.CaptureMirrorSkipped(req)
+	c.second.CaptureMirrorSkipped(req)
+}
+
+func (c *CompositeReporter) CaptureCacheHit(req *http.Request) {
+	c.first.CaptureCacheHit(req)
+	c.second.CaptureCacheHit(req)
+}
+
+func (c *CompositeReporter) CaptureCacheMiss(req *http.Request) {
+	c.first.CaptureCacheMiss(req)
+	c.second.CaptureCacheMiss(req)
+}
+
+func (c *CompositeReporter) CaptureBackendConnection(b *route.Endpoint, reused bool) {
+	c.first.CaptureBackendConnection(b, reused)
+	c.second.CaptureBackendConnection(b, reused)
+}
+
+func (c *CompositeReporter) CaptureActiveWebSockets(active int64) {
+	c.first.CaptureActiveWebSockets(active)
+	c.second.CaptureActiveWebSockets(active)
+}
+
+func (c *CompositeReporter) CaptureTcpConnection(b *route.Endpoint, bytesReceived, bytesSent int64) {
+	c.first.CaptureTcpConnection(b, bytesReceived, bytesSent)
+	c.second.CaptureTcpConnection(b, bytesReceived, bytesSent)
+}
+
+func (c *CompositeReporter) CaptureTLSPassthroughConnection(b *route.Endpoint, bytesReceived, bytesSent int64) {
+	c.first.CaptureTLSPassthroughConnection(b, bytesReceived, bytesSent)
+	c.second.CaptureTLSPassthroughConnection(b, bytesReceived, bytesSent)
+}
@@ -48,6 +48,16 @@ var _ = Describe("CompositeReporter", func() {
 		Expect(fakeReporter2.CaptureBadRequestArgsForCall(0)).To(Equal(req))
 	})
 
+	It("forwards CaptureIPDenied to both reporters", func() {
+		composite.CaptureIPDenied(req)
+
+		Expect(fakeReporter1.CaptureIPDeniedCallCount()).To(Equal(1))
+		Expect(fakeReporter2.CaptureIPDeniedCallCount()).To(Equal(1))
+
+		Expect(fakeReporter1.CaptureIPDeniedArgsForCall(0)).To(Equal(req))
+		Expect(fakeReporter2.CaptureIPDeniedArgsForCall(0)).To(Equal(req))
+	})
+
 	It("forwards CaptureBadGateway to both reporters", func() {
 		composite.CaptureBadGateway(req)
 		Expect(fakeReporter1.CaptureBadGatewayCallCount()).To(Equal(1))
@@ -57,6 +67,56 @@ var _ = Describe("CompositeReporter", func() {
 		Expect(fakeReporter2.CaptureBadGatewayArgsForCall(0)).To(Equal(req))
 	})
 
+	It("forwards CaptureBackendError to both reporters", func() {
+		composite.CaptureBackendError(req, "dial_timeout")
+		Expect(fakeReporter1.CaptureBackendErrorCallCount()).To(Equal(1))
+		Expect(fakeReporter2.CaptureBackendErrorCallCount()).To(Equal(1))
+
+		callReq, errorType := fakeReporter1.CaptureBackendErrorArgsForCall(0)
+		Expect(callReq).To(Equal(req))
+		Expect(errorType).To(Equal("dial_timeout"))
+	})
+
+	It("forwards CaptureBackendConnection to both reporters", func() {
+		composite.CaptureBackendConnection(endpoint, true)
+		Expect(fakeReporter1.CaptureBackendConnectionCallCount()).To(Equal(1))
+		Expect(fakeReporter2.CaptureBackendConnectionCallCount()).To(Equal(1))
+
+		callEndpoint, reused := fakeReporter1.CaptureBackendConnectionArgsForCall(0)
+		Expect(callEndpoint).To(Equal(endpoint))
+		Expect(reused).To(BeTrue())
+	})
+
+	It("forwards CaptureActiveWebSockets to both reporters", func() {
+		composite.CaptureActiveWebSockets(3)
+		Expect(fakeReporter1.CaptureActiveWebSocketsCallCount()).To(Equal(1))
+		Expect(fakeReporter2.CaptureActiveWebSocketsCallCount()).To(Equal(1))
+
+		Expect(fakeReporter1.CaptureActiveWebSocketsArgsForCall(0)).To(Equal(int64(3)))
+	})
+
+	It("forwards CaptureTcpConnection to both reporters", func() {
+		composite.CaptureTcpConnection(endpoint, 10, 20)
+		Expect(fakeReporter1.CaptureTcpConnectionCallCount()).To(Equal(1))
+		Expect(fakeReporter2.CaptureTcpConnectionCallCount()).To(Equal(1))
+
+		callEndpoint, received, sent := fakeReporter2.CaptureTcpConnectionArgsForCall(0)
+		Expect(callEndpoint).To(Equal(endpoint))
+		Expect(received).To(Equal(int64(10)))
+		Expect(sent).To(Equal(int64(20)))
+	})
+
+	It("forwards CaptureTLSPassthroughConnection to both reporters", func() {
+		composite.CaptureTLSPassthroughConnection(endpoint, 10, 20)
+		Expect(fakeReporter1.CaptureTLSPassthroughConnectionCallCount()).To(Equal(1))
+		Expect(fakeReporter2.CaptureTLSPassthroughConnectionCallCount()).To(Equal(1))
+
+		callEndpoint, received, sent := fakeReporter1.CaptureTLSPassthroughConnectionArgsForCall(0)
+		Expect(callEndpoint).To(Equal(endpoint))
+		Expect(received).To(Equal(int64(10)))
+		Expect(sent).To(Equal(int64(20)))
+	})
+
 	It("forwards CaptureRoutingRequest to both reporters", func() {
 		composite.CaptureRoutingRequest(endpoint, req)
 		Expect(fakeReporter1.CaptureRoutingRequestCallCount()).To(Equal(1))
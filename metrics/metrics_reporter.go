@@ -27,6 +27,14 @@ func (m *MetricsReporter) CaptureBadGateway(req *http.Request) {
 	dropsondeMetrics.BatchIncrementCounter("bad_gateways")
 }
 
+func (m *MetricsReporter) CaptureIPDenied(req *http.Request) {
+	dropsondeMetrics.BatchIncrementCounter("ip_denied")
+}
+
+func (m *MetricsReporter) CaptureBackendError(req *http.Request, errorType string) {
+	dropsondeMetrics.BatchIncrementCounter("backend_errors." + errorType)
+}
+
 func (m *MetricsReporter) CaptureRoutingRequest(b *route.Endpoint, req *http.Request) {
 	dropsondeMetrics.BatchIncrementCounter("total_requests")
 
@@ -58,6 +66,49 @@ func (m *MetricsReporter) CaptureRoutingResponse(b *route.Endpoint, res *http.Re
 	}
 }
 
+func (m *MetricsReporter) CaptureMirrorResponse(b *route.Endpoint, res *http.Response, d time.Duration) {
+	dropsondeMetrics.BatchIncrementCounter(fmt.Sprintf("mirror.responses.%s", getResponseCounterName(res)))
+	dropsondeMetrics.BatchIncrementCounter("mirror.responses")
+
+	dropsondeMetrics.SendValue("mirror.latency", float64(d/time.Millisecond), "ms")
+}
+
+func (m *MetricsReporter) CaptureMirrorSkipped(req *http.Request) {
+	dropsondeMetrics.BatchIncrementCounter("mirror.skipped")
+}
+
+func (m *MetricsReporter) CaptureCacheHit(req *http.Request) {
+	dropsondeMetrics.BatchIncrementCounter("cache.hits")
+}
+
+func (m *MetricsReporter) CaptureCacheMiss(req *http.Request) {
+	dropsondeMetrics.BatchIncrementCounter("cache.misses")
+}
+
+func (m *MetricsReporter) CaptureBackendConnection(b *route.Endpoint, reused bool) {
+	if reused {
+		dropsondeMetrics.BatchIncrementCounter("backend_connections.reused")
+	} else {
+		dropsondeMetrics.BatchIncrementCounter("backend_connections.new")
+	}
+}
+
+func (m *MetricsReporter) CaptureActiveWebSockets(active int64) {
+	dropsondeMetrics.SendValue("websockets.active", float64(active), "")
+}
+
+func (m *MetricsReporter) CaptureTcpConnection(b *route.Endpoint, bytesReceived, bytesSent int64) {
+	dropsondeMetrics.BatchIncrementCounter("tcp.connections")
+	dropsondeMetrics.BatchAddCounter("tcp.bytes_received", uint64(bytesReceived))
+	dropsondeMetrics.BatchAddCounter("tcp.bytes_sent", uint64(bytesSent))
+}
+
+func (m *MetricsReporter) CaptureTLSPassthroughConnection(b *route.Endpoint, bytesReceived, bytesSent int64) {
+	dropsondeMetrics.BatchIncrementCounter("tls_passthrough.connections")
+	dropsondeMetrics.BatchAddCounter("tls_passthrough.bytes_received", uint64(bytesReceived))
+	dropsondeMetrics.BatchAddCounter("tls_passthrough.bytes_sent", uint64(bytesSent))
+}
+
 func (c *MetricsReporter) CaptureLookupTime(t time.Duration) {
 	unit := "ns"
 	dropsondeMetrics.SendValue("route_lookup_time", float64(t.Nanoseconds()), unit)
@@ -72,6 +123,11 @@ func (c *MetricsReporter) CaptureRegistryMessage(msg reporter.ComponentTagged) {
 	dropsondeMetrics.IncrementCounter("registry_message." + msg.Component())
 }
 
+func (c *MetricsReporter) CaptureRegistryQueue(depth int, latency time.Duration) {
+	dropsondeMetrics.SendValue("registry_queue_depth", float64(depth), "")
+	dropsondeMetrics.SendValue("registry_message_latency", float64(latency.Nanoseconds()), "ns")
+}
+
 func getResponseCounterName(res *http.Response) string {
 	var statusCode int
 
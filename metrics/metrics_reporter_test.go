@@ -38,6 +38,14 @@ var _ = Describe("MetricsReporter", func() {
 		Eventually(func() uint64 { return sender.GetCounter("rejected_requests") }).Should(BeEquivalentTo(2))
 	})
 
+	It("increments the ip_denied metric", func() {
+		metricsReporter.CaptureIPDenied(req)
+		Eventually(func() uint64 { return sender.GetCounter("ip_denied") }).Should(BeEquivalentTo(1))
+
+		metricsReporter.CaptureIPDenied(req)
+		Eventually(func() uint64 { return sender.GetCounter("ip_denied") }).Should(BeEquivalentTo(2))
+	})
+
 	It("increments the bad_gateway metric", func() {
 		metricsReporter.CaptureBadGateway(req)
 		Eventually(func() uint64 { return sender.GetCounter("bad_gateways") }).Should(BeEquivalentTo(1))
@@ -46,6 +54,54 @@ var _ = Describe("MetricsReporter", func() {
 		Eventually(func() uint64 { return sender.GetCounter("bad_gateways") }).Should(BeEquivalentTo(2))
 	})
 
+	It("increments a backend_errors metric per error type", func() {
+		metricsReporter.CaptureBackendError(req, "dial_timeout")
+		metricsReporter.CaptureBackendError(req, "dial_timeout")
+		metricsReporter.CaptureBackendError(req, "connection_reset")
+		Eventually(func() uint64 { return sender.GetCounter("backend_errors.dial_timeout") }).Should(BeEquivalentTo(2))
+		Eventually(func() uint64 { return sender.GetCounter("backend_errors.connection_reset") }).Should(BeEquivalentTo(1))
+	})
+
+	It("increments the cache metrics", func() {
+		metricsReporter.CaptureCacheHit(req)
+		metricsReporter.CaptureCacheHit(req)
+		metricsReporter.CaptureCacheMiss(req)
+		Eventually(func() uint64 { return sender.GetCounter("cache.hits") }).Should(BeEquivalentTo(2))
+		Eventually(func() uint64 { return sender.GetCounter("cache.misses") }).Should(BeEquivalentTo(1))
+	})
+
+	It("increments the backend connection metrics", func() {
+		metricsReporter.CaptureBackendConnection(endpoint, true)
+		metricsReporter.CaptureBackendConnection(endpoint, true)
+		metricsReporter.CaptureBackendConnection(endpoint, false)
+		Eventually(func() uint64 { return sender.GetCounter("backend_connections.reused") }).Should(BeEquivalentTo(2))
+		Eventually(func() uint64 { return sender.GetCounter("backend_connections.new") }).Should(BeEquivalentTo(1))
+	})
+
+	It("sends the number of active websocket connections", func() {
+		metricsReporter.CaptureActiveWebSockets(4)
+		Eventually(func() fake.Metric { return sender.GetValue("websockets.active") }).Should(Equal(
+			fake.Metric{
+				Value: 4,
+				Unit:  "",
+			}))
+	})
+
+	It("counts the tcp connections and their bytes", func() {
+		metricsReporter.CaptureTcpConnection(endpoint, 10, 20)
+		metricsReporter.CaptureTcpConnection(endpoint, 1, 2)
+		Eventually(func() uint64 { return sender.GetCounter("tcp.connections") }).Should(BeEquivalentTo(2))
+		Eventually(func() uint64 { return sender.GetCounter("tcp.bytes_received") }).Should(BeEquivalentTo(11))
+		Eventually(func() uint64 { return sender.GetCounter("tcp.bytes_sent") }).Should(BeEquivalentTo(22))
+	})
+
+	It("counts the tls passthrough connections and their bytes", func() {
+		metricsReporter.CaptureTLSPassthroughConnection(endpoint, 10, 20)
+		Eventually(func() uint64 { return sender.GetCounter("tls_passthrough.connections") }).Should(BeEquivalentTo(1))
+		Eventually(func() uint64 { return sender.GetCounter("tls_passthrough.bytes_received") }).Should(BeEquivalentTo(10))
+		Eventually(func() uint64 { return sender.GetCounter("tls_passthrough.bytes_sent") }).Should(BeEquivalentTo(20))
+	})
+
 	Context("increments the request metrics", func() {
 		It("increments the total requests metric", func() {
 			metricsReporter.CaptureRoutingRequest(&route.Endpoint{}, req)
@@ -339,5 +395,19 @@ var _ = Describe("MetricsReporter", func() {
 					Unit:  "ns",
 				}))
 		})
+
+		It("sends the depth of the registry queue and the latency of its messages", func() {
+			metricsReporter.CaptureRegistryQueue(7, 3*time.Millisecond)
+			Eventually(func() fake.Metric { return sender.GetValue("registry_queue_depth") }).Should(Equal(
+				fake.Metric{
+					Value: 7,
+					Unit:  "",
+				}))
+			Eventually(func() fake.Metric { return sender.GetValue("registry_message_latency") }).Should(Equal(
+				fake.Metric{
+					Value: 3000000,
+					Unit:  "ns",
+				}))
+		})
 	})
 })
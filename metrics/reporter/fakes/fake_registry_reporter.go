@@ -25,6 +25,12 @@ type FakeRouteRegistryReporter struct {
 	captureRegistryMessageArgsForCall []struct {
 		msg reporter.ComponentTagged
 	}
+	CaptureRegistryQueueStub        func(depth int, latency time.Duration)
+	captureRegistryQueueMutex       sync.RWMutex
+	captureRegistryQueueArgsForCall []struct {
+		depth   int
+		latency time.Duration
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -102,6 +108,31 @@ func (fake *FakeRouteRegistryReporter) CaptureRegistryMessageArgsForCall(i int)
 	return fake.captureRegistryMessageArgsForCall[i].msg
 }
 
+func (fake *FakeRouteRegistryReporter) CaptureRegistryQueue(depth int, latency time.Duration) {
+	fake.captureRegistryQueueMutex.Lock()
+	fake.captureRegistryQueueArgsForCall = append(fake.captureRegistryQueueArgsForCall, struct {
+		depth   int
+		latency time.Duration
+	}{depth, latency})
+	fake.recordInvocation("CaptureRegistryQueue", []interface{}{depth, latency})
+	fake.captureRegistryQueueMutex.Unlock()
+	if fake.CaptureRegistryQueueStub != nil {
+		fake.CaptureRegistryQueueStub(depth, latency)
+	}
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureRegistryQueueCallCount() int {
+	fake.captureRegistryQueueMutex.RLock()
+	defer fake.captureRegistryQueueMutex.RUnlock()
+	return len(fake.captureRegistryQueueArgsForCall)
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureRegistryQueueArgsForCall(i int) (int, time.Duration) {
+	fake.captureRegistryQueueMutex.RLock()
+	defer fake.captureRegistryQueueMutex.RUnlock()
+	return fake.captureRegistryQueueArgsForCall[i].depth, fake.captureRegistryQueueArgsForCall[i].latency
+}
+
 func (fake *FakeRouteRegistryReporter) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -111,6 +142,8 @@ func (fake *FakeRouteRegistryReporter) Invocations() map[string][][]interface{}
 	defer fake.captureLookupTimeMutex.RUnlock()
 	fake.captureRegistryMessageMutex.RLock()
 	defer fake.captureRegistryMessageMutex.RUnlock()
+	fake.captureRegistryQueueMutex.RLock()
+	defer fake.captureRegistryQueueMutex.RUnlock()
 	return fake.invocations
 }
 
@@ -43,6 +43,64 @@ type FakeProxyReporter struct {
 		t   time.Time
 		d   time.Duration
 	}
+	CaptureMirrorResponseStub        func(b *route.Endpoint, res *http.Response, d time.Duration)
+	captureMirrorResponseMutex       sync.RWMutex
+	captureMirrorResponseArgsForCall []struct {
+		b   *route.Endpoint
+		res *http.Response
+		d   time.Duration
+	}
+	CaptureMirrorSkippedStub        func(req *http.Request)
+	captureMirrorSkippedMutex       sync.RWMutex
+	captureMirrorSkippedArgsForCall []struct {
+		req *http.Request
+	}
+	CaptureCacheHitStub        func(req *http.Request)
+	captureCacheHitMutex       sync.RWMutex
+	captureCacheHitArgsForCall []struct {
+		req *http.Request
+	}
+	CaptureCacheMissStub        func(req *http.Request)
+	captureCacheMissMutex       sync.RWMutex
+	captureCacheMissArgsForCall []struct {
+		req *http.Request
+	}
+	CaptureBackendErrorStub        func(req *http.Request, errorType string)
+	captureBackendErrorMutex       sync.RWMutex
+	captureBackendErrorArgsForCall []struct {
+		req       *http.Request
+		errorType string
+	}
+	CaptureBackendConnectionStub        func(b *route.Endpoint, reused bool)
+	captureBackendConnectionMutex       sync.RWMutex
+	captureBackendConnectionArgsForCall []struct {
+		b      *route.Endpoint
+		reused bool
+	}
+	CaptureActiveWebSocketsStub        func(active int64)
+	captureActiveWebSocketsMutex       sync.RWMutex
+	captureActiveWebSocketsArgsForCall []struct {
+		active int64
+	}
+	CaptureTcpConnectionStub        func(b *route.Endpoint, bytesReceived int64, bytesSent int64)
+	captureTcpConnectionMutex       sync.RWMutex
+	captureTcpConnectionArgsForCall []struct {
+		b             *route.Endpoint
+		bytesReceived int64
+		bytesSent     int64
+	}
+	CaptureTLSPassthroughConnectionStub        func(b *route.Endpoint, bytesReceived int64, bytesSent int64)
+	captureTLSPassthroughConnectionMutex       sync.RWMutex
+	captureTLSPassthroughConnectionArgsForCall []struct {
+		b             *route.Endpoint
+		bytesReceived int64
+		bytesSent     int64
+	}
+	CaptureIPDeniedStub        func(req *http.Request)
+	captureIPDeniedMutex       sync.RWMutex
+	captureIPDeniedArgsForCall []struct {
+		req *http.Request
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -174,6 +232,254 @@ func (fake *FakeProxyReporter) CaptureRouteServiceResponseArgsForCall(i int) (*r
 	return fake.captureRouteServiceResponseArgsForCall[i].b, fake.captureRouteServiceResponseArgsForCall[i].res, fake.captureRouteServiceResponseArgsForCall[i].t, fake.captureRouteServiceResponseArgsForCall[i].d
 }
 
+func (fake *FakeProxyReporter) CaptureMirrorResponse(b *route.Endpoint, res *http.Response, d time.Duration) {
+	fake.captureMirrorResponseMutex.Lock()
+	fake.captureMirrorResponseArgsForCall = append(fake.captureMirrorResponseArgsForCall, struct {
+		b   *route.Endpoint
+		res *http.Response
+		d   time.Duration
+	}{b, res, d})
+	fake.recordInvocation("CaptureMirrorResponse", []interface{}{b, res, d})
+	fake.captureMirrorResponseMutex.Unlock()
+	if fake.CaptureMirrorResponseStub != nil {
+		fake.CaptureMirrorResponseStub(b, res, d)
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureMirrorResponseCallCount() int {
+	fake.captureMirrorResponseMutex.RLock()
+	defer fake.captureMirrorResponseMutex.RUnlock()
+	return len(fake.captureMirrorResponseArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureMirrorResponseArgsForCall(i int) (*route.Endpoint, *http.Response, time.Duration) {
+	fake.captureMirrorResponseMutex.RLock()
+	defer fake.captureMirrorResponseMutex.RUnlock()
+	return fake.captureMirrorResponseArgsForCall[i].b, fake.captureMirrorResponseArgsForCall[i].res, fake.captureMirrorResponseArgsForCall[i].d
+}
+
+func (fake *FakeProxyReporter) CaptureMirrorSkipped(req *http.Request) {
+	fake.captureMirrorSkippedMutex.Lock()
+	fake.captureMirrorSkippedArgsForCall = append(fake.captureMirrorSkippedArgsForCall, struct {
+		req *http.Request
+	}{req})
+	fake.recordInvocation("CaptureMirrorSkipped", []interface{}{req})
+	fake.captureMirrorSkippedMutex.Unlock()
+	if fake.CaptureMirrorSkippedStub != nil {
+		fake.CaptureMirrorSkippedStub(req)
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureMirrorSkippedCallCount() int {
+	fake.captureMirrorSkippedMutex.RLock()
+	defer fake.captureMirrorSkippedMutex.RUnlock()
+	return len(fake.captureMirrorSkippedArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureMirrorSkippedArgsForCall(i int) *http.Request {
+	fake.captureMirrorSkippedMutex.RLock()
+	defer fake.captureMirrorSkippedMutex.RUnlock()
+	return fake.captureMirrorSkippedArgsForCall[i].req
+}
+
+func (fake *FakeProxyReporter) CaptureCacheHit(req *http.Request) {
+	fake.captureCacheHitMutex.Lock()
+	fake.captureCacheHitArgsForCall = append(fake.captureCacheHitArgsForCall, struct {
+		req *http.Request
+	}{req})
+	fake.recordInvocation("CaptureCacheHit", []interface{}{req})
+	fake.captureCacheHitMutex.Unlock()
+	if fake.CaptureCacheHitStub != nil {
+		fake.CaptureCacheHitStub(req)
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureCacheHitCallCount() int {
+	fake.captureCacheHitMutex.RLock()
+	defer fake.captureCacheHitMutex.RUnlock()
+	return len(fake.captureCacheHitArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureCacheHitArgsForCall(i int) *http.Request {
+	fake.captureCacheHitMutex.RLock()
+	defer fake.captureCacheHitMutex.RUnlock()
+	return fake.captureCacheHitArgsForCall[i].req
+}
+
+func (fake *FakeProxyReporter) CaptureCacheMiss(req *http.Request) {
+	fake.captureCacheMissMutex.Lock()
+	fake.captureCacheMissArgsForCall = append(fake.captureCacheMissArgsForCall, struct {
+		req *http.Request
+	}{req})
+	fake.recordInvocation("CaptureCacheMiss", []interface{}{req})
+	fake.captureCacheMissMutex.Unlock()
+	if fake.CaptureCacheMissStub != nil {
+		fake.CaptureCacheMissStub(req)
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureCacheMissCallCount() int {
+	fake.captureCacheMissMutex.RLock()
+	defer fake.captureCacheMissMutex.RUnlock()
+	return len(fake.captureCacheMissArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureCacheMissArgsForCall(i int) *http.Request {
+	fake.captureCacheMissMutex.RLock()
+	defer fake.captureCacheMissMutex.RUnlock()
+	return fake.captureCacheMissArgsForCall[i].req
+}
+
+func (fake *FakeProxyReporter) CaptureBackendError(req *http.Request, errorType string) {
+	fake.captureBackendErrorMutex.Lock()
+	fake.captureBackendErrorArgsForCall = append(fake.captureBackendErrorArgsForCall, struct {
+		req       *http.Request
+		errorType string
+	}{req, errorType})
+	fake.recordInvocation("CaptureBackendError", []interface{}{req, errorType})
+	fake.captureBackendErrorMutex.Unlock()
+	if fake.CaptureBackendErrorStub != nil {
+		fake.CaptureBackendErrorStub(req, errorType)
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureBackendErrorCallCount() int {
+	fake.captureBackendErrorMutex.RLock()
+	defer fake.captureBackendErrorMutex.RUnlock()
+	return len(fake.captureBackendErrorArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureBackendErrorArgsForCall(i int) (*http.Request, string) {
+	fake.captureBackendErrorMutex.RLock()
+	defer fake.captureBackendErrorMutex.RUnlock()
+	return fake.captureBackendErrorArgsForCall[i].req, fake.captureBackendErrorArgsForCall[i].errorType
+}
+
+func (fake *FakeProxyReporter) CaptureBackendConnection(b *route.Endpoint, reused bool) {
+	fake.captureBackendConnectionMutex.Lock()
+	fake.captureBackendConnectionArgsForCall = append(fake.captureBackendConnectionArgsForCall, struct {
+		b      *route.Endpoint
+		reused bool
+	}{b, reused})
+	fake.recordInvocation("CaptureBackendConnection", []interface{}{b, reused})
+	fake.captureBackendConnectionMutex.Unlock()
+	if fake.CaptureBackendConnectionStub != nil {
+		fake.CaptureBackendConnectionStub(b, reused)
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureBackendConnectionCallCount() int {
+	fake.captureBackendConnectionMutex.RLock()
+	defer fake.captureBackendConnectionMutex.RUnlock()
+	return len(fake.captureBackendConnectionArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureBackendConnectionArgsForCall(i int) (*route.Endpoint, bool) {
+	fake.captureBackendConnectionMutex.RLock()
+	defer fake.captureBackendConnectionMutex.RUnlock()
+	return fake.captureBackendConnectionArgsForCall[i].b, fake.captureBackendConnectionArgsForCall[i].reused
+}
+
+func (fake *FakeProxyReporter) CaptureActiveWebSockets(active int64) {
+	fake.captureActiveWebSocketsMutex.Lock()
+	fake.captureActiveWebSocketsArgsForCall = append(fake.captureActiveWebSocketsArgsForCall, struct {
+		active int64
+	}{active})
+	fake.recordInvocation("CaptureActiveWebSockets", []interface{}{active})
+	fake.captureActiveWebSocketsMutex.Unlock()
+	if fake.CaptureActiveWebSocketsStub != nil {
+		fake.CaptureActiveWebSocketsStub(active)
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureActiveWebSocketsCallCount() int {
+	fake.captureActiveWebSocketsMutex.RLock()
+	defer fake.captureActiveWebSocketsMutex.RUnlock()
+	return len(fake.captureActiveWebSocketsArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureActiveWebSocketsArgsForCall(i int) int64 {
+	fake.captureActiveWebSocketsMutex.RLock()
+	defer fake.captureActiveWebSocketsMutex.RUnlock()
+	return fake.captureActiveWebSocketsArgsForCall[i].active
+}
+
+func (fake *FakeProxyReporter) CaptureTcpConnection(b *route.Endpoint, bytesReceived int64, bytesSent int64) {
+	fake.captureTcpConnectionMutex.Lock()
+	fake.captureTcpConnectionArgsForCall = append(fake.captureTcpConnectionArgsForCall, struct {
+		b             *route.Endpoint
+		bytesReceived int64
+		bytesSent     int64
+	}{b, bytesReceived, bytesSent})
+	fake.recordInvocation("CaptureTcpConnection", []interface{}{b, bytesReceived, bytesSent})
+	fake.captureTcpConnectionMutex.Unlock()
+	if fake.CaptureTcpConnectionStub != nil {
+		fake.CaptureTcpConnectionStub(b, bytesReceived, bytesSent)
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureTcpConnectionCallCount() int {
+	fake.captureTcpConnectionMutex.RLock()
+	defer fake.captureTcpConnectionMutex.RUnlock()
+	return len(fake.captureTcpConnectionArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureTcpConnectionArgsForCall(i int) (*route.Endpoint, int64, int64) {
+	fake.captureTcpConnectionMutex.RLock()
+	defer fake.captureTcpConnectionMutex.RUnlock()
+	return fake.captureTcpConnectionArgsForCall[i].b, fake.captureTcpConnectionArgsForCall[i].bytesReceived, fake.captureTcpConnectionArgsForCall[i].bytesSent
+}
+
+func (fake *FakeProxyReporter) CaptureTLSPassthroughConnection(b *route.Endpoint, bytesReceived int64, bytesSent int64) {
+	fake.captureTLSPassthroughConnectionMutex.Lock()
+	fake.captureTLSPassthroughConnectionArgsForCall = append(fake.captureTLSPassthroughConnectionArgsForCall, struct {
+		b             *route.Endpoint
+		bytesReceived int64
+		bytesSent     int64
+	}{b, bytesReceived, bytesSent})
+	fake.recordInvocation("CaptureTLSPassthroughConnection", []interface{}{b, bytesReceived, bytesSent})
+	fake.captureTLSPassthroughConnectionMutex.Unlock()
+	if fake.CaptureTLSPassthroughConnectionStub != nil {
+		fake.CaptureTLSPassthroughConnectionStub(b, bytesReceived, bytesSent)
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureTLSPassthroughConnectionCallCount() int {
+	fake.captureTLSPassthroughConnectionMutex.RLock()
+	defer fake.captureTLSPassthroughConnectionMutex.RUnlock()
+	return len(fake.captureTLSPassthroughConnectionArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureTLSPassthroughConnectionArgsForCall(i int) (*route.Endpoint, int64, int64) {
+	fake.captureTLSPassthroughConnectionMutex.RLock()
+	defer fake.captureTLSPassthroughConnectionMutex.RUnlock()
+	return fake.captureTLSPassthroughConnectionArgsForCall[i].b, fake.captureTLSPassthroughConnectionArgsForCall[i].bytesReceived, fake.captureTLSPassthroughConnectionArgsForCall[i].bytesSent
+}
+
+func (fake *FakeProxyReporter) CaptureIPDenied(req *http.Request) {
+	fake.captureIPDeniedMutex.Lock()
+	fake.captureIPDeniedArgsForCall = append(fake.captureIPDeniedArgsForCall, struct {
+		req *http.Request
+	}{req})
+	fake.recordInvocation("CaptureIPDenied", []interface{}{req})
+	fake.captureIPDeniedMutex.Unlock()
+	if fake.CaptureIPDeniedStub != nil {
+		fake.CaptureIPDeniedStub(req)
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureIPDeniedCallCount() int {
+	fake.captureIPDeniedMutex.RLock()
+	defer fake.captureIPDeniedMutex.RUnlock()
+	return len(fake.captureIPDeniedArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureIPDeniedArgsForCall(i int) *http.Request {
+	fake.captureIPDeniedMutex.RLock()
+	defer fake.captureIPDeniedMutex.RUnlock()
+	return fake.captureIPDeniedArgsForCall[i].req
+}
+
 func (fake *FakeProxyReporter) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -187,6 +493,26 @@ func (fake *FakeProxyReporter) Invocations() map[string][][]interface{} {
 	defer fake.captureRoutingResponseMutex.RUnlock()
 	fake.captureRouteServiceResponseMutex.RLock()
 	defer fake.captureRouteServiceResponseMutex.RUnlock()
+	fake.captureMirrorResponseMutex.RLock()
+	defer fake.captureMirrorResponseMutex.RUnlock()
+	fake.captureMirrorSkippedMutex.RLock()
+	defer fake.captureMirrorSkippedMutex.RUnlock()
+	fake.captureCacheHitMutex.RLock()
+	defer fake.captureCacheHitMutex.RUnlock()
+	fake.captureCacheMissMutex.RLock()
+	defer fake.captureCacheMissMutex.RUnlock()
+	fake.captureBackendErrorMutex.RLock()
+	defer fake.captureBackendErrorMutex.RUnlock()
+	fake.captureBackendConnectionMutex.RLock()
+	defer fake.captureBackendConnectionMutex.RUnlock()
+	fake.captureActiveWebSocketsMutex.RLock()
+	defer fake.captureActiveWebSocketsMutex.RUnlock()
+	fake.captureTcpConnectionMutex.RLock()
+	defer fake.captureTcpConnectionMutex.RUnlock()
+	fake.captureTLSPassthroughConnectionMutex.RLock()
+	defer fake.captureTLSPassthroughConnectionMutex.RUnlock()
+	fake.captureIPDeniedMutex.RLock()
+	defer fake.captureIPDeniedMutex.RUnlock()
 	return fake.invocations
 }
 
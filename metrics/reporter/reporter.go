@@ -11,9 +11,19 @@ import (
 type ProxyReporter interface {
 	CaptureBadRequest(req *http.Request)
 	CaptureBadGateway(req *http.Request)
+	CaptureIPDenied(req *http.Request)
+	CaptureBackendError(req *http.Request, errorType string)
 	CaptureRoutingRequest(b *route.Endpoint, req *http.Request)
 	CaptureRoutingResponse(b *route.Endpoint, res *http.Response, t time.Time, d time.Duration)
 	CaptureRouteServiceResponse(b *route.Endpoint, res *http.Response, t time.Time, d time.Duration)
+	CaptureMirrorResponse(b *route.Endpoint, res *http.Response, d time.Duration)
+	CaptureMirrorSkipped(req *http.Request)
+	CaptureCacheHit(req *http.Request)
+	CaptureCacheMiss(req *http.Request)
+	CaptureBackendConnection(b *route.Endpoint, reused bool)
+	CaptureActiveWebSockets(active int64)
+	CaptureTcpConnection(b *route.Endpoint, bytesReceived, bytesSent int64)
+	CaptureTLSPassthroughConnection(b *route.Endpoint, bytesReceived, bytesSent int64)
 }
 
 type ComponentTagged interface {
@@ -25,4 +35,5 @@ type RouteRegistryReporter interface {
 	CaptureRouteStats(totalRoutes int, msSinceLastUpdate uint64)
 	CaptureLookupTime(t time.Duration)
 	CaptureRegistryMessage(msg ComponentTagged)
+	CaptureRegistryQueue(depth int, latency time.Duration)
 }
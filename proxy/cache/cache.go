@@ -0,0 +1,254 @@
+package cache
+
+import (
+	"container/list"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache is an in-memory store of backend responses. Its size is bounded and the
+// least recently used responses are evicted first.
+type Cache struct {
+	lock sync.Mutex
+
+	maxSize      int64
+	maxEntrySize int64
+	size         int64
+
+	lru      *list.List
+	entries  map[string]*list.Element
+	vary     map[string][]string
+	variants map[string]int
+}
+
+func NewCache(maxSize, maxEntrySize int64) *Cache {
+	return &Cache{
+		maxSize:      maxSize,
+		maxEntrySize: maxEntrySize,
+		lru:          list.New(),
+		entries:      make(map[string]*list.Element),
+		vary:         make(map[string][]string),
+		variants:     make(map[string]int),
+	}
+}
+
+// PrimaryKey identifies the resource requested, regardless of the variant.
+func PrimaryKey(request *http.Request) string {
+	host := request.Host
+	if pos := strings.Index(host, ":"); pos >= 0 {
+		host = host[0:pos]
+	}
+	return strings.ToLower(host) + request.URL.RequestURI()
+}
+
+func variantKey(primary string, vary []string, header http.Header) string {
+	if len(vary) == 0 {
+		return primary
+	}
+
+	key := primary
+	for _, name := range vary {
+		key += "\x00" + name + "=" + strings.Join(header[name], ",")
+	}
+	return key
+}
+
+// Lookup returns the stored response for the request, fresh or stale, or nil.
+func (c *Cache) Lookup(request *http.Request) *Entry {
+	primary := PrimaryKey(request)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	element, ok := c.entries[variantKey(primary, c.vary[primary], request.Header)]
+	if !ok {
+		return nil
+	}
+
+	c.lru.MoveToFront(element)
+	return element.Value.(*Entry)
+}
+
+// Store adds the response to the request to the cache, replacing the stored
+// variant. Responses that are not cacheable or too large are ignored.
+func (c *Cache) Store(request *http.Request, response *http.Response, body []byte) *Entry {
+	entry := newEntry(response, body, time.Now())
+	if entry == nil || entry.size() > c.maxEntrySize {
+		return nil
+	}
+
+	c.put(request, entry)
+	return entry
+}
+
+// Revalidated refreshes a stored response with the headers of a 304 response.
+func (c *Cache) Revalidated(request *http.Request, entry *Entry, response *http.Response) *Entry {
+	header := cloneHeader(entry.Header)
+	for name, values := range response.Header {
+		if name == "Content-Length" {
+			continue
+		}
+		header[name] = values
+	}
+
+	refreshed := newEntry(&http.Response{StatusCode: entry.StatusCode, Header: header}, entry.Body, time.Now())
+	if refreshed == nil {
+		c.remove(entry)
+		return entry
+	}
+
+	c.put(request, refreshed)
+	return refreshed
+}
+
+func (c *Cache) put(request *http.Request, entry *Entry) {
+	primary := PrimaryKey(request)
+	vary := varyHeaders(entry.Header)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !equalStrings(c.vary[primary], vary) {
+		c.purge(func(e *Entry) bool { return e.primary == primary })
+	}
+
+	entry.primary = primary
+	entry.key = variantKey(primary, vary, request.Header)
+
+	if element, ok := c.entries[entry.key]; ok {
+		c.removeElement(element)
+	}
+
+	c.vary[primary] = vary
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	c.variants[primary]++
+	c.size += entry.size()
+
+	for c.size > c.maxSize {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+func (c *Cache) remove(entry *Entry) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if element, ok := c.entries[entry.key]; ok && element.Value == entry {
+		c.removeElement(element)
+	}
+}
+
+func (c *Cache) removeElement(element *list.Element) {
+	entry := element.Value.(*Entry)
+	c.lru.Remove(element)
+	delete(c.entries, entry.key)
+	c.size -= entry.size()
+
+	c.variants[entry.primary]--
+	if c.variants[entry.primary] == 0 {
+		delete(c.variants, entry.primary)
+		delete(c.vary, entry.primary)
+	}
+}
+
+func (c *Cache) purge(match func(*Entry) bool) int {
+	purged := 0
+	for element := c.lru.Front(); element != nil; {
+		next := element.Next()
+		if match(element.Value.(*Entry)) {
+			c.removeElement(element)
+			purged++
+		}
+		element = next
+	}
+	return purged
+}
+
+// Purge removes the responses stored for the host whose path starts with the
+// given prefix, and returns how many were removed.
+func (c *Cache) Purge(host, pathPrefix string) int {
+	prefix := strings.ToLower(host) + pathPrefix
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.purge(func(e *Entry) bool { return strings.HasPrefix(e.primary, prefix) })
+}
+
+// Len returns the number of responses stored.
+func (c *Cache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.lru.Len()
+}
+
+// Size returns the memory used by the responses stored, in bytes.
+func (c *Cache) Size() int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.size
+}
+
+// ServeHTTP reports the size of the cache, and purges it on DELETE requests.
+// The host parameter is required to purge, the path parameter is a prefix
+// that defaults to all paths of the host.
+func (c *Cache) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Connection", "close")
+	w.Header().Set("Content-Type", "application/json")
+
+	switch req.Method {
+	case "GET":
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]int64{
+			"entries":  int64(c.Len()),
+			"size":     c.Size(),
+			"max_size": c.maxSize,
+		})
+	case "DELETE":
+		host := req.URL.Query().Get("host")
+		if host == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "host is required"})
+			return
+		}
+
+		purged := c.Purge(host, req.URL.Query().Get("path"))
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]int{"purged": purged})
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, value := range header["Vary"] {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
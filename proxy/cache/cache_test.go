@@ -0,0 +1,158 @@
+package cache_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/proxy/cache"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func cacheableResponse(cacheControl string) *http.Response {
+	res := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	res.Header.Set("Cache-Control", cacheControl)
+	return res
+}
+
+var _ = Describe("Cache", func() {
+	var c *cache.Cache
+
+	BeforeEach(func() {
+		c = cache.NewCache(1024, 256)
+	})
+
+	It("returns the response stored for the request", func() {
+		req := test_util.NewRequest("GET", "foo.example.com", "/bar?baz=1", nil)
+		Expect(c.Lookup(req)).To(BeNil())
+
+		Expect(c.Store(req, cacheableResponse("max-age=60"), []byte("body"))).ToNot(BeNil())
+
+		entry := c.Lookup(test_util.NewRequest("GET", "FOO.example.com:8080", "/bar?baz=1", nil))
+		Expect(entry).ToNot(BeNil())
+		Expect(entry.StatusCode).To(Equal(http.StatusOK))
+		Expect(string(entry.Body)).To(Equal("body"))
+
+		Expect(c.Lookup(test_util.NewRequest("GET", "foo.example.com", "/bar?baz=2", nil))).To(BeNil())
+	})
+
+	It("does not store responses that are not cacheable", func() {
+		req := test_util.NewRequest("GET", "foo.example.com", "/", nil)
+
+		Expect(c.Store(req, cacheableResponse("no-store"), []byte("body"))).To(BeNil())
+		Expect(c.Store(req, cacheableResponse("private, max-age=60"), []byte("body"))).To(BeNil())
+		Expect(c.Store(req, cacheableResponse(""), []byte("body"))).To(BeNil())
+
+		res := cacheableResponse("max-age=60")
+		res.Header.Set("Set-Cookie", "session=1")
+		Expect(c.Store(req, res, []byte("body"))).To(BeNil())
+
+		res = cacheableResponse("max-age=60")
+		res.StatusCode = http.StatusInternalServerError
+		Expect(c.Store(req, res, []byte("body"))).To(BeNil())
+
+		Expect(c.Len()).To(Equal(0))
+	})
+
+	It("does not store responses larger than the maximum entry size", func() {
+		req := test_util.NewRequest("GET", "foo.example.com", "/", nil)
+		Expect(c.Store(req, cacheableResponse("max-age=60"), make([]byte, 512))).To(BeNil())
+		Expect(c.Len()).To(Equal(0))
+	})
+
+	It("stores a variant per value of the headers listed by Vary", func() {
+		res := cacheableResponse("max-age=60")
+		res.Header.Set("Vary", "accept-language")
+
+		en := test_util.NewRequest("GET", "foo.example.com", "/", nil)
+		en.Header.Set("Accept-Language", "en")
+		c.Store(en, res, []byte("hello"))
+
+		de := test_util.NewRequest("GET", "foo.example.com", "/", nil)
+		de.Header.Set("Accept-Language", "de")
+		Expect(c.Lookup(de)).To(BeNil())
+		c.Store(de, res, []byte("hallo"))
+
+		Expect(string(c.Lookup(en).Body)).To(Equal("hello"))
+		Expect(string(c.Lookup(de).Body)).To(Equal("hallo"))
+		Expect(c.Len()).To(Equal(2))
+	})
+
+	It("does not store responses that vary on every header", func() {
+		res := cacheableResponse("max-age=60")
+		res.Header.Set("Vary", "*")
+
+		Expect(c.Store(test_util.NewRequest("GET", "foo.example.com", "/", nil), res, []byte("body"))).To(BeNil())
+	})
+
+	It("evicts the least recently used responses when full", func() {
+		first := test_util.NewRequest("GET", "foo.example.com", "/first", nil)
+		second := test_util.NewRequest("GET", "foo.example.com", "/second", nil)
+		third := test_util.NewRequest("GET", "foo.example.com", "/third", nil)
+		fourth := test_util.NewRequest("GET", "foo.example.com", "/fourth", nil)
+
+		body := make([]byte, 200)
+		c.Store(first, cacheableResponse("max-age=60"), body)
+		c.Store(second, cacheableResponse("max-age=60"), body)
+		c.Store(third, cacheableResponse("max-age=60"), body)
+		c.Store(fourth, cacheableResponse("max-age=60"), body)
+		Expect(c.Len()).To(Equal(4))
+
+		Expect(c.Lookup(first)).ToNot(BeNil())
+
+		c.Store(test_util.NewRequest("GET", "foo.example.com", "/fifth", nil), cacheableResponse("max-age=60"), body)
+		Expect(c.Size()).To(BeNumerically("<=", 1024))
+		Expect(c.Lookup(first)).ToNot(BeNil())
+		Expect(c.Lookup(second)).To(BeNil())
+	})
+
+	It("purges the responses of a host by path prefix", func() {
+		c.Store(test_util.NewRequest("GET", "foo.example.com", "/assets/a.css", nil), cacheableResponse("max-age=60"), []byte("a"))
+		c.Store(test_util.NewRequest("GET", "foo.example.com", "/assets/b.css", nil), cacheableResponse("max-age=60"), []byte("b"))
+		c.Store(test_util.NewRequest("GET", "foo.example.com", "/index.html", nil), cacheableResponse("max-age=60"), []byte("c"))
+		c.Store(test_util.NewRequest("GET", "bar.example.com", "/assets/a.css", nil), cacheableResponse("max-age=60"), []byte("d"))
+
+		Expect(c.Purge("foo.example.com", "/assets/")).To(Equal(2))
+		Expect(c.Len()).To(Equal(2))
+
+		Expect(c.Purge("bar.example.com", "")).To(Equal(1))
+		Expect(c.Len()).To(Equal(1))
+	})
+
+	Context("ServeHTTP", func() {
+		BeforeEach(func() {
+			c.Store(test_util.NewRequest("GET", "foo.example.com", "/a", nil), cacheableResponse("max-age=60"), []byte("a"))
+			c.Store(test_util.NewRequest("GET", "foo.example.com", "/b", nil), cacheableResponse("max-age=60"), []byte("b"))
+		})
+
+		It("reports the size of the cache", func() {
+			recorder := httptest.NewRecorder()
+			c.ServeHTTP(recorder, httptest.NewRequest("GET", "/cache", nil))
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var stats map[string]int64
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &stats)).To(Succeed())
+			Expect(stats["entries"]).To(Equal(int64(2)))
+			Expect(stats["size"]).To(Equal(c.Size()))
+			Expect(stats["max_size"]).To(Equal(int64(1024)))
+		})
+
+		It("purges the cache", func() {
+			recorder := httptest.NewRecorder()
+			c.ServeHTTP(recorder, httptest.NewRequest("DELETE", "/cache?host=foo.example.com&path=/a", nil))
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"purged":1}`))
+			Expect(c.Len()).To(Equal(1))
+		})
+
+		It("requires a host to purge", func() {
+			recorder := httptest.NewRecorder()
+			c.ServeHTTP(recorder, httptest.NewRequest("DELETE", "/cache", nil))
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(c.Len()).To(Equal(2))
+		})
+	})
+})
@@ -0,0 +1,272 @@
+package cache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// hopHeaders apply to a single connection and are not stored.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Entry is a response stored in the cache.
+type Entry struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+
+	key     string
+	primary string
+
+	storedAt       time.Time
+	initialAge     time.Duration
+	lifetime       time.Duration
+	staleIfError   time.Duration
+	mustRevalidate bool
+}
+
+// newEntry returns nil if the response may not be stored by a shared cache.
+func newEntry(response *http.Response, body []byte, now time.Time) *Entry {
+	if !cacheableStatus[response.StatusCode] {
+		return nil
+	}
+
+	header := response.Header
+	if _, ok := header["Set-Cookie"]; ok {
+		return nil
+	}
+
+	for _, name := range varyHeaders(header) {
+		if name == "*" {
+			return nil
+		}
+	}
+
+	directives := parseCacheControl(header.Get("Cache-Control"))
+	if _, ok := directives["no-store"]; ok {
+		return nil
+	}
+	if _, ok := directives["private"]; ok {
+		return nil
+	}
+
+	lifetime, explicit := freshnessLifetime(directives, header, now)
+	if _, ok := directives["no-cache"]; ok {
+		lifetime = 0
+	}
+
+	hasValidator := header.Get("ETag") != "" || header.Get("Last-Modified") != ""
+	if !explicit && !hasValidator {
+		return nil
+	}
+
+	_, mustRevalidate := directives["must-revalidate"]
+	_, proxyRevalidate := directives["proxy-revalidate"]
+
+	entry := &Entry{
+		StatusCode:     response.StatusCode,
+		Header:         cloneHeader(header),
+		Body:           body,
+		storedAt:       now,
+		initialAge:     seconds(header.Get("Age")),
+		lifetime:       lifetime,
+		staleIfError:   seconds(directives["stale-if-error"]),
+		mustRevalidate: mustRevalidate || proxyRevalidate,
+	}
+	entry.Header.Del("Age")
+	for _, name := range hopHeaders {
+		entry.Header.Del(name)
+	}
+
+	return entry
+}
+
+func freshnessLifetime(directives map[string]string, header http.Header, now time.Time) (time.Duration, bool) {
+	if value, ok := directives["s-maxage"]; ok {
+		return seconds(value), true
+	}
+	if value, ok := directives["max-age"]; ok {
+		return seconds(value), true
+	}
+
+	if value := header.Get("Expires"); value != "" {
+		expires, err := http.ParseTime(value)
+		if err != nil {
+			return 0, true
+		}
+
+		date := now
+		if d, err := http.ParseTime(header.Get("Date")); err == nil {
+			date = d
+		}
+		if expires.Before(date) {
+			return 0, true
+		}
+		return expires.Sub(date), true
+	}
+
+	return 0, false
+}
+
+// Age is the time since the response was generated by the backend.
+func (e *Entry) Age(now time.Time) time.Duration {
+	return e.initialAge + now.Sub(e.storedAt)
+}
+
+// Fresh returns true if the response may be served without revalidation.
+func (e *Entry) Fresh(now time.Time) bool {
+	return e.Age(now) < e.lifetime
+}
+
+// UsableOnError returns true if the response may be served in place of a
+// backend error, as allowed by the stale-if-error directive.
+func (e *Entry) UsableOnError(now time.Time) bool {
+	return !e.mustRevalidate && e.Age(now) < e.lifetime+e.staleIfError
+}
+
+// HasValidators returns true if the response can be revalidated with a conditional request.
+func (e *Entry) HasValidators() bool {
+	return e.Header.Get("ETag") != "" || e.Header.Get("Last-Modified") != ""
+}
+
+// SetConditionalHeaders asks the backend to answer with 304 Not Modified if
+// the stored response is still valid.
+func (e *Entry) SetConditionalHeaders(header http.Header) {
+	if etag := e.Header.Get("ETag"); etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+	if lastModified := e.Header.Get("Last-Modified"); lastModified != "" {
+		header.Set("If-Modified-Since", lastModified)
+	}
+}
+
+// NotModified returns true if the conditional headers of the request match the stored response.
+func (e *Entry) NotModified(request *http.Request) bool {
+	if inm := request.Header.Get("If-None-Match"); inm != "" {
+		etag := e.Header.Get("ETag")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := request.Header.Get("If-Modified-Since"); ims != "" {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		modified, err := http.ParseTime(e.Header.Get("Last-Modified"))
+		return err == nil && !modified.After(since)
+	}
+
+	return false
+}
+
+// Response builds a response to the request from the stored one.
+func (e *Entry) Response(request *http.Request, now time.Time) *http.Response {
+	header := cloneHeader(e.Header)
+	header.Set("Age", strconv.Itoa(int(e.Age(now).Seconds())))
+
+	return &http.Response{
+		Status:        strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       request,
+	}
+}
+
+// Serve writes the stored response to the client, answering conditional
+// requests with 304 Not Modified. It returns the status code written.
+func (e *Entry) Serve(w http.ResponseWriter, request *http.Request, now time.Time) int {
+	header := w.Header()
+	for name, values := range e.Header {
+		header[name] = append([]string(nil), values...)
+	}
+	header.Set("Age", strconv.Itoa(int(e.Age(now).Seconds())))
+
+	if e.NotModified(request) {
+		header.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return http.StatusNotModified
+	}
+
+	header.Set("Content-Length", strconv.Itoa(len(e.Body)))
+	w.WriteHeader(e.StatusCode)
+	if request.Method != "HEAD" {
+		w.Write(e.Body)
+	}
+	return e.StatusCode
+}
+
+func (e *Entry) size() int64 {
+	size := int64(len(e.Body) + len(e.key))
+	for name, values := range e.Header {
+		size += int64(len(name))
+		for _, v := range values {
+			size += int64(len(v))
+		}
+	}
+	return size
+}
+
+func parseCacheControl(value string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, arg := part, ""
+		if pos := strings.Index(part, "="); pos >= 0 {
+			name, arg = part[:pos], strings.Trim(part[pos+1:], `"`)
+		}
+		directives[strings.ToLower(name)] = arg
+	}
+	return directives
+}
+
+func seconds(value string) time.Duration {
+	s, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || s < 0 {
+		return 0
+	}
+	return time.Duration(s) * time.Second
+}
+
+func cloneHeader(header http.Header) http.Header {
+	clone := make(http.Header, len(header))
+	for name, values := range header {
+		clone[name] = append([]string(nil), values...)
+	}
+	return clone
+}
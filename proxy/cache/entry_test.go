@@ -0,0 +1,173 @@
+package cache_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/proxy/cache"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Entry", func() {
+	var (
+		c   *cache.Cache
+		req *http.Request
+		res *http.Response
+	)
+
+	BeforeEach(func() {
+		c = cache.NewCache(1024, 1024)
+		req = test_util.NewRequest("GET", "foo.example.com", "/", nil)
+		res = &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	})
+
+	Context("freshness", func() {
+		It("is fresh for the max-age of the response", func() {
+			res.Header.Set("Cache-Control", "max-age=60")
+			entry := c.Store(req, res, []byte("body"))
+
+			Expect(entry.Fresh(time.Now())).To(BeTrue())
+			Expect(entry.Fresh(time.Now().Add(61 * time.Second))).To(BeFalse())
+		})
+
+		It("prefers s-maxage over max-age", func() {
+			res.Header.Set("Cache-Control", "max-age=60, s-maxage=10")
+			entry := c.Store(req, res, []byte("body"))
+
+			Expect(entry.Fresh(time.Now().Add(11 * time.Second))).To(BeFalse())
+		})
+
+		It("takes the age of the response into account", func() {
+			res.Header.Set("Cache-Control", "max-age=60")
+			res.Header.Set("Age", "50")
+			entry := c.Store(req, res, []byte("body"))
+
+			Expect(entry.Fresh(time.Now().Add(11 * time.Second))).To(BeFalse())
+		})
+
+		It("is fresh until the Expires date", func() {
+			now := time.Now().UTC()
+			res.Header.Set("Date", now.Format(http.TimeFormat))
+			res.Header.Set("Expires", now.Add(30*time.Second).Format(http.TimeFormat))
+			entry := c.Store(req, res, []byte("body"))
+
+			Expect(entry.Fresh(time.Now())).To(BeTrue())
+			Expect(entry.Fresh(time.Now().Add(31 * time.Second))).To(BeFalse())
+		})
+
+		It("is never fresh with no-cache", func() {
+			res.Header.Set("Cache-Control", "no-cache, max-age=60")
+			res.Header.Set("ETag", `"v1"`)
+			entry := c.Store(req, res, []byte("body"))
+
+			Expect(entry).ToNot(BeNil())
+			Expect(entry.Fresh(time.Now())).To(BeFalse())
+		})
+
+		It("stores responses with validators only", func() {
+			res.Header.Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+			entry := c.Store(req, res, []byte("body"))
+
+			Expect(entry).ToNot(BeNil())
+			Expect(entry.Fresh(time.Now())).To(BeFalse())
+			Expect(entry.HasValidators()).To(BeTrue())
+		})
+	})
+
+	Context("stale-if-error", func() {
+		It("is usable on error for the allowed period", func() {
+			res.Header.Set("Cache-Control", "max-age=0, stale-if-error=60")
+			entry := c.Store(req, res, []byte("body"))
+
+			Expect(entry.UsableOnError(time.Now())).To(BeTrue())
+			Expect(entry.UsableOnError(time.Now().Add(61 * time.Second))).To(BeFalse())
+		})
+
+		It("is not usable on error when it must be revalidated", func() {
+			res.Header.Set("Cache-Control", "max-age=0, stale-if-error=60, must-revalidate")
+			entry := c.Store(req, res, []byte("body"))
+
+			Expect(entry.UsableOnError(time.Now())).To(BeFalse())
+		})
+	})
+
+	Context("Serve", func() {
+		var entry *cache.Entry
+
+		BeforeEach(func() {
+			res.Header.Set("Cache-Control", "max-age=60")
+			res.Header.Set("ETag", `"v1"`)
+			res.Header.Set("Content-Type", "text/plain")
+			res.Header.Set("Connection", "close")
+			entry = c.Store(req, res, []byte("body"))
+		})
+
+		It("writes the stored response", func() {
+			recorder := httptest.NewRecorder()
+			Expect(entry.Serve(recorder, req, time.Now())).To(Equal(http.StatusOK))
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(Equal("body"))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("text/plain"))
+			Expect(recorder.Header().Get("Content-Length")).To(Equal("4"))
+			Expect(recorder.Header().Get("Age")).To(Equal("0"))
+			Expect(recorder.Header().Get("Connection")).To(BeEmpty())
+		})
+
+		It("answers matching conditional requests with 304", func() {
+			req.Header.Set("If-None-Match", `W/"v1"`)
+
+			recorder := httptest.NewRecorder()
+			Expect(entry.Serve(recorder, req, time.Now())).To(Equal(http.StatusNotModified))
+			Expect(recorder.Code).To(Equal(http.StatusNotModified))
+			Expect(recorder.Body.Len()).To(Equal(0))
+		})
+
+		It("sends the body when the conditional request does not match", func() {
+			req.Header.Set("If-None-Match", `"v2"`)
+
+			recorder := httptest.NewRecorder()
+			Expect(entry.Serve(recorder, req, time.Now())).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(Equal("body"))
+		})
+	})
+
+	Context("requests", func() {
+		It("caches GET and HEAD requests", func() {
+			Expect(cache.Cacheable(test_util.NewRequest("GET", "foo.example.com", "/", nil))).To(BeTrue())
+			Expect(cache.Cacheable(test_util.NewRequest("HEAD", "foo.example.com", "/", nil))).To(BeTrue())
+			Expect(cache.Cacheable(test_util.NewRequest("POST", "foo.example.com", "/", nil))).To(BeFalse())
+		})
+
+		It("does not cache authorized, ranged or no-store requests", func() {
+			req.Header.Set("Authorization", "Bearer token")
+			Expect(cache.Cacheable(req)).To(BeFalse())
+
+			req = test_util.NewRequest("GET", "foo.example.com", "/", nil)
+			req.Header.Set("Range", "bytes=0-10")
+			Expect(cache.Cacheable(req)).To(BeFalse())
+
+			req = test_util.NewRequest("GET", "foo.example.com", "/", nil)
+			req.Header.Set("Cache-Control", "no-store")
+			Expect(cache.Cacheable(req)).To(BeFalse())
+		})
+
+		It("requires validation when the client asks for it", func() {
+			Expect(cache.RequiresValidation(req)).To(BeFalse())
+
+			req.Header.Set("Cache-Control", "no-cache")
+			Expect(cache.RequiresValidation(req)).To(BeTrue())
+
+			req.Header.Set("Cache-Control", "max-age=0")
+			Expect(cache.RequiresValidation(req)).To(BeTrue())
+
+			req.Header.Del("Cache-Control")
+			req.Header.Set("Pragma", "no-cache")
+			Expect(cache.RequiresValidation(req)).To(BeTrue())
+		})
+	})
+})
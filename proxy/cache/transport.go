@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Cacheable returns true if the response to the request may be served from
+// or stored in the cache.
+func Cacheable(request *http.Request) bool {
+	if request.Method != "GET" && request.Method != "HEAD" {
+		return false
+	}
+
+	if request.Header.Get("Authorization") != "" || request.Header.Get("Range") != "" {
+		return false
+	}
+
+	_, noStore := parseCacheControl(request.Header.Get("Cache-Control"))["no-store"]
+	return !noStore
+}
+
+// RequiresValidation returns true if the client does not accept a stored
+// response without it being revalidated with the backend.
+func RequiresValidation(request *http.Request) bool {
+	directives := parseCacheControl(request.Header.Get("Cache-Control"))
+	if _, ok := directives["no-cache"]; ok {
+		return true
+	}
+	if maxAge, ok := directives["max-age"]; ok && seconds(maxAge) == 0 {
+		return true
+	}
+	return strings.Contains(strings.ToLower(request.Header.Get("Pragma")), "no-cache")
+}
+
+func hasConditionalHeaders(request *http.Request) bool {
+	return request.Header.Get("If-None-Match") != "" || request.Header.Get("If-Modified-Since") != ""
+}
+
+// Transport sends a request to the backend on behalf of the cache. It
+// revalidates the stale response stored for the request, serves it in place
+// of backend errors when allowed and stores cacheable responses once their
+// body has been read. A Transport is used for a single request.
+type Transport struct {
+	cache       *Cache
+	transport   http.RoundTripper
+	request     *http.Request
+	entry       *Entry
+	maxAttempts int
+
+	attempts    int
+	conditional bool
+	hit         bool
+}
+
+// NewTransport returns a Transport for the request as received by the router.
+// The entry is the stored response for the request, or nil. Backend errors
+// are only replaced by the stored response after maxAttempts attempts.
+func NewTransport(c *Cache, transport http.RoundTripper, request *http.Request, entry *Entry, maxAttempts int) *Transport {
+	return &Transport{
+		cache:       c,
+		transport:   transport,
+		request:     request,
+		entry:       entry,
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Hit returns true if the response was served from the cache.
+func (t *Transport) Hit() bool {
+	return t.hit
+}
+
+func (t *Transport) RoundTrip(outreq *http.Request) (*http.Response, error) {
+	t.attempts++
+
+	if t.entry != nil && t.entry.HasValidators() && !hasConditionalHeaders(t.request) {
+		t.entry.SetConditionalHeaders(outreq.Header)
+		t.conditional = true
+	}
+
+	res, err := t.transport.RoundTrip(outreq)
+	now := time.Now()
+
+	if err != nil {
+		if t.entry != nil && t.entry.UsableOnError(now) && !t.willRetry(err) {
+			return t.stale(now), nil
+		}
+		return nil, err
+	}
+
+	if t.entry != nil {
+		switch {
+		case res.StatusCode == http.StatusNotModified && t.conditional:
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+
+			t.hit = true
+			return t.cache.Revalidated(t.request, t.entry, res).Response(t.request, now), nil
+		case res.StatusCode >= 500 && t.entry.UsableOnError(now):
+			res.Body.Close()
+			return t.stale(now), nil
+		}
+	}
+
+	if t.request.Method == "GET" && res.Body != nil && res.ContentLength <= t.cache.maxEntrySize {
+		res.Body = &recorder{
+			ReadCloser: res.Body,
+			limit:      t.cache.maxEntrySize,
+			done: func(body []byte) {
+				t.cache.Store(t.request, res, body)
+			},
+		}
+	}
+
+	return res, nil
+}
+
+// willRetry mirrors the retries of the backend round tripper, which only
+// retries dial errors.
+func (t *Transport) willRetry(err error) bool {
+	ne, ok := err.(*net.OpError)
+	return ok && ne.Op == "dial" && t.attempts < t.maxAttempts
+}
+
+func (t *Transport) stale(now time.Time) *http.Response {
+	t.hit = true
+	res := t.entry.Response(t.request, now)
+	res.Header.Add("Warning", `111 - "Revalidation Failed"`)
+	return res
+}
+
+// recorder keeps a copy of the body read, and hands it over when the end of
+// the body is reached unless it exceeded the limit.
+type recorder struct {
+	io.ReadCloser
+	buf      bytes.Buffer
+	limit    int64
+	overflow bool
+	done     func(body []byte)
+}
+
+func (r *recorder) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+
+	if !r.overflow {
+		if int64(r.buf.Len()+n) > r.limit {
+			r.overflow = true
+			r.buf = bytes.Buffer{}
+		} else {
+			r.buf.Write(p[:n])
+		}
+	}
+
+	if err == io.EOF && !r.overflow && r.done != nil {
+		r.done(r.buf.Bytes())
+		r.done = nil
+	}
+
+	return n, err
+}
@@ -0,0 +1,157 @@
+package cache_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/proxy/cache"
+	roundtripperfakes "code.cloudfoundry.org/gorouter/proxy/round_tripper/fakes"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transport", func() {
+	var (
+		c         *cache.Cache
+		transport *roundtripperfakes.FakeRoundTripper
+		req       *http.Request
+		outreq    *http.Request
+		dialError = &net.OpError{
+			Err: errors.New("error"),
+			Op:  "dial",
+		}
+	)
+
+	backendResponse := func(status int, cacheControl, body string) *http.Response {
+		res := &http.Response{
+			StatusCode:    status,
+			Header:        http.Header{},
+			Body:          ioutil.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+		}
+		res.Header.Set("Cache-Control", cacheControl)
+		res.Header.Set("ETag", `"v1"`)
+		return res
+	}
+
+	BeforeEach(func() {
+		c = cache.NewCache(1024, 1024)
+		transport = &roundtripperfakes.FakeRoundTripper{}
+		req = test_util.NewRequest("GET", "foo.example.com", "/", nil)
+		outreq = test_util.NewRequest("GET", "foo.example.com", "/", nil)
+	})
+
+	It("stores the response once its body has been read", func() {
+		transport.RoundTripReturns(backendResponse(http.StatusOK, "max-age=60", "body"), nil)
+
+		t := cache.NewTransport(c, transport, req, nil, 3)
+		res, err := t.RoundTrip(outreq)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(t.Hit()).To(BeFalse())
+		Expect(c.Lookup(req)).To(BeNil())
+
+		body, err := ioutil.ReadAll(res.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(body)).To(Equal("body"))
+
+		entry := c.Lookup(req)
+		Expect(entry).ToNot(BeNil())
+		Expect(string(entry.Body)).To(Equal("body"))
+	})
+
+	It("does not store responses larger than the maximum entry size", func() {
+		transport.RoundTripReturns(backendResponse(http.StatusOK, "max-age=60", strings.Repeat("x", 2048)), nil)
+
+		res, err := cache.NewTransport(c, transport, req, nil, 3).RoundTrip(outreq)
+		Expect(err).ToNot(HaveOccurred())
+		ioutil.ReadAll(res.Body)
+
+		Expect(c.Lookup(req)).To(BeNil())
+	})
+
+	Context("with a stale response", func() {
+		var entry *cache.Entry
+
+		BeforeEach(func() {
+			res := backendResponse(http.StatusOK, "max-age=0, stale-if-error=60", "")
+			res.Header.Set("Content-Type", "text/plain")
+			entry = c.Store(req, res, []byte("stale body"))
+		})
+
+		It("revalidates the response with the backend", func() {
+			notModified := backendResponse(http.StatusNotModified, "max-age=60", "")
+			transport.RoundTripReturns(notModified, nil)
+
+			t := cache.NewTransport(c, transport, req, entry, 3)
+			res, err := t.RoundTrip(outreq)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(transport.RoundTripArgsForCall(0).Header.Get("If-None-Match")).To(Equal(`"v1"`))
+
+			Expect(t.Hit()).To(BeTrue())
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+			Expect(res.Header.Get("Content-Type")).To(Equal("text/plain"))
+			body, _ := ioutil.ReadAll(res.Body)
+			Expect(string(body)).To(Equal("stale body"))
+
+			Expect(c.Lookup(req).Fresh(time.Now())).To(BeTrue())
+		})
+
+		It("leaves the conditional headers of the client alone", func() {
+			req.Header.Set("If-None-Match", `"v0"`)
+			outreq.Header.Set("If-None-Match", `"v0"`)
+			transport.RoundTripReturns(backendResponse(http.StatusNotModified, "max-age=60", ""), nil)
+
+			t := cache.NewTransport(c, transport, req, entry, 3)
+			res, err := t.RoundTrip(outreq)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(transport.RoundTripArgsForCall(0).Header.Get("If-None-Match")).To(Equal(`"v0"`))
+			Expect(res.StatusCode).To(Equal(http.StatusNotModified))
+			Expect(t.Hit()).To(BeFalse())
+		})
+
+		It("serves the stale response when the backend fails", func() {
+			transport.RoundTripReturns(backendResponse(http.StatusBadGateway, "", "error"), nil)
+
+			t := cache.NewTransport(c, transport, req, entry, 3)
+			res, err := t.RoundTrip(outreq)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(t.Hit()).To(BeTrue())
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+			Expect(res.Header.Get("Warning")).To(ContainSubstring("111"))
+		})
+
+		It("serves the stale response once dial errors are no longer retried", func() {
+			transport.RoundTripReturns(nil, dialError)
+
+			t := cache.NewTransport(c, transport, req, entry, 2)
+			_, err := t.RoundTrip(outreq)
+			Expect(err).To(Equal(dialError))
+
+			res, err := t.RoundTrip(outreq)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+			Expect(t.Hit()).To(BeTrue())
+		})
+
+		Context("when the response must be revalidated", func() {
+			BeforeEach(func() {
+				res := backendResponse(http.StatusOK, "max-age=0, must-revalidate", "")
+				entry = c.Store(req, res, []byte("stale body"))
+			})
+
+			It("returns the backend error", func() {
+				transport.RoundTripReturns(backendResponse(http.StatusBadGateway, "", "error"), nil)
+
+				res, err := cache.NewTransport(c, transport, req, entry, 3).RoundTrip(outreq)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(res.StatusCode).To(Equal(http.StatusBadGateway))
+			})
+		})
+	})
+})
@@ -0,0 +1,38 @@
+package proxy
+
+import (
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/access_log/schema"
+	"code.cloudfoundry.org/gorouter/proxy/cache"
+	"code.cloudfoundry.org/gorouter/proxy/handler"
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// serveFromCache answers the request with the fresh response stored for it, if any.
+// Otherwise it returns the transport that consults and fills the cache on the way to
+// the backend, or nil if responses of the route are not cached.
+//
+// Responses are stored by host and URI alone, so they are not cached for routes
+// that split their requests between variants or applications, nor for requests
+// that ask for an instance.
+func (p *proxy) serveFromCache(writer http.ResponseWriter, request *http.Request, routePool, endpointPool *route.Pool,
+	accessLog *schema.AccessLogRecord, transport http.RoundTripper) (*cache.Transport, bool) {
+	if p.cache == nil || !routePool.CacheEnabled() || !cache.Cacheable(request) {
+		return nil, false
+	}
+	if endpointPool != routePool || routePool.Splits() {
+		return nil, false
+	}
+
+	entry := p.cache.Lookup(request)
+	if entry != nil && entry.Fresh(time.Now()) && !cache.RequiresValidation(request) {
+		p.reporter.CaptureCacheHit(request)
+		accessLog.FirstByteAt = time.Now()
+		accessLog.StatusCode = entry.Serve(writer, request, accessLog.FirstByteAt)
+		return nil, true
+	}
+
+	return cache.NewTransport(p.cache, transport, request, entry, handler.MaxRetries), false
+}
@@ -0,0 +1,193 @@
+package proxy_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Caching", func() {
+	var (
+		requests     int32
+		cacheControl string
+		status       int
+	)
+
+	responder := func(x *test_util.HttpConn) {
+		req, err := http.ReadRequest(x.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		atomic.AddInt32(&requests, 1)
+
+		if req.Header.Get("If-None-Match") == `"v1"` && status == http.StatusOK {
+			resp := test_util.NewResponse(http.StatusNotModified)
+			resp.Header.Set("Cache-Control", cacheControl)
+			resp.Header.Set("ETag", `"v1"`)
+			x.WriteResponse(resp)
+			x.Close()
+			return
+		}
+
+		body := "a cacheable body"
+		resp := test_util.NewResponse(status)
+		resp.Header.Set("Cache-Control", cacheControl)
+		resp.Header.Set("ETag", `"v1"`)
+		resp.ContentLength = int64(len(body))
+		resp.Body = ioutil.NopCloser(strings.NewReader(body))
+		x.WriteResponse(resp)
+		x.Close()
+	}
+
+	enableCache := func(e *route.Endpoint) {
+		e.EnableCache = true
+	}
+
+	sendRequest := func(header http.Header) (*http.Response, string) {
+		conn := dialProxy(proxyServer)
+		req := test_util.NewRequest("GET", "cached", "/", nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		conn.WriteRequest(req)
+		return conn.ReadResponse()
+	}
+
+	BeforeEach(func() {
+		conf.Cache.Enabled = true
+		atomic.StoreInt32(&requests, 0)
+		cacheControl = "max-age=60"
+		status = http.StatusOK
+	})
+
+	It("serves fresh responses from the cache", func() {
+		ln := registerHandlerWithOptions(r, "cached", responder, enableCache)
+		defer ln.Close()
+
+		resp, body := sendRequest(nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(body).To(Equal("a cacheable body"))
+		Expect(fakeReporter.CaptureCacheMissCallCount()).To(Equal(1))
+
+		resp, body = sendRequest(nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Age")).ToNot(BeEmpty())
+		Expect(body).To(Equal("a cacheable body"))
+		Expect(fakeReporter.CaptureCacheHitCallCount()).To(Equal(1))
+
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(1)))
+	})
+
+	It("answers conditional requests from the cache", func() {
+		ln := registerHandlerWithOptions(r, "cached", responder, enableCache)
+		defer ln.Close()
+
+		sendRequest(nil)
+
+		resp, _ := sendRequest(http.Header{"If-None-Match": []string{`"v1"`}})
+		Expect(resp.StatusCode).To(Equal(http.StatusNotModified))
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(1)))
+	})
+
+	It("revalidates stale responses with the backend", func() {
+		cacheControl = "no-cache"
+		ln := registerHandlerWithOptions(r, "cached", responder, enableCache)
+		defer ln.Close()
+
+		sendRequest(nil)
+
+		resp, body := sendRequest(nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(body).To(Equal("a cacheable body"))
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+		Expect(fakeReporter.CaptureCacheHitCallCount()).To(Equal(1))
+	})
+
+	It("goes to the backend when the client requires it", func() {
+		ln := registerHandlerWithOptions(r, "cached", responder, enableCache)
+		defer ln.Close()
+
+		sendRequest(nil)
+		sendRequest(http.Header{"Cache-Control": []string{"no-cache"}})
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+	})
+
+	It("serves stale responses when the backend fails and it is allowed", func() {
+		cacheControl = "max-age=0, stale-if-error=60"
+		ln := registerHandlerWithOptions(r, "cached", responder, enableCache)
+		defer ln.Close()
+
+		sendRequest(nil)
+
+		status = http.StatusServiceUnavailable
+		resp, body := sendRequest(nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Warning")).To(ContainSubstring("111"))
+		Expect(body).To(Equal("a cacheable body"))
+	})
+
+	It("does not cache responses of routes that did not opt in", func() {
+		ln := registerHandler(r, "cached", responder)
+		defer ln.Close()
+
+		sendRequest(nil)
+		sendRequest(nil)
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+		Expect(fakeReporter.CaptureCacheMissCallCount()).To(Equal(0))
+	})
+
+	It("does not cache responses of routes with predicates", func() {
+		ln := registerHandlerWithOptions(r, "cached", responder, enableCache)
+		defer ln.Close()
+		variant := registerHandlerWithOptions(r, "cached", responder, func(e *route.Endpoint) {
+			e.EnableCache = true
+			e.Predicates = &route.Predicates{Headers: map[string]string{"X-Canary": "true"}}
+		})
+		defer variant.Close()
+
+		sendRequest(http.Header{"X-Canary": []string{"true"}})
+		sendRequest(nil)
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+		Expect(fakeReporter.CaptureCacheHitCallCount()).To(Equal(0))
+	})
+
+	It("does not cache responses of routes split between applications", func() {
+		ln := registerHandlerWithOptions(r, "cached", responder, func(e *route.Endpoint) {
+			e.EnableCache = true
+			e.ApplicationId = "app-a"
+		})
+		defer ln.Close()
+		canary := registerHandlerWithOptions(r, "cached", responder, func(e *route.Endpoint) {
+			e.EnableCache = true
+			e.ApplicationId = "app-b"
+		})
+		defer canary.Close()
+		r.SetWeights("cached", map[string]int{"app-a": 9, "app-b": 1})
+
+		sendRequest(nil)
+		sendRequest(nil)
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+		Expect(fakeReporter.CaptureCacheHitCallCount()).To(Equal(0))
+	})
+
+	Context("when caching is disabled", func() {
+		BeforeEach(func() {
+			conf.Cache.Enabled = false
+		})
+
+		It("sends every request to the backend", func() {
+			ln := registerHandlerWithOptions(r, "cached", responder, enableCache)
+			defer ln.Close()
+
+			sendRequest(nil)
+			sendRequest(nil)
+			Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+			Expect(p.Cache()).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,197 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"github.com/andybalholm/brotli"
+)
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var encoderPools = map[string]*sync.Pool{
+	config.ENCODING_GZIP: {
+		New: func() interface{} { return gzip.NewWriter(nil) },
+	},
+	config.ENCODING_BROTLI: {
+		New: func() interface{} { return brotli.NewWriter(nil) },
+	},
+}
+
+type compression struct {
+	encodings    []string
+	contentTypes map[string]bool
+	minSize      int64
+}
+
+func newCompression(c config.CompressionConfig) *compression {
+	if !c.Enabled {
+		return nil
+	}
+
+	contentTypes := make(map[string]bool, len(c.ContentTypes))
+	for _, t := range c.ContentTypes {
+		contentTypes[strings.ToLower(t)] = true
+	}
+
+	return &compression{
+		encodings:    c.Encodings,
+		contentTypes: contentTypes,
+		minSize:      c.MinSize,
+	}
+}
+
+// negotiate returns the configured encoding the client prefers, or an empty string.
+func (c *compression) negotiate(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		accepted[coding] = q
+	}
+
+	selected := ""
+	best := 0.0
+	for _, encoding := range c.encodings {
+		q, ok := accepted[encoding]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > best {
+			selected, best = encoding, q
+		}
+	}
+	return selected
+}
+
+// compressible returns true if a response with the given headers may be encoded by the router.
+func (c *compression) compressible(request *http.Request, status int, header http.Header) bool {
+	if request.Method == "HEAD" || status < 200 || status == http.StatusNoContent || status == http.StatusNotModified {
+		return false
+	}
+
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+
+	if strings.Contains(strings.ToLower(header.Get("Cache-Control")), "no-transform") {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || !c.contentTypes[mediaType] {
+		return false
+	}
+
+	if length := header.Get("Content-Length"); length != "" {
+		if size, err := strconv.ParseInt(length, 10, 64); err == nil && size < c.minSize {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compressWriter encodes the response body if the response and the client allow it.
+// The decision is taken when the header is written.
+type compressWriter struct {
+	http.ResponseWriter
+	compression *compression
+	request     *http.Request
+
+	encoding    string
+	encoder     encoder
+	wroteHeader bool
+}
+
+func newCompressWriter(w http.ResponseWriter, c *compression, request *http.Request) *compressWriter {
+	return &compressWriter{
+		ResponseWriter: w,
+		compression:    c,
+		request:        request,
+	}
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	header := w.ResponseWriter.Header()
+	if w.compression.compressible(w.request, status, header) {
+		header.Add("Vary", "Accept-Encoding")
+
+		w.encoding = w.compression.negotiate(w.request.Header.Get("Accept-Encoding"))
+		if w.encoding != "" {
+			header.Set("Content-Encoding", w.encoding)
+			header.Del("Content-Length")
+			if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				header.Set("ETag", "W/"+etag)
+			}
+
+			w.encoder = encoderPools[w.encoding].Get().(encoder)
+			w.encoder.Reset(w.ResponseWriter)
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.encoder != nil {
+		return w.encoder.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends the data encoded so far to the client, so that streaming responses keep working.
+func (w *compressWriter) Flush() {
+	if w.encoder != nil {
+		w.encoder.Flush()
+	}
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close writes the remaining encoded data and returns the encoder to its pool.
+func (w *compressWriter) Close() error {
+	if w.encoder == nil {
+		return nil
+	}
+
+	err := w.encoder.Close()
+	w.encoder.Reset(nil)
+	encoderPools[w.encoding].Put(w.encoder)
+	w.encoder = nil
+	return err
+}
@@ -0,0 +1,232 @@
+package proxy_test
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"github.com/andybalholm/brotli"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Compression", func() {
+	const body = "<html><body>a response body that is worth compressing</body></html>"
+
+	var (
+		contentType     string
+		contentEncoding string
+	)
+
+	responder := func(x *test_util.HttpConn) {
+		_, err := http.ReadRequest(x.Reader)
+		Expect(err).ToNot(HaveOccurred())
+
+		resp := test_util.NewResponse(http.StatusOK)
+		resp.Header.Set("Content-Type", contentType)
+		resp.Header.Set("ETag", `"abc"`)
+		if contentEncoding != "" {
+			resp.Header.Set("Content-Encoding", contentEncoding)
+		}
+		resp.ContentLength = int64(len(body))
+		resp.Body = ioutil.NopCloser(strings.NewReader(body))
+		x.WriteResponse(resp)
+		x.Close()
+	}
+
+	sendRequest := func(acceptEncoding string) (*http.Response, string) {
+		conn := dialProxy(proxyServer)
+		req := test_util.NewRequest("GET", "compressed", "/", nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		conn.WriteRequest(req)
+		return conn.ReadResponse()
+	}
+
+	BeforeEach(func() {
+		conf.Compression.Enabled = true
+		conf.Compression.MinSize = 16
+		contentType = "text/html; charset=utf-8"
+		contentEncoding = ""
+	})
+
+	It("encodes the response with gzip", func() {
+		ln := registerHandler(r, "compressed", responder)
+		defer ln.Close()
+
+		resp, encoded := sendRequest("gzip")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Encoding")).To(Equal("gzip"))
+		Expect(resp.Header.Get("Vary")).To(Equal("Accept-Encoding"))
+		Expect(resp.Header.Get("ETag")).To(Equal(`W/"abc"`))
+		Expect(resp.Header.Get("Content-Length")).ToNot(Equal(strconv.Itoa(len(body))))
+
+		reader, err := gzip.NewReader(strings.NewReader(encoded))
+		Expect(err).ToNot(HaveOccurred())
+		decoded, err := ioutil.ReadAll(reader)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(decoded)).To(Equal(body))
+	})
+
+	It("prefers brotli when the client accepts it", func() {
+		ln := registerHandler(r, "compressed", responder)
+		defer ln.Close()
+
+		resp, encoded := sendRequest("gzip, deflate, br")
+		Expect(resp.Header.Get("Content-Encoding")).To(Equal("br"))
+
+		decoded, err := ioutil.ReadAll(brotli.NewReader(strings.NewReader(encoded)))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(decoded)).To(Equal(body))
+	})
+
+	It("honours the quality values of the client", func() {
+		ln := registerHandler(r, "compressed", responder)
+		defer ln.Close()
+
+		resp, _ := sendRequest("br;q=0.5, gzip")
+		Expect(resp.Header.Get("Content-Encoding")).To(Equal("gzip"))
+	})
+
+	It("does not encode the response when the client does not accept an encoding", func() {
+		ln := registerHandler(r, "compressed", responder)
+		defer ln.Close()
+
+		resp, decoded := sendRequest("identity")
+		Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+		Expect(resp.Header.Get("Vary")).To(Equal("Accept-Encoding"))
+		Expect(decoded).To(Equal(body))
+	})
+
+	It("leaves encoded responses alone", func() {
+		contentEncoding = "deflate"
+		ln := registerHandler(r, "compressed", responder)
+		defer ln.Close()
+
+		resp, decoded := sendRequest("gzip")
+		Expect(resp.Header.Get("Content-Encoding")).To(Equal("deflate"))
+		Expect(decoded).To(Equal(body))
+	})
+
+	It("does not encode content types that are not allowed", func() {
+		contentType = "image/png"
+		ln := registerHandler(r, "compressed", responder)
+		defer ln.Close()
+
+		resp, decoded := sendRequest("gzip")
+		Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+		Expect(resp.Header.Get("Content-Length")).To(Equal(strconv.Itoa(len(body))))
+		Expect(decoded).To(Equal(body))
+	})
+
+	Context("when the response is smaller than the minimum size", func() {
+		BeforeEach(func() {
+			conf.Compression.MinSize = 1024
+		})
+
+		It("does not encode the response", func() {
+			ln := registerHandler(r, "compressed", responder)
+			defer ln.Close()
+
+			resp, decoded := sendRequest("gzip")
+			Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+			Expect(decoded).To(Equal(body))
+		})
+	})
+
+	It("does not encode responses of routes that opted out", func() {
+		ln := registerHandlerWithOptions(r, "compressed", responder, func(e *route.Endpoint) {
+			e.DisableCompression = true
+		})
+		defer ln.Close()
+
+		resp, decoded := sendRequest("gzip")
+		Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+		Expect(decoded).To(Equal(body))
+	})
+
+	It("keeps to the opt-out of the route when the request picks a variant of it", func() {
+		ln := registerHandlerWithOptions(r, "compressed", responder, func(e *route.Endpoint) {
+			e.DisableCompression = true
+		})
+		defer ln.Close()
+		variantLn := registerHandlerWithOptions(r, "compressed", responder, func(e *route.Endpoint) {
+			e.Predicates = &route.Predicates{Headers: map[string]string{"X-Canary": "true"}}
+		})
+		defer variantLn.Close()
+
+		conn := dialProxy(proxyServer)
+		req := test_util.NewRequest("GET", "compressed", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("X-Canary", "true")
+		conn.WriteRequest(req)
+		resp, decoded := conn.ReadResponse()
+		Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+		Expect(decoded).To(Equal(body))
+	})
+
+	Context("when compression is disabled", func() {
+		BeforeEach(func() {
+			conf.Compression.Enabled = false
+		})
+
+		It("does not encode the response", func() {
+			ln := registerHandler(r, "compressed", responder)
+			defer ln.Close()
+
+			resp, decoded := sendRequest("gzip")
+			Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+			Expect(decoded).To(Equal(body))
+		})
+	})
+
+	It("flushes encoded data of streaming responses", func() {
+		release := make(chan struct{})
+		ln := registerHandler(r, "compressed", func(x *test_util.HttpConn) {
+			_, err := http.ReadRequest(x.Reader)
+			Expect(err).ToNot(HaveOccurred())
+
+			x.WriteLines([]string{
+				"HTTP/1.1 200 OK",
+				"Content-Type: text/plain",
+				"Transfer-Encoding: chunked",
+			})
+			x.Writer.WriteString("6\r\nfirst \r\n")
+			x.Writer.Flush()
+
+			<-release
+			x.Writer.WriteString("6\r\nsecond\r\n0\r\n\r\n")
+			x.Writer.Flush()
+			x.Close()
+		})
+		defer ln.Close()
+
+		conn := dialProxy(proxyServer)
+		req := test_util.NewRequest("GET", "compressed", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		conn.WriteRequest(req)
+
+		resp, err := http.ReadResponse(conn.Reader, &http.Request{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.Header.Get("Content-Encoding")).To(Equal("gzip"))
+
+		reader, err := gzip.NewReader(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		first := make([]byte, 6)
+		_, err = io.ReadFull(reader, first)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(first)).To(Equal("first "))
+
+		close(release)
+		rest, err := ioutil.ReadAll(reader)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(rest)).To(Equal("second"))
+	})
+})
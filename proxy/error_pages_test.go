@@ -0,0 +1,111 @@
+package proxy_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Error Pages", func() {
+	var dir string
+
+	writeTemplate := func(name, text string) string {
+		path := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(path, []byte(text), 0644)).To(Succeed())
+		return path
+	}
+
+	sendRequest := func(host, accept string) (*http.Response, string) {
+		conn := dialProxy(proxyServer)
+		req := test_util.NewRequest("GET", host, "/", nil)
+		req.Header.Set("X-Vcap-Request-Id", "some-request-id")
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		conn.WriteRequest(req)
+		return conn.ReadResponse()
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "error-pages")
+		Expect(err).ToNot(HaveOccurred())
+
+		conf.ErrorPages = map[string]config.ErrorPageConfig{
+			"unknown_route": {
+				HTML: writeTemplate("unknown_route.html", `<h1>{{.Status}} {{.StatusText}}</h1><p>{{.Host}} {{.RequestId}}</p>`),
+				JSON: writeTemplate("unknown_route.json", `{"error":{{json .ErrorType}},"host":{{json .Host}},"request_id":{{json .RequestId}}}`),
+			},
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("renders the HTML page for browsers", func() {
+		resp, body := sendRequest("unknown", "text/html,application/xhtml+xml,*/*;q=0.8")
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("text/html; charset=utf-8"))
+		Expect(resp.Header.Get("Vary")).To(Equal("Accept"))
+		Expect(resp.Header.Get("X-Cf-RouterError")).To(Equal("unknown_route"))
+		Expect(body).To(Equal("<h1>404 Not Found</h1><p>unknown some-request-id</p>"))
+	})
+
+	It("escapes request data in the HTML page", func() {
+		conn := dialProxy(proxyServer)
+		req := test_util.NewRequest("GET", "unknown", "/", nil)
+		req.Header.Set("X-Vcap-Request-Id", "<script>")
+		req.Header.Set("Accept", "text/html")
+		conn.WriteRequest(req)
+
+		resp, body := conn.ReadResponse()
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		Expect(body).To(ContainSubstring("&lt;script&gt;"))
+	})
+
+	It("renders the JSON page for API clients", func() {
+		resp, body := sendRequest("unknown", "application/json")
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("application/json"))
+
+		var page map[string]string
+		Expect(json.Unmarshal([]byte(body), &page)).To(Succeed())
+		Expect(page).To(Equal(map[string]string{
+			"error":      "unknown_route",
+			"host":       "unknown",
+			"request_id": "some-request-id",
+		}))
+	})
+
+	It("honours the quality values of the client", func() {
+		resp, _ := sendRequest("unknown", "text/html;q=0.5, application/json")
+		Expect(resp.Header.Get("Content-Type")).To(Equal("application/json"))
+	})
+
+	It("keeps the plain text body for other clients", func() {
+		resp, body := sendRequest("unknown", "*/*")
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		Expect(body).To(Equal("404 Not Found: Requested route ('unknown') does not exist.\n"))
+	})
+
+	It("keeps the plain text body for errors without a page", func() {
+		ln := registerHandler(r, "enfant-terrible", func(conn *test_util.HttpConn) {
+			conn.Close()
+		})
+		defer ln.Close()
+
+		resp, body := sendRequest("enfant-terrible", "text/html")
+		Expect(resp.StatusCode).To(Equal(http.StatusBadGateway))
+		Expect(resp.Header.Get("Vary")).To(BeEmpty())
+		Expect(body).To(Equal("502 Bad Gateway: Registered endpoint failed to handle the request.\n"))
+	})
+})
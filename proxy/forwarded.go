@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/config"
+)
+
+// setForwardedHeaders tells the endpoint about the client and the request as the
+// router received it, with the X-Forwarded headers, the Forwarded header or
+// both. Those received from trusted proxies, or from all clients without any,
+// are kept or appended to.
+func setForwardedHeaders(source *http.Request, target *http.Request, mode string, forceForwardedProtoHttps bool) {
+	proto := "http"
+	if forceForwardedProtoHttps || source.TLS != nil {
+		proto = "https"
+	}
+
+	if mode == config.FORWARDED_RFC7239 {
+		// a nil X-Forwarded-For keeps the reverse proxy from adding one
+		target.Header["X-Forwarded-For"] = nil
+		target.Header.Del("X-Forwarded-Proto")
+		target.Header.Del("X-Forwarded-Host")
+		target.Header.Del("X-Forwarded-Port")
+	} else {
+		if forceForwardedProtoHttps {
+			target.Header.Set("X-Forwarded-Proto", "https")
+		} else if source.Header.Get("X-Forwarded-Proto") == "" {
+			target.Header.Set("X-Forwarded-Proto", proto)
+		}
+		if source.Header.Get("X-Forwarded-Host") == "" {
+			target.Header.Set("X-Forwarded-Host", source.Host)
+		}
+		if source.Header.Get("X-Forwarded-Port") == "" {
+			target.Header.Set("X-Forwarded-Port", forwardedPort(source.Host, target.Header.Get("X-Forwarded-Proto")))
+		}
+	}
+
+	if mode != config.FORWARDED_LEGACY {
+		element := router_http.ForwardedElement{Proto: proto, Host: source.Host}
+		if host, _, err := net.SplitHostPort(source.RemoteAddr); err == nil {
+			if ip := net.ParseIP(host); ip != nil {
+				element.For = router_http.ForwardedNode(ip)
+			}
+		}
+		if addr, ok := source.Context().Value(http.LocalAddrContextKey).(*net.TCPAddr); ok {
+			element.By = router_http.ForwardedNode(addr.IP)
+		}
+
+		forwarded := element.String()
+		if prior, ok := source.Header[router_http.ForwardedHeader]; ok {
+			forwarded = strings.Join(prior, ", ") + ", " + forwarded
+		}
+		target.Header.Set(router_http.ForwardedHeader, forwarded)
+	}
+}
+
+// forwardedPort returns the port of a host, or the default port of the
+// protocol when it has none.
+func forwardedPort(host string, proto string) string {
+	if _, port, err := net.SplitHostPort(host); err == nil {
+		return port
+	}
+	if proto == "https" {
+		return "443"
+	}
+	return "80"
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/config"
+)
+
+const (
+	htmlContentType = "text/html; charset=utf-8"
+	jsonContentType = "application/json"
+)
+
+// ErrorPageData is available to error page templates.
+type ErrorPageData struct {
+	Status     int
+	StatusText string
+	ErrorType  string
+	Message    string
+	Host       string
+	RequestId  string
+}
+
+type errorPage struct {
+	html *htmltemplate.Template
+	json *texttemplate.Template
+}
+
+// ErrorPages renders the templates configured for router errors. A nil
+// ErrorPages renders nothing.
+type ErrorPages map[string]*errorPage
+
+var jsonFuncs = texttemplate.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// NewErrorPages parses the HTML and JSON templates of each error type.
+func NewErrorPages(c map[string]config.ErrorPageConfig) (ErrorPages, error) {
+	if len(c) == 0 {
+		return nil, nil
+	}
+
+	pages := make(ErrorPages, len(c))
+	for errorType, pageConfig := range c {
+		page := &errorPage{}
+
+		if pageConfig.HTML != "" {
+			text, err := ioutil.ReadFile(pageConfig.HTML)
+			if err != nil {
+				return nil, err
+			}
+			page.html, err = htmltemplate.New(errorType).Parse(string(text))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if pageConfig.JSON != "" {
+			text, err := ioutil.ReadFile(pageConfig.JSON)
+			if err != nil {
+				return nil, err
+			}
+			page.json, err = texttemplate.New(errorType).Funcs(jsonFuncs).Parse(string(text))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		pages[errorType] = page
+	}
+
+	return pages, nil
+}
+
+// lookup returns the page of the error type. Backend errors without a page of
+// their own use the page of endpoint failures.
+func (p ErrorPages) lookup(errorType string) (*errorPage, bool) {
+	page, ok := p[errorType]
+	if !ok && IsBackendError(errorType) {
+		page, ok = p[EndpointFailure.Type]
+	}
+	return page, ok
+}
+
+// render returns the content type and body of the page for the error type
+// preferred by the Accept header of the request. The body is nil if no page
+// is configured or the client accepts none of them.
+func (p ErrorPages) render(errorType string, request *http.Request, status int, message string) (string, []byte, error) {
+	page, ok := p.lookup(errorType)
+	if !ok {
+		return "", nil, nil
+	}
+
+	data := ErrorPageData{
+		Status:     status,
+		StatusText: http.StatusText(status),
+		ErrorType:  errorType,
+		Message:    message,
+		Host:       request.Host,
+		RequestId:  request.Header.Get(router_http.VcapRequestIdHeader),
+	}
+
+	var body bytes.Buffer
+	switch page.negotiate(request.Header.Get("Accept")) {
+	case jsonContentType:
+		if err := page.json.Execute(&body, data); err != nil {
+			return "", nil, err
+		}
+		return jsonContentType, body.Bytes(), nil
+	case htmlContentType:
+		if err := page.html.Execute(&body, data); err != nil {
+			return "", nil, err
+		}
+		return htmlContentType, body.Bytes(), nil
+	}
+
+	return "", nil, nil
+}
+
+// negotiate picks the content type of the page with the highest quality in
+// the Accept header. Media ranges matching any type do not select a page, so
+// clients that do not ask for HTML or JSON keep getting plain text.
+func (page *errorPage) negotiate(accept string) string {
+	selected := ""
+	best := 0.0
+
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		mediaRange := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		contentType := ""
+		switch {
+		case page.json != nil && (mediaRange == "application/json" || mediaRange == "application/*"):
+			contentType = jsonContentType
+		case page.html != nil && (mediaRange == "text/html" || mediaRange == "text/*"):
+			contentType = htmlContentType
+		}
+
+		if contentType != "" && q > best {
+			selected, best = contentType, q
+		}
+	}
+
+	return selected
+}
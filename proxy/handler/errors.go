@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Phases of a request to an endpoint that are bound by a timeout.
+const (
+	DialPhase           = "dial"
+	TLSHandshakePhase   = "tls_handshake"
+	ResponseHeaderPhase = "response_header"
+	IdleBodyPhase       = "idle_body"
+)
+
+// TimeoutError is returned when a phase of a request to an endpoint takes
+// longer than its timeout.
+type TimeoutError struct {
+	Phase    string
+	Duration time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timeout of %s exceeded", strings.Replace(e.Phase, "_", " ", -1), e.Duration)
+}
+
+func (e *TimeoutError) Timeout() bool   { return true }
+func (e *TimeoutError) Temporary() bool { return true }
+
+// BackendError is a class of failure to get a response from an endpoint. The
+// type is reported in the X-Cf-RouterError header, the access log and metrics.
+type BackendError struct {
+	Type    string
+	Status  int
+	Message string
+}
+
+var (
+	EndpointFailure = BackendError{
+		Type:    "endpoint_failure",
+		Status:  http.StatusBadGateway,
+		Message: "Registered endpoint failed to handle the request.",
+	}
+	DialRefused = BackendError{
+		Type:    "dial_refused",
+		Status:  http.StatusBadGateway,
+		Message: "Registered endpoint refused the connection.",
+	}
+	DialTimeout = BackendError{
+		Type:    "dial_timeout",
+		Status:  http.StatusGatewayTimeout,
+		Message: "Timed out connecting to the registered endpoint.",
+	}
+	ResponseTimeout = BackendError{
+		Type:    "response_timeout",
+		Status:  http.StatusGatewayTimeout,
+		Message: "Registered endpoint did not respond in time.",
+	}
+	ConnectionReset = BackendError{
+		Type:    "connection_reset",
+		Status:  http.StatusBadGateway,
+		Message: "Registered endpoint reset the connection.",
+	}
+	TLSHandshakeFailure = BackendError{
+		Type:    "tls_handshake_failure",
+		Status:  http.StatusBadGateway,
+		Message: "TLS handshake with the registered endpoint failed.",
+	}
+	TLSHandshakeTimeout = BackendError{
+		Type:    "tls_handshake_timeout",
+		Status:  http.StatusGatewayTimeout,
+		Message: "Timed out in the TLS handshake with the registered endpoint.",
+	}
+	IdleBodyTimeout = BackendError{
+		Type:    "idle_body_timeout",
+		Status:  http.StatusGatewayTimeout,
+		Message: "Registered endpoint stopped sending the response body.",
+	}
+	NoEndpoints = BackendError{
+		Type:    "no_endpoints",
+		Status:  http.StatusServiceUnavailable,
+		Message: "No registered endpoints are available.",
+	}
+)
+
+var BackendErrors = []BackendError{
+	EndpointFailure,
+	DialRefused,
+	DialTimeout,
+	ResponseTimeout,
+	ConnectionReset,
+	TLSHandshakeFailure,
+	TLSHandshakeTimeout,
+	IdleBodyTimeout,
+	NoEndpoints,
+}
+
+// IsBackendError returns true if the error type is one of the BackendErrors.
+func IsBackendError(errorType string) bool {
+	for _, e := range BackendErrors {
+		if e.Type == errorType {
+			return true
+		}
+	}
+	return false
+}
+
+// ClassifyBackendError returns the class of the error returned when sending a
+// request to an endpoint. Errors that fit no other class are endpoint failures.
+func ClassifyBackendError(err error) BackendError {
+	if err == NoEndpointsAvailable {
+		return NoEndpoints
+	}
+
+	var timeoutErr *TimeoutError
+	if errors.As(err, &timeoutErr) {
+		switch timeoutErr.Phase {
+		case DialPhase:
+			return DialTimeout
+		case TLSHandshakePhase:
+			return TLSHandshakeTimeout
+		case IdleBodyPhase:
+			return IdleBodyTimeout
+		}
+		return ResponseTimeout
+	}
+
+	if isTLSError(err) {
+		return TLSHandshakeFailure
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		switch {
+		case opErr.Timeout():
+			return DialTimeout
+		case errors.Is(err, syscall.ECONNREFUSED):
+			return DialRefused
+		}
+		return EndpointFailure
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return ConnectionReset
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ResponseTimeout
+	}
+
+	return EndpointFailure
+}
+
+func isTLSError(err error) bool {
+	var recordHeaderErr tls.RecordHeaderError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certificateInvalidErr x509.CertificateInvalidError
+
+	switch {
+	case errors.As(err, &recordHeaderErr),
+		errors.As(err, &unknownAuthorityErr),
+		errors.As(err, &hostnameErr),
+		errors.As(err, &certificateInvalidErr):
+		return true
+	}
+
+	// alerts and handshake timeouts have no exported type
+	message := err.Error()
+	return strings.Contains(message, "tls: ") || strings.Contains(message, "TLS handshake")
+}
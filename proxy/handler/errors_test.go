@@ -0,0 +1,91 @@
+package handler_test
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/proxy/handler"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ = Describe("ClassifyBackendError", func() {
+	dialError := func(err error) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: err}
+	}
+
+	It("classifies missing endpoints", func() {
+		Expect(handler.ClassifyBackendError(handler.NoEndpointsAvailable)).To(Equal(handler.NoEndpoints))
+	})
+
+	It("classifies refused connections", func() {
+		err := dialError(os.NewSyscallError("connect", syscall.ECONNREFUSED))
+		Expect(handler.ClassifyBackendError(err)).To(Equal(handler.DialRefused))
+	})
+
+	It("classifies dial timeouts", func() {
+		Expect(handler.ClassifyBackendError(dialError(timeoutError{}))).To(Equal(handler.DialTimeout))
+	})
+
+	It("classifies response timeouts", func() {
+		err := &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}
+		Expect(handler.ClassifyBackendError(err)).To(Equal(handler.ResponseTimeout))
+	})
+
+	It("classifies reset connections", func() {
+		err := &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+		Expect(handler.ClassifyBackendError(err)).To(Equal(handler.ConnectionReset))
+	})
+
+	It("classifies TLS handshake failures", func() {
+		err := fmt.Errorf("wrapped: %w", x509.UnknownAuthorityError{})
+		Expect(handler.ClassifyBackendError(err)).To(Equal(handler.TLSHandshakeFailure))
+
+		Expect(handler.ClassifyBackendError(errors.New("remote error: tls: handshake failure"))).To(Equal(handler.TLSHandshakeFailure))
+		Expect(handler.ClassifyBackendError(errors.New("net/http: TLS handshake timeout"))).To(Equal(handler.TLSHandshakeFailure))
+	})
+
+	It("classifies the timeouts of each phase", func() {
+		timeout := func(phase string) error {
+			return fmt.Errorf("wrapped: %w", &handler.TimeoutError{Phase: phase, Duration: time.Second})
+		}
+
+		Expect(handler.ClassifyBackendError(timeout(handler.DialPhase))).To(Equal(handler.DialTimeout))
+		Expect(handler.ClassifyBackendError(timeout(handler.TLSHandshakePhase))).To(Equal(handler.TLSHandshakeTimeout))
+		Expect(handler.ClassifyBackendError(timeout(handler.ResponseHeaderPhase))).To(Equal(handler.ResponseTimeout))
+		Expect(handler.ClassifyBackendError(timeout(handler.IdleBodyPhase))).To(Equal(handler.IdleBodyTimeout))
+	})
+
+	It("names the phase and the timeout in timeout errors", func() {
+		err := &handler.TimeoutError{Phase: handler.ResponseHeaderPhase, Duration: 2 * time.Second}
+		Expect(err.Error()).To(Equal("response header timeout of 2s exceeded"))
+		Expect(err.Timeout()).To(BeTrue())
+	})
+
+	It("classifies other errors as endpoint failures", func() {
+		Expect(handler.ClassifyBackendError(errors.New("EOF"))).To(Equal(handler.EndpointFailure))
+		Expect(handler.ClassifyBackendError(dialError(errors.New("no route to host")))).To(Equal(handler.EndpointFailure))
+	})
+
+	It("gives each error type a distinct header value", func() {
+		types := map[string]bool{}
+		for _, e := range handler.BackendErrors {
+			Expect(types).ToNot(HaveKey(e.Type))
+			types[e.Type] = true
+			Expect(handler.IsBackendError(e.Type)).To(BeTrue())
+		}
+		Expect(handler.IsBackendError("unknown_route")).To(BeFalse())
+	})
+})
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"sync"
+	"time"
+)
+
+// HijackedConns are the client connections hijacked for websocket and TCP
+// upgrades, and those accepted by TCP listeners. The server does not track
+// them, so a drain waits for and closes them here.
+type HijackedConns struct {
+	lock    sync.Mutex
+	tunnels map[*tunnel]struct{}
+	waiters []chan struct{}
+}
+
+func NewHijackedConns() *HijackedConns {
+	return &HijackedConns{
+		tunnels: make(map[*tunnel]struct{}),
+	}
+}
+
+func (c *HijackedConns) add(t *tunnel) {
+	c.lock.Lock()
+	c.tunnels[t] = struct{}{}
+	c.lock.Unlock()
+}
+
+func (c *HijackedConns) remove(t *tunnel) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.tunnels, t)
+	if len(c.tunnels) == 0 {
+		for _, waiter := range c.waiters {
+			close(waiter)
+		}
+		c.waiters = nil
+	}
+}
+
+// Len returns the number of open hijacked connections.
+func (c *HijackedConns) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return len(c.tunnels)
+}
+
+// Wait waits up to the timeout for the hijacked connections to close, and
+// reports whether they did.
+func (c *HijackedConns) Wait(timeout time.Duration) bool {
+	c.lock.Lock()
+	if len(c.tunnels) == 0 {
+		c.lock.Unlock()
+		return true
+	}
+	waiter := make(chan struct{})
+	c.waiters = append(c.waiters, waiter)
+	c.lock.Unlock()
+
+	select {
+	case <-waiter:
+		return true
+	case <-time.After(timeout):
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for i, w := range c.waiters {
+		if w == waiter {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return false
+		}
+	}
+	// the connections closed as the wait timed out
+	return true
+}
+
+// Close closes the hijacked connections once the data read from their
+// endpoints has been forwarded, sending websocket clients a close frame, and
+// returns how many it closed.
+func (c *HijackedConns) Close() int {
+	c.lock.Lock()
+	tunnels := make([]*tunnel, 0, len(c.tunnels))
+	for t := range c.tunnels {
+		tunnels = append(tunnels, t)
+	}
+	c.lock.Unlock()
+
+	for _, t := range tunnels {
+		t.drain()
+	}
+
+	// the tunnels end promptly once their endpoints are closed
+	c.Wait(2 * closeFrameTimeout)
+	return len(tunnels)
+}
@@ -2,9 +2,10 @@ package handler
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
-	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"strconv"
@@ -26,22 +27,24 @@ const (
 var NoEndpointsAvailable = errors.New("No endpoints available")
 
 type RequestHandler struct {
-	logger    lager.Logger
-	reporter  reporter.ProxyReporter
-	logrecord *schema.AccessLogRecord
+	logger     lager.Logger
+	reporter   reporter.ProxyReporter
+	logrecord  *schema.AccessLogRecord
+	errorPages ErrorPages
 
 	request  *http.Request
 	response utils.ProxyResponseWriter
 }
 
-func NewRequestHandler(request *http.Request, response utils.ProxyResponseWriter, r reporter.ProxyReporter, alr *schema.AccessLogRecord, logger lager.Logger) *RequestHandler {
+func NewRequestHandler(request *http.Request, response utils.ProxyResponseWriter, r reporter.ProxyReporter, alr *schema.AccessLogRecord, logger lager.Logger, errorPages ErrorPages) *RequestHandler {
 	requestLogger := setupLogger(request, logger)
 	return &RequestHandler{
-		logger:    requestLogger,
-		reporter:  r,
-		logrecord: alr,
-		request:   request,
-		response:  response,
+		logger:     requestLogger,
+		reporter:   r,
+		logrecord:  alr,
+		errorPages: errorPages,
+		request:    request,
+		response:   response,
 	}
 }
 
@@ -77,12 +80,30 @@ func (h *RequestHandler) HandleUnsupportedProtocol() {
 	// must be hijacked, otherwise no response is sent back
 	conn, buf, err := h.hijack()
 	if err != nil {
-		h.writeStatus(http.StatusBadRequest, "Unsupported protocol")
+		h.writeStatus(http.StatusBadRequest, "unsupported_protocol", "Unsupported protocol")
 		return
 	}
 
 	h.logrecord.StatusCode = http.StatusBadRequest
-	fmt.Fprintf(buf, "HTTP/1.0 400 Bad Request\r\n\r\n")
+	h.logrecord.RouterError = "unsupported_protocol"
+	contentType, page := h.renderErrorPage(http.StatusBadRequest, "unsupported_protocol", "Unsupported protocol")
+	if page != nil {
+		res := &http.Response{
+			StatusCode: http.StatusBadRequest,
+			ProtoMajor: 1,
+			ProtoMinor: 0,
+			Header: http.Header{
+				"Content-Type":     []string{contentType},
+				"X-Cf-Routererror": []string{"unsupported_protocol"},
+			},
+			Body:          ioutil.NopCloser(bytes.NewReader(page)),
+			ContentLength: int64(len(page)),
+			Close:         true,
+		}
+		res.Write(buf)
+	} else {
+		fmt.Fprintf(buf, "HTTP/1.0 400 Bad Request\r\nX-Cf-RouterError: unsupported_protocol\r\n\r\n")
+	}
 	buf.Flush()
 	conn.Close()
 }
@@ -91,128 +112,108 @@ func (h *RequestHandler) HandleMissingRoute() {
 	h.reporter.CaptureBadRequest(h.request)
 	h.logger.Info("unknown-route")
 
-	h.response.Header().Set("X-Cf-RouterError", "unknown_route")
 	message := fmt.Sprintf("Requested route ('%s') does not exist.", h.request.Host)
-	h.writeStatus(http.StatusNotFound, message)
+	h.writeStatus(http.StatusNotFound, "unknown_route", message)
+}
+
+func (h *RequestHandler) HandleIPDenied() {
+	h.reporter.CaptureIPDenied(h.request)
+	h.logger.Info("ip-denied")
+
+	h.writeStatus(http.StatusForbidden, "ip_denied", "Client IP is not allowed.")
 }
 
 func (h *RequestHandler) HandleBadGateway(err error, request *http.Request) {
+	backendError := ClassifyBackendError(err)
 	h.reporter.CaptureBadGateway(request)
+	h.reporter.CaptureBackendError(request, backendError.Type)
 
-	h.response.Header().Set("X-Cf-RouterError", "endpoint_failure")
-	h.writeStatus(http.StatusBadGateway, "Registered endpoint failed to handle the request.")
+	h.writeStatus(backendError.Status, backendError.Type, backendError.Message)
 	h.response.Done()
 }
 
 func (h *RequestHandler) HandleBadSignature(err error) {
 	h.logger.Error("signature-validation-failed", err)
 
-	h.writeStatus(http.StatusBadRequest, "Failed to validate Route Service Signature")
+	h.writeStatus(http.StatusBadRequest, "bad_signature", "Failed to validate Route Service Signature")
 	h.response.Done()
 }
 
 func (h *RequestHandler) HandleRouteServiceFailure(err error) {
 	h.logger.Error("route-service-failed", err)
 
-	h.writeStatus(http.StatusInternalServerError, "Route service request failed.")
+	h.writeStatus(http.StatusInternalServerError, "route_service_failure", "Route service request failed.")
 	h.response.Done()
 }
 
 func (h *RequestHandler) HandleUnsupportedRouteService() {
 	h.logger.Info("route-service-unsupported")
 
-	h.response.Header().Set("X-Cf-RouterError", "route_service_unsupported")
-	h.writeStatus(http.StatusBadGateway, "Support for route services is disabled.")
+	h.writeStatus(http.StatusBadGateway, "route_service_unsupported", "Support for route services is disabled.")
 	h.response.Done()
 }
 
-func (h *RequestHandler) HandleTcpRequest(iter route.EndpointIterator) {
+// HandleTcpRequest tunnels the connection of a TCP upgrade to an endpoint,
+// which is first sent the PROXY protocol header when there is one.
+func (h *RequestHandler) HandleTcpRequest(iter route.EndpointIterator, dialTimeout time.Duration, proxyHeader []byte,
+	hijacked *HijackedConns) {
 	h.logger.Info("handling-tcp-request", lager.Data{"Upgrade": "tcp"})
 
 	h.logrecord.StatusCode = http.StatusSwitchingProtocols
 
-	err := h.serveTcp(iter)
+	err := h.serveTcp(iter, dialTimeout, proxyHeader, hijacked)
 	if err != nil {
 		h.logger.Error("tcp-request-failed", err)
-		h.writeStatus(http.StatusBadRequest, "TCP forwarding to endpoint failed.")
-	}
-}
-
-func (h *RequestHandler) HandleWebSocketRequest(iter route.EndpointIterator) {
-	h.logger.Info("handling-websocket-request", lager.Data{"Upgrade": "websocket"})
-
-	h.logrecord.StatusCode = http.StatusSwitchingProtocols
-
-	err := h.serveWebSocket(iter)
-	if err != nil {
-		h.logger.Error("websocket-request-failed", err)
-		h.writeStatus(http.StatusBadRequest, "WebSocket request to endpoint failed.")
+		h.writeStatus(http.StatusBadRequest, "", "TCP forwarding to endpoint failed.")
 	}
 }
 
-func (h *RequestHandler) writeStatus(code int, message string) {
+func (h *RequestHandler) writeStatus(code int, errorType, message string) {
 	body := fmt.Sprintf("%d %s: %s", code, http.StatusText(code), message)
 
 	h.logger.Info("status", lager.Data{"body": body})
 	h.logrecord.StatusCode = code
 
-	http.Error(h.response, body, code)
+	if errorType != "" {
+		h.logrecord.RouterError = errorType
+		h.response.Header().Set("X-Cf-RouterError", errorType)
+	}
+
+	contentType, page := h.renderErrorPage(code, errorType, message)
+	if page != nil {
+		h.response.Header().Set("Content-Type", contentType)
+		h.response.Header().Set("X-Content-Type-Options", "nosniff")
+		h.response.WriteHeader(code)
+		h.response.Write(page)
+	} else {
+		http.Error(h.response, body, code)
+	}
 	if code > 299 {
 		h.response.Header().Del("Connection")
 	}
 }
 
-func (h *RequestHandler) serveTcp(iter route.EndpointIterator) error {
-	var err error
-	var connection net.Conn
-
-	client, _, err := h.hijack()
-	if err != nil {
-		return err
-	}
-
-	defer func() {
-		client.Close()
-		if connection != nil {
-			connection.Close()
-		}
-	}()
-
-	retry := 0
-	for {
-		endpoint := iter.Next()
-		if endpoint == nil {
-			err = NoEndpointsAvailable
-			h.HandleBadGateway(err, h.request)
-			return err
-		}
-
-		connection, err = net.DialTimeout("tcp", endpoint.CanonicalAddr(), 5*time.Second)
-		if err == nil {
-			break
-		}
-
-		iter.EndpointFailed()
-		h.logger.Error("tcp-connection-failed", err)
-
-		retry++
-		if retry == MaxRetries {
-			return err
-		}
+// renderErrorPage returns the page configured for the error type, or a nil page
+// if there is none the client accepts.
+func (h *RequestHandler) renderErrorPage(code int, errorType, message string) (string, []byte) {
+	if _, ok := h.errorPages.lookup(errorType); ok {
+		h.response.Header().Add("Vary", "Accept")
 	}
 
-	if connection != nil {
-		forwardIO(client, connection)
+	contentType, page, err := h.errorPages.render(errorType, h.request, code, message)
+	if err != nil {
+		h.logger.Error("error-page-failed", err, lager.Data{"error-type": errorType})
+		return "", nil
 	}
-
-	return nil
+	return contentType, page
 }
 
-func (h *RequestHandler) serveWebSocket(iter route.EndpointIterator) error {
+func (h *RequestHandler) serveTcp(iter route.EndpointIterator, dialTimeout time.Duration, proxyHeader []byte,
+	hijacked *HijackedConns) error {
 	var err error
 	var connection net.Conn
 
-	client, _, err := h.hijack()
+	client, buf, err := h.hijack()
 	if err != nil {
 		return err
 	}
@@ -233,14 +234,13 @@ func (h *RequestHandler) serveWebSocket(iter route.EndpointIterator) error {
 			return err
 		}
 
-		connection, err = net.DialTimeout("tcp", endpoint.CanonicalAddr(), 5*time.Second)
+		connection, err = net.DialTimeout("tcp", endpoint.CanonicalAddr(), dialTimeout)
 		if err == nil {
-			h.setupRequest(endpoint)
 			break
 		}
 
 		iter.EndpointFailed()
-		h.logger.Error("websocket-connection-failed", err)
+		h.logger.Error("tcp-connection-failed", err)
 
 		retry++
 		if retry == MaxRetries {
@@ -248,37 +248,32 @@ func (h *RequestHandler) serveWebSocket(iter route.EndpointIterator) error {
 		}
 	}
 
-	if connection != nil {
-		err = h.request.Write(connection)
+	if proxyHeader != nil {
+		_, err = connection.Write(proxyHeader)
 		if err != nil {
 			return err
 		}
-
-		forwardIO(client, connection)
 	}
-	return nil
-}
 
-func (h *RequestHandler) setupRequest(endpoint *route.Endpoint) {
-	h.setRequestURL(endpoint.CanonicalAddr())
-	h.setRequestXForwardedFor()
-	SetRequestXRequestStart(h.request)
-}
+	if connection != nil {
+		t := &tunnel{hijacked: hijacked}
+		t.run(&bufferedConn{Conn: client, reader: buf.Reader}, connection)
+	}
 
-func (h *RequestHandler) setRequestURL(addr string) {
-	h.request.URL.Scheme = "http"
-	h.request.URL.Host = addr
+	return nil
 }
 
-func (h *RequestHandler) setRequestXForwardedFor() {
-	if clientIP, _, err := net.SplitHostPort(h.request.RemoteAddr); err == nil {
+// SetRequestXForwardedFor appends the address of the client to the
+// X-Forwarded-For header of the request.
+func SetRequestXForwardedFor(request *http.Request) {
+	if clientIP, _, err := net.SplitHostPort(request.RemoteAddr); err == nil {
 		// If we aren't the first proxy retain prior
 		// X-Forwarded-For information as a comma+space
 		// separated list and fold multiple headers into one.
-		if prior, ok := h.request.Header["X-Forwarded-For"]; ok {
+		if prior, ok := request.Header["X-Forwarded-For"]; ok {
 			clientIP = strings.Join(prior, ", ") + ", " + clientIP
 		}
-		h.request.Header.Set("X-Forwarded-For", clientIP)
+		request.Header.Set("X-Forwarded-For", clientIP)
 	}
 }
 
@@ -300,18 +295,3 @@ func SetRequestXCfInstanceId(request *http.Request, endpoint *route.Endpoint) {
 func (h *RequestHandler) hijack() (client net.Conn, io *bufio.ReadWriter, err error) {
 	return h.response.Hijack()
 }
-
-func forwardIO(a, b net.Conn) {
-	done := make(chan bool, 2)
-
-	copy := func(dst io.Writer, src io.Reader) {
-		// don't care about errors here
-		io.Copy(dst, src)
-		done <- true
-	}
-
-	go copy(a, b)
-	go copy(b, a)
-
-	<-done
-}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// closeFrameTimeout bounds the time to send a close frame to a websocket client
+// when its connection is drained.
+const closeFrameTimeout = time.Second
+
+// goingAwayFrame is the websocket close frame with status 1001, going away.
+var goingAwayFrame = []byte{0x88, 0x02, 0x03, 0xe9}
+
+// bufferedConn reads the data the client sent before the connection was
+// hijacked first.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *bufferedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(closeWriter); ok {
+		return cw.CloseWrite()
+	}
+	return errors.New("connection cannot be closed for writing")
+}
+
+type closeWriter interface {
+	CloseWrite() error
+}
+
+// tunnel copies the bytes of an upgraded connection in both directions. When
+// the client stops sending, the endpoint is closed for writing and may go on
+// sending; the tunnel ends when the endpoint stops sending. Endpoints that
+// cannot be closed for writing are closed as a whole.
+type tunnel struct {
+	idleTimeout time.Duration
+	maxLifetime time.Duration
+	websocket   bool
+	hijacked    *HijackedConns
+
+	fromClient int64
+	toClient   int64
+	closedBy   string
+
+	client   io.ReadWriteCloser
+	backend  io.ReadWriteCloser
+	frames   frameBoundary
+	draining int32
+	once     sync.Once
+	// writing orders the close frame after the data written to the client
+	writing sync.Mutex
+}
+
+// close closes both connections, or sends a websocket client a close frame
+// first when the tunnel is draining, whichever copier gets here first.
+func (t *tunnel) close(closedBy string) {
+	if atomic.LoadInt32(&t.draining) == 1 {
+		t.goAway()
+		return
+	}
+	t.once.Do(func() {
+		t.closedBy = closedBy
+		t.client.Close()
+		t.backend.Close()
+	})
+}
+
+func (t *tunnel) run(client, backend io.ReadWriteCloser) {
+	t.client = client
+	t.backend = backend
+
+	if t.hijacked != nil {
+		t.hijacked.add(t)
+		defer t.hijacked.remove(t)
+	}
+
+	var idle *time.Timer
+	if t.idleTimeout > 0 {
+		idle = time.AfterFunc(t.idleTimeout, func() { t.close("idle_timeout") })
+		defer idle.Stop()
+	}
+	if t.maxLifetime > 0 {
+		lifetime := time.AfterFunc(t.maxLifetime, func() { t.close("max_lifetime") })
+		defer lifetime.Stop()
+	}
+
+	done := make(chan struct{}, 2)
+	copy := func(dst, src io.ReadWriteCloser, count *int64, fromBackend bool) {
+		defer func() { done <- struct{}{} }()
+
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := src.Read(buf)
+			if n > 0 {
+				if idle != nil {
+					idle.Reset(t.idleTimeout)
+				}
+				if fromBackend {
+					t.writing.Lock()
+				}
+				_, werr := dst.Write(buf[:n])
+				if werr == nil && fromBackend && t.websocket {
+					t.frames.observe(buf[:n])
+				}
+				if fromBackend {
+					t.writing.Unlock()
+				}
+				if werr != nil {
+					t.close("")
+					return
+				}
+				atomic.AddInt64(count, int64(n))
+			}
+			if err == io.EOF && !fromBackend {
+				break
+			}
+			if err != nil {
+				t.close("")
+				return
+			}
+		}
+
+		cw, ok := dst.(closeWriter)
+		if !ok || cw.CloseWrite() != nil {
+			t.close("")
+		}
+	}
+
+	go copy(backend, client, &t.fromClient, false)
+	go copy(client, backend, &t.toClient, true)
+	<-done
+	<-done
+
+	t.close("")
+}
+
+// Tunnel copies the bytes of a connection routed to an endpoint in both
+// directions until the endpoint stops sending or the connection is idle for the
+// timeout, and returns the bytes copied from and to the client. A zero timeout
+// is disabled.
+func Tunnel(client, backend io.ReadWriteCloser, idleTimeout time.Duration, hijacked *HijackedConns) (fromClient, toClient int64) {
+	t := &tunnel{
+		idleTimeout: idleTimeout,
+		hijacked:    hijacked,
+	}
+	t.run(client, backend)
+
+	return t.fromClient, t.toClient
+}
+
+// drain closes the connection to the endpoint, which ends the tunnel once the
+// data read from it has been forwarded.
+func (t *tunnel) drain() {
+	atomic.StoreInt32(&t.draining, 1)
+	t.backend.Close()
+}
+
+// goAway sends a websocket client a close frame, unless the endpoint stopped in
+// the middle of a frame. The deadline is set first so that a write to a stuck
+// client does not hold the frame back.
+func (t *tunnel) goAway() {
+	t.once.Do(func() {
+		t.closedBy = "drain"
+		if t.websocket {
+			if conn, ok := t.client.(net.Conn); ok {
+				conn.SetWriteDeadline(time.Now().Add(closeFrameTimeout))
+			}
+			t.writing.Lock()
+			if t.frames.complete() {
+				t.client.Write(goingAwayFrame)
+			}
+			t.writing.Unlock()
+		}
+		t.client.Close()
+		t.backend.Close()
+	})
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// WebSockets tunnels the connections of websocket upgrades and counts those
+// that are open. A zero timeout is disabled.
+type WebSockets struct {
+	IdleTimeout time.Duration
+	MaxLifetime time.Duration
+
+	active int64
+}
+
+// Active returns the number of tunneled connections.
+func (w *WebSockets) Active() int64 {
+	return atomic.LoadInt64(&w.active)
+}
+
+// HandleWebSocketResponse completes a websocket upgrade with the response of
+// the endpoint. When the endpoint switches protocols the response is written to
+// the hijacked client connection, which is then tunneled to the endpoint until
+// both sides close it or a timeout fires. Any other response is returned to the
+// client as it is.
+func (h *RequestHandler) HandleWebSocketResponse(res *http.Response, websockets *WebSockets, hijacked *HijackedConns) {
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		h.logger.Info("websocket-upgrade-refused", lager.Data{"status": res.StatusCode})
+		h.copyResponse(res)
+		return
+	}
+
+	backend, ok := res.Body.(io.ReadWriteCloser)
+	if !ok || !strings.EqualFold(res.Header.Get("Upgrade"), "websocket") {
+		res.Body.Close()
+		h.logger.Error("websocket-upgrade-failed", errors.New("endpoint did not switch to websocket"))
+		h.writeStatus(http.StatusBadGateway, "", "WebSocket upgrade of endpoint failed.")
+		return
+	}
+	defer backend.Close()
+
+	client, buf, err := h.hijack()
+	if err != nil {
+		h.logger.Error("websocket-request-failed", err)
+		h.writeStatus(http.StatusBadRequest, "", "WebSocket request to endpoint failed.")
+		return
+	}
+	defer client.Close()
+
+	// the header of the response carries the sticky session and trace headers
+	header := h.response.Header()
+	for k, vv := range res.Header {
+		for _, v := range vv {
+			header.Add(k, v)
+		}
+	}
+
+	fmt.Fprintf(buf, "HTTP/1.1 %d %s\r\n", res.StatusCode, http.StatusText(res.StatusCode))
+	header.Write(buf)
+	buf.WriteString("\r\n")
+	err = buf.Flush()
+	if err != nil {
+		h.logger.Error("websocket-response-failed", err)
+		return
+	}
+
+	h.reporter.CaptureActiveWebSockets(atomic.AddInt64(&websockets.active, 1))
+	defer func() {
+		h.reporter.CaptureActiveWebSockets(atomic.AddInt64(&websockets.active, -1))
+	}()
+
+	startedAt := time.Now()
+	t := &tunnel{
+		idleTimeout: websockets.IdleTimeout,
+		maxLifetime: websockets.MaxLifetime,
+		websocket:   true,
+		hijacked:    hijacked,
+	}
+	t.run(&bufferedConn{Conn: client, reader: buf.Reader}, backend)
+
+	// the bytes of the tunnel are logged instead of those of the request and
+	// response, which have none
+	h.logrecord.RequestBytesReceived = int(t.fromClient)
+	h.logrecord.BodyBytesSent = int(t.toClient)
+
+	h.logger.Info("websocket-closed", lager.Data{
+		"bytes-from-client": t.fromClient,
+		"bytes-to-client":   t.toClient,
+		"duration":          time.Since(startedAt).String(),
+		"closed-by":         t.closedBy,
+	})
+}
+
+// copyResponse returns a response that does not switch protocols to the client.
+func (h *RequestHandler) copyResponse(res *http.Response) {
+	defer res.Body.Close()
+
+	header := h.response.Header()
+	for k, vv := range res.Header {
+		for _, v := range vv {
+			header.Add(k, v)
+		}
+	}
+	h.response.WriteHeader(res.StatusCode)
+	io.Copy(h.response, res.Body)
+}
+
+// frameBoundary follows the frames a websocket endpoint sends, to tell whether
+// the data forwarded so far ends with a complete frame.
+type frameBoundary struct {
+	header    []byte
+	remaining uint64
+}
+
+func (f *frameBoundary) observe(p []byte) {
+	for len(p) > 0 {
+		if f.remaining > 0 {
+			n := uint64(len(p))
+			if n > f.remaining {
+				n = f.remaining
+			}
+			f.remaining -= n
+			p = p[n:]
+			continue
+		}
+
+		f.header = append(f.header, p[0])
+		p = p[1:]
+		if size, ok := frameHeaderSize(f.header); ok && len(f.header) == size {
+			f.remaining = framePayloadLength(f.header)
+			f.header = f.header[:0]
+		}
+	}
+}
+
+func (f *frameBoundary) complete() bool {
+	return f.remaining == 0 && len(f.header) == 0
+}
+
+// frameHeaderSize returns the size of a frame header once its first two bytes
+// are known.
+func frameHeaderSize(header []byte) (int, bool) {
+	if len(header) < 2 {
+		return 0, false
+	}
+
+	size := 2
+	switch header[1] & 0x7f {
+	case 126:
+		size += 2
+	case 127:
+		size += 8
+	}
+	if header[1]&0x80 != 0 {
+		size += 4
+	}
+	return size, true
+}
+
+func framePayloadLength(header []byte) uint64 {
+	switch length := header[1] & 0x7f; length {
+	case 126:
+		return uint64(binary.BigEndian.Uint16(header[2:4]))
+	case 127:
+		return binary.BigEndian.Uint64(header[2:10])
+	default:
+		return uint64(length)
+	}
+}
@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/proxy/round_tripper"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/lager"
+)
+
+const CfMirrorHeader = "X-Cf-Mirror"
+
+type bufferedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// mirrorRequest sends a copy of the request to the shadow target of its route, if any.
+// The copy is sent asynchronously and its response is discarded; the request itself is
+// left untouched apart from its body being partially buffered. Requests are not copied
+// while MirrorMaxInFlight copies are in flight, so a slow target cannot pile them up.
+// Applications on the same route are looked for in the pool serving the request.
+func (p *proxy) mirrorRequest(request *http.Request, m *route.Mirror, routePool *route.Pool) {
+	if !m.Sample() {
+		return
+	}
+
+	body, ok := p.bufferBody(request)
+	if !ok {
+		p.reporter.CaptureMirrorSkipped(request)
+		return
+	}
+
+	targetPool := routePool
+	if m.Uri != "" {
+		targetPool = p.registry.Lookup(m.Uri)
+		if targetPool != nil {
+			targetPool = targetPool.Match(request)
+		}
+	}
+
+	// the target may be the pool of the request, whose load balancing the copy must not move
+	var endpoint *route.Endpoint
+	if targetPool != nil {
+		endpoint = targetPool.SampleEndpoint(m.App)
+	}
+	if endpoint == nil {
+		p.logger.Debug("mirror-target-not-found", lager.Data{"uri": m.Uri, "app": m.App})
+		p.reporter.CaptureMirrorSkipped(request)
+		return
+	}
+
+	select {
+	case p.mirrors <- struct{}{}:
+	default:
+		p.logger.Debug("mirror-limit-reached", lager.Data{"endpoint": endpoint.CanonicalAddr()})
+		p.reporter.CaptureMirrorSkipped(request)
+		return
+	}
+
+	shadow := newMirrorRequest(request, body, m, endpoint)
+
+	go func() {
+		defer func() { <-p.mirrors }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), p.mirrorTimeout)
+		defer cancel()
+
+		started := time.Now()
+		res, err := p.transport.RoundTrip(round_tripper.WithEndpoint(shadow.WithContext(ctx), endpoint))
+		if err != nil {
+			p.logger.Debug("mirror-request-failed", lager.Data{"error": err.Error(), "endpoint": endpoint.CanonicalAddr()})
+		} else {
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		p.reporter.CaptureMirrorResponse(endpoint, res, time.Since(started))
+	}()
+}
+
+// bufferBody reads the request body up to the configured limit and puts the
+// buffered bytes back in front of the remainder. It returns false if the body
+// is larger than the limit or could not be read.
+func (p *proxy) bufferBody(request *http.Request) ([]byte, bool) {
+	if request.Body == nil || request.ContentLength == 0 {
+		return nil, true
+	}
+
+	if request.ContentLength > p.mirrorMaxBodySize {
+		return nil, false
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(request.Body, p.mirrorMaxBodySize+1))
+	request.Body = bufferedBody{
+		Reader: io.MultiReader(bytes.NewReader(body), request.Body),
+		Closer: request.Body,
+	}
+
+	if err != nil || int64(len(body)) > p.mirrorMaxBodySize {
+		return nil, false
+	}
+
+	return body, true
+}
+
+func newMirrorRequest(request *http.Request, body []byte, m *route.Mirror, endpoint *route.Endpoint) *http.Request {
+	shadow := new(http.Request)
+	*shadow = *request
+
+	u := *request.URL
+	u.Scheme = "http"
+	u.Host = endpoint.CanonicalAddr()
+	shadow.URL = &u
+
+	shadow.Header = make(http.Header, len(request.Header)+1)
+	for k, v := range request.Header {
+		shadow.Header[k] = append([]string(nil), v...)
+	}
+	shadow.Header.Set(CfMirrorHeader, "true")
+
+	if m.Uri != "" {
+		shadow.Host = strings.SplitN(string(m.Uri), "/", 2)[0]
+	}
+
+	shadow.RequestURI = ""
+	shadow.Close = false
+	shadow.TransferEncoding = nil
+	shadow.ContentLength = int64(len(body))
+	if len(body) > 0 {
+		shadow.Body = ioutil.NopCloser(bytes.NewReader(body))
+	} else {
+		shadow.Body = nil
+	}
+
+	return shadow
+}
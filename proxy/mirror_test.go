@@ -0,0 +1,240 @@
+package proxy_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/proxy"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Mirroring", func() {
+	var (
+		mirrored chan *http.Request
+		bodies   chan string
+		mirror   *route.Mirror
+	)
+
+	shadowHandler := func(x *test_util.HttpConn) {
+		req, err := http.ReadRequest(x.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		body, err := ioutil.ReadAll(req.Body)
+		Expect(err).ToNot(HaveOccurred())
+
+		resp := test_util.NewResponse(http.StatusInternalServerError)
+		x.WriteResponse(resp)
+		x.Close()
+
+		mirrored <- req
+		bodies <- string(body)
+	}
+
+	primaryHandler := func(x *test_util.HttpConn) {
+		req, err := http.ReadRequest(x.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		body, err := ioutil.ReadAll(req.Body)
+		Expect(err).ToNot(HaveOccurred())
+
+		resp := test_util.NewResponse(http.StatusOK)
+		resp.Body = ioutil.NopCloser(strings.NewReader(string(body)))
+		x.WriteResponse(resp)
+		x.Close()
+	}
+
+	BeforeEach(func() {
+		mirrored = make(chan *http.Request, 1)
+		bodies = make(chan string, 1)
+		mirror = &route.Mirror{Uri: "shadow", SampleRate: 1}
+		conf.MirrorMaxBodySize = 16
+	})
+
+	sendRequest := func(body string) (*http.Response, string) {
+		conn := dialProxy(proxyServer)
+		req := test_util.NewRequest("POST", "primary", "/some/path", strings.NewReader(body))
+		req.ContentLength = int64(len(body))
+		conn.WriteRequest(req)
+		return conn.ReadResponse()
+	}
+
+	Context("when the route mirrors to another route", func() {
+		It("sends a copy of the request to the shadow route and returns the primary response", func() {
+			ln := registerHandlerWithOptions(r, "primary", primaryHandler, func(e *route.Endpoint) { e.Mirror = mirror })
+			defer ln.Close()
+			shadowLn := registerHandler(r, "shadow", shadowHandler)
+			defer shadowLn.Close()
+
+			resp, body := sendRequest("hello")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body).To(Equal("hello"))
+
+			var req *http.Request
+			Eventually(mirrored).Should(Receive(&req))
+			Expect(req.Host).To(Equal("shadow"))
+			Expect(req.URL.Path).To(Equal("/some/path"))
+			Expect(req.Header.Get(proxy.CfMirrorHeader)).To(Equal("true"))
+			Expect(<-bodies).To(Equal("hello"))
+
+			Eventually(fakeReporter.CaptureMirrorResponseCallCount).Should(Equal(1))
+			_, res, _ := fakeReporter.CaptureMirrorResponseArgsForCall(0)
+			Expect(res.StatusCode).To(Equal(http.StatusInternalServerError))
+		})
+
+		It("does not mirror requests with bodies over the limit", func() {
+			ln := registerHandlerWithOptions(r, "primary", primaryHandler, func(e *route.Endpoint) { e.Mirror = mirror })
+			defer ln.Close()
+			shadowLn := registerHandler(r, "shadow", shadowHandler)
+			defer shadowLn.Close()
+
+			resp, body := sendRequest("this body is too large to mirror")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body).To(Equal("this body is too large to mirror"))
+
+			Consistently(mirrored).ShouldNot(Receive())
+			Expect(fakeReporter.CaptureMirrorSkippedCallCount()).To(Equal(1))
+		})
+
+		It("reports the request as skipped when the shadow route does not exist", func() {
+			ln := registerHandlerWithOptions(r, "primary", primaryHandler, func(e *route.Endpoint) { e.Mirror = mirror })
+			defer ln.Close()
+
+			resp, _ := sendRequest("hello")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(fakeReporter.CaptureMirrorSkippedCallCount()).To(Equal(1))
+		})
+
+		Context("when the response is served from the cache", func() {
+			BeforeEach(func() {
+				conf.Cache.Enabled = true
+			})
+
+			It("does not mirror the request", func() {
+				cached := func(x *test_util.HttpConn) {
+					_, err := http.ReadRequest(x.Reader)
+					Expect(err).ToNot(HaveOccurred())
+
+					resp := test_util.NewResponse(http.StatusOK)
+					resp.Header.Set("Cache-Control", "max-age=60")
+					x.WriteResponse(resp)
+					x.Close()
+				}
+				ln := registerHandlerWithOptions(r, "primary", cached, func(e *route.Endpoint) {
+					e.Mirror = mirror
+					e.EnableCache = true
+				})
+				defer ln.Close()
+				shadowLn := registerHandler(r, "shadow", shadowHandler)
+				defer shadowLn.Close()
+
+				get := func() {
+					conn := dialProxy(proxyServer)
+					conn.WriteRequest(test_util.NewRequest("GET", "primary", "/", nil))
+					resp, _ := conn.ReadResponse()
+					Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				}
+
+				get()
+				Eventually(mirrored).Should(Receive())
+				<-bodies
+
+				get()
+				Expect(fakeReporter.CaptureCacheHitCallCount()).To(Equal(1))
+				Consistently(mirrored).ShouldNot(Receive())
+			})
+		})
+
+		Context("when the copies in flight reach the limit", func() {
+			BeforeEach(func() {
+				conf.MirrorMaxInFlight = 1
+			})
+
+			It("reports further requests as skipped", func() {
+				release := make(chan struct{})
+				defer close(release)
+
+				ln := registerHandlerWithOptions(r, "primary", primaryHandler, func(e *route.Endpoint) { e.Mirror = mirror })
+				defer ln.Close()
+				shadowLn := registerHandler(r, "shadow", func(x *test_util.HttpConn) {
+					mirrored <- nil
+					<-release
+					x.Close()
+				})
+				defer shadowLn.Close()
+
+				resp, _ := sendRequest("hello")
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				Eventually(mirrored).Should(Receive())
+
+				resp, _ = sendRequest("hello")
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				Expect(fakeReporter.CaptureMirrorSkippedCallCount()).To(Equal(1))
+				Consistently(mirrored).ShouldNot(Receive())
+			})
+		})
+	})
+
+	Context("when the route mirrors to an application", func() {
+		It("sends a copy of the request to an endpoint of the application", func() {
+			mirror = &route.Mirror{App: "shadow-app", SampleRate: 1}
+			ln := registerHandlerWithOptions(r, "primary", primaryHandler, func(e *route.Endpoint) {
+				e.ApplicationId = "primary-app"
+				e.Mirror = mirror
+			})
+			defer ln.Close()
+			r.SetWeights("primary", map[string]int{"primary-app": 1})
+			shadowLn := registerHandlerWithOptions(r, "primary", shadowHandler, func(e *route.Endpoint) {
+				e.ApplicationId = "shadow-app"
+				e.Mirror = mirror
+			})
+			defer shadowLn.Close()
+
+			resp, body := sendRequest("hello")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body).To(Equal("hello"))
+
+			var req *http.Request
+			Eventually(mirrored).Should(Receive(&req))
+			Expect(req.Host).To(Equal("primary"))
+			Expect(<-bodies).To(Equal("hello"))
+		})
+
+		It("does not move the load balancing of the primary application", func() {
+			mirror = &route.Mirror{App: "shadow-app", SampleRate: 1}
+			hits := make(chan int, 4)
+			for i := 0; i < 2; i++ {
+				i := i
+				ln := registerHandlerWithOptions(r, "primary", func(x *test_util.HttpConn) {
+					hits <- i
+					primaryHandler(x)
+				}, func(e *route.Endpoint) {
+					e.ApplicationId = "primary-app"
+					e.Mirror = mirror
+				})
+				defer ln.Close()
+			}
+			r.SetWeights("primary", map[string]int{"primary-app": 1})
+			shadowLn := registerHandlerWithOptions(r, "primary", func(x *test_util.HttpConn) {
+				x.Close()
+			}, func(e *route.Endpoint) {
+				e.ApplicationId = "shadow-app"
+				e.Mirror = mirror
+			})
+			defer shadowLn.Close()
+
+			for i := 0; i < 4; i++ {
+				resp, _ := sendRequest("hello")
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			}
+
+			counts := make([]int, 2)
+			for i := 0; i < 4; i++ {
+				counts[<-hits]++
+			}
+			Expect(counts).To(Equal([]int{2, 2}))
+		})
+	})
+})
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"code.cloudfoundry.org/gorouter/access_log"
@@ -15,8 +16,10 @@ import (
 	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/handlers"
 	"code.cloudfoundry.org/gorouter/metrics/reporter"
+	"code.cloudfoundry.org/gorouter/proxy/cache"
 	"code.cloudfoundry.org/gorouter/proxy/handler"
 	"code.cloudfoundry.org/gorouter/proxy/round_tripper"
+	"code.cloudfoundry.org/gorouter/proxy/tcp"
 	"code.cloudfoundry.org/gorouter/proxy/utils"
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/gorouter/routeservice"
@@ -33,10 +36,16 @@ const (
 type LookupRegistry interface {
 	Lookup(uri route.Uri) *route.Pool
 	LookupWithInstance(uri route.Uri, appId string, appIndex string) *route.Pool
+	LookupTcp(routerGroup string, port uint16) *route.Pool
 }
 
 type Proxy interface {
 	ServeHTTP(responseWriter http.ResponseWriter, request *http.Request)
+	Cache() *cache.Cache
+	Connections() *round_tripper.TransportPool
+	Hijacked() *handler.HijackedConns
+	Tcp() *tcp.Proxy
+	SetIPAccess(c config.IPAccessConfig)
 }
 
 type proxyHandler struct {
@@ -48,6 +57,34 @@ func (p *proxyHandler) ServeHTTP(responseWriter http.ResponseWriter, request *ht
 	p.handlers.ServeHTTP(responseWriter, request)
 }
 
+// Cache returns the response cache of the proxy, or nil if caching is disabled.
+func (p *proxyHandler) Cache() *cache.Cache {
+	return p.proxy.cache
+}
+
+// Connections returns the pool of connections to endpoints, which is evicted
+// by the registry when endpoints are removed.
+func (p *proxyHandler) Connections() *round_tripper.TransportPool {
+	return p.proxy.transport
+}
+
+// Hijacked returns the client connections hijacked for upgrades, which a drain
+// waits for.
+func (p *proxyHandler) Hijacked() *handler.HijackedConns {
+	return p.proxy.hijacked
+}
+
+// Tcp returns the proxy of the connections accepted by TCP listeners.
+func (p *proxyHandler) Tcp() *tcp.Proxy {
+	return p.proxy.tcp
+}
+
+// SetIPAccess replaces the IP allow and deny lists of all routes, so they can be
+// reloaded while requests are served.
+func (p *proxyHandler) SetIPAccess(c config.IPAccessConfig) {
+	p.proxy.setIPAccess(c)
+}
+
 type proxyWriterHandler struct{}
 
 // ServeHTTP wraps the responseWriter in a ProxyResponseWriter
@@ -63,14 +100,31 @@ type proxy struct {
 	registry                 LookupRegistry
 	reporter                 reporter.ProxyReporter
 	accessLogger             access_log.AccessLogger
-	transport                *http.Transport
-	secureCookies            bool
+	transport                *round_tripper.TransportPool
+	timeouts                 route.Timeouts
+	stickySessions           *stickySessions
 	heartbeatOK              *int32
 	routeServiceConfig       *routeservice.RouteServiceConfig
 	extraHeadersToLog        *[]string
 	healthCheckUserAgent     string
 	forceForwardedProtoHttps bool
+	forwardedHeaders         string
 	defaultLoadBalance       string
+	hashKey                  *route.HashKey
+	zone                     string
+	zoneHealthyPercentage    int
+	mirrorMaxBodySize        int64
+	mirrorTimeout            time.Duration
+	mirrors                  chan struct{}
+	compression              *compression
+	cache                    *cache.Cache
+	errorPages               handler.ErrorPages
+	websockets               *handler.WebSockets
+	hijacked                 *handler.HijackedConns
+	tcp                      *tcp.Proxy
+	sendProxyHeader          bool
+	proxyHeaderVersion       int
+	ipAccess                 atomic.Value
 }
 
 func NewProxy(
@@ -84,42 +138,99 @@ func NewProxy(
 	heartbeatOK *int32,
 ) Proxy {
 
+	timeouts := route.Timeouts{
+		Dial:           c.EndpointDialTimeout,
+		TLSHandshake:   c.EndpointTLSHandshakeTimeout,
+		ResponseHeader: c.EndpointResponseHeaderTimeout,
+		IdleBody:       c.EndpointIdleBodyTimeout,
+	}
+	if timeouts.ResponseHeader == 0 {
+		timeouts.ResponseHeader = c.EndpointTimeout
+	}
+	if timeouts.IdleBody == 0 {
+		timeouts.IdleBody = c.EndpointTimeout
+	}
+
+	dialer := &round_tripper.Dialer{
+		Timeouts:  timeouts,
+		TLSConfig: tlsConfig,
+	}
+
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		DialTLSContext:      dialer.DialTLSContext,
+		DisableKeepAlives:   c.DisableKeepAlives,
+		MaxIdleConns:        c.MaxIdleConns,
+		MaxIdleConnsPerHost: c.MaxIdleConnsPerHost,
+		IdleConnTimeout:     c.IdleConnTimeout,
+		DisableCompression:  true,
+	}
+
 	p := &proxy{
-		accessLogger: accessLogger,
-		traceKey:     c.TraceKey,
-		ip:           c.Ip,
-		logger:       logger,
-		registry:     registry,
-		reporter:     reporter,
-		transport: &http.Transport{
-			Dial: func(network, addr string) (net.Conn, error) {
-				conn, err := net.DialTimeout(network, addr, 5*time.Second)
-				if err != nil {
-					return conn, err
-				}
-				if c.EndpointTimeout > 0 {
-					err = conn.SetDeadline(time.Now().Add(c.EndpointTimeout))
-				}
-				return conn, err
-			},
-			DisableKeepAlives:   c.DisableKeepAlives,
-			MaxIdleConns:        c.MaxIdleConns,
-			MaxIdleConnsPerHost: c.MaxIdleConnsPerHost,
-			DisableCompression:  true,
-			TLSClientConfig:     tlsConfig,
-		},
-		secureCookies:            c.SecureCookies,
+		accessLogger:             accessLogger,
+		traceKey:                 c.TraceKey,
+		ip:                       c.Ip,
+		logger:                   logger,
+		registry:                 registry,
+		reporter:                 reporter,
+		transport:                round_tripper.NewTransportPool(transport, reporter.CaptureBackendConnection),
+		timeouts:                 timeouts,
 		heartbeatOK:              heartbeatOK, // 1->true, 0->false
 		routeServiceConfig:       routeServiceConfig,
 		extraHeadersToLog:        &c.ExtraHeadersToLog,
 		healthCheckUserAgent:     c.HealthCheckUserAgent,
 		forceForwardedProtoHttps: c.ForceForwardedProtoHttps,
+		forwardedHeaders:         c.ForwardedHeaders,
 		defaultLoadBalance:       c.LoadBalance,
+		mirrorMaxBodySize:        c.MirrorMaxBodySize,
+		mirrorTimeout:            c.MirrorTimeout,
+		mirrors:                  make(chan struct{}, c.MirrorMaxInFlight),
+		compression:              newCompression(c.Compression),
+		websockets: &handler.WebSockets{
+			IdleTimeout: c.WebSocket.IdleTimeout,
+			MaxLifetime: c.WebSocket.MaxLifetime,
+		},
+		hijacked:           handler.NewHijackedConns(),
+		sendProxyHeader:    c.ProxyProtocol.SendToBackends,
+		proxyHeaderVersion: c.ProxyProtocol.BackendVersion,
+	}
+
+	if c.ZoneAware.Enabled {
+		p.zone = c.Zone
+		p.zoneHealthyPercentage = c.ZoneAware.HealthyPercentage
+	}
+
+	p.setIPAccess(c.IPAccess)
+	p.tcp = tcp.NewProxy(logger, c, registry, reporter, p.hijacked)
+
+	if c.Cache.Enabled {
+		p.cache = cache.NewCache(c.Cache.MaxSize, c.Cache.MaxEntrySize)
 	}
 
+	if c.LoadBalance == config.LOAD_BALANCE_CH {
+		hashKey, err := route.ParseHashKey(c.HashOn)
+		if err != nil {
+			logger.Fatal("hash-on-invalid", err)
+		}
+		p.hashKey = hashKey
+	}
+
+	stickySessions, err := newStickySessions(c)
+	if err != nil {
+		logger.Fatal("sticky-sessions-invalid", err)
+	}
+	p.stickySessions = stickySessions
+
+	errorPages, err := handler.NewErrorPages(c.ErrorPages)
+	if err != nil {
+		logger.Fatal("error-pages-invalid", err)
+	}
+	p.errorPages = errorPages
+
 	n := negroni.New()
 	n.Use(&proxyWriterHandler{})
 	n.Use(handlers.NewAccessLog(accessLogger, &c.ExtraHeadersToLog))
+	n.Use(handlers.NewClientIP(c.TrustedProxyNets, c.TrustedProxyHeader))
 	n.Use(handlers.NewHealthcheck(c.HealthCheckUserAgent, p.heartbeatOK, logger))
 	n.Use(handlers.NewZipkin(c.Tracing.EnableZipkin, &c.ExtraHeadersToLog, logger))
 
@@ -132,6 +243,14 @@ func NewProxy(
 	return handlers
 }
 
+func (p *proxy) setIPAccess(c config.IPAccessConfig) {
+	p.ipAccess.Store(&route.IPAccess{Allow: c.AllowNets, Deny: c.DenyNets})
+}
+
+func (p *proxy) globalIPAccess() *route.IPAccess {
+	return p.ipAccess.Load().(*route.IPAccess)
+}
+
 func hostWithoutPort(req *http.Request) string {
 	host := req.Host
 
@@ -144,33 +263,53 @@ func hostWithoutPort(req *http.Request) string {
 	return host
 }
 
-func (p *proxy) getStickySession(request *http.Request) string {
-	// Try choosing a backend using sticky session
-	if _, err := request.Cookie(StickyCookieKey); err == nil {
-		if sticky, err := request.Cookie(VcapCookieId); err == nil {
-			return sticky.Value
-		}
+// endpoints returns an iterator over the endpoints of the pool serving a
+// request, which balances requests by the hash key of the route, or of the
+// router, or else by the default algorithm. It reports whether the iterator
+// keeps to the zone of the router.
+func (p *proxy) endpoints(routePool, endpointPool *route.Pool, request *http.Request, clientIP net.IP, initial string) (route.EndpointIterator, bool) {
+	hashKey := routePool.HashKey()
+	if hashKey == nil {
+		hashKey = p.hashKey
 	}
-	return ""
+	if hashKey == nil {
+		return endpointPool.ZoneEndpoints(p.defaultLoadBalance, initial, p.zone, p.zoneHealthyPercentage)
+	}
+
+	ip := ""
+	if clientIP != nil {
+		ip = clientIP.String()
+	}
+	return endpointPool.HashEndpoints(hashKey.Of(request, ip), initial, p.zone, p.zoneHealthyPercentage)
 }
 
+// lookup returns the pool of the route of a request, which holds the settings
+// of the whole route.
 func (p *proxy) lookup(request *http.Request) *route.Pool {
 	requestPath := request.URL.EscapedPath()
 
 	uri := route.Uri(hostWithoutPort(request) + requestPath)
+	return p.registry.Lookup(uri)
+}
+
+// endpointPool returns the pool of the endpoints that may serve a request: the
+// instance the request asks for, or else the variant of the route its
+// predicates choose. Variants only choose the endpoints, so that requests
+// cannot pick the settings of their route.
+func (p *proxy) endpointPool(request *http.Request, routePool *route.Pool) *route.Pool {
 	appInstanceHeader := request.Header.Get(router_http.CfAppInstance)
-	if appInstanceHeader != "" {
-		appId, appIndex, err := router_http.ValidateCfAppInstance(appInstanceHeader)
+	if appInstanceHeader == "" {
+		return routePool.Match(request)
+	}
 
-		if err != nil {
-			p.logger.Error("invalid-app-instance-header", err)
-			return nil
-		} else {
-			return p.registry.LookupWithInstance(uri, appId, appIndex)
-		}
+	appId, appIndex, err := router_http.ValidateCfAppInstance(appInstanceHeader)
+	if err != nil {
+		p.logger.Error("invalid-app-instance-header", err)
+		return nil
 	}
 
-	return p.registry.Lookup(uri)
+	uri := route.Uri(hostWithoutPort(request) + request.URL.EscapedPath())
+	return p.registry.LookupWithInstance(uri, appId, appIndex)
 }
 
 func (p *proxy) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
@@ -182,22 +321,48 @@ func (p *proxy) ServeHTTP(responseWriter http.ResponseWriter, request *http.Requ
 	}
 	accessLog := alr.(*schema.AccessLogRecord)
 
-	handler := handler.NewRequestHandler(request, proxyWriter, p.reporter, accessLog, p.logger)
+	handler := handler.NewRequestHandler(request, proxyWriter, p.reporter, accessLog, p.logger, p.errorPages)
 
 	if !isProtocolSupported(request) {
 		handler.HandleUnsupportedProtocol()
 		return
 	}
 
+	clientIP := handlers.ClientIP(request)
+	if !p.globalIPAccess().Permits(clientIP) {
+		handler.HandleIPDenied()
+		return
+	}
+
 	routePool := p.lookup(request)
 	if routePool == nil {
 		handler.HandleMissingRoute()
 		return
 	}
 
-	stickyEndpointId := p.getStickySession(request)
+	if !routePool.IPAccess().Permits(clientIP) {
+		handler.HandleIPDenied()
+		return
+	}
+
+	endpointPool := p.endpointPool(request, routePool)
+	if endpointPool == nil {
+		handler.HandleMissingRoute()
+		return
+	}
+
+	stickyCookieNames := routePool.StickyCookieNames()
+	stickyEndpointId := p.stickySessions.endpointId(request, stickyCookieNames)
+	endpoints, local := p.endpoints(routePool, endpointPool, request, clientIP, stickyEndpointId)
+	if p.zone != "" {
+		accessLog.ZoneSelection = schema.ZoneSpillover
+		if local {
+			accessLog.ZoneSelection = schema.ZoneLocal
+		}
+	}
+
 	iter := &wrappedIterator{
-		nested: routePool.Endpoints(p.defaultLoadBalance, stickyEndpointId),
+		nested: endpoints,
 
 		afterNext: func(endpoint *route.Endpoint) {
 			if endpoint != nil {
@@ -207,16 +372,14 @@ func (p *proxy) ServeHTTP(responseWriter http.ResponseWriter, request *http.Requ
 		},
 	}
 
-	if isTcpUpgrade(request) {
-		handler.HandleTcpRequest(iter)
-		return
-	}
+	timeouts := routePool.Timeouts().Merge(p.timeouts)
 
-	if isWebSocketUpgrade(request) {
-		handler.HandleWebSocketRequest(iter)
+	if isTcpUpgrade(request) {
+		handler.HandleTcpRequest(iter, timeouts.Dial, p.proxyHeader(request), p.hijacked)
 		return
 	}
 
+	upgrade := isWebSocketUpgrade(request)
 	backend := true
 
 	routeServiceUrl := routePool.RouteServiceUrl()
@@ -259,7 +422,39 @@ func (p *proxy) ServeHTTP(responseWriter http.ResponseWriter, request *http.Requ
 		}
 	}
 
+	var writer http.ResponseWriter = proxyWriter
+	if p.compression != nil && !routePool.DisableCompression() && !upgrade {
+		compressWriter := newCompressWriter(proxyWriter, p.compression, request)
+		defer compressWriter.Close()
+		writer = compressWriter
+	}
+
+	var transport http.RoundTripper = p.timeoutTransport(request, timeouts, accessLog, handler.Logger())
+
+	var cacheTransport *cache.Transport
+	if backend && !upgrade {
+		var served bool
+		cacheTransport, served = p.serveFromCache(writer, request, routePool, endpointPool, accessLog, transport)
+		if served {
+			return
+		}
+		if cacheTransport != nil {
+			transport = cacheTransport
+		}
+
+		// only requests that go to the backend are mirrored
+		p.mirrorRequest(request, routePool.Mirror(), endpointPool)
+	}
+
 	after := func(rsp *http.Response, endpoint *route.Endpoint, err error) {
+		if cacheTransport != nil {
+			if cacheTransport.Hit() {
+				p.reporter.CaptureCacheHit(request)
+			} else {
+				p.reporter.CaptureCacheMiss(request)
+			}
+		}
+
 		if endpoint == nil {
 			handler.HandleBadGateway(err, request)
 			return
@@ -288,7 +483,7 @@ func (p *proxy) ServeHTTP(responseWriter http.ResponseWriter, request *http.Requ
 		}
 
 		if endpoint.PrivateInstanceId != "" {
-			setupStickySession(responseWriter, rsp, endpoint, stickyEndpointId, p.secureCookies, routePool.ContextPath())
+			p.stickySessions.setCookie(responseWriter, rsp, endpoint, stickyEndpointId, routePool.ContextPath(), stickyCookieNames)
 		}
 
 		// if Content-Type not in response, nil out to suppress Go's auto-detect
@@ -297,19 +492,37 @@ func (p *proxy) ServeHTTP(responseWriter http.ResponseWriter, request *http.Requ
 		}
 	}
 
-	roundTripper := round_tripper.NewProxyRoundTripper(backend,
-		dropsonde.InstrumentedRoundTripper(p.transport), iter, handler.Logger(), after)
+	roundTripper := round_tripper.NewProxyRoundTripper(backend, transport, iter, handler.Logger(), after)
+
+	if upgrade {
+		p.serveWebSocket(handler, roundTripper, request, routeServiceArgs)
+		return
+	}
+
+	newReverseProxy(roundTripper, request, routeServiceArgs, p.routeServiceConfig, p.forwardedHeaders, p.forceForwardedProtoHttps).ServeHTTP(writer, request)
+}
 
-	newReverseProxy(roundTripper, request, routeServiceArgs, p.routeServiceConfig, p.forceForwardedProtoHttps).ServeHTTP(proxyWriter, request)
+// timeoutTransport applies the timeouts of the route to the requests sent to its
+// endpoints. The client only sees a truncated response when the idle body
+// timeout fires, so it is reported here.
+func (p *proxy) timeoutTransport(request *http.Request, timeouts route.Timeouts, accessLog *schema.AccessLogRecord, logger lager.Logger) http.RoundTripper {
+	transport := dropsonde.InstrumentedRoundTripper(p.transport)
+
+	return round_tripper.NewTimeoutRoundTripper(transport, timeouts, func(err *handler.TimeoutError) {
+		logger.Error("idle-body-timeout", err)
+		accessLog.RouterError = handler.IdleBodyTimeout.Type
+		p.reporter.CaptureBackendError(request, handler.IdleBodyTimeout.Type)
+	})
 }
 
 func newReverseProxy(proxyTransport http.RoundTripper, req *http.Request,
 	routeServiceArgs routeservice.RouteServiceRequest,
 	routeServiceConfig *routeservice.RouteServiceConfig,
+	forwardedHeaders string,
 	forceForwardedProtoHttps bool) http.Handler {
 	rproxy := &httputil.ReverseProxy{
 		Director: func(request *http.Request) {
-			setupProxyRequest(req, request, forceForwardedProtoHttps)
+			setupProxyRequest(req, request, forwardedHeaders, forceForwardedProtoHttps)
 			handleRouteServiceIntegration(request, routeServiceArgs, routeServiceConfig)
 		},
 		Transport:     proxyTransport,
@@ -341,16 +554,8 @@ func handleRouteServiceIntegration(
 	}
 }
 
-func setupProxyRequest(source *http.Request, target *http.Request, forceForwardedProtoHttps bool) {
-	if forceForwardedProtoHttps {
-		target.Header.Set("X-Forwarded-Proto", "https")
-	} else if source.Header.Get("X-Forwarded-Proto") == "" {
-		scheme := "http"
-		if source.TLS != nil {
-			scheme = "https"
-		}
-		target.Header.Set("X-Forwarded-Proto", scheme)
-	}
+func setupProxyRequest(source *http.Request, target *http.Request, forwardedHeaders string, forceForwardedProtoHttps bool) {
+	setForwardedHeaders(source, target, forwardedHeaders, forceForwardedProtoHttps)
 
 	target.URL.Scheme = "http"
 	target.URL.Host = source.Host
@@ -384,48 +589,6 @@ func (i *wrappedIterator) PostRequest(e *route.Endpoint) {
 	i.nested.PostRequest(e)
 }
 
-func setupStickySession(responseWriter http.ResponseWriter, response *http.Response,
-	endpoint *route.Endpoint,
-	originalEndpointId string,
-	secureCookies bool,
-	path string) {
-	secure := false
-	maxAge := 0
-
-	// did the endpoint change?
-	sticky := originalEndpointId != "" && originalEndpointId != endpoint.PrivateInstanceId
-
-	for _, v := range response.Cookies() {
-		if v.Name == StickyCookieKey {
-			sticky = true
-			if v.MaxAge < 0 {
-				maxAge = v.MaxAge
-			}
-			secure = v.Secure
-			break
-		}
-	}
-
-	if sticky {
-		// right now secure attribute would as equal to the JSESSION ID cookie (if present),
-		// but override if set to true in config
-		if secureCookies {
-			secure = true
-		}
-
-		cookie := &http.Cookie{
-			Name:     VcapCookieId,
-			Value:    endpoint.PrivateInstanceId,
-			Path:     path,
-			MaxAge:   maxAge,
-			HttpOnly: true,
-			Secure:   secure,
-		}
-
-		http.SetCookie(responseWriter, cookie)
-	}
-}
-
 func forwardingToRouteService(rsUrl, sigHeader string) bool {
 	return sigHeader == "" && rsUrl != ""
 }
@@ -19,6 +19,7 @@ import (
 	"time"
 
 	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/registry"
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/gorouter/test_util"
@@ -331,6 +332,52 @@ var _ = Describe("Proxy", func() {
 		Expect(body).To(Equal("404 Not Found: Requested route ('abcdefghijklmnopqrstuvwxyz.0123456789-ABCDEFGHIJKLMNOPQRSTUVW.XYZ') does not exist.\n"))
 	})
 
+	It("responds to an endpoint refusing connections with 502", func() {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		ln.Close()
+
+		registerAddr(r, "refusing", "", ln.Addr(), "", "", "")
+
+		conn := dialProxy(proxyServer)
+
+		req := test_util.NewRequest("GET", "refusing", "/", nil)
+		conn.WriteRequest(req)
+
+		resp, body := conn.ReadResponse()
+		Expect(resp.StatusCode).To(Equal(http.StatusBadGateway))
+		Expect(resp.Header.Get("X-Cf-RouterError")).To(Equal("dial_refused"))
+		Expect(body).To(Equal("502 Bad Gateway: Registered endpoint refused the connection.\n"))
+
+		Expect(fakeReporter.CaptureBackendErrorCallCount()).To(Equal(1))
+		_, errorType := fakeReporter.CaptureBackendErrorArgsForCall(0)
+		Expect(errorType).To(Equal("dial_refused"))
+	})
+
+	It("counts the connections to endpoints", func() {
+		ln := registerHandler(r, "counted", func(conn *test_util.HttpConn) {
+			conn.CheckLine("GET / HTTP/1.1")
+			resp := test_util.NewResponse(http.StatusOK)
+			conn.WriteResponse(resp)
+			conn.Close()
+		})
+		defer ln.Close()
+
+		conn := dialProxy(proxyServer)
+		conn.WriteRequest(test_util.NewRequest("GET", "counted", "/", nil))
+		resp, _ := conn.ReadResponse()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		Expect(fakeReporter.CaptureBackendConnectionCallCount()).To(Equal(1))
+		_, reused := fakeReporter.CaptureBackendConnectionArgsForCall(0)
+		Expect(reused).To(BeFalse())
+
+		stats := p.Connections().Stats()
+		Expect(stats).To(HaveLen(1))
+		Expect(stats[0].Address).To(Equal(ln.Addr().String()))
+		Expect(stats[0].New).To(BeEquivalentTo(1))
+	})
+
 	It("responds to misbehaving host with 502", func() {
 		ln := registerHandler(r, "enfant-terrible", func(conn *test_util.HttpConn) {
 			conn.Close()
@@ -348,6 +395,51 @@ var _ = Describe("Proxy", func() {
 		Expect(body).To(Equal("502 Bad Gateway: Registered endpoint failed to handle the request.\n"))
 	})
 
+	It("routes requests matching the predicates of an endpoint to it", func() {
+		ln := registerHandler(r, "canary", func(conn *test_util.HttpConn) {
+			conn.ReadRequest()
+			resp := test_util.NewResponse(http.StatusOK)
+			resp.Header.Set("X-Backend", "default")
+			conn.WriteResponse(resp)
+			conn.Close()
+		})
+		defer ln.Close()
+
+		canaryLn, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer canaryLn.Close()
+		go runBackendInstance(canaryLn, func(conn *test_util.HttpConn) {
+			conn.ReadRequest()
+			resp := test_util.NewResponse(http.StatusOK)
+			resp.Header.Set("X-Backend", "canary")
+			conn.WriteResponse(resp)
+			conn.Close()
+		})
+
+		host, portStr, err := net.SplitHostPort(canaryLn.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		port, err := strconv.Atoi(portStr)
+		Expect(err).NotTo(HaveOccurred())
+		canary := route.NewEndpoint("", host, uint16(port), "", "", nil, -1, "", models.ModificationTag{})
+		canary.Predicates = &route.Predicates{Headers: map[string]string{"X-Canary": "true"}}
+		r.Register(route.Uri("canary"), canary)
+
+		conn := dialProxy(proxyServer)
+		req := test_util.NewRequest("GET", "canary", "/", nil)
+		req.Header.Set("X-Canary", "true")
+		conn.WriteRequest(req)
+		resp, _ := conn.ReadResponse()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("X-Backend")).To(Equal("canary"))
+
+		conn = dialProxy(proxyServer)
+		req = test_util.NewRequest("GET", "canary", "/", nil)
+		conn.WriteRequest(req)
+		resp, _ = conn.ReadResponse()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("X-Backend")).To(Equal("default"))
+	})
+
 	It("trace headers added on correct TraceKey", func() {
 		ln := registerHandler(r, "trace-test", func(conn *test_util.HttpConn) {
 			_, err := http.ReadRequest(conn.Reader)
@@ -488,6 +580,330 @@ var _ = Describe("Proxy", func() {
 		conn.ReadResponse()
 	})
 
+	Context("with trusted proxies", func() {
+		BeforeEach(func() {
+			_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
+			conf.TrustedProxyNets = []*net.IPNet{trusted}
+		})
+
+		It("replaces the X-Forwarded-For of untrusted clients", func() {
+			done := make(chan string)
+
+			ln := registerHandler(r, "app", func(conn *test_util.HttpConn) {
+				req, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+
+				resp := test_util.NewResponse(http.StatusOK)
+				conn.WriteResponse(resp)
+				conn.Close()
+
+				done <- req.Header.Get("X-Forwarded-For")
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+
+			req := test_util.NewRequest("GET", "app", "/", nil)
+			req.Header.Add("X-Forwarded-For", "1.2.3.4")
+			conn.WriteRequest(req)
+
+			Eventually(done).Should(Receive(Equal("127.0.0.1")))
+
+			conn.ReadResponse()
+		})
+
+		Context("when the request comes from a trusted proxy", func() {
+			BeforeEach(func() {
+				_, trusted, _ := net.ParseCIDR("127.0.0.0/8")
+				conf.TrustedProxyNets = append(conf.TrustedProxyNets, trusted)
+			})
+
+			It("appends to its X-Forwarded-For", func() {
+				done := make(chan string)
+
+				ln := registerHandler(r, "app", func(conn *test_util.HttpConn) {
+					req, err := http.ReadRequest(conn.Reader)
+					Expect(err).NotTo(HaveOccurred())
+
+					resp := test_util.NewResponse(http.StatusOK)
+					conn.WriteResponse(resp)
+					conn.Close()
+
+					done <- req.Header.Get("X-Forwarded-For")
+				})
+				defer ln.Close()
+
+				conn := dialProxy(proxyServer)
+
+				req := test_util.NewRequest("GET", "app", "/", nil)
+				req.Header.Add("X-Forwarded-For", "1.2.3.4")
+				conn.WriteRequest(req)
+
+				Eventually(done).Should(Receive(Equal("1.2.3.4, 127.0.0.1")))
+
+				conn.ReadResponse()
+			})
+
+			Context("when the router sends the Forwarded header as well", func() {
+				BeforeEach(func() {
+					conf.ForwardedHeaders = config.FORWARDED_BOTH
+				})
+
+				It("does not pass on a Forwarded header the trusted proxy did not add to", func() {
+					done := make(chan http.Header)
+
+					ln := registerHandler(r, "app", func(conn *test_util.HttpConn) {
+						req, err := http.ReadRequest(conn.Reader)
+						Expect(err).NotTo(HaveOccurred())
+
+						resp := test_util.NewResponse(http.StatusOK)
+						conn.WriteResponse(resp)
+						conn.Close()
+
+						done <- req.Header
+					})
+					defer ln.Close()
+
+					conn := dialProxy(proxyServer)
+
+					req := test_util.NewRequest("GET", "app", "/", nil)
+					req.Header.Add("X-Forwarded-For", "1.2.3.4")
+					req.Header.Add("Forwarded", "for=10.0.0.1")
+					conn.WriteRequest(req)
+
+					var header http.Header
+					Eventually(done).Should(Receive(&header))
+					Expect(header.Get("Forwarded")).To(Equal("for=127.0.0.1;proto=http;host=app;by=127.0.0.1"))
+					Expect(header.Get("X-Forwarded-For")).To(Equal("1.2.3.4, 127.0.0.1"))
+
+					conn.ReadResponse()
+				})
+			})
+		})
+	})
+
+	Context("with ip access lists", func() {
+		okHandler := func(conn *test_util.HttpConn) {
+			_, err := http.ReadRequest(conn.Reader)
+			Expect(err).NotTo(HaveOccurred())
+
+			resp := test_util.NewResponse(http.StatusOK)
+			conn.WriteResponse(resp)
+			conn.Close()
+		}
+
+		get := func(host string) *http.Response {
+			conn := dialProxy(proxyServer)
+
+			req := test_util.NewRequest("GET", host, "/", nil)
+			conn.WriteRequest(req)
+
+			resp, _ := conn.ReadResponse()
+			return resp
+		}
+
+		Context("when the client is denied for all routes", func() {
+			BeforeEach(func() {
+				_, denied, _ := net.ParseCIDR("127.0.0.0/8")
+				conf.IPAccess.DenyNets = []*net.IPNet{denied}
+			})
+
+			It("responds with 403 before looking up the route", func() {
+				resp := get("unknown")
+				Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+				Expect(resp.Header.Get("X-Cf-RouterError")).To(Equal("ip_denied"))
+				Expect(fakeReporter.CaptureIPDeniedCallCount()).To(Equal(1))
+			})
+
+			It("permits the client once the lists are reloaded", func() {
+				ln := registerHandler(r, "app", okHandler)
+				defer ln.Close()
+
+				p.SetIPAccess(config.IPAccessConfig{})
+
+				resp := get("app")
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			})
+		})
+
+		Context("when a route only allows other clients", func() {
+			var ln net.Listener
+
+			JustBeforeEach(func() {
+				_, allowed, _ := net.ParseCIDR("10.0.0.0/8")
+				ln = registerHandlerWithOptions(r, "app", okHandler, func(e *route.Endpoint) {
+					e.IPAccess = &route.IPAccess{Allow: []*net.IPNet{allowed}}
+				})
+			})
+
+			AfterEach(func() {
+				ln.Close()
+			})
+
+			It("responds with 403", func() {
+				resp := get("app")
+				Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+				Expect(resp.Header.Get("X-Cf-RouterError")).To(Equal("ip_denied"))
+			})
+
+			It("does not restrict other routes", func() {
+				other := registerHandler(r, "other-app", okHandler)
+				defer other.Close()
+
+				resp := get("other-app")
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			})
+
+			It("responds with 403 when other endpoints of the route have no lists", func() {
+				other := registerHandler(r, "app", okHandler)
+				defer other.Close()
+
+				for i := 0; i < 4; i++ {
+					resp := get("app")
+					Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+				}
+			})
+
+			It("responds with 403 when the request picks a variant without lists", func() {
+				variant := registerHandlerWithOptions(r, "app", okHandler, func(e *route.Endpoint) {
+					e.Predicates = &route.Predicates{Headers: map[string]string{"X-Canary": "true"}}
+				})
+				defer variant.Close()
+
+				conn := dialProxy(proxyServer)
+				req := test_util.NewRequest("GET", "app", "/", nil)
+				req.Header.Set("X-Canary", "true")
+				conn.WriteRequest(req)
+
+				resp, _ := conn.ReadResponse()
+				Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+				Expect(resp.Header.Get("X-Cf-RouterError")).To(Equal("ip_denied"))
+			})
+		})
+	})
+
+	Context("with consistent hashing", func() {
+		var (
+			ln, ln2 net.Listener
+			served  chan string
+		)
+
+		handlerNamed := func(name string) connHandler {
+			return func(conn *test_util.HttpConn) {
+				_, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+
+				resp := test_util.NewResponse(http.StatusOK)
+				conn.WriteResponse(resp)
+				conn.Close()
+
+				served <- name
+			}
+		}
+
+		get := func(userId string) string {
+			conn := dialProxy(proxyServer)
+
+			req := test_util.NewRequest("GET", "app", "/", nil)
+			req.Header.Set("X-User-Id", userId)
+			conn.WriteRequest(req)
+
+			var name string
+			Eventually(served).Should(Receive(&name))
+			conn.ReadResponse()
+			return name
+		}
+
+		JustBeforeEach(func() {
+			served = make(chan string, 1)
+			hashOn := func(e *route.Endpoint) {
+				e.HashKey = &route.HashKey{Header: "X-User-Id"}
+			}
+			ln = registerHandlerWithOptions(r, "app", handlerNamed("a"), hashOn)
+			ln2 = registerHandlerWithOptions(r, "app", handlerNamed("b"), hashOn)
+		})
+
+		AfterEach(func() {
+			ln.Close()
+			ln2.Close()
+		})
+
+		It("sends the requests with the same key of the route to the same endpoint", func() {
+			names := make(map[string]bool)
+			for i := 0; i < 20; i++ {
+				userId := fmt.Sprintf("user-%d", i)
+				name := get(userId)
+				names[name] = true
+
+				for j := 0; j < 3; j++ {
+					Expect(get(userId)).To(Equal(name))
+				}
+			}
+			Expect(names).To(HaveLen(2))
+		})
+	})
+
+	Context("with zone aware routing", func() {
+		var (
+			ln, ln2 net.Listener
+			served  chan string
+		)
+
+		handlerNamed := func(name string) connHandler {
+			return func(conn *test_util.HttpConn) {
+				_, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+
+				resp := test_util.NewResponse(http.StatusOK)
+				conn.WriteResponse(resp)
+				conn.Close()
+
+				served <- name
+			}
+		}
+
+		inZone := func(zone string) func(*route.Endpoint) {
+			return func(e *route.Endpoint) {
+				e.Tags = map[string]string{"zone": zone}
+			}
+		}
+
+		BeforeEach(func() {
+			conf.Zone = "z1"
+			conf.ZoneAware = config.ZoneAwareConfig{Enabled: true, HealthyPercentage: 50}
+		})
+
+		JustBeforeEach(func() {
+			served = make(chan string, 1)
+			ln = registerHandlerWithOptions(r, "app", handlerNamed("local"), inZone("z1"))
+			ln2 = registerHandlerWithOptions(r, "app", handlerNamed("remote"), inZone("z2"))
+		})
+
+		AfterEach(func() {
+			ln.Close()
+			ln2.Close()
+		})
+
+		It("sends the requests to the endpoints in the zone of the router and logs it", func() {
+			for i := 0; i < 10; i++ {
+				conn := dialProxy(proxyServer)
+				conn.WriteRequest(test_util.NewRequest("GET", "app", "/", nil))
+
+				var name string
+				Eventually(served).Should(Receive(&name))
+				Expect(name).To(Equal("local"))
+				conn.ReadResponse()
+			}
+
+			var payload []byte
+			Eventually(func() int {
+				accessLogFile.Read(&payload)
+				return len(payload)
+			}).ShouldNot(BeZero())
+			Expect(string(payload)).To(ContainSubstring(`zone_selection:"local"`))
+		})
+	})
+
 	It("X-Request-Start is appended", func() {
 		done := make(chan string)
 
@@ -658,6 +1074,89 @@ var _ = Describe("Proxy", func() {
 		})
 	})
 
+	Context("forwarded headers", func() {
+		forwardedHeaders := func(req *http.Request) http.Header {
+			done := make(chan http.Header)
+
+			ln := registerHandler(r, "app", func(conn *test_util.HttpConn) {
+				req, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+
+				resp := test_util.NewResponse(http.StatusOK)
+				conn.WriteResponse(resp)
+				conn.Close()
+
+				done <- req.Header
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+			conn.WriteRequest(req)
+
+			var header http.Header
+			Eventually(done).Should(Receive(&header))
+			conn.ReadResponse()
+			return header
+		}
+
+		It("adds X-Forwarded-Host and X-Forwarded-Port", func() {
+			header := forwardedHeaders(test_util.NewRequest("GET", "app", "/", nil))
+			Expect(header.Get("X-Forwarded-Host")).To(Equal("app"))
+			Expect(header.Get("X-Forwarded-Port")).To(Equal("80"))
+			Expect(header["Forwarded"]).To(BeEmpty())
+		})
+
+		It("does not overwrite X-Forwarded-Host and X-Forwarded-Port if present", func() {
+			req := test_util.NewRequest("GET", "app", "/", nil)
+			req.Header.Set("X-Forwarded-Host", "example.com")
+			req.Header.Set("X-Forwarded-Port", "8443")
+
+			header := forwardedHeaders(req)
+			Expect(header.Get("X-Forwarded-Host")).To(Equal("example.com"))
+			Expect(header.Get("X-Forwarded-Port")).To(Equal("8443"))
+		})
+
+		Context("when both are sent", func() {
+			BeforeEach(func() {
+				conf.ForwardedHeaders = config.FORWARDED_BOTH
+			})
+
+			It("adds the Forwarded header as well as the X-Forwarded headers", func() {
+				header := forwardedHeaders(test_util.NewRequest("GET", "app", "/", nil))
+				Expect(header.Get("Forwarded")).To(Equal("for=127.0.0.1;proto=http;host=app;by=127.0.0.1"))
+				Expect(header.Get("X-Forwarded-For")).To(Equal("127.0.0.1"))
+				Expect(header.Get("X-Forwarded-Proto")).To(Equal("http"))
+			})
+
+			It("appends to the Forwarded header if present", func() {
+				req := test_util.NewRequest("GET", "app", "/", nil)
+				req.Header.Set("Forwarded", "for=1.2.3.4")
+
+				header := forwardedHeaders(req)
+				Expect(header.Get("Forwarded")).To(Equal("for=1.2.3.4, for=127.0.0.1;proto=http;host=app;by=127.0.0.1"))
+			})
+		})
+
+		Context("when only the Forwarded header is sent", func() {
+			BeforeEach(func() {
+				conf.ForwardedHeaders = config.FORWARDED_RFC7239
+				conf.ForceForwardedProtoHttps = true
+			})
+
+			It("does not send the X-Forwarded headers", func() {
+				req := test_util.NewRequest("GET", "app", "/", nil)
+				req.Header.Set("X-Forwarded-For", "1.2.3.4")
+
+				header := forwardedHeaders(req)
+				Expect(header.Get("Forwarded")).To(Equal("for=127.0.0.1;proto=https;host=app;by=127.0.0.1"))
+				Expect(header["X-Forwarded-For"]).To(BeEmpty())
+				Expect(header["X-Forwarded-Proto"]).To(BeEmpty())
+				Expect(header["X-Forwarded-Host"]).To(BeEmpty())
+				Expect(header["X-Forwarded-Port"]).To(BeEmpty())
+			})
+		})
+	})
+
 	It("emits HTTP startstop events", func() {
 		ln := registerHandlerWithInstanceId(r, "app", "", func(conn *test_util.HttpConn) {
 		}, "fake-instance-id")
@@ -857,6 +1356,194 @@ var _ = Describe("Proxy", func() {
 		conn.Close()
 	})
 
+	It("forwards the end of the client's data to the WebSocket endpoint", func() {
+		ln := registerHandler(r, "ws-half-close", func(conn *test_util.HttpConn) {
+			_, err := http.ReadRequest(conn.Reader)
+			Expect(err).NotTo(HaveOccurred())
+
+			resp := test_util.NewResponse(http.StatusSwitchingProtocols)
+			resp.Header.Set("Upgrade", "websocket")
+			resp.Header.Set("Connection", "Upgrade")
+			conn.WriteResponse(resp)
+
+			data, err := ioutil.ReadAll(conn.Reader)
+			Expect(err).NotTo(HaveOccurred())
+			conn.WriteLine("received " + strings.TrimSpace(string(data)))
+			conn.Close()
+		})
+		defer ln.Close()
+
+		conn := dialProxy(proxyServer)
+
+		req := test_util.NewRequest("GET", "ws-half-close", "/chat", nil)
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Connection", "Upgrade")
+		conn.WriteRequest(req)
+
+		resp, _ := conn.ReadResponse()
+		Expect(resp.StatusCode).To(Equal(http.StatusSwitchingProtocols))
+
+		conn.WriteLine("hello from client")
+		conn.Conn.(*net.TCPConn).CloseWrite()
+
+		conn.CheckLine("received hello from client")
+		conn.Close()
+	})
+
+	It("returns the response of a WebSocket endpoint refusing the upgrade", func() {
+		ln := registerHandler(r, "ws-refused", func(conn *test_util.HttpConn) {
+			_, err := http.ReadRequest(conn.Reader)
+			Expect(err).NotTo(HaveOccurred())
+
+			resp := test_util.NewResponse(http.StatusForbidden)
+			resp.Body = ioutil.NopCloser(strings.NewReader("go away"))
+			resp.ContentLength = 7
+			conn.WriteResponse(resp)
+			conn.Close()
+		})
+		defer ln.Close()
+
+		conn := dialProxy(proxyServer)
+
+		req := test_util.NewRequest("GET", "ws-refused", "/chat", nil)
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Connection", "Upgrade")
+		conn.WriteRequest(req)
+
+		resp, body := conn.ReadResponse()
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(body).To(Equal("go away"))
+		conn.Close()
+	})
+
+	Context("when WebSocket connections have an idle timeout", func() {
+		BeforeEach(func() {
+			conf.WebSocket.IdleTimeout = 100 * time.Millisecond
+		})
+
+		It("closes idle connections and counts the active ones", func() {
+			ln := registerHandler(r, "ws-idle", func(conn *test_util.HttpConn) {
+				_, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+
+				resp := test_util.NewResponse(http.StatusSwitchingProtocols)
+				resp.Header.Set("Upgrade", "websocket")
+				resp.Header.Set("Connection", "Upgrade")
+				conn.WriteResponse(resp)
+
+				conn.CheckLine("hello from client")
+				conn.WriteLine("hello from server")
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+
+			req := test_util.NewRequest("GET", "ws-idle", "/chat", nil)
+			req.Header.Set("Upgrade", "websocket")
+			req.Header.Set("Connection", "Upgrade")
+			conn.WriteRequest(req)
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusSwitchingProtocols))
+
+			conn.WriteLine("hello from client")
+			conn.CheckLine("hello from server")
+			Expect(fakeReporter.CaptureActiveWebSocketsArgsForCall(0)).To(Equal(int64(1)))
+
+			_, err := conn.Reader.ReadString('\n')
+			Expect(err).To(Equal(io.EOF))
+
+			Eventually(fakeReporter.CaptureActiveWebSocketsCallCount).Should(Equal(2))
+			Expect(fakeReporter.CaptureActiveWebSocketsArgsForCall(1)).To(Equal(int64(0)))
+		})
+	})
+
+	Context("when hijacked connections are closed", func() {
+		var ln net.Listener
+
+		upgrade := func(host string, frame []byte) *test_util.HttpConn {
+			ln = registerHandler(r, host, func(conn *test_util.HttpConn) {
+				_, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+
+				resp := test_util.NewResponse(http.StatusSwitchingProtocols)
+				resp.Header.Set("Upgrade", "websocket")
+				resp.Header.Set("Connection", "Upgrade")
+				conn.WriteResponse(resp)
+
+				conn.Write(frame)
+				ioutil.ReadAll(conn.Reader)
+				conn.Close()
+			})
+
+			conn := dialProxy(proxyServer)
+
+			req := test_util.NewRequest("GET", host, "/chat", nil)
+			req.Header.Set("Upgrade", "websocket")
+			req.Header.Set("Connection", "Upgrade")
+			conn.WriteRequest(req)
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusSwitchingProtocols))
+			return conn
+		}
+
+		AfterEach(func() {
+			ln.Close()
+		})
+
+		It("sends WebSocket clients a close frame after the last complete frame", func() {
+			conn := upgrade("ws-drain", []byte{0x81, 0x05, 'h', 'e', 'l', 'l', 'o'})
+			Eventually(p.Hijacked().Len).Should(Equal(1))
+
+			Expect(p.Hijacked().Close()).To(Equal(1))
+			Expect(p.Hijacked().Len()).To(BeZero())
+
+			data, err := ioutil.ReadAll(conn.Reader)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(Equal([]byte{0x81, 0x05, 'h', 'e', 'l', 'l', 'o', 0x88, 0x02, 0x03, 0xe9}))
+		})
+
+		It("sends a close frame while the client is sending", func() {
+			conn := upgrade("ws-drain-sending", []byte{0x81, 0x05, 'h', 'e', 'l', 'l', 'o'})
+			Eventually(p.Hijacked().Len).Should(Equal(1))
+
+			go func() {
+				defer GinkgoRecover()
+				for {
+					if _, err := conn.Write([]byte{0x81, 0x00}); err != nil {
+						return
+					}
+				}
+			}()
+
+			Expect(p.Hijacked().Close()).To(Equal(1))
+
+			data, _ := ioutil.ReadAll(conn.Reader)
+			Expect(data).To(Equal([]byte{0x81, 0x05, 'h', 'e', 'l', 'l', 'o', 0x88, 0x02, 0x03, 0xe9}))
+		})
+
+		It("does not send a close frame in the middle of a frame", func() {
+			conn := upgrade("ws-drain-partial", []byte{0x81, 0x05, 'h', 'e'})
+			Eventually(p.Hijacked().Len).Should(Equal(1))
+
+			Expect(p.Hijacked().Close()).To(Equal(1))
+
+			data, err := ioutil.ReadAll(conn.Reader)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(Equal([]byte{0x81, 0x05, 'h', 'e'}))
+		})
+
+		It("stops waiting once the connections are closed", func() {
+			conn := upgrade("ws-drain-wait", []byte{0x81, 0x00})
+			Eventually(p.Hijacked().Len).Should(Equal(1))
+			Expect(p.Hijacked().Wait(10 * time.Millisecond)).To(BeFalse())
+
+			conn.Close()
+			Expect(p.Hijacked().Wait(time.Second)).To(BeTrue())
+		})
+	})
+
 	It("upgrades a Tcp request", func() {
 		ln := registerHandler(r, "tcp-handler", func(conn *test_util.HttpConn) {
 			conn.WriteLine("hello")
@@ -882,6 +1569,65 @@ var _ = Describe("Proxy", func() {
 		conn.Close()
 	})
 
+	Context("when the PROXY protocol is sent to endpoints", func() {
+		BeforeEach(func() {
+			conf.ProxyProtocol.SendToBackends = true
+		})
+
+		It("sends the header ahead of a WebSocket upgrade", func() {
+			headers := make(chan string, 1)
+
+			ln := registerHandler(r, "ws-proxy-protocol", func(conn *test_util.HttpConn) {
+				header, _ := conn.Reader.ReadString('\n')
+				headers <- header
+
+				_, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+
+				resp := test_util.NewResponse(http.StatusSwitchingProtocols)
+				resp.Header.Set("Upgrade", "websocket")
+				resp.Header.Set("Connection", "Upgrade")
+				conn.WriteResponse(resp)
+				conn.Close()
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+
+			req := test_util.NewRequest("GET", "ws-proxy-protocol", "/chat", nil)
+			req.Header.Set("Upgrade", "websocket")
+			req.Header.Set("Connection", "Upgrade")
+			conn.WriteRequest(req)
+
+			Eventually(headers).Should(Receive(HavePrefix("PROXY TCP4 127.0.0.1 127.0.0.1 ")))
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusSwitchingProtocols))
+			conn.Close()
+		})
+
+		It("sends the header ahead of a Tcp upgrade", func() {
+			headers := make(chan string, 1)
+
+			ln := registerHandler(r, "tcp-proxy-protocol", func(conn *test_util.HttpConn) {
+				header, _ := conn.Reader.ReadString('\n')
+				headers <- header
+				conn.Close()
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+
+			req := test_util.NewRequest("GET", "tcp-proxy-protocol", "/chat", nil)
+			req.Header.Set("Upgrade", "tcp")
+			req.Header.Set("Connection", "Upgrade")
+			conn.WriteRequest(req)
+
+			Eventually(headers).Should(Receive(HavePrefix("PROXY TCP4 127.0.0.1 127.0.0.1 ")))
+			conn.Close()
+		})
+	})
+
 	It("transfers chunked encodings", func() {
 		ln := registerHandler(r, "chunk", func(conn *test_util.HttpConn) {
 			r, w := io.Pipe()
@@ -1006,10 +1752,62 @@ var _ = Describe("Proxy", func() {
 
 		resp, _ := readResponse(conn)
 
-		Expect(resp.StatusCode).To(Equal(http.StatusBadGateway))
+		Expect(resp.StatusCode).To(Equal(http.StatusGatewayTimeout))
+		Expect(resp.Header.Get("X-Cf-RouterError")).To(Equal("response_timeout"))
 		Expect(time.Since(started)).To(BeNumerically("<", time.Duration(800*time.Millisecond)))
 	})
 
+	It("uses the response header timeout of the route", func() {
+		ln := registerHandlerWithOptions(r, "report-app", func(conn *test_util.HttpConn) {
+			_, err := http.ReadRequest(conn.Reader)
+			Expect(err).NotTo(HaveOccurred())
+
+			time.Sleep(1 * time.Second)
+			resp := test_util.NewResponse(http.StatusOK)
+			conn.WriteResponse(resp)
+			conn.Close()
+		}, func(e *route.Endpoint) {
+			e.Timeouts = &route.Timeouts{ResponseHeader: 2 * time.Second}
+		})
+		defer ln.Close()
+
+		conn := dialProxy(proxyServer)
+
+		req := test_util.NewRequest("GET", "report-app", "/", nil)
+		conn.WriteRequest(req)
+
+		resp, _ := conn.ReadResponse()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	})
+
+	It("reports idle body timeouts", func() {
+		ln := registerHandlerWithOptions(r, "stalling-app", func(conn *test_util.HttpConn) {
+			_, err := http.ReadRequest(conn.Reader)
+			Expect(err).NotTo(HaveOccurred())
+
+			conn.WriteLines([]string{
+				"HTTP/1.1 200 OK",
+				"Content-Length: 20",
+				"",
+				"partial",
+			})
+			time.Sleep(1 * time.Second)
+			conn.Close()
+		}, func(e *route.Endpoint) {
+			e.Timeouts = &route.Timeouts{IdleBody: 100 * time.Millisecond}
+		})
+		defer ln.Close()
+
+		conn := dialProxy(proxyServer)
+
+		req := test_util.NewRequest("GET", "stalling-app", "/", nil)
+		conn.WriteRequest(req)
+
+		Eventually(fakeReporter.CaptureBackendErrorCallCount).Should(Equal(1))
+		_, errorType := fakeReporter.CaptureBackendErrorArgsForCall(0)
+		Expect(errorType).To(Equal("idle_body_timeout"))
+	})
+
 	It("proxy detects closed client connection", func() {
 		serverResult := make(chan error)
 		ln := registerHandler(r, "slow-app", func(conn *test_util.HttpConn) {
@@ -1361,6 +2159,7 @@ var _ = Describe("Proxy", func() {
 				Expect(string(payload)).To(ContainSubstring(`response_time:`))
 				responseTime := parseResponseTimeFromLog(string(payload))
 				Expect(responseTime).To(BeNumerically(">", 0))
+				Expect(string(payload)).To(ContainSubstring(`"GET /chat HTTP/1.1" 101 19 19`))
 
 				conn.Close()
 			})
@@ -1378,7 +2177,7 @@ var _ = Describe("Proxy", func() {
 			}
 		}
 
-		It("responds with a 502 BadGateway", func() {
+		It("responds with a 503 ServiceUnavailable", func() {
 			ln := registerHandler(r, "nil-endpoint", func(conn *test_util.HttpConn) {
 				conn.CheckLine("GET / HTTP/1.1")
 				resp := test_util.NewResponse(http.StatusOK)
@@ -1399,7 +2198,8 @@ var _ = Describe("Proxy", func() {
 			res, _ := conn.ReadResponse()
 			log.SetOutput(os.Stderr)
 			Expect(buf).NotTo(ContainSubstring("multiple response.WriteHeader calls"))
-			Expect(res.StatusCode).To(Equal(http.StatusBadGateway))
+			Expect(res.StatusCode).To(Equal(http.StatusServiceUnavailable))
+			Expect(res.Header.Get("X-Cf-RouterError")).To(Equal("no_endpoints"))
 		})
 
 		It("does not capture routing response", func() {
@@ -1418,7 +2218,7 @@ var _ = Describe("Proxy", func() {
 			conn.WriteRequest(req)
 
 			res, _ := conn.ReadResponse()
-			Expect(res.StatusCode).To(Equal(http.StatusBadGateway))
+			Expect(res.StatusCode).To(Equal(http.StatusServiceUnavailable))
 			Expect(fakeReporter.CaptureBadGatewayCallCount()).To(Equal(1))
 			Expect(fakeReporter.CaptureRoutingResponseCallCount()).To(Equal(0))
 		})
@@ -1466,6 +2266,25 @@ func registerHandlerWithAppId(reg *registry.RouteRegistry, path string, routeSer
 	return ln
 }
 
+func registerHandlerWithOptions(reg *registry.RouteRegistry, path string, handler connHandler, configure func(*route.Endpoint)) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+
+	go runBackendInstance(ln, handler)
+
+	host, portStr, err := net.SplitHostPort(ln.Addr().String())
+	Expect(err).NotTo(HaveOccurred())
+
+	port, err := strconv.Atoi(portStr)
+	Expect(err).NotTo(HaveOccurred())
+
+	endpoint := route.NewEndpoint("", host, uint16(port), "", "2", nil, -1, "", models.ModificationTag{})
+	configure(endpoint)
+	reg.Register(route.Uri(path), endpoint)
+
+	return ln
+}
+
 func runBackendInstance(ln net.Listener, handler connHandler) {
 	var tempDelay time.Duration // how long to sleep on accept failure
 	for {
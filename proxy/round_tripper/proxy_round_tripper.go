@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"time"
 
 	"code.cloudfoundry.org/gorouter/proxy/handler"
 	"code.cloudfoundry.org/gorouter/route"
@@ -62,7 +63,12 @@ func (rt *BackendRoundTripper) RoundTrip(request *http.Request) (*http.Response,
 		// increment connection stats
 		rt.iter.PreRequest(endpoint)
 
-		res, err = rt.transport.RoundTrip(request)
+		started := time.Now()
+		res, err = rt.transport.RoundTrip(WithEndpoint(request, endpoint))
+		if err == nil {
+			// feeds the latency aware load balancing with this endpoint alone
+			endpoint.Stats.Latency.Observe(time.Since(started))
+		}
 
 		// decrement connection stats
 		rt.iter.PostRequest(endpoint)
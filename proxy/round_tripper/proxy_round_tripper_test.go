@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net"
 	"net/http"
+	"time"
 
 	"code.cloudfoundry.org/gorouter/proxy/handler"
 	"code.cloudfoundry.org/gorouter/proxy/round_tripper"
@@ -50,7 +51,8 @@ var _ = Describe("ProxyRoundTripper", func() {
 		Context("backend", func() {
 			BeforeEach(func() {
 				endpoint := &route.Endpoint{
-					Tags: map[string]string{},
+					Tags:  map[string]string{},
+					Stats: route.NewStats(),
 				}
 
 				endpointIterator.NextReturns(endpoint)
@@ -110,6 +112,39 @@ var _ = Describe("ProxyRoundTripper", func() {
 					Expect(endpointIterator.NextCallCount()).To(Equal(2))
 				})
 			})
+
+			Context("when the request is retried on another endpoint", func() {
+				var failed, served *route.Endpoint
+
+				BeforeEach(func() {
+					failed = &route.Endpoint{Tags: map[string]string{}, Stats: route.NewStats()}
+					served = &route.Endpoint{Tags: map[string]string{}, Stats: route.NewStats()}
+					endpointIterator.NextStub = func() *route.Endpoint {
+						if endpointIterator.NextCallCount() == 1 {
+							return failed
+						}
+						return served
+					}
+
+					transport.RoundTripStub = func(req *http.Request) (*http.Response, error) {
+						if transport.RoundTripCallCount() == 1 {
+							time.Sleep(100 * time.Millisecond)
+							return nil, dialError
+						}
+						time.Sleep(10 * time.Millisecond)
+						return &http.Response{StatusCode: http.StatusOK}, nil
+					}
+				})
+
+				It("feeds the latency of the successful round trip alone to its endpoint", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(failed.Stats.Latency.Value()).To(BeZero())
+					Expect(served.Stats.Latency.Value()).To(BeNumerically(">=", 10*time.Millisecond))
+					Expect(served.Stats.Latency.Value()).To(BeNumerically("<", 100*time.Millisecond))
+				})
+			})
 		})
 
 		Context("route service", func() {
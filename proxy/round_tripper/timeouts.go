@@ -0,0 +1,208 @@
+package round_tripper
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/proxy/handler"
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+type timeoutsKey struct{}
+
+func timeoutsFromContext(ctx context.Context, defaults route.Timeouts) route.Timeouts {
+	if timeouts, ok := ctx.Value(timeoutsKey{}).(route.Timeouts); ok {
+		return timeouts
+	}
+	return defaults
+}
+
+type dialProxyHeaderKey struct{}
+
+// Dialer connects to endpoints with the dial and TLS handshake timeouts of the
+// route of the request, or its own timeouts for requests without a route. The
+// PROXY protocol header of a request is written as soon as it connects.
+type Dialer struct {
+	Timeouts  route.Timeouts
+	TLSConfig *tls.Config
+}
+
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	timeouts := timeoutsFromContext(ctx, d.Timeouts)
+
+	dialer := &net.Dialer{Timeout: timeouts.Dial}
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if header, ok := ctx.Value(dialProxyHeaderKey{}).([]byte); ok {
+		_, err = conn.Write(header)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (d *Dialer) DialTLSContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	timeouts := timeoutsFromContext(ctx, d.Timeouts)
+
+	conn, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{}
+	if d.TLSConfig != nil {
+		config = d.TLSConfig.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if timeouts.TLSHandshake > 0 {
+		conn.SetDeadline(time.Now().Add(timeouts.TLSHandshake))
+	}
+
+	err = tlsConn.Handshake()
+	if err != nil {
+		conn.Close()
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return nil, &handler.TimeoutError{Phase: handler.TLSHandshakePhase, Duration: timeouts.TLSHandshake}
+		}
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+// TimeoutRoundTripper applies the timeouts of a route to each request sent to
+// its endpoints. The response header timeout runs until the response header
+// arrives, including the time to connect. The idle body timeout only runs while
+// the response body is read and no data has arrived, so slow clients do not
+// exhaust it.
+type TimeoutRoundTripper struct {
+	transport http.RoundTripper
+	timeouts  route.Timeouts
+	onTimeout func(err *handler.TimeoutError)
+}
+
+// NewTimeoutRoundTripper returns a round tripper applying the timeouts to the
+// requests it sends. onTimeout is called when the idle body timeout fires, as
+// the error is only seen by the reader of the body.
+func NewTimeoutRoundTripper(transport http.RoundTripper, timeouts route.Timeouts, onTimeout func(err *handler.TimeoutError)) *TimeoutRoundTripper {
+	return &TimeoutRoundTripper{
+		transport: transport,
+		timeouts:  timeouts,
+		onTimeout: onTimeout,
+	}
+}
+
+func (rt *TimeoutRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(context.WithValue(request.Context(), timeoutsKey{}, rt.timeouts))
+
+	var expired int32
+	var timer *time.Timer
+	if rt.timeouts.ResponseHeader > 0 {
+		timer = time.AfterFunc(rt.timeouts.ResponseHeader, func() {
+			atomic.StoreInt32(&expired, 1)
+			cancel()
+		})
+	}
+
+	res, err := rt.transport.RoundTrip(request.WithContext(ctx))
+	if timer != nil {
+		timer.Stop()
+	}
+
+	if atomic.LoadInt32(&expired) == 1 {
+		if res != nil {
+			res.Body.Close()
+		}
+		cancel()
+		return nil, &handler.TimeoutError{Phase: handler.ResponseHeaderPhase, Duration: rt.timeouts.ResponseHeader}
+	}
+
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// the connection of an upgrade is tunneled with timeouts of its own
+	if isUpgrade(res) {
+		res.Body = &upgradedBody{ReadWriteCloser: res.Body.(io.ReadWriteCloser), onClose: cancel}
+		return res, nil
+	}
+
+	res.Body = newIdleTimeoutBody(res.Body, rt.timeouts.IdleBody, cancel, rt.onTimeout)
+	return res, nil
+}
+
+type idleTimeoutBody struct {
+	body      io.ReadCloser
+	timeout   time.Duration
+	timer     *time.Timer
+	expired   int32
+	reported  bool
+	cancel    context.CancelFunc
+	onTimeout func(err *handler.TimeoutError)
+}
+
+func newIdleTimeoutBody(body io.ReadCloser, timeout time.Duration, cancel context.CancelFunc, onTimeout func(err *handler.TimeoutError)) *idleTimeoutBody {
+	b := &idleTimeoutBody{
+		body:      body,
+		timeout:   timeout,
+		cancel:    cancel,
+		onTimeout: onTimeout,
+	}
+
+	if timeout > 0 {
+		b.timer = time.AfterFunc(timeout, func() {
+			atomic.StoreInt32(&b.expired, 1)
+			cancel()
+		})
+		b.timer.Stop()
+	}
+	return b
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	if b.timer != nil {
+		b.timer.Reset(b.timeout)
+	}
+
+	n, err := b.body.Read(p)
+
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+
+	if atomic.LoadInt32(&b.expired) == 1 {
+		timeoutErr := &handler.TimeoutError{Phase: handler.IdleBodyPhase, Duration: b.timeout}
+		if !b.reported && b.onTimeout != nil {
+			b.reported = true
+			b.onTimeout(timeoutErr)
+		}
+		return n, timeoutErr
+	}
+
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	err := b.body.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,172 @@
+package round_tripper_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/proxy/handler"
+	"code.cloudfoundry.org/gorouter/proxy/round_tripper"
+	"code.cloudfoundry.org/gorouter/route"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Timeouts", func() {
+	var (
+		server     *httptest.Server
+		transport  *http.Transport
+		timeouts   route.Timeouts
+		timeoutErr *handler.TimeoutError
+	)
+
+	roundTrip := func(url string) (*http.Response, error) {
+		req, err := http.NewRequest("GET", url, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		rt := round_tripper.NewTimeoutRoundTripper(transport, timeouts, func(err *handler.TimeoutError) {
+			Expect(timeoutErr).To(BeNil())
+			timeoutErr = err
+		})
+		return rt.RoundTrip(req)
+	}
+
+	BeforeEach(func() {
+		dialer := &round_tripper.Dialer{Timeouts: route.Timeouts{Dial: 5 * time.Second, TLSHandshake: 10 * time.Second}}
+		transport = &http.Transport{
+			DialContext:    dialer.DialContext,
+			DialTLSContext: dialer.DialTLSContext,
+		}
+		timeouts = route.Timeouts{}
+		timeoutErr = nil
+	})
+
+	AfterEach(func() {
+		if server != nil {
+			server.Close()
+		}
+	})
+
+	Context("when the response header is late", func() {
+		BeforeEach(func() {
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(200 * time.Millisecond)
+			}))
+		})
+
+		It("returns a response header timeout", func() {
+			timeouts.ResponseHeader = 50 * time.Millisecond
+
+			_, err := roundTrip(server.URL)
+			Expect(err).To(Equal(&handler.TimeoutError{Phase: handler.ResponseHeaderPhase, Duration: 50 * time.Millisecond}))
+		})
+
+		It("waits for the response without a timeout", func() {
+			res, err := roundTrip(server.URL)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+		})
+	})
+
+	Context("when the response body stalls", func() {
+		BeforeEach(func() {
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("partial"))
+				w.(http.Flusher).Flush()
+				time.Sleep(200 * time.Millisecond)
+				w.Write([]byte(" body"))
+			}))
+		})
+
+		It("returns an idle body timeout to the reader and reports it", func() {
+			timeouts.ResponseHeader = time.Second
+			timeouts.IdleBody = 50 * time.Millisecond
+
+			res, err := roundTrip(server.URL)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = ioutil.ReadAll(res.Body)
+			res.Body.Close()
+
+			var phaseErr *handler.TimeoutError
+			Expect(errors.As(err, &phaseErr)).To(BeTrue())
+			Expect(phaseErr.Phase).To(Equal(handler.IdleBodyPhase))
+			Expect(timeoutErr).To(Equal(phaseErr))
+		})
+
+		It("reads the whole body when the idle body timeout is longer", func() {
+			timeouts.IdleBody = time.Second
+
+			res, err := roundTrip(server.URL)
+			Expect(err).ToNot(HaveOccurred())
+
+			body, err := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal("partial body"))
+			Expect(timeoutErr).To(BeNil())
+		})
+	})
+
+	It("does not count the time the reader takes against the idle body timeout", func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("a complete body"))
+		}))
+		timeouts.IdleBody = 50 * time.Millisecond
+
+		res, err := roundTrip(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+
+		time.Sleep(100 * time.Millisecond)
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(body)).To(Equal("a complete body"))
+	})
+
+	Context("when the endpoint does not complete the TLS handshake", func() {
+		var listener net.Listener
+
+		BeforeEach(func() {
+			var err error
+			listener, err = net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+
+			go func() {
+				for {
+					conn, err := listener.Accept()
+					if err != nil {
+						return
+					}
+					defer conn.Close()
+				}
+			}()
+		})
+
+		AfterEach(func() {
+			listener.Close()
+		})
+
+		It("uses the TLS handshake timeout of the route", func() {
+			timeouts.TLSHandshake = 50 * time.Millisecond
+
+			_, err := roundTrip("https://" + listener.Addr().String())
+
+			var phaseErr *handler.TimeoutError
+			Expect(errors.As(err, &phaseErr)).To(BeTrue())
+			Expect(phaseErr).To(Equal(&handler.TimeoutError{Phase: handler.TLSHandshakePhase, Duration: 50 * time.Millisecond}))
+		})
+
+		It("uses the TLS handshake timeout of the dialer without a route", func() {
+			dialer := &round_tripper.Dialer{Timeouts: route.Timeouts{TLSHandshake: 50 * time.Millisecond}}
+
+			_, err := dialer.DialTLSContext(context.Background(), "tcp", listener.Addr().String())
+			Expect(err).To(Equal(&handler.TimeoutError{Phase: handler.TLSHandshakePhase, Duration: 50 * time.Millisecond}))
+		})
+	})
+})
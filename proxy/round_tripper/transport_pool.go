@@ -0,0 +1,373 @@
+package round_tripper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+type endpointKey struct{}
+
+// WithEndpoint returns a copy of the request that the TransportPool sends with
+// the transport of the endpoint.
+func WithEndpoint(request *http.Request, endpoint *route.Endpoint) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), endpointKey{}, endpoint))
+}
+
+type proxyHeaderKey struct{}
+
+// WithProxyHeader returns a copy of the request that the TransportPool sends to
+// an endpoint on a new connection of its own, which the Dialer starts with the
+// PROXY protocol header. Requests to route services are sent without it.
+func WithProxyHeader(request *http.Request, header []byte) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), proxyHeaderKey{}, header))
+}
+
+// EndpointConnections are the statistics of the connections to an endpoint.
+type EndpointConnections struct {
+	Address           string `json:"address"`
+	ApplicationId     string `json:"app,omitempty"`
+	PrivateInstanceId string `json:"private_instance_id,omitempty"`
+	Reused            int64  `json:"reused"`
+	New               int64  `json:"new"`
+	Open              int    `json:"open"`
+	Idle              int    `json:"idle"`
+}
+
+type transportKey struct {
+	addr     string
+	app      string
+	instance string
+}
+
+func keyOf(endpoint *route.Endpoint) transportKey {
+	return transportKey{
+		addr:     endpoint.CanonicalAddr(),
+		app:      endpoint.ApplicationId,
+		instance: endpoint.PrivateInstanceId,
+	}
+}
+
+// TransportPool sends the requests to each endpoint with a transport of its
+// own, so idle connections are kept, counted and evicted per endpoint. Endpoints
+// are told apart by application and instance as well as address, so a
+// connection to an address that is recycled by another application is never
+// reused. Requests without an endpoint, such as those to route services, share
+// a transport. The MaxIdleConns of the shared transport limits the idle
+// connections to all the endpoints together, closing those idle the longest
+// first.
+type TransportPool struct {
+	lock       sync.Mutex
+	shared     *http.Transport
+	transports map[transportKey]*endpointTransport
+	onConn     func(endpoint *route.Endpoint, reused bool)
+	idle       int64
+}
+
+// NewTransportPool returns a pool whose transports are configured like the
+// shared transport. onConn is called whenever a request to an endpoint gets a
+// connection.
+func NewTransportPool(shared *http.Transport, onConn func(endpoint *route.Endpoint, reused bool)) *TransportPool {
+	return &TransportPool{
+		shared:     shared,
+		transports: make(map[transportKey]*endpointTransport),
+		onConn:     onConn,
+	}
+}
+
+func (p *TransportPool) RoundTrip(request *http.Request) (*http.Response, error) {
+	endpoint, ok := request.Context().Value(endpointKey{}).(*route.Endpoint)
+	if !ok {
+		return p.shared.RoundTrip(request)
+	}
+
+	t := p.transportFor(endpoint)
+
+	var conn *trackedConn
+	var rawConn net.Conn
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			rawConn = info.Conn
+			conn, _ = info.Conn.(*trackedConn)
+			t.gotConn(conn, info.Reused)
+			if p.onConn != nil {
+				p.onConn(endpoint, info.Reused)
+			}
+		},
+		PutIdleConn: func(err error) {
+			if err == nil && conn != nil {
+				t.putIdleConn(conn)
+			}
+		},
+	}
+
+	transport := t.transport
+	ctx := httptrace.WithClientTrace(request.Context(), trace)
+	if header, ok := request.Context().Value(proxyHeaderKey{}).([]byte); ok {
+		transport = t.proxied
+		ctx = context.WithValue(ctx, dialProxyHeaderKey{}, header)
+	}
+
+	res, err := transport.RoundTrip(request.WithContext(ctx))
+	if err == nil && isUpgrade(res) {
+		res.Body = &upgradedBody{ReadWriteCloser: res.Body.(io.ReadWriteCloser), conn: rawConn}
+	}
+	return res, err
+}
+
+func (p *TransportPool) transportFor(endpoint *route.Endpoint) *endpointTransport {
+	key := keyOf(endpoint)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if t, ok := p.transports[key]; ok {
+		return t
+	}
+
+	// an address serves one instance at a time, so the connections to any
+	// other instance at the address are stale
+	p.evict(key.addr)
+
+	t := newEndpointTransport(p, key)
+	p.transports[key] = t
+	return t
+}
+
+// Evict closes the idle connections to the address of the endpoint and stops
+// reusing the connections in use.
+func (p *TransportPool) Evict(endpoint *route.Endpoint) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.evict(endpoint.CanonicalAddr())
+}
+
+// lock must be held
+func (p *TransportPool) evict(addr string) {
+	for key, t := range p.transports {
+		if key.addr == addr {
+			delete(p.transports, key)
+			t.evict()
+		}
+	}
+}
+
+// Stats returns the connection statistics of each endpoint, by address.
+func (p *TransportPool) Stats() []EndpointConnections {
+	p.lock.Lock()
+	stats := make([]EndpointConnections, 0, len(p.transports))
+	for _, t := range p.transports {
+		stats = append(stats, t.stats())
+	}
+	p.lock.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Address < stats[j].Address
+	})
+	return stats
+}
+
+func (p *TransportPool) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Stats())
+}
+
+// idled counts a connection becoming idle, or no longer idle, and closes the
+// connection idle the longest once there are more than the transport keeps.
+func (p *TransportPool) idled(delta int64) {
+	idle := atomic.AddInt64(&p.idle, delta)
+	if delta < 0 || p.shared.MaxIdleConns <= 0 || idle <= int64(p.shared.MaxIdleConns) {
+		return
+	}
+
+	var oldest *trackedConn
+	p.lock.Lock()
+	for _, t := range p.transports {
+		t.lock.Lock()
+		for c := range t.conns {
+			if c.idle && (oldest == nil || c.idleSince.Before(oldest.idleSince)) {
+				oldest = c
+			}
+		}
+		t.lock.Unlock()
+	}
+	p.lock.Unlock()
+
+	if oldest != nil {
+		oldest.Close()
+	}
+}
+
+type endpointTransport struct {
+	pool      *TransportPool
+	key       transportKey
+	transport *http.Transport
+	// proxied sends the requests with a PROXY protocol header, whose
+	// connections are only used once
+	proxied *http.Transport
+	reused  int64
+	created int64
+
+	lock    sync.Mutex
+	conns   map[*trackedConn]struct{}
+	evicted bool
+}
+
+func newEndpointTransport(pool *TransportPool, key transportKey) *endpointTransport {
+	t := &endpointTransport{
+		pool:  pool,
+		key:   key,
+		conns: make(map[*trackedConn]struct{}),
+	}
+
+	dial := pool.shared.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	t.transport = pool.shared.Clone()
+	t.transport.DialContext = t.tracked(dial)
+	// TLS connections are tracked once the handshake is done
+	if pool.shared.DialTLSContext != nil {
+		t.transport.DialTLSContext = t.tracked(pool.shared.DialTLSContext)
+	}
+
+	t.proxied = t.transport.Clone()
+	t.proxied.DisableKeepAlives = true
+	return t
+}
+
+func (t *endpointTransport) tracked(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return t.track(conn), nil
+	}
+}
+
+func (t *endpointTransport) track(conn net.Conn) *trackedConn {
+	c := &trackedConn{Conn: conn, owner: t}
+
+	t.lock.Lock()
+	t.conns[c] = struct{}{}
+	t.lock.Unlock()
+	return c
+}
+
+func (t *endpointTransport) gotConn(conn *trackedConn, reused bool) {
+	if reused {
+		atomic.AddInt64(&t.reused, 1)
+	} else {
+		atomic.AddInt64(&t.created, 1)
+	}
+
+	if conn != nil {
+		t.lock.Lock()
+		wasIdle := conn.idle
+		conn.idle = false
+		t.lock.Unlock()
+
+		if wasIdle {
+			t.pool.idled(-1)
+		}
+	}
+}
+
+func (t *endpointTransport) putIdleConn(conn *trackedConn) {
+	t.lock.Lock()
+	evicted := t.evicted
+	_, open := t.conns[conn]
+	wasIdle := conn.idle
+	if !evicted && open {
+		conn.idle = true
+		conn.idleSince = time.Now()
+	}
+	t.lock.Unlock()
+
+	// connections in use when the transport was evicted are returned to a
+	// pool nobody takes from
+	if evicted {
+		conn.Close()
+	} else if open && !wasIdle {
+		t.pool.idled(1)
+	}
+}
+
+func (t *endpointTransport) remove(conn *trackedConn) {
+	t.lock.Lock()
+	wasIdle := conn.idle
+	conn.idle = false
+	delete(t.conns, conn)
+	t.lock.Unlock()
+
+	if wasIdle {
+		t.pool.idled(-1)
+	}
+}
+
+func (t *endpointTransport) evict() {
+	t.lock.Lock()
+	t.evicted = true
+	t.lock.Unlock()
+
+	t.transport.CloseIdleConnections()
+	t.proxied.CloseIdleConnections()
+}
+
+func (t *endpointTransport) stats() EndpointConnections {
+	t.lock.Lock()
+	idle := 0
+	for c := range t.conns {
+		if c.idle {
+			idle++
+		}
+	}
+	open := len(t.conns)
+	t.lock.Unlock()
+
+	return EndpointConnections{
+		Address:           t.key.addr,
+		ApplicationId:     t.key.app,
+		PrivateInstanceId: t.key.instance,
+		Reused:            atomic.LoadInt64(&t.reused),
+		New:               atomic.LoadInt64(&t.created),
+		Open:              open,
+		Idle:              idle,
+	}
+}
+
+// trackedConn is a connection to an endpoint that is counted until it closes.
+// idle and idleSince are guarded by the lock of the owner.
+type trackedConn struct {
+	net.Conn
+	owner     *endpointTransport
+	idle      bool
+	idleSince time.Time
+	once      sync.Once
+}
+
+func (c *trackedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(closeWriter); ok {
+		return cw.CloseWrite()
+	}
+	return errors.New("connection cannot be closed for writing")
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {
+		c.owner.remove(c)
+	})
+	return c.Conn.Close()
+}
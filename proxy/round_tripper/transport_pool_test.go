@@ -0,0 +1,234 @@
+package round_tripper_test
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/gorouter/common/proxyproto"
+	"code.cloudfoundry.org/gorouter/proxy/round_tripper"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TransportPool", func() {
+	var (
+		server  *httptest.Server
+		closed  int32
+		pool    *round_tripper.TransportPool
+		reused  []bool
+		appA    *route.Endpoint
+		appB    *route.Endpoint
+		address string
+	)
+
+	newEndpoint := func(app, instance string) *route.Endpoint {
+		host, portStr, err := net.SplitHostPort(address)
+		Expect(err).ToNot(HaveOccurred())
+		port, err := strconv.Atoi(portStr)
+		Expect(err).ToNot(HaveOccurred())
+
+		return route.NewEndpoint(app, host, uint16(port), instance, "0", nil, -1, "", models.ModificationTag{})
+	}
+
+	sendWithHeader := func(endpoint *route.Endpoint, header []byte) string {
+		req, err := http.NewRequest("GET", "http://"+address+"/", nil)
+		Expect(err).ToNot(HaveOccurred())
+		if endpoint != nil {
+			req = round_tripper.WithEndpoint(req, endpoint)
+		}
+		if header != nil {
+			req = round_tripper.WithProxyHeader(req, header)
+		}
+
+		res, err := pool.RoundTrip(req)
+		Expect(err).ToNot(HaveOccurred())
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		return string(body)
+	}
+
+	send := func(endpoint *route.Endpoint) {
+		sendWithHeader(endpoint, nil)
+	}
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&closed, 0)
+		server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.RemoteAddr))
+		}))
+		server.Listener = &proxyproto.Listener{Listener: server.Listener}
+		server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+			if state == http.StateClosed {
+				atomic.AddInt32(&closed, 1)
+			}
+		}
+		server.Start()
+		address = server.Listener.Addr().String()
+
+		reused = nil
+		pool = round_tripper.NewTransportPool(&http.Transport{}, func(endpoint *route.Endpoint, r bool) {
+			reused = append(reused, r)
+		})
+
+		appA = newEndpoint("app-a", "instance-a")
+		appB = newEndpoint("app-b", "instance-b")
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("reuses the connections to an endpoint and counts them", func() {
+		send(appA)
+		send(appA)
+		Expect(reused).To(Equal([]bool{false, true}))
+
+		Eventually(pool.Stats).Should(Equal([]round_tripper.EndpointConnections{{
+			Address:           address,
+			ApplicationId:     "app-a",
+			PrivateInstanceId: "instance-a",
+			Reused:            1,
+			New:               1,
+			Open:              1,
+			Idle:              1,
+		}}))
+	})
+
+	It("never reuses a connection for another application at the same address", func() {
+		send(appA)
+		send(appB)
+		Expect(reused).To(Equal([]bool{false, false}))
+
+		stats := pool.Stats()
+		Expect(stats).To(HaveLen(1))
+		Expect(stats[0].ApplicationId).To(Equal("app-b"))
+		Eventually(func() int32 { return atomic.LoadInt32(&closed) }).Should(Equal(int32(1)))
+	})
+
+	It("closes the idle connections of evicted endpoints", func() {
+		send(appA)
+		Eventually(func() int {
+			return pool.Stats()[0].Idle
+		}).Should(Equal(1))
+
+		pool.Evict(appA)
+		Expect(pool.Stats()).To(BeEmpty())
+		Eventually(func() int32 { return atomic.LoadInt32(&closed) }).Should(Equal(int32(1)))
+
+		send(appA)
+		Expect(reused).To(Equal([]bool{false, false}))
+	})
+
+	Context("when more connections are idle than the transport keeps", func() {
+		var other *httptest.Server
+
+		BeforeEach(func() {
+			other = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			pool = round_tripper.NewTransportPool(&http.Transport{MaxIdleConns: 1}, nil)
+		})
+
+		AfterEach(func() {
+			other.Close()
+		})
+
+		It("closes the connection idle the longest across the endpoints", func() {
+			send(appA)
+			Eventually(func() int {
+				return pool.Stats()[0].Idle
+			}).Should(Equal(1))
+
+			address = other.Listener.Addr().String()
+			send(newEndpoint("app-c", "instance-c"))
+
+			Eventually(func() int32 { return atomic.LoadInt32(&closed) }).Should(Equal(int32(1)))
+			Eventually(func() map[string]int {
+				idle := make(map[string]int)
+				for _, s := range pool.Stats() {
+					idle[s.ApplicationId] = s.Idle
+				}
+				return idle
+			}).Should(Equal(map[string]int{"app-a": 0, "app-c": 1}))
+		})
+	})
+
+	Context("with TLS", func() {
+		BeforeEach(func() {
+			server.Close()
+			server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			address = server.Listener.Addr().String()
+			appA = newEndpoint("app-a", "instance-a")
+
+			dialer := &round_tripper.Dialer{TLSConfig: &tls.Config{InsecureSkipVerify: true}}
+			pool = round_tripper.NewTransportPool(&http.Transport{DialTLSContext: dialer.DialTLSContext}, nil)
+		})
+
+		It("counts the connections to an endpoint", func() {
+			for i := 0; i < 2; i++ {
+				req, err := http.NewRequest("GET", "https://"+address+"/", nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				res, err := pool.RoundTrip(round_tripper.WithEndpoint(req, appA))
+				Expect(err).ToNot(HaveOccurred())
+				ioutil.ReadAll(res.Body)
+				res.Body.Close()
+			}
+
+			Eventually(pool.Stats).Should(Equal([]round_tripper.EndpointConnections{{
+				Address:           address,
+				ApplicationId:     "app-a",
+				PrivateInstanceId: "instance-a",
+				Reused:            1,
+				New:               1,
+				Open:              1,
+				Idle:              1,
+			}}))
+		})
+	})
+
+	It("sends requests without an endpoint with the shared transport", func() {
+		send(nil)
+		Expect(reused).To(BeEmpty())
+		Expect(pool.Stats()).To(BeEmpty())
+	})
+
+	It("marshals the statistics", func() {
+		send(appA)
+
+		Eventually(func() string {
+			b, err := pool.MarshalJSON()
+			Expect(err).ToNot(HaveOccurred())
+			return string(b)
+		}).Should(MatchJSON(`[{"address":"` + address + `","app":"app-a","private_instance_id":"instance-a","reused":0,"new":1,"open":1,"idle":1}]`))
+	})
+
+	Context("with a PROXY protocol header", func() {
+		var header []byte
+
+		BeforeEach(func() {
+			dialer := &round_tripper.Dialer{}
+			pool = round_tripper.NewTransportPool(&http.Transport{DialContext: dialer.DialContext}, func(endpoint *route.Endpoint, r bool) {
+				reused = append(reused, r)
+			})
+			header = []byte("PROXY TCP4 192.168.0.1 10.0.0.1 12345 80\r\n")
+		})
+
+		It("sends the header ahead of requests to endpoints on connections of their own", func() {
+			Expect(sendWithHeader(appA, header)).To(Equal("192.168.0.1:12345"))
+			Expect(sendWithHeader(appA, header)).To(Equal("192.168.0.1:12345"))
+			Expect(sendWithHeader(appA, nil)).To(HavePrefix("127.0.0.1:"))
+			Expect(reused).To(Equal([]bool{false, false, false}))
+		})
+
+		It("sends requests without an endpoint without the header", func() {
+			Expect(sendWithHeader(nil, header)).To(HavePrefix("127.0.0.1:"))
+		})
+	})
+})
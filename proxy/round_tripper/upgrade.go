@@ -0,0 +1,49 @@
+package round_tripper
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+// closeWriter is implemented by connections that can stop writing while they
+// are still read from, such as TCP and TLS connections.
+type closeWriter interface {
+	CloseWrite() error
+}
+
+// upgradedBody is the body of a response switching protocols, which reads from
+// and writes to the connection to the endpoint. It can close the connection for
+// writing, so a tunnel forwards a half-close to the endpoint.
+type upgradedBody struct {
+	io.ReadWriteCloser
+	conn    net.Conn
+	onClose func()
+}
+
+func isUpgrade(res *http.Response) bool {
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		return false
+	}
+	_, ok := res.Body.(io.ReadWriteCloser)
+	return ok
+}
+
+func (b *upgradedBody) CloseWrite() error {
+	if cw, ok := b.ReadWriteCloser.(closeWriter); ok {
+		return cw.CloseWrite()
+	}
+	if cw, ok := b.conn.(closeWriter); ok {
+		return cw.CloseWrite()
+	}
+	return errors.New("connection cannot be closed for writing")
+}
+
+func (b *upgradedBody) Close() error {
+	err := b.ReadWriteCloser.Close()
+	if b.onClose != nil {
+		b.onClose()
+	}
+	return err
+}
@@ -341,6 +341,7 @@ var _ = Describe("Route Services", func() {
 
 			res, body := conn.ReadResponse()
 			Expect(res.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(res.Header.Get("X-Cf-RouterError")).To(Equal("bad_signature"))
 			Expect(body).To(ContainSubstring("Failed to validate Route Service Signature"))
 		})
 	})
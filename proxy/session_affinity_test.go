@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"code.cloudfoundry.org/gorouter/proxy"
+	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/gorouter/test_util"
 
 	. "github.com/onsi/ginkgo"
@@ -361,6 +362,115 @@ var _ = Describe("Session Affinity", func() {
 			})
 		})
 	})
+
+	Context("with other session cookies", func() {
+		BeforeEach(func() {
+			conf.StickySessions.CookieNames = []string{"PHPSESSID"}
+			conf.StickySessions.SameSite = "lax"
+			conf.StickySessions.Domain = "app.com"
+			jSessionIdCookie.Name = "PHPSESSID"
+		})
+
+		It("responds with a VCAP_ID cookie when one of them is set", func() {
+			ln := registerHandlerWithInstanceId(r, "app.com", "", responseWithJSessionID, "my-id")
+			defer ln.Close()
+
+			x := dialProxy(proxyServer)
+			req := test_util.NewRequest("GET", "app.com", "/", nil)
+			x.WriteRequest(req)
+
+			Eventually(done).Should(Receive())
+
+			resp, _ := x.ReadResponse()
+			cookie := getCookie(proxy.VcapCookieId, resp.Cookies())
+			Expect(cookie).ToNot(BeNil())
+			Expect(cookie.Value).To(Equal("my-id"))
+			Expect(cookie.Domain).To(Equal("app.com"))
+			Expect(cookie.SameSite).To(Equal(http.SameSiteLaxMode))
+		})
+
+		It("uses the session cookies registered for the route", func() {
+			ln := registerHandlerWithOptions(r, "app.com", responseWithJSessionID, func(e *route.Endpoint) {
+				e.PrivateInstanceId = "my-id"
+				e.StickyCookieNames = []string{"connect.sid"}
+			})
+			defer ln.Close()
+
+			x := dialProxy(proxyServer)
+			req := test_util.NewRequest("GET", "app.com", "/", nil)
+			x.WriteRequest(req)
+
+			Eventually(done).Should(Receive())
+
+			resp, _ := x.ReadResponse()
+			Expect(getCookie(proxy.VcapCookieId, resp.Cookies())).To(BeNil())
+		})
+	})
+
+	Context("when the router issues the affinity cookie", func() {
+		BeforeEach(func() {
+			conf.StickySessions.RouterIssued = true
+			conf.StickySessions.Secret = "secret"
+			conf.StickySessions.TTL = time.Hour
+		})
+
+		It("responds with a signed VCAP_ID cookie that keeps the session on the endpoint", func() {
+			ln := registerHandlerWithInstanceId(r, "app", "", responseNoCookies, "my-id")
+			defer ln.Close()
+
+			x := dialProxy(proxyServer)
+			req := test_util.NewRequest("GET", "app", "/", nil)
+			x.WriteRequest(req)
+
+			Eventually(done).Should(Receive())
+
+			resp, _ := x.ReadResponse()
+			cookie := getCookie(proxy.VcapCookieId, resp.Cookies())
+			Expect(cookie).ToNot(BeNil())
+			Expect(cookie.Value).ToNot(ContainSubstring("my-id"))
+			Expect(cookie.MaxAge).To(Equal(3600))
+
+			other := make(chan bool, 4)
+			ln2 := registerHandlerWithInstanceId(r, "app", "", func(x *test_util.HttpConn) {
+				_, err := http.ReadRequest(x.Reader)
+				Expect(err).ToNot(HaveOccurred())
+
+				x.WriteResponse(test_util.NewResponse(http.StatusOK))
+				x.Close()
+				other <- true
+			}, "other-id")
+			defer ln2.Close()
+
+			for i := 0; i < 4; i++ {
+				x = dialProxy(proxyServer)
+				req = test_util.NewRequest("GET", "app", "/", nil)
+				req.AddCookie(&http.Cookie{Name: proxy.VcapCookieId, Value: cookie.Value})
+				x.WriteRequest(req)
+
+				Eventually(done).Should(Receive())
+				x.ReadResponse()
+			}
+			Expect(other).ToNot(Receive())
+		})
+
+		It("ignores affinity cookies it did not issue", func() {
+			ln := registerHandlerWithInstanceId(r, "app", "", responseNoCookies, "my-id")
+			defer ln.Close()
+
+			x := dialProxy(proxyServer)
+			req := test_util.NewRequest("GET", "app", "/", nil)
+			req.AddCookie(&http.Cookie{Name: proxy.VcapCookieId, Value: "other-id"})
+			req.AddCookie(&http.Cookie{Name: proxy.StickyCookieKey, Value: "xxx"})
+			x.WriteRequest(req)
+
+			Eventually(done).Should(Receive())
+
+			resp, _ := x.ReadResponse()
+			cookie := getCookie(proxy.VcapCookieId, resp.Cookies())
+			Expect(cookie).ToNot(BeNil())
+			Expect(cookie.Value).ToNot(Equal("other-id"))
+		})
+	})
 })
 
 func getCookie(name string, cookies []*http.Cookie) *http.Cookie {
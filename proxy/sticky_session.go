@@ -0,0 +1,195 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/common/secure"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+var sameSiteModes = map[string]http.SameSite{
+	"lax":    http.SameSiteLaxMode,
+	"strict": http.SameSiteStrictMode,
+	"none":   http.SameSiteNoneMode,
+}
+
+// stickySessions keeps the sessions of clients on one endpoint with the
+// affinity cookie.
+type stickySessions struct {
+	cookieNames []string
+	secure      bool
+	sameSite    http.SameSite
+	domain      string
+	ttl         time.Duration
+	crypto      *secure.AesGCM // set when the router issues affinity cookies
+}
+
+// affinity is the content of the affinity cookies issued by the router.
+type affinity struct {
+	InstanceId string `json:"instance_id"`
+	Expires    int64  `json:"expires"`
+}
+
+func newStickySessions(c *config.Config) (*stickySessions, error) {
+	s := &stickySessions{
+		cookieNames: c.StickySessions.CookieNames,
+		secure:      c.SecureCookies,
+		sameSite:    sameSiteModes[c.StickySessions.SameSite],
+		domain:      c.StickySessions.Domain,
+	}
+
+	if c.StickySessions.RouterIssued {
+		crypto, err := secure.NewAesGCM(secure.NewPbkdf2([]byte(c.StickySessions.Secret), 16))
+		if err != nil {
+			return nil, err
+		}
+		s.crypto = crypto
+		s.ttl = c.StickySessions.TTL
+	}
+
+	return s, nil
+}
+
+// endpointId returns the instance a request is stuck to, or the empty string.
+// The affinity cookie set by the router only counts along with a session
+// cookie, unless the router issued it. The session cookies of a route replace
+// those of the config.
+func (s *stickySessions) endpointId(request *http.Request, cookieNames []string) string {
+	cookie, err := request.Cookie(VcapCookieId)
+	if err != nil {
+		return ""
+	}
+
+	if s.crypto != nil {
+		return s.open(cookie.Value)
+	}
+
+	for _, name := range s.names(cookieNames) {
+		if _, err := request.Cookie(name); err == nil {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// setCookie sets the affinity cookie on the response of an endpoint when the
+// endpoint sets a session cookie or the session moved to it, scoped like the
+// session cookie. Affinity cookies issued by the router are set on every
+// response, so they expire after the TTL without requests.
+func (s *stickySessions) setCookie(responseWriter http.ResponseWriter, response *http.Response,
+	endpoint *route.Endpoint,
+	originalEndpointId string,
+	path string,
+	cookieNames []string) {
+	if s.crypto != nil {
+		value, err := s.seal(endpoint.PrivateInstanceId, time.Now().Add(s.ttl))
+		if err != nil {
+			return
+		}
+		http.SetCookie(responseWriter, s.cookie(value, path, int(s.ttl.Seconds()), s.secure))
+		return
+	}
+
+	secure := false
+	maxAge := 0
+
+	// did the endpoint change?
+	sticky := originalEndpointId != "" && originalEndpointId != endpoint.PrivateInstanceId
+
+	names := s.names(cookieNames)
+	for _, v := range response.Cookies() {
+		if isSessionCookie(names, v.Name) {
+			sticky = true
+			if v.MaxAge < 0 {
+				maxAge = v.MaxAge
+			}
+			secure = v.Secure
+			break
+		}
+	}
+
+	if sticky {
+		// right now secure attribute would as equal to the session cookie (if present),
+		// but override if set to true in config
+		http.SetCookie(responseWriter, s.cookie(endpoint.PrivateInstanceId, path, maxAge, secure || s.secure))
+	}
+}
+
+func (s *stickySessions) cookie(value string, path string, maxAge int, secure bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     VcapCookieId,
+		Value:    value,
+		Path:     path,
+		Domain:   s.domain,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: s.sameSite,
+	}
+}
+
+func (s *stickySessions) names(cookieNames []string) []string {
+	if len(cookieNames) > 0 {
+		return cookieNames
+	}
+	return s.cookieNames
+}
+
+// seal encrypts the instance of an affinity cookie with its expiry, so clients
+// can neither read nor forge it.
+func (s *stickySessions) seal(instanceId string, expires time.Time) (string, error) {
+	plainText, err := json.Marshal(affinity{InstanceId: instanceId, Expires: expires.Unix()})
+	if err != nil {
+		return "", err
+	}
+
+	cipherText, nonce, err := s.crypto.Encrypt(plainText)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(nonce) + "." + base64.RawURLEncoding.EncodeToString(cipherText), nil
+}
+
+// open returns the instance of an affinity cookie, or the empty string if it
+// is invalid or expired.
+func (s *stickySessions) open(value string) string {
+	parts := strings.SplitN(value, ".", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+
+	nonce, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || len(nonce) != s.crypto.NonceSize() {
+		return ""
+	}
+	cipherText, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+
+	plainText, err := s.crypto.Decrypt(cipherText, nonce)
+	if err != nil {
+		return ""
+	}
+
+	var a affinity
+	if err := json.Unmarshal(plainText, &a); err != nil || time.Now().Unix() > a.Expires {
+		return ""
+	}
+	return a.InstanceId
+}
+
+func isSessionCookie(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package tcp
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// clientHelloTimeout bounds the time to read the ClientHello of a TLS
+// connection.
+const clientHelloTimeout = 5 * time.Second
+
+var errClientHelloRead = errors.New("client hello read")
+
+// readOnlyConn lets a TLS server read a ClientHello without answering it.
+type readOnlyConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c readOnlyConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c readOnlyConn) Write(p []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+// peekedConn reads the bytes read from a connection before it was handed on
+// first.
+type peekedConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// NetConn returns the connection the ClientHello was read from.
+func (c *peekedConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// readServerName reads the ClientHello of a TLS connection and returns the
+// server name it asks for, which is empty when the client sends none. The
+// returned connection reads the ClientHello again, so the TLS handshake can be
+// made with it as if nothing had been read.
+func readServerName(conn net.Conn) (string, net.Conn, error) {
+	peeked := &bytes.Buffer{}
+	var serverName string
+
+	conn.SetReadDeadline(time.Now().Add(clientHelloTimeout))
+	err := tls.Server(readOnlyConn{Conn: conn, reader: io.TeeReader(conn, peeked)}, &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = hello.ServerName
+			return nil, errClientHelloRead
+		},
+	}).Handshake()
+	conn.SetReadDeadline(time.Time{})
+
+	replay := &peekedConn{Conn: conn, reader: io.MultiReader(peeked, conn)}
+	if err != errClientHelloRead {
+		return "", replay, err
+	}
+	return serverName, replay, nil
+}
@@ -0,0 +1,93 @@
+package tcp
+
+import (
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/lager"
+)
+
+// passthroughListener accepts the TLS connections of a listener and passes
+// those for routes that terminate TLS themselves through to their endpoints.
+// The other connections are returned by Accept, to be terminated by the router.
+type passthroughListener struct {
+	net.Listener
+	proxy *Proxy
+
+	conns chan net.Conn
+	done  chan struct{}
+	err   error
+}
+
+// PassthroughListener wraps a TLS listener so the connections for routes
+// registered with TLS passthrough are spliced to their endpoints by the server
+// name of their ClientHello, without being terminated.
+func (p *Proxy) PassthroughListener(listener net.Listener) net.Listener {
+	l := &passthroughListener{
+		Listener: listener,
+		proxy:    p,
+		conns:    make(chan net.Conn),
+		done:     make(chan struct{}),
+	}
+	go l.serve()
+	return l
+}
+
+func (l *passthroughListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, l.err
+	}
+}
+
+func (l *passthroughListener) serve() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				l.proxy.logger.Error("tls-passthrough-accept-failed", err)
+				time.Sleep(acceptRetryDelay)
+				continue
+			}
+			l.err = err
+			close(l.done)
+			return
+		}
+
+		// the ClientHello is read apart from the accept loop, so slow clients
+		// do not hold up the others
+		go l.handle(conn)
+	}
+}
+
+func (l *passthroughListener) handle(conn net.Conn) {
+	serverName, conn, err := readServerName(conn)
+	if err == nil && serverName != "" {
+		pool := l.proxy.registry.Lookup(route.Uri(serverName))
+		if pool != nil && pool.TLSPassthrough() {
+			l.proxy.passthrough(conn, pool, serverName)
+			return
+		}
+	}
+
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+// passthrough splices a TLS connection to an endpoint of the route.
+func (p *Proxy) passthrough(client net.Conn, pool *route.Pool, serverName string) {
+	defer client.Close()
+
+	logger := p.logger.Session("tls-passthrough", lager.Data{
+		"server_name": serverName,
+		"client":      client.RemoteAddr().String(),
+	})
+
+	p.forward(client, pool, p.tlsPassthroughIdleTimeout, p.sendProxyHeader, logger, p.reporter.CaptureTLSPassthroughConnection)
+}
@@ -0,0 +1,129 @@
+package tcp_test
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/metrics/reporter/fakes"
+	"code.cloudfoundry.org/gorouter/proxy/handler"
+	"code.cloudfoundry.org/gorouter/proxy/tcp"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PassthroughListener", func() {
+	var (
+		r            *registry.RouteRegistry
+		fakeReporter *fakes.FakeProxyReporter
+		tlsConfig    *tls.Config
+		listener     net.Listener
+		backend      net.Listener
+	)
+
+	// serve answers each TLS connection with a greeting once the handshake is
+	// made
+	serve := func(listener net.Listener, greeting string) {
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					defer conn.Close()
+					conn.Write([]byte(greeting))
+				}()
+			}
+		}()
+	}
+
+	register := func(uri route.Uri, passthrough bool) {
+		port := uint16(backend.Addr().(*net.TCPAddr).Port)
+		endpoint := route.NewEndpoint("", "127.0.0.1", port, "", "", nil, -1, "", models.ModificationTag{})
+		endpoint.TLSPassthrough = passthrough
+		r.Register(uri, endpoint)
+	}
+
+	dial := func(serverName string) string {
+		conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: true,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+
+		greeting, err := ioutil.ReadAll(conn)
+		Expect(err).NotTo(HaveOccurred())
+		return string(greeting)
+	}
+
+	BeforeEach(func() {
+		logger := lagertest.NewTestLogger("test")
+		c := config.DefaultConfig()
+
+		r = registry.NewRouteRegistry(logger, c, new(fakes.FakeRouteRegistryReporter))
+		fakeReporter = &fakes.FakeProxyReporter{}
+		p := tcp.NewProxy(logger, c, r, fakeReporter, handler.NewHijackedConns())
+
+		cert, err := tls.LoadX509KeyPair("../../test/assets/certs/server.pem", "../../test/assets/certs/server.key")
+		Expect(err).NotTo(HaveOccurred())
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+
+		backend, err = tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+		Expect(err).NotTo(HaveOccurred())
+		serve(backend, "endpoint")
+
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		listener = tls.NewListener(p.PassthroughListener(l), tlsConfig)
+		serve(listener, "router")
+	})
+
+	AfterEach(func() {
+		listener.Close()
+		backend.Close()
+	})
+
+	It("passes connections for routes with tls passthrough through to their endpoints", func() {
+		register("passthrough.example.com", true)
+
+		Expect(dial("passthrough.example.com")).To(Equal("endpoint"))
+
+		Eventually(fakeReporter.CaptureTLSPassthroughConnectionCallCount).Should(Equal(1))
+		endpoint, received, sent := fakeReporter.CaptureTLSPassthroughConnectionArgsForCall(0)
+		Expect(endpoint.CanonicalAddr()).To(Equal(backend.Addr().String()))
+		Expect(received).To(BeNumerically(">", 0))
+		Expect(sent).To(BeNumerically(">", 0))
+	})
+
+	It("terminates connections for other routes", func() {
+		register("terminated.example.com", false)
+
+		Expect(dial("terminated.example.com")).To(Equal("router"))
+		Expect(fakeReporter.CaptureTLSPassthroughConnectionCallCount()).To(BeZero())
+	})
+
+	It("terminates connections for unknown routes", func() {
+		Expect(dial("unknown.example.com")).To(Equal("router"))
+	})
+
+	It("terminates connections without a server name", func() {
+		register("passthrough.example.com", true)
+
+		Expect(dial("")).To(Equal("router"))
+	})
+
+	It("returns the error of the listener once it is closed", func() {
+		listener.Close()
+
+		_, err := listener.Accept()
+		Expect(err).To(HaveOccurred())
+	})
+})
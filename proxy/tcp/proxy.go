@@ -0,0 +1,150 @@
+package tcp
+
+import (
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/common/proxyproto"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/metrics/reporter"
+	"code.cloudfoundry.org/gorouter/proxy/handler"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/lager"
+)
+
+// acceptRetryDelay is the time to wait before accepting again after a
+// temporary error, such as running out of file descriptors.
+const acceptRetryDelay = 5 * time.Millisecond
+
+// Registry looks up the endpoints of TCP routes, and of the routes TLS
+// connections are passed through to.
+type Registry interface {
+	Lookup(uri route.Uri) *route.Pool
+	LookupTcp(routerGroup string, port uint16) *route.Pool
+}
+
+// Proxy routes the connections accepted by TCP listeners to the endpoints of
+// the TCP route of the listener's port in its router group, and TLS connections
+// to the endpoints of routes that terminate TLS themselves.
+type Proxy struct {
+	logger                    lager.Logger
+	registry                  Registry
+	reporter                  reporter.ProxyReporter
+	hijacked                  *handler.HijackedConns
+	defaultLoadBalance        string
+	dialTimeout               time.Duration
+	tlsPassthroughIdleTimeout time.Duration
+	sendProxyHeader           bool
+	proxyHeaderVersion        int
+}
+
+func NewProxy(logger lager.Logger, c *config.Config, registry Registry, reporter reporter.ProxyReporter,
+	hijacked *handler.HijackedConns) *Proxy {
+	return &Proxy{
+		logger:                    logger,
+		registry:                  registry,
+		reporter:                  reporter,
+		hijacked:                  hijacked,
+		defaultLoadBalance:        c.LoadBalance,
+		dialTimeout:               c.EndpointDialTimeout,
+		tlsPassthroughIdleTimeout: c.TLSPassthrough.IdleTimeout,
+		sendProxyHeader:           c.ProxyProtocol.SendToBackends,
+		proxyHeaderVersion:        c.ProxyProtocol.BackendVersion,
+	}
+}
+
+// Serve accepts connections on the listener until it is closed, and routes
+// each to an endpoint of the TCP route configured for the listener.
+func (p *Proxy) Serve(listener net.Listener, listenerConfig config.TCPListenerConfig) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				p.logger.Error("tcp-accept-failed", err)
+				time.Sleep(acceptRetryDelay)
+				continue
+			}
+			return err
+		}
+
+		go p.handle(conn, listenerConfig)
+	}
+}
+
+func (p *Proxy) handle(client net.Conn, listenerConfig config.TCPListenerConfig) {
+	defer client.Close()
+
+	logger := p.logger.Session("tcp", lager.Data{
+		"router_group": listenerConfig.RouterGroup,
+		"port":         listenerConfig.Port,
+		"client":       client.RemoteAddr().String(),
+	})
+
+	pool := p.registry.LookupTcp(listenerConfig.RouterGroup, listenerConfig.Port)
+	if pool == nil {
+		logger.Info("unknown-tcp-route")
+		return
+	}
+
+	proxyProtocol := listenerConfig.ProxyProtocol || p.sendProxyHeader
+	p.forward(client, pool, listenerConfig.IdleTimeout, proxyProtocol, logger, p.reporter.CaptureTcpConnection)
+}
+
+// forward connects a client to an endpoint of the route and tunnels the
+// connection until it closes, then reports the bytes it carried. With the PROXY
+// protocol the endpoint is first sent the addresses of the client, so it sees
+// the address of the client rather than that of the router.
+func (p *Proxy) forward(client net.Conn, pool *route.Pool, idleTimeout time.Duration, proxyProtocol bool,
+	logger lager.Logger, capture func(b *route.Endpoint, bytesReceived, bytesSent int64)) {
+	iter := pool.Endpoints(p.defaultLoadBalance, "")
+	backend, endpoint, err := p.dial(iter, logger)
+	if err != nil {
+		logger.Error("tcp-connection-failed", err)
+		return
+	}
+	defer backend.Close()
+
+	iter.PreRequest(endpoint)
+	defer iter.PostRequest(endpoint)
+
+	if proxyProtocol {
+		err = proxyproto.HeaderOf(client).Write(backend, p.proxyHeaderVersion)
+		if err != nil {
+			logger.Error("proxy-protocol-header-failed", err)
+			return
+		}
+	}
+
+	startedAt := time.Now()
+	fromClient, toClient := handler.Tunnel(client, backend, idleTimeout, p.hijacked)
+
+	capture(endpoint, fromClient, toClient)
+	logger.Info("tcp-connection-closed", lager.Data{
+		"backend":           endpoint.CanonicalAddr(),
+		"bytes-from-client": fromClient,
+		"bytes-to-client":   toClient,
+		"duration":          time.Since(startedAt).String(),
+	})
+}
+
+// dial connects to an endpoint of the route, trying the next endpoint when a
+// connection fails.
+func (p *Proxy) dial(iter route.EndpointIterator, logger lager.Logger) (net.Conn, *route.Endpoint, error) {
+	var err error
+	for retry := 0; retry < handler.MaxRetries; retry++ {
+		endpoint := iter.Next()
+		if endpoint == nil {
+			return nil, nil, handler.NoEndpointsAvailable
+		}
+
+		var conn net.Conn
+		conn, err = net.DialTimeout("tcp", endpoint.CanonicalAddr(), p.dialTimeout)
+		if err == nil {
+			return conn, endpoint, nil
+		}
+
+		iter.EndpointFailed()
+		logger.Error("tcp-endpoint-failed", err, lager.Data{"backend": endpoint.CanonicalAddr()})
+	}
+	return nil, nil, err
+}
@@ -0,0 +1,257 @@
+package tcp_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/common/proxyproto"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/metrics/reporter/fakes"
+	"code.cloudfoundry.org/gorouter/proxy/handler"
+	"code.cloudfoundry.org/gorouter/proxy/tcp"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Proxy", func() {
+	var (
+		logger         *lagertest.TestLogger
+		c              *config.Config
+		r              *registry.RouteRegistry
+		p              *tcp.Proxy
+		fakeReporter   *fakes.FakeProxyReporter
+		hijacked       *handler.HijackedConns
+		listener       net.Listener
+		listenerConfig config.TCPListenerConfig
+		served         chan error
+	)
+
+	register := func(port int) {
+		endpoint := route.NewEndpoint("", "127.0.0.1", uint16(port), "", "", nil, -1, "", models.ModificationTag{})
+		r.RegisterTcp("default-tcp", 1024, endpoint)
+	}
+
+	startBackend := func(serve func(conn net.Conn)) net.Listener {
+		backend, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+
+		go func() {
+			for {
+				conn, err := backend.Accept()
+				if err != nil {
+					return
+				}
+				go serve(conn)
+			}
+		}()
+
+		register(backend.Addr().(*net.TCPAddr).Port)
+		return backend
+	}
+
+	echo := func(conn net.Conn) {
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}
+
+	dial := func() net.Conn {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		return conn
+	}
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		c = config.DefaultConfig()
+		c.EndpointDialTimeout = 100 * time.Millisecond
+
+		r = registry.NewRouteRegistry(logger, c, new(fakes.FakeRouteRegistryReporter))
+		fakeReporter = &fakes.FakeProxyReporter{}
+		hijacked = handler.NewHijackedConns()
+		listenerConfig = config.TCPListenerConfig{Port: 1024, RouterGroup: "default-tcp"}
+	})
+
+	JustBeforeEach(func() {
+		p = tcp.NewProxy(logger, c, r, fakeReporter, hijacked)
+
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+
+		served = make(chan error, 1)
+		go func(listenerConfig config.TCPListenerConfig) {
+			served <- p.Serve(listener, listenerConfig)
+		}(listenerConfig)
+	})
+
+	AfterEach(func() {
+		listener.Close()
+		Eventually(served).Should(Receive())
+	})
+
+	It("routes connections to an endpoint of the route", func() {
+		backend := startBackend(echo)
+		defer backend.Close()
+
+		conn := dial()
+		_, err := conn.Write([]byte("hello"))
+		Expect(err).NotTo(HaveOccurred())
+
+		buf := make([]byte, 5)
+		_, err = io.ReadFull(conn, buf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(buf)).To(Equal("hello"))
+
+		conn.(*net.TCPConn).CloseWrite()
+		_, err = conn.Read(buf)
+		Expect(err).To(Equal(io.EOF))
+		conn.Close()
+
+		Eventually(fakeReporter.CaptureTcpConnectionCallCount).Should(Equal(1))
+		endpoint, received, sent := fakeReporter.CaptureTcpConnectionArgsForCall(0)
+		Expect(endpoint.CanonicalAddr()).To(Equal(backend.Addr().String()))
+		Expect(received).To(Equal(int64(5)))
+		Expect(sent).To(Equal(int64(5)))
+	})
+
+	It("retries the next endpoint when a connection fails", func() {
+		closed, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		register(closed.Addr().(*net.TCPAddr).Port)
+		closed.Close()
+
+		backend := startBackend(echo)
+		defer backend.Close()
+
+		for i := 0; i < 2; i++ {
+			conn := dial()
+			_, err := conn.Write([]byte("hello"))
+			Expect(err).NotTo(HaveOccurred())
+
+			buf := make([]byte, 5)
+			_, err = io.ReadFull(conn, buf)
+			Expect(err).NotTo(HaveOccurred())
+			conn.Close()
+		}
+	})
+
+	It("closes connections to ports without endpoints", func() {
+		conn := dial()
+		defer conn.Close()
+
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, err := conn.Read(make([]byte, 1))
+		Expect(err).To(Equal(io.EOF))
+	})
+
+	It("tracks the connections so a drain can close them", func() {
+		backend := startBackend(echo)
+		defer backend.Close()
+
+		conn := dial()
+		defer conn.Close()
+
+		Eventually(hijacked.Len).Should(Equal(1))
+		Expect(hijacked.Close()).To(Equal(1))
+
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, err := conn.Read(make([]byte, 1))
+		Expect(err).To(Equal(io.EOF))
+	})
+
+	Context("when the listener has an idle timeout", func() {
+		BeforeEach(func() {
+			listenerConfig.IdleTimeout = 100 * time.Millisecond
+		})
+
+		It("closes idle connections", func() {
+			backend := startBackend(echo)
+			defer backend.Close()
+
+			conn := dial()
+			defer conn.Close()
+
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			_, err := conn.Read(make([]byte, 1))
+			Expect(err).To(Equal(io.EOF))
+		})
+	})
+
+	Context("when the listener sends the PROXY protocol", func() {
+		BeforeEach(func() {
+			listenerConfig.ProxyProtocol = true
+		})
+
+		It("sends the endpoint the addresses of the client", func() {
+			headers := make(chan string, 1)
+			backend := startBackend(func(conn net.Conn) {
+				defer conn.Close()
+				header, _ := bufio.NewReader(conn).ReadString('\n')
+				headers <- header
+			})
+			defer backend.Close()
+
+			conn := dial()
+			defer conn.Close()
+
+			local := conn.LocalAddr().(*net.TCPAddr)
+			remote := conn.RemoteAddr().(*net.TCPAddr)
+			Eventually(headers).Should(Receive(Equal(
+				fmt.Sprintf("PROXY TCP4 127.0.0.1 127.0.0.1 %d %d\r\n", local.Port, remote.Port))))
+		})
+
+		Context("with version 2 for endpoints", func() {
+			BeforeEach(func() {
+				c.ProxyProtocol.BackendVersion = 2
+			})
+
+			It("sends the endpoint a version 2 header", func() {
+				headers := make(chan *proxyproto.Header, 1)
+				backend := startBackend(func(conn net.Conn) {
+					defer conn.Close()
+					header, _ := proxyproto.ReadHeader(bufio.NewReader(conn))
+					headers <- header
+				})
+				defer backend.Close()
+
+				conn := dial()
+				defer conn.Close()
+
+				var header *proxyproto.Header
+				Eventually(headers).Should(Receive(&header))
+				Expect(header.Version).To(Equal(2))
+				Expect(header.Source.String()).To(Equal(conn.LocalAddr().String()))
+				Expect(header.Destination.String()).To(Equal(conn.RemoteAddr().String()))
+			})
+		})
+	})
+
+	Context("when the PROXY protocol is sent to all endpoints", func() {
+		BeforeEach(func() {
+			c.ProxyProtocol.SendToBackends = true
+		})
+
+		It("sends the endpoint the addresses of the client", func() {
+			headers := make(chan string, 1)
+			backend := startBackend(func(conn net.Conn) {
+				defer conn.Close()
+				header, _ := bufio.NewReader(conn).ReadString('\n')
+				headers <- header
+			})
+			defer backend.Close()
+
+			conn := dial()
+			defer conn.Close()
+
+			Eventually(headers).Should(Receive(HavePrefix("PROXY TCP4 127.0.0.1 127.0.0.1 ")))
+		})
+	})
+})
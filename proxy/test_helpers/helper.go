@@ -16,8 +16,19 @@ func (_ NullVarz) MarshalJSON() ([]byte, error)
 func (_ NullVarz) ActiveApps() *stats.ActiveApps                                                    { return stats.NewActiveApps() }
 func (_ NullVarz) CaptureBadRequest(*http.Request)                                                  {}
 func (_ NullVarz) CaptureBadGateway(*http.Request)                                                  {}
+func (_ NullVarz) CaptureBackendError(*http.Request, string)                                        {}
 func (_ NullVarz) CaptureRoutingRequest(b *route.Endpoint, req *http.Request)                       {}
 func (_ NullVarz) CaptureRoutingResponse(*route.Endpoint, *http.Response, time.Time, time.Duration) {}
 func (_ NullVarz) CaptureRouteServiceResponse(*route.Endpoint, *http.Response, time.Time, time.Duration) {
 }
-func (_ NullVarz) CaptureRegistryMessage(msg reporter.ComponentTagged) {}
+func (_ NullVarz) CaptureMirrorResponse(*route.Endpoint, *http.Response, time.Duration) {}
+func (_ NullVarz) CaptureMirrorSkipped(*http.Request)                                   {}
+func (_ NullVarz) CaptureIPDenied(*http.Request)                                        {}
+func (_ NullVarz) CaptureCacheHit(*http.Request)                                        {}
+func (_ NullVarz) CaptureCacheMiss(*http.Request)                                       {}
+func (_ NullVarz) CaptureBackendConnection(*route.Endpoint, bool)                       {}
+func (_ NullVarz) CaptureActiveWebSockets(int64)                                        {}
+func (_ NullVarz) CaptureTcpConnection(*route.Endpoint, int64, int64)                   {}
+func (_ NullVarz) CaptureTLSPassthroughConnection(*route.Endpoint, int64, int64)        {}
+func (_ NullVarz) CaptureRegistryMessage(msg reporter.ComponentTagged)                  {}
+func (_ NullVarz) CaptureRegistryQueue(int, time.Duration)                              {}
@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/common/proxyproto"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/proxy/handler"
+	"code.cloudfoundry.org/gorouter/proxy/round_tripper"
+	"code.cloudfoundry.org/gorouter/routeservice"
+	"code.cloudfoundry.org/lager"
+)
+
+// serveWebSocket sends a websocket upgrade through the round tripper like any
+// other request, so it is retried, sent to route services and recorded the same
+// way. The connection is only tunneled once the endpoint switches protocols.
+func (p *proxy) serveWebSocket(h *handler.RequestHandler, roundTripper http.RoundTripper, request *http.Request,
+	routeServiceArgs routeservice.RouteServiceRequest) {
+	h.Logger().Info("handling-websocket-request", lager.Data{"Upgrade": "websocket"})
+
+	outreq := request.Clone(request.Context())
+	if request.ContentLength == 0 {
+		outreq.Body = nil
+	}
+	outreq.RequestURI = ""
+	outreq.Close = false
+
+	setupProxyRequest(request, outreq, p.forwardedHeaders, p.forceForwardedProtoHttps)
+	handleRouteServiceIntegration(outreq, routeServiceArgs, p.routeServiceConfig)
+	if p.forwardedHeaders != config.FORWARDED_RFC7239 {
+		handler.SetRequestXForwardedFor(outreq)
+	}
+	if header := p.proxyHeader(request); header != nil {
+		outreq = round_tripper.WithProxyHeader(outreq, header)
+	}
+
+	res, err := roundTripper.RoundTrip(outreq)
+	if err != nil {
+		// the round tripper has already responded with the error
+		return
+	}
+
+	h.HandleWebSocketResponse(res, p.websockets, p.hijacked)
+}
+
+// proxyHeader returns the PROXY protocol header to send the endpoint of an
+// upgraded connection, with the addresses of the client and the TLVs of the
+// header of its own connection, or nil when headers are not sent to endpoints.
+func (p *proxy) proxyHeader(request *http.Request) []byte {
+	if !p.sendProxyHeader {
+		return nil
+	}
+
+	header := &proxyproto.Header{}
+	if h := proxyproto.FromContext(request.Context()); h != nil {
+		header.TLVs = h.TLVs
+	}
+	header.Source, _ = net.ResolveTCPAddr("tcp", request.RemoteAddr)
+	header.Destination, _ = request.Context().Value(http.LocalAddrContextKey).(*net.TCPAddr)
+
+	// the version is validated with the config
+	buf := &bytes.Buffer{}
+	header.Write(buf, p.proxyHeaderVersion)
+	return buf.Bytes()
+}
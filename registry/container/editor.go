@@ -0,0 +1,132 @@
+package container
+
+import (
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// Editor changes a trie without changing any node of it, so the trie can be
+// read while it is edited. It copies the nodes on the paths it changes and
+// shares the others with the trie. The nodes it copies have no Parent, as the
+// nodes they share would keep pointing to the parents they were copied from.
+type Editor struct {
+	root  *Trie
+	owned map[*Trie]bool
+}
+
+// Edit returns an editor of the trie.
+func (r *Trie) Edit() *Editor {
+	return &Editor{root: r}
+}
+
+// Find returns the pool of the URI as edited so far, nil if there is none.
+func (e *Editor) Find(uri route.Uri) *route.Pool {
+	return e.root.Find(uri)
+}
+
+// Trie returns the edited trie, which is the trie itself when nothing changed.
+// The editor must not be used after.
+func (e *Editor) Trie() *Trie {
+	e.owned = nil
+	return e.root
+}
+
+// Insert sets the pool of the URI.
+func (e *Editor) Insert(uri route.Uri, value *route.Pool) {
+	e.root = e.own(e.root)
+	node := e.root
+
+	for i, segment := range segments(uri) {
+		child, ok := node.ChildNodes[segment]
+		if ok {
+			child = e.replace(node, child)
+		} else {
+			child = NewTrie()
+			child.Segment = segment
+			e.owned[child] = true
+
+			node.ChildNodes[segment] = child
+			if i > 0 {
+				node.indexChild(child)
+			}
+		}
+		node = child
+	}
+
+	node.Pool = value
+}
+
+// Delete removes the pool of the URI, and the nodes left without pools or
+// children. It returns false if the URI has no pool.
+func (e *Editor) Delete(uri route.Uri) bool {
+	if e.root.Find(uri) == nil {
+		return false
+	}
+
+	e.root = e.own(e.root)
+	path := []*Trie{e.root}
+	for _, segment := range segments(uri) {
+		node := path[len(path)-1]
+		path = append(path, e.replace(node, node.ChildNodes[segment]))
+	}
+
+	path[len(path)-1].Pool = nil
+	for i := len(path) - 1; i > 0; i-- {
+		if path[i].Pool != nil || !path[i].isLeaf() {
+			break
+		}
+		path[i-1].removeChild(path[i].Segment)
+	}
+
+	return true
+}
+
+// replace makes a child of a node the editor owns its own, and returns it.
+func (e *Editor) replace(node, child *Trie) *Trie {
+	owned := e.own(child)
+	if owned == child {
+		return child
+	}
+
+	node.ChildNodes[child.Segment] = owned
+	if node.wildcardChild == child {
+		node.wildcardChild = owned
+	}
+	for i, c := range node.patternChildren {
+		if c == child {
+			node.patternChildren[i] = owned
+		}
+	}
+	return owned
+}
+
+// own returns the node if the editor copied it, or a copy of it.
+func (e *Editor) own(node *Trie) *Trie {
+	if e.owned[node] {
+		return node
+	}
+	if e.owned == nil {
+		e.owned = make(map[*Trie]bool)
+	}
+
+	copied := &Trie{
+		Segment:         node.Segment,
+		Pool:            node.Pool,
+		ChildNodes:      make(map[string]*Trie, len(node.ChildNodes)),
+		pattern:         node.pattern,
+		wildcard:        node.wildcard,
+		wildcardChild:   node.wildcardChild,
+		patternChildren: append([]*Trie(nil), node.patternChildren...),
+	}
+	for segment, child := range node.ChildNodes {
+		copied.ChildNodes[segment] = child
+	}
+
+	e.owned[copied] = true
+	return copied
+}
+
+func segments(uri route.Uri) []string {
+	return strings.Split(strings.TrimPrefix(uri.String(), "/"), "/")
+}
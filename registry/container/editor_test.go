@@ -0,0 +1,87 @@
+package container_test
+
+import (
+	"code.cloudfoundry.org/gorouter/route"
+
+	"code.cloudfoundry.org/gorouter/registry/container"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Editor", func() {
+
+	var (
+		r      *container.Trie
+		p1, p2 *route.Pool
+	)
+
+	BeforeEach(func() {
+		r = container.NewTrie()
+		p1 = route.NewPool(0, "")
+		p2 = route.NewPool(0, "")
+		r.Insert("foo.com/users", p1)
+	})
+
+	It("inserts pools without changing the trie", func() {
+		e := r.Edit()
+		e.Insert("foo.com/users/*/avatar", p2)
+		e.Insert("bar.com", p2)
+		Expect(e.Find("bar.com")).To(Equal(p2))
+
+		edited := e.Trie()
+		Expect(edited.MatchUri("foo.com/users/42/avatar")).To(Equal(p2))
+		Expect(edited.MatchUri("foo.com/users")).To(Equal(p1))
+		Expect(edited.Find("bar.com")).To(Equal(p2))
+		Expect(edited.PoolCount()).To(Equal(3))
+
+		Expect(r.MatchUri("foo.com/users/42/avatar")).To(Equal(p1))
+		Expect(r.Find("bar.com")).To(BeNil())
+		Expect(r.PoolCount()).To(Equal(1))
+	})
+
+	It("deletes pools and the nodes left empty without changing the trie", func() {
+		r.Insert("foo.com/users/{[0-9]+}/avatar", p2)
+
+		e := r.Edit()
+		Expect(e.Delete("foo.com/users/{[0-9]+}/avatar")).To(BeTrue())
+		Expect(e.Delete("foo.com/orders")).To(BeFalse())
+
+		edited := e.Trie()
+		Expect(edited.MatchUri("foo.com/users/42/avatar")).To(Equal(p1))
+		Expect(edited.ChildNodes["foo.com"].ChildNodes["users"].ChildNodes).To(BeEmpty())
+
+		Expect(r.MatchUri("foo.com/users/42/avatar")).To(Equal(p2))
+	})
+
+	It("shares the nodes it does not change", func() {
+		r.Insert("bar.com/orders", p2)
+
+		e := r.Edit()
+		e.Insert("foo.com/users/me", p2)
+		edited := e.Trie()
+
+		Expect(edited).ToNot(BeIdenticalTo(r))
+		Expect(edited.ChildNodes["foo.com"]).ToNot(BeIdenticalTo(r.ChildNodes["foo.com"]))
+		Expect(edited.ChildNodes["bar.com"]).To(BeIdenticalTo(r.ChildNodes["bar.com"]))
+	})
+
+	It("returns the trie itself when nothing changed", func() {
+		e := r.Edit()
+		Expect(e.Find("foo.com/users")).To(Equal(p1))
+		Expect(e.Trie()).To(BeIdenticalTo(r))
+	})
+
+	It("edits the trie it edited", func() {
+		e := r.Edit()
+		e.Insert("foo.com/users/*", p2)
+		edited := e.Trie()
+
+		e = edited.Edit()
+		Expect(e.Delete("foo.com/users")).To(BeTrue())
+		again := e.Trie()
+
+		Expect(again.MatchUri("foo.com/users/42")).To(Equal(p2))
+		Expect(again.MatchUri("foo.com/users")).To(BeNil())
+		Expect(edited.MatchUri("foo.com/users")).To(Equal(p1))
+	})
+})
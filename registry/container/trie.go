@@ -1,17 +1,58 @@
 package container
 
 import (
+	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 
 	"code.cloudfoundry.org/gorouter/route"
 )
 
+// WildcardSegment matches any single, non-empty path segment.
+const WildcardSegment = "*"
+
 // package name inspired by golang package that includes heap, list and ring.
+//
+// A trie that may be read concurrently, such as the routes published by the
+// registry, must only be changed through Edit. Insert, Delete, Snip and
+// PruneDeadLeaves change the nodes in place, and they and ToPath rely on the
+// Parent of the nodes, which the nodes copied by an Editor do not have.
 type Trie struct {
 	Segment    string
 	Pool       *route.Pool
 	ChildNodes map[string]*Trie
 	Parent     *Trie
+
+	// pattern is set when Segment is a regular expression of the form {regex}
+	pattern *regexp.Regexp
+	// wildcard is set when Segment is the wildcard segment below the host
+	wildcard bool
+
+	// wildcardChild and patternChildren index the entries of ChildNodes
+	// that are matched by MatchUri after the exact segment.
+	wildcardChild   *Trie
+	patternChildren []*Trie
+}
+
+// ValidatePattern returns an error if a path segment of the URI is a
+// malformed pattern. Patterns are matched against the lower-cased request
+// path, so they must not contain upper-case characters or a '?'.
+func ValidatePattern(uri route.Uri) error {
+	segments := strings.Split(strings.TrimPrefix(uri.String(), "/"), "/")
+	for _, segment := range segments[1:] {
+		expr, ok := patternExpr(segment)
+		if !ok {
+			continue
+		}
+		if strings.ContainsAny(expr, "?") || strings.ToLower(expr) != expr {
+			return fmt.Errorf("route pattern %s must not contain '?' or upper-case characters", segment)
+		}
+		if _, err := compilePattern(expr); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Find returns a *route.Pool that matches exactly the URI parameter, nil if no match was found.
@@ -45,42 +86,50 @@ func (r *Trie) Find(uri route.Uri) *route.Pool {
 }
 
 // MatchUri returns the longest route that matches the URI parameter, nil if nothing matches.
+// At every path segment an exact match takes precedence over a wildcard segment,
+// which takes precedence over a regular expression segment. A branch that does not
+// lead to a pool falls back to the next candidate, so each node is visited at most once.
 func (r *Trie) MatchUri(uri route.Uri) *route.Pool {
 	key := strings.TrimPrefix(uri.String(), "/")
-	node := r
-	var lastPool *route.Pool
+	return r.match(parts(key))
+}
 
-	for {
-		pathParts := parts(key)
-		SegmentValue := pathParts[0]
+func (r *Trie) match(pathParts []string) *route.Pool {
+	SegmentValue := pathParts[0]
 
-		matchingChild, ok := node.ChildNodes[SegmentValue]
-		if !ok {
-			break
+	if child, ok := r.ChildNodes[SegmentValue]; ok && !child.isPattern() {
+		if pool := child.matchRemaining(pathParts); pool != nil {
+			return pool
 		}
+	}
 
-		node = matchingChild
-
-		if nil != node.Pool {
-			lastPool = node.Pool
+	if r.wildcardChild != nil && SegmentValue != "" {
+		if pool := r.wildcardChild.matchRemaining(pathParts); pool != nil {
+			return pool
 		}
+	}
 
-		if len(pathParts) <= 1 {
-			break
+	for _, child := range r.patternChildren {
+		if !child.pattern.MatchString(SegmentValue) {
+			continue
+		}
+		if pool := child.matchRemaining(pathParts); pool != nil {
+			return pool
 		}
-
-		key = pathParts[1]
 	}
 
-	if nil != node.Pool {
-		return node.Pool
-	}
+	return nil
+}
 
-	if nil != lastPool {
-		return lastPool
+// matchRemaining returns the longest match below this node, or the pool of the node itself.
+func (r *Trie) matchRemaining(pathParts []string) *route.Pool {
+	if len(pathParts) > 1 {
+		if pool := r.match(parts(pathParts[1])); pool != nil {
+			return pool
+		}
 	}
 
-	return nil
+	return r.Pool
 }
 
 func (r *Trie) Insert(uri route.Uri, value *route.Pool) *Trie {
@@ -97,7 +146,7 @@ func (r *Trie) Insert(uri route.Uri, value *route.Pool) *Trie {
 			matchingChild = NewTrie()
 			matchingChild.Segment = SegmentValue
 			matchingChild.Parent = node
-			node.ChildNodes[SegmentValue] = matchingChild
+			node.addChild(matchingChild)
 		}
 
 		node = matchingChild
@@ -169,7 +218,7 @@ func (r *Trie) deleteEmptyNodes(key string) {
 
 	if node.isLeaf() {
 		nodeToRemove.Parent = nil
-		delete(nodeToKeep.ChildNodes, nodeToRemove.Segment)
+		nodeToKeep.removeChild(nodeToRemove.Segment)
 	}
 }
 
@@ -238,7 +287,7 @@ func (r *Trie) Snip() {
 	if (r.Pool != nil && !r.Pool.IsEmpty()) || r.isRoot() || !r.isLeaf() {
 		return
 	}
-	delete(r.Parent.ChildNodes, r.Segment)
+	r.Parent.removeChild(r.Segment)
 	r.Parent.Snip()
 }
 
@@ -271,6 +320,57 @@ func (r *Trie) toMap(segment string, m map[route.Uri]*route.Pool) map[route.Uri]
 	return m
 }
 
+// addChild indexes wildcard and pattern segments below the host segment.
+func (r *Trie) addChild(child *Trie) {
+	r.ChildNodes[child.Segment] = child
+	if !r.isRoot() {
+		r.indexChild(child)
+	}
+}
+
+// indexChild indexes a wildcard or pattern segment for MatchUri; segments that
+// fail to compile are kept as literals.
+func (r *Trie) indexChild(child *Trie) {
+	if child.Segment == WildcardSegment {
+		child.wildcard = true
+		r.wildcardChild = child
+		return
+	}
+
+	if expr, ok := patternExpr(child.Segment); ok {
+		pattern, err := compilePattern(expr)
+		if err != nil {
+			return
+		}
+		child.pattern = pattern
+		r.patternChildren = append(r.patternChildren, child)
+		sort.Sort(bySegment(r.patternChildren))
+	}
+}
+
+func (r *Trie) removeChild(segment string) {
+	child, ok := r.ChildNodes[segment]
+	if !ok {
+		return
+	}
+	delete(r.ChildNodes, segment)
+
+	if child == r.wildcardChild {
+		r.wildcardChild = nil
+	}
+
+	for i, c := range r.patternChildren {
+		if c == child {
+			r.patternChildren = append(r.patternChildren[:i], r.patternChildren[i+1:]...)
+			break
+		}
+	}
+}
+
+func (r *Trie) isPattern() bool {
+	return r.pattern != nil || r.wildcard
+}
+
 func (r *Trie) isRoot() bool {
 	return r.Parent == nil
 }
@@ -282,3 +382,22 @@ func (r *Trie) isLeaf() bool {
 func parts(key string) []string {
 	return strings.SplitN(key, "/", 2)
 }
+
+// patternExpr returns the regular expression of a segment of the form {regex}.
+func patternExpr(segment string) (string, bool) {
+	if len(segment) < 3 || segment[0] != '{' || segment[len(segment)-1] != '}' {
+		return "", false
+	}
+	return segment[1 : len(segment)-1], true
+}
+
+// compilePattern anchors the expression so that it must match the whole segment.
+func compilePattern(expr string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+type bySegment []*Trie
+
+func (s bySegment) Len() int           { return len(s) }
+func (s bySegment) Less(i, j int) bool { return s[i].Segment < s[j].Segment }
+func (s bySegment) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,57 @@
+package container_test
+
+import (
+	"fmt"
+	"testing"
+
+	"code.cloudfoundry.org/gorouter/registry/container"
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+func matchUriFor(kind string, b *testing.B) {
+	trie := container.NewTrie()
+	total := 1000
+
+	var segment, request string
+	switch kind {
+	case "exact":
+		segment = "avatar"
+		request = "avatar"
+	case "wildcard":
+		segment = "*"
+		request = "42"
+	case "regex":
+		segment = "{[0-9]+}"
+		request = "42"
+	default:
+		panic("invalid route kind")
+	}
+
+	for i := 0; i < total; i++ {
+		uri := route.Uri(fmt.Sprintf("app-%d.example.com/users/%s/settings", i, segment))
+		trie.Insert(uri, route.NewPool(0, ""))
+		// sibling routes that share a prefix but do not match
+		trie.Insert(route.Uri(fmt.Sprintf("app-%d.example.com/users/me/profile", i)), route.NewPool(0, ""))
+	}
+
+	uri := route.Uri(fmt.Sprintf("app-%d.example.com/users/%s/settings/extra", total/2, request))
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if trie.MatchUri(uri) == nil {
+			b.Fatal("expected a match")
+		}
+	}
+}
+
+func BenchmarkMatchUriExact(b *testing.B) {
+	matchUriFor("exact", b)
+}
+
+func BenchmarkMatchUriWildcard(b *testing.B) {
+	matchUriFor("wildcard", b)
+}
+
+func BenchmarkMatchUriRegex(b *testing.B) {
+	matchUriFor("regex", b)
+}
@@ -97,6 +97,111 @@ var _ = Describe("Trie", func() {
 			node := r.MatchUri("/foo/bar")
 			Expect(node).To(Equal(p1))
 		})
+
+		Context("with wildcard segments", func() {
+			It("matches any single segment", func() {
+				p := route.NewPool(42, "")
+				r.Insert("foo.com/users/*/avatar", p)
+				Expect(r.MatchUri("foo.com/users/123/avatar")).To(Equal(p))
+				Expect(r.MatchUri("foo.com/users/abc/avatar/small")).To(Equal(p))
+				Expect(r.MatchUri("foo.com/users/123/profile")).To(BeNil())
+				Expect(r.MatchUri("foo.com/users/avatar")).To(BeNil())
+			})
+
+			It("does not treat the host segment as a wildcard", func() {
+				p := route.NewPool(42, "")
+				r.Insert("*/users", p)
+				Expect(r.MatchUri("foo.com/users")).To(BeNil())
+				Expect(r.MatchUri("*/users")).To(Equal(p))
+			})
+
+			It("prefers exact segments to wildcard segments", func() {
+				p1 := route.NewPool(42, "")
+				p2 := route.NewPool(42, "")
+				r.Insert("foo.com/users/*/avatar", p1)
+				r.Insert("foo.com/users/me/avatar", p2)
+				Expect(r.MatchUri("foo.com/users/me/avatar")).To(Equal(p2))
+				Expect(r.MatchUri("foo.com/users/you/avatar")).To(Equal(p1))
+			})
+
+			It("falls back to a wildcard when the exact branch does not match", func() {
+				p1 := route.NewPool(42, "")
+				p2 := route.NewPool(42, "")
+				r.Insert("foo.com/users/*/avatar", p1)
+				r.Insert("foo.com/users/me/settings", p2)
+				Expect(r.MatchUri("foo.com/users/me/avatar")).To(Equal(p1))
+			})
+
+			It("falls back to the longest prefix when no pattern matches", func() {
+				p1 := route.NewPool(42, "")
+				p2 := route.NewPool(42, "")
+				r.Insert("foo.com/users", p1)
+				r.Insert("foo.com/users/*/avatar", p2)
+				Expect(r.MatchUri("foo.com/users/123/profile")).To(Equal(p1))
+			})
+		})
+
+		Context("with regular expression segments", func() {
+			It("matches segments against the anchored expression", func() {
+				p := route.NewPool(42, "")
+				r.Insert("foo.com/orders/{[0-9]+}", p)
+				Expect(r.MatchUri("foo.com/orders/42")).To(Equal(p))
+				Expect(r.MatchUri("foo.com/orders/42/items")).To(Equal(p))
+				Expect(r.MatchUri("foo.com/orders/a42")).To(BeNil())
+				Expect(r.MatchUri("foo.com/orders/42a")).To(BeNil())
+			})
+
+			It("prefers exact, then wildcard, then regular expression segments", func() {
+				exact := route.NewPool(42, "")
+				wildcard := route.NewPool(42, "")
+				regex := route.NewPool(42, "")
+				r.Insert("foo.com/orders/{[0-9]+}", regex)
+				r.Insert("foo.com/orders/*", wildcard)
+				r.Insert("foo.com/orders/latest", exact)
+
+				Expect(r.MatchUri("foo.com/orders/latest")).To(Equal(exact))
+				Expect(r.MatchUri("foo.com/orders/42")).To(Equal(wildcard))
+
+				r.Delete("foo.com/orders/*")
+				Expect(r.MatchUri("foo.com/orders/42")).To(Equal(regex))
+				Expect(r.MatchUri("foo.com/orders/abc")).To(BeNil())
+			})
+
+			It("evaluates expressions in a stable order", func() {
+				p1 := route.NewPool(42, "")
+				p2 := route.NewPool(42, "")
+				r.Insert("foo.com/{[a-z]+}", p2)
+				r.Insert("foo.com/{[a-c]+}", p1)
+				Expect(r.MatchUri("foo.com/abc")).To(Equal(p1))
+				Expect(r.MatchUri("foo.com/xyz")).To(Equal(p2))
+			})
+
+			It("treats invalid expressions as literal segments", func() {
+				p := route.NewPool(42, "")
+				r.Insert("foo.com/{[0-9}", p)
+				Expect(r.MatchUri("foo.com/1")).To(BeNil())
+				Expect(r.MatchUri("foo.com/{[0-9}")).To(Equal(p))
+			})
+		})
+	})
+
+	Describe("ValidatePattern", func() {
+		It("accepts literal, wildcard and regular expression segments", func() {
+			Expect(container.ValidatePattern("foo.com/users/*/{[a-z0-9]+}")).To(Succeed())
+		})
+
+		It("ignores the host segment", func() {
+			Expect(container.ValidatePattern("{foo.com")).To(Succeed())
+		})
+
+		It("rejects expressions that do not compile", func() {
+			Expect(container.ValidatePattern("foo.com/{[0-9}")).NotTo(Succeed())
+		})
+
+		It("rejects expressions that would be altered by the route key", func() {
+			Expect(container.ValidatePattern("foo.com/{colou?r}")).NotTo(Succeed())
+			Expect(container.ValidatePattern(`foo.com/{\D+}`)).NotTo(Succeed())
+		})
 	})
 
 	Describe(".Insert", func() {
@@ -321,6 +426,21 @@ var _ = Describe("Trie", func() {
 			Expect(fooNode.ChildNodes).To(HaveLen(0))
 		})
 
+		It("removes wildcard and pattern segments from matching", func() {
+			p1 := route.NewPool(42, "")
+			p2 := route.NewPool(42, "")
+
+			wildcardNode := r.Insert("foo.com/*", p1)
+			patternNode := r.Insert("foo.com/{[0-9]+}", p2)
+
+			wildcardNode.Snip()
+			patternNode.Snip()
+			Expect(r.ChildNodes).To(BeEmpty())
+
+			r.Insert("foo.com/bar", route.NewPool(42, ""))
+			Expect(r.MatchUri("foo.com/1")).To(BeNil())
+		})
+
 		It("deletes empty pools", func() {
 			p1 := route.NewPool(42, "")
 			p2 := route.NewPool(42, "")
@@ -21,6 +21,12 @@ type FakeRegistryInterface struct {
 		uri      route.Uri
 		endpoint *route.Endpoint
 	}
+	SetWeightsStub        func(uri route.Uri, weights map[string]int)
+	setWeightsMutex       sync.RWMutex
+	setWeightsArgsForCall []struct {
+		uri     route.Uri
+		weights map[string]int
+	}
 	LookupStub        func(uri route.Uri) *route.Pool
 	lookupMutex       sync.RWMutex
 	lookupArgsForCall []struct {
@@ -64,6 +70,34 @@ type FakeRegistryInterface struct {
 		result1 []byte
 		result2 error
 	}
+	RegisterTcpStub        func(routerGroup string, port uint16, endpoint *route.Endpoint)
+	registerTcpMutex       sync.RWMutex
+	registerTcpArgsForCall []struct {
+		routerGroup string
+		port        uint16
+		endpoint    *route.Endpoint
+	}
+	UnregisterTcpStub        func(routerGroup string, port uint16, endpoint *route.Endpoint)
+	unregisterTcpMutex       sync.RWMutex
+	unregisterTcpArgsForCall []struct {
+		routerGroup string
+		port        uint16
+		endpoint    *route.Endpoint
+	}
+	ApplyStub        func(changes []registry.Change)
+	applyMutex       sync.RWMutex
+	applyArgsForCall []struct {
+		changes []registry.Change
+	}
+	LookupTcpStub        func(routerGroup string, port uint16) *route.Pool
+	lookupTcpMutex       sync.RWMutex
+	lookupTcpArgsForCall []struct {
+		routerGroup string
+		port        uint16
+	}
+	lookupTcpReturns struct {
+		result1 *route.Pool
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -118,6 +152,31 @@ func (fake *FakeRegistryInterface) UnregisterArgsForCall(i int) (route.Uri, *rou
 	return fake.unregisterArgsForCall[i].uri, fake.unregisterArgsForCall[i].endpoint
 }
 
+func (fake *FakeRegistryInterface) SetWeights(uri route.Uri, weights map[string]int) {
+	fake.setWeightsMutex.Lock()
+	fake.setWeightsArgsForCall = append(fake.setWeightsArgsForCall, struct {
+		uri     route.Uri
+		weights map[string]int
+	}{uri, weights})
+	fake.recordInvocation("SetWeights", []interface{}{uri, weights})
+	fake.setWeightsMutex.Unlock()
+	if fake.SetWeightsStub != nil {
+		fake.SetWeightsStub(uri, weights)
+	}
+}
+
+func (fake *FakeRegistryInterface) SetWeightsCallCount() int {
+	fake.setWeightsMutex.RLock()
+	defer fake.setWeightsMutex.RUnlock()
+	return len(fake.setWeightsArgsForCall)
+}
+
+func (fake *FakeRegistryInterface) SetWeightsArgsForCall(i int) (route.Uri, map[string]int) {
+	fake.setWeightsMutex.RLock()
+	defer fake.setWeightsMutex.RUnlock()
+	return fake.setWeightsArgsForCall[i].uri, fake.setWeightsArgsForCall[i].weights
+}
+
 func (fake *FakeRegistryInterface) Lookup(uri route.Uri) *route.Pool {
 	fake.lookupMutex.Lock()
 	fake.lookupArgsForCall = append(fake.lookupArgsForCall, struct {
@@ -289,6 +348,120 @@ func (fake *FakeRegistryInterface) MarshalJSONReturns(result1 []byte, result2 er
 	}{result1, result2}
 }
 
+func (fake *FakeRegistryInterface) RegisterTcp(routerGroup string, port uint16, endpoint *route.Endpoint) {
+	fake.registerTcpMutex.Lock()
+	fake.registerTcpArgsForCall = append(fake.registerTcpArgsForCall, struct {
+		routerGroup string
+		port        uint16
+		endpoint    *route.Endpoint
+	}{routerGroup, port, endpoint})
+	fake.recordInvocation("RegisterTcp", []interface{}{routerGroup, port, endpoint})
+	fake.registerTcpMutex.Unlock()
+	if fake.RegisterTcpStub != nil {
+		fake.RegisterTcpStub(routerGroup, port, endpoint)
+	}
+}
+
+func (fake *FakeRegistryInterface) RegisterTcpCallCount() int {
+	fake.registerTcpMutex.RLock()
+	defer fake.registerTcpMutex.RUnlock()
+	return len(fake.registerTcpArgsForCall)
+}
+
+func (fake *FakeRegistryInterface) RegisterTcpArgsForCall(i int) (string, uint16, *route.Endpoint) {
+	fake.registerTcpMutex.RLock()
+	defer fake.registerTcpMutex.RUnlock()
+	return fake.registerTcpArgsForCall[i].routerGroup, fake.registerTcpArgsForCall[i].port, fake.registerTcpArgsForCall[i].endpoint
+}
+
+func (fake *FakeRegistryInterface) UnregisterTcp(routerGroup string, port uint16, endpoint *route.Endpoint) {
+	fake.unregisterTcpMutex.Lock()
+	fake.unregisterTcpArgsForCall = append(fake.unregisterTcpArgsForCall, struct {
+		routerGroup string
+		port        uint16
+		endpoint    *route.Endpoint
+	}{routerGroup, port, endpoint})
+	fake.recordInvocation("UnregisterTcp", []interface{}{routerGroup, port, endpoint})
+	fake.unregisterTcpMutex.Unlock()
+	if fake.UnregisterTcpStub != nil {
+		fake.UnregisterTcpStub(routerGroup, port, endpoint)
+	}
+}
+
+func (fake *FakeRegistryInterface) UnregisterTcpCallCount() int {
+	fake.unregisterTcpMutex.RLock()
+	defer fake.unregisterTcpMutex.RUnlock()
+	return len(fake.unregisterTcpArgsForCall)
+}
+
+func (fake *FakeRegistryInterface) UnregisterTcpArgsForCall(i int) (string, uint16, *route.Endpoint) {
+	fake.unregisterTcpMutex.RLock()
+	defer fake.unregisterTcpMutex.RUnlock()
+	return fake.unregisterTcpArgsForCall[i].routerGroup, fake.unregisterTcpArgsForCall[i].port, fake.unregisterTcpArgsForCall[i].endpoint
+}
+
+func (fake *FakeRegistryInterface) Apply(changes []registry.Change) {
+	var changesCopy []registry.Change
+	if changes != nil {
+		changesCopy = make([]registry.Change, len(changes))
+		copy(changesCopy, changes)
+	}
+	fake.applyMutex.Lock()
+	fake.applyArgsForCall = append(fake.applyArgsForCall, struct {
+		changes []registry.Change
+	}{changesCopy})
+	fake.recordInvocation("Apply", []interface{}{changesCopy})
+	fake.applyMutex.Unlock()
+	if fake.ApplyStub != nil {
+		fake.ApplyStub(changes)
+	}
+}
+
+func (fake *FakeRegistryInterface) ApplyCallCount() int {
+	fake.applyMutex.RLock()
+	defer fake.applyMutex.RUnlock()
+	return len(fake.applyArgsForCall)
+}
+
+func (fake *FakeRegistryInterface) ApplyArgsForCall(i int) []registry.Change {
+	fake.applyMutex.RLock()
+	defer fake.applyMutex.RUnlock()
+	return fake.applyArgsForCall[i].changes
+}
+
+func (fake *FakeRegistryInterface) LookupTcp(routerGroup string, port uint16) *route.Pool {
+	fake.lookupTcpMutex.Lock()
+	fake.lookupTcpArgsForCall = append(fake.lookupTcpArgsForCall, struct {
+		routerGroup string
+		port        uint16
+	}{routerGroup, port})
+	fake.recordInvocation("LookupTcp", []interface{}{routerGroup, port})
+	fake.lookupTcpMutex.Unlock()
+	if fake.LookupTcpStub != nil {
+		return fake.LookupTcpStub(routerGroup, port)
+	}
+	return fake.lookupTcpReturns.result1
+}
+
+func (fake *FakeRegistryInterface) LookupTcpCallCount() int {
+	fake.lookupTcpMutex.RLock()
+	defer fake.lookupTcpMutex.RUnlock()
+	return len(fake.lookupTcpArgsForCall)
+}
+
+func (fake *FakeRegistryInterface) LookupTcpArgsForCall(i int) (string, uint16) {
+	fake.lookupTcpMutex.RLock()
+	defer fake.lookupTcpMutex.RUnlock()
+	return fake.lookupTcpArgsForCall[i].routerGroup, fake.lookupTcpArgsForCall[i].port
+}
+
+func (fake *FakeRegistryInterface) LookupTcpReturns(result1 *route.Pool) {
+	fake.LookupTcpStub = nil
+	fake.lookupTcpReturns = struct {
+		result1 *route.Pool
+	}{result1}
+}
+
 func (fake *FakeRegistryInterface) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -296,6 +469,8 @@ func (fake *FakeRegistryInterface) Invocations() map[string][][]interface{} {
 	defer fake.registerMutex.RUnlock()
 	fake.unregisterMutex.RLock()
 	defer fake.unregisterMutex.RUnlock()
+	fake.setWeightsMutex.RLock()
+	defer fake.setWeightsMutex.RUnlock()
 	fake.lookupMutex.RLock()
 	defer fake.lookupMutex.RUnlock()
 	fake.lookupWithInstanceMutex.RLock()
@@ -310,6 +485,14 @@ func (fake *FakeRegistryInterface) Invocations() map[string][][]interface{} {
 	defer fake.numEndpointsMutex.RUnlock()
 	fake.marshalJSONMutex.RLock()
 	defer fake.marshalJSONMutex.RUnlock()
+	fake.registerTcpMutex.RLock()
+	defer fake.registerTcpMutex.RUnlock()
+	fake.unregisterTcpMutex.RLock()
+	defer fake.unregisterTcpMutex.RUnlock()
+	fake.applyMutex.RLock()
+	defer fake.applyMutex.RUnlock()
+	fake.lookupTcpMutex.RLock()
+	defer fake.lookupTcpMutex.RUnlock()
 	return fake.invocations
 }
 
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"code.cloudfoundry.org/gorouter/config"
@@ -17,8 +18,13 @@ import (
 type RegistryInterface interface {
 	Register(uri route.Uri, endpoint *route.Endpoint)
 	Unregister(uri route.Uri, endpoint *route.Endpoint)
+	SetWeights(uri route.Uri, weights map[string]int)
 	Lookup(uri route.Uri) *route.Pool
 	LookupWithInstance(uri route.Uri, appId, appIndex string) *route.Pool
+	RegisterTcp(routerGroup string, port uint16, endpoint *route.Endpoint)
+	UnregisterTcp(routerGroup string, port uint16, endpoint *route.Endpoint)
+	Apply(changes []Change)
+	LookupTcp(routerGroup string, port uint16) *route.Pool
 	StartPruningCycle()
 	StopPruningCycle()
 	NumUris() int
@@ -26,6 +32,21 @@ type RegistryInterface interface {
 	MarshalJSON() ([]byte, error)
 }
 
+// Change registers or unregisters an endpoint. Changes with a router group are
+// of the TCP route of a port in the group, the others of the route of a URI.
+type Change struct {
+	Register    bool
+	Uri         route.Uri
+	RouterGroup string
+	Port        uint16
+	Endpoint    *route.Endpoint
+}
+
+type tcpRouteKey struct {
+	routerGroup string
+	port        uint16
+}
+
 type PruneStatus int
 
 const (
@@ -38,16 +59,31 @@ type RouteRegistry struct {
 
 	logger lager.Logger
 
-	// Access to the Trie datastructure should be governed by the RWMutex of RouteRegistry
-	byUri *container.Trie
+	// byUri holds the *container.Trie of the routes, which lookups load without
+	// locking. Writers edit the trie under the lock, and publish the edits in a
+	// new trie when they are done, so the published trie never changes.
+	byUri atomic.Value
+	edits *container.Editor
+
+	// traffic splits between applications, keyed by route key; outlive the pools they apply to
+	weights map[route.Uri]map[string]int
+
+	// TCP routes, keyed by router group and listener port
+	tcpRoutes map[tcpRouteKey]*route.Pool
 
 	// used for ability to suspend pruning
 	suspendPruning func() bool
 	pruningStatus  PruneStatus
 
+	// called with each endpoint that is unregistered or pruned
+	endpointRemoved func(*route.Endpoint)
+
 	pruneStaleDropletsInterval time.Duration
 	dropletStaleThreshold      time.Duration
 
+	// ramps up the traffic of new endpoints
+	slowStart config.SlowStartConfig
+
 	reporter reporter.RouteRegistryReporter
 
 	ticker           *time.Ticker
@@ -57,85 +93,150 @@ type RouteRegistry struct {
 func NewRouteRegistry(logger lager.Logger, c *config.Config, reporter reporter.RouteRegistryReporter) *RouteRegistry {
 	r := &RouteRegistry{}
 	r.logger = logger
-	r.byUri = container.NewTrie()
+	r.byUri.Store(container.NewTrie())
+	r.weights = make(map[route.Uri]map[string]int)
+	r.tcpRoutes = make(map[tcpRouteKey]*route.Pool)
 
 	r.pruneStaleDropletsInterval = c.PruneStaleDropletsInterval
 	r.dropletStaleThreshold = c.DropletStaleThreshold
+	r.slowStart = c.SlowStart
 	r.suspendPruning = func() bool { return false }
+	r.endpointRemoved = func(*route.Endpoint) {}
 
 	r.reporter = reporter
 	return r
 }
 
 func (r *RouteRegistry) Register(uri route.Uri, endpoint *route.Endpoint) {
-	t := time.Now()
-	data := lager.Data{"uri": uri, "backend": endpoint.CanonicalAddr(), "modification_tag": endpoint.ModificationTag}
+	r.Apply([]Change{{Register: true, Uri: uri, Endpoint: endpoint}})
+}
 
-	r.reporter.CaptureRegistryMessage(endpoint)
+func (r *RouteRegistry) Unregister(uri route.Uri, endpoint *route.Endpoint) {
+	r.Apply([]Change{{Uri: uri, Endpoint: endpoint}})
+}
+
+// Apply makes changes in order under one lock, and publishes the routes once,
+// so that lookups see all of the routes added and removed or none of them.
+// Endpoints added to or removed from an existing route are seen by lookups
+// as soon as they are made, since the pools are shared between snapshots.
+func (r *RouteRegistry) Apply(changes []Change) {
+	t := time.Now()
+	for _, c := range changes {
+		r.reporter.CaptureRegistryMessage(c.Endpoint)
+	}
 
 	r.Lock()
 
+	for _, c := range changes {
+		switch {
+		case c.RouterGroup != "" && c.Register:
+			r.registerTcp(c.RouterGroup, c.Port, c.Endpoint)
+		case c.RouterGroup != "":
+			r.unregisterTcp(c.RouterGroup, c.Port, c.Endpoint)
+		case c.Register:
+			r.register(c.Uri, c.Endpoint)
+		default:
+			r.unregister(c.Uri, c.Endpoint)
+		}
+		if c.Register {
+			r.timeOfLastUpdate = t
+		}
+	}
+
+	r.publish()
+	r.Unlock()
+}
+
+// lock must be held
+func (r *RouteRegistry) register(uri route.Uri, endpoint *route.Endpoint) {
+	data := lager.Data{"uri": uri, "backend": endpoint.CanonicalAddr(), "modification_tag": endpoint.ModificationTag}
+
+	if err := container.ValidatePattern(uri); err != nil {
+		r.logger.Error("invalid-route-pattern", err, data)
+		return
+	}
+
 	uri = uri.RouteKey()
 
-	pool := r.byUri.Find(uri)
+	pool := r.editor().Find(uri)
 	if pool == nil {
 		contextPath := parseContextPath(uri)
 		pool = route.NewPool(r.dropletStaleThreshold/4, contextPath)
-		r.byUri.Insert(uri, pool)
+		pool.SetSlowStart(r.slowStart)
+		if weights, ok := r.weights[uri]; ok {
+			pool.SetWeights(weights)
+		}
+		r.editor().Insert(uri, pool)
 		r.logger.Debug("uri-added", lager.Data{"uri": uri})
 	}
 
-	endpointAdded := pool.Put(endpoint)
-
-	r.timeOfLastUpdate = t
-	r.Unlock()
-
-	if endpointAdded {
+	if pool.Put(endpoint) {
 		r.logger.Debug("endpoint-registered", data)
+	} else if pool.Conflicts(endpoint) {
+		r.logger.Info("endpoint-conflicts-with-route", data)
 	} else {
 		r.logger.Debug("endpoint-not-registered", data)
 	}
 }
 
-func (r *RouteRegistry) Unregister(uri route.Uri, endpoint *route.Endpoint) {
+// lock must be held
+func (r *RouteRegistry) unregister(uri route.Uri, endpoint *route.Endpoint) {
 	data := lager.Data{"uri": uri, "backend": endpoint.CanonicalAddr(), "modification_tag": endpoint.ModificationTag}
-	r.reporter.CaptureRegistryMessage(endpoint)
-
-	r.Lock()
 
 	uri = uri.RouteKey()
 
-	pool := r.byUri.Find(uri)
+	pool := r.editor().Find(uri)
 	if pool != nil {
 		endpointRemoved := pool.Remove(endpoint)
 		if endpointRemoved {
 			r.logger.Debug("endpoint-unregistered", data)
+			r.endpointRemoved(endpoint)
 		} else {
 			r.logger.Debug("endpoint-not-unregistered", data)
 		}
 
 		if pool.IsEmpty() {
-			r.byUri.Delete(uri)
+			r.editor().Delete(uri)
 		}
 	}
+}
+
+// SetWeights splits the traffic of a route between applications. Empty weights remove the split.
+func (r *RouteRegistry) SetWeights(uri route.Uri, weights map[string]int) {
+	r.Lock()
+
+	uri = uri.RouteKey()
+
+	if len(weights) == 0 {
+		weights = nil
+		delete(r.weights, uri)
+	} else {
+		r.weights[uri] = weights
+	}
 
+	if pool := r.editor().Find(uri); pool != nil {
+		pool.SetWeights(weights)
+	}
+
+	r.timeOfLastUpdate = time.Now()
 	r.Unlock()
+
+	r.logger.Debug("route-weights-updated", lager.Data{"uri": uri, "weights": weights})
 }
 
 func (r *RouteRegistry) Lookup(uri route.Uri) *route.Pool {
 	started := time.Now()
 
-	r.RLock()
+	routes := r.routes()
 
 	uri = uri.RouteKey()
 	var err error
-	pool := r.byUri.MatchUri(uri)
+	pool := routes.MatchUri(uri)
 	for pool == nil && err == nil {
 		uri, err = uri.NextWildcard()
-		pool = r.byUri.MatchUri(uri)
+		pool = routes.MatchUri(uri)
 	}
 
-	r.RUnlock()
 	endLookup := time.Now()
 	r.reporter.CaptureLookupTime(endLookup.Sub(started))
 	return pool
@@ -156,6 +257,65 @@ func (r *RouteRegistry) LookupWithInstance(uri route.Uri, appId string, appIndex
 	return surgicalPool
 }
 
+// RegisterTcp adds an endpoint to the TCP route of a port in a router group.
+func (r *RouteRegistry) RegisterTcp(routerGroup string, port uint16, endpoint *route.Endpoint) {
+	r.Apply([]Change{{Register: true, RouterGroup: routerGroup, Port: port, Endpoint: endpoint}})
+}
+
+// UnregisterTcp removes an endpoint from the TCP route of a port in a router
+// group.
+func (r *RouteRegistry) UnregisterTcp(routerGroup string, port uint16, endpoint *route.Endpoint) {
+	r.Apply([]Change{{RouterGroup: routerGroup, Port: port, Endpoint: endpoint}})
+}
+
+// lock must be held
+func (r *RouteRegistry) registerTcp(routerGroup string, port uint16, endpoint *route.Endpoint) {
+	key := tcpRouteKey{routerGroup: routerGroup, port: port}
+	data := lager.Data{"router_group": routerGroup, "port": port, "backend": endpoint.CanonicalAddr(), "modification_tag": endpoint.ModificationTag}
+
+	pool, ok := r.tcpRoutes[key]
+	if !ok {
+		pool = route.NewPool(r.dropletStaleThreshold/4, "")
+		pool.SetSlowStart(r.slowStart)
+		r.tcpRoutes[key] = pool
+		r.logger.Debug("tcp-route-added", lager.Data{"router_group": routerGroup, "port": port})
+	}
+
+	if pool.Put(endpoint) {
+		r.logger.Debug("tcp-endpoint-registered", data)
+	} else {
+		r.logger.Debug("tcp-endpoint-not-registered", data)
+	}
+}
+
+// lock must be held
+func (r *RouteRegistry) unregisterTcp(routerGroup string, port uint16, endpoint *route.Endpoint) {
+	key := tcpRouteKey{routerGroup: routerGroup, port: port}
+	data := lager.Data{"router_group": routerGroup, "port": port, "backend": endpoint.CanonicalAddr(), "modification_tag": endpoint.ModificationTag}
+
+	if pool, ok := r.tcpRoutes[key]; ok {
+		if pool.Remove(endpoint) {
+			r.logger.Debug("tcp-endpoint-unregistered", data)
+			r.endpointRemoved(endpoint)
+		} else {
+			r.logger.Debug("tcp-endpoint-not-unregistered", data)
+		}
+
+		if pool.IsEmpty() {
+			delete(r.tcpRoutes, key)
+		}
+	}
+}
+
+// LookupTcp returns the TCP route of a port in a router group, or nil if there
+// is none.
+func (r *RouteRegistry) LookupTcp(routerGroup string, port uint16) *route.Pool {
+	r.RLock()
+	defer r.RUnlock()
+
+	return r.tcpRoutes[tcpRouteKey{routerGroup: routerGroup, port: port}]
+}
+
 func (r *RouteRegistry) StartPruningCycle() {
 	if r.pruneStaleDropletsInterval > 0 {
 		r.Lock()
@@ -186,11 +346,7 @@ func (r *RouteRegistry) StopPruningCycle() {
 }
 
 func (registry *RouteRegistry) NumUris() int {
-	registry.RLock()
-	uriCount := registry.byUri.PoolCount()
-	registry.RUnlock()
-
-	return uriCount
+	return registry.routes().PoolCount()
 }
 
 func (r *RouteRegistry) TimeOfLastUpdate() time.Time {
@@ -202,18 +358,11 @@ func (r *RouteRegistry) TimeOfLastUpdate() time.Time {
 }
 
 func (r *RouteRegistry) NumEndpoints() int {
-	r.RLock()
-	count := r.byUri.EndpointCount()
-	r.RUnlock()
-
-	return count
+	return r.routes().EndpointCount()
 }
 
 func (r *RouteRegistry) MarshalJSON() ([]byte, error) {
-	r.RLock()
-	defer r.RUnlock()
-
-	return json.Marshal(r.byUri.ToMap())
+	return json.Marshal(r.routes().ToMap())
 }
 
 func (r *RouteRegistry) pruneStaleDroplets() {
@@ -236,17 +385,37 @@ func (r *RouteRegistry) pruneStaleDroplets() {
 		r.pruningStatus = CONNECTED
 	}
 
-	r.byUri.EachNodeWithPool(func(t *container.Trie) {
-		endpoints := t.Pool.PruneEndpoints(r.dropletStaleThreshold)
-		t.Snip()
+	// the routes left empty are removed in one new trie
+	for uri, pool := range r.routes().ToMap() {
+		endpoints := pool.PruneEndpoints(r.dropletStaleThreshold)
+		if pool.IsEmpty() {
+			r.editor().Delete(uri)
+		}
 		if len(endpoints) > 0 {
 			addresses := []string{}
 			for _, e := range endpoints {
 				addresses = append(addresses, e.CanonicalAddr())
+				r.endpointRemoved(e)
 			}
-			r.logger.Info("pruned-route", lager.Data{"uri": t.ToPath(), "endpoints": addresses})
+			r.logger.Info("pruned-route", lager.Data{"uri": uri, "endpoints": addresses})
 		}
-	})
+	}
+	r.publish()
+
+	for key, pool := range r.tcpRoutes {
+		endpoints := pool.PruneEndpoints(r.dropletStaleThreshold)
+		if pool.IsEmpty() {
+			delete(r.tcpRoutes, key)
+		}
+		if len(endpoints) > 0 {
+			addresses := []string{}
+			for _, e := range endpoints {
+				addresses = append(addresses, e.CanonicalAddr())
+				r.endpointRemoved(e)
+			}
+			r.logger.Info("pruned-tcp-route", lager.Data{"router_group": key.routerGroup, "port": key.port, "endpoints": addresses})
+		}
+	}
 }
 
 func (r *RouteRegistry) SuspendPruning(f func() bool) {
@@ -255,12 +424,45 @@ func (r *RouteRegistry) SuspendPruning(f func() bool) {
 	r.Unlock()
 }
 
+// OnEndpointRemoved sets the function called with each endpoint that is
+// unregistered or pruned. It is called with the registry locked.
+func (r *RouteRegistry) OnEndpointRemoved(f func(*route.Endpoint)) {
+	r.Lock()
+	r.endpointRemoved = f
+	r.Unlock()
+}
+
 // bulk update to mark pool / endpoints as updated
 func (r *RouteRegistry) freshenRoutes() {
 	now := time.Now()
-	r.byUri.EachNodeWithPool(func(t *container.Trie) {
+	r.routes().EachNodeWithPool(func(t *container.Trie) {
 		t.Pool.MarkUpdated(now)
 	})
+	for _, pool := range r.tcpRoutes {
+		pool.MarkUpdated(now)
+	}
+}
+
+// routes returns the published trie of the routes.
+func (r *RouteRegistry) routes() *container.Trie {
+	return r.byUri.Load().(*container.Trie)
+}
+
+// editor returns the editor of the routes, whose edits lookups do not see until
+// they are published. lock must be held
+func (r *RouteRegistry) editor() *container.Editor {
+	if r.edits == nil {
+		r.edits = r.routes().Edit()
+	}
+	return r.edits
+}
+
+// publish makes the edits of the routes visible to lookups. lock must be held
+func (r *RouteRegistry) publish() {
+	if r.edits != nil {
+		r.byUri.Store(r.edits.Trie())
+		r.edits = nil
+	}
 }
 
 func parseContextPath(uri route.Uri) string {
@@ -0,0 +1,89 @@
+package registry_test
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/metrics/reporter"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/routing-api/models"
+)
+
+type nullRegistryReporter struct{}
+
+func (nullRegistryReporter) CaptureRouteStats(int, uint64)                   {}
+func (nullRegistryReporter) CaptureLookupTime(time.Duration)                 {}
+func (nullRegistryReporter) CaptureRegistryMessage(reporter.ComponentTagged) {}
+func (nullRegistryReporter) CaptureRegistryQueue(int, time.Duration)         {}
+
+const benchmarkRoutes = 10000
+
+var (
+	benchmarkRegistry     *registry.RouteRegistry
+	benchmarkRegistryOnce sync.Once
+)
+
+// routes returns a registry of many routes, built once as it takes a while.
+func routes() *registry.RouteRegistry {
+	benchmarkRegistryOnce.Do(func() {
+		benchmarkRegistry = registry.NewRouteRegistry(lager.NewLogger("bench"), config.DefaultConfig(), nullRegistryReporter{})
+		for i := 0; i < benchmarkRoutes; i++ {
+			e := route.NewEndpoint("", fmt.Sprintf("10.0.%d.%d", i/256, i%256), 60000, "", "", nil, -1, "", models.ModificationTag{})
+			benchmarkRegistry.Register(route.Uri(fmt.Sprintf("app-%d.example.com", i)), e)
+		}
+	})
+	return benchmarkRegistry
+}
+
+func lookupWithChurn(writers int, b *testing.B) {
+	r := routes()
+	total := benchmarkRoutes
+
+	// writers keep heartbeating the routes, and adding and removing others
+	var stop int32
+	done := make(chan struct{})
+	for w := 0; w < writers; w++ {
+		go func(w int) {
+			defer func() { done <- struct{}{} }()
+			e := route.NewEndpoint("", fmt.Sprintf("10.1.0.%d", w), 60000, "", "", nil, -1, "", models.ModificationTag{})
+			for i := 0; atomic.LoadInt32(&stop) == 0; i++ {
+				uri := route.Uri(fmt.Sprintf("app-%d.example.com", i%total))
+				churned := route.Uri(fmt.Sprintf("churn-%d-%d.example.com", w, i%100))
+				r.Register(uri, e)
+				r.Register(churned, e)
+				r.Unregister(churned, e)
+			}
+		}(w)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if r.Lookup(route.Uri(fmt.Sprintf("app-%d.example.com/path", i%total))) == nil {
+				b.Fatal("expected a route")
+			}
+			i++
+		}
+	})
+	b.StopTimer()
+
+	atomic.StoreInt32(&stop, 1)
+	for w := 0; w < writers; w++ {
+		<-done
+	}
+}
+
+func BenchmarkLookup(b *testing.B) {
+	lookupWithChurn(0, b)
+}
+
+func BenchmarkLookupWithRegistrationChurn(b *testing.B) {
+	lookupWithChurn(4, b)
+}
@@ -150,6 +150,33 @@ var _ = Describe("RouteRegistry", func() {
 			})
 		})
 
+		Context("pattern routes", func() {
+			It("records a uri with wildcard and regular expression segments", func() {
+				r.Register("a.route/users/*/{[0-9]+}", fooEndpoint)
+
+				Expect(r.NumUris()).To(Equal(1))
+				Expect(r.NumEndpoints()).To(Equal(1))
+			})
+
+			It("rejects a uri with an invalid regular expression", func() {
+				r.Register("a.route/users/{[0-9}", fooEndpoint)
+
+				Expect(r.NumUris()).To(Equal(0))
+				Expect(logger).To(gbytes.Say(`invalid-route-pattern`))
+			})
+		})
+
+		Context("when the endpoint disagrees with the route on TLS passthrough", func() {
+			It("does not register the endpoint", func() {
+				fooEndpoint.TLSPassthrough = true
+				r.Register("a.route", fooEndpoint)
+				r.Register("a.route", barEndpoint)
+
+				Expect(r.NumEndpoints()).To(Equal(1))
+				Expect(logger).To(gbytes.Say(`endpoint-conflicts-with-route`))
+			})
+		})
+
 		Context("when route registration message is received", func() {
 			BeforeEach(func() {
 				r.Register("a.route", fooEndpoint)
@@ -370,6 +397,18 @@ var _ = Describe("RouteRegistry", func() {
 
 		})
 
+		It("reports removed endpoints", func() {
+			var removed []*route.Endpoint
+			r.OnEndpointRemoved(func(e *route.Endpoint) {
+				removed = append(removed, e)
+			})
+
+			r.Register("bar", barEndpoint)
+			r.Unregister("bar", barEndpoint)
+			r.Unregister("bar", barEndpoint)
+			Expect(removed).To(Equal([]*route.Endpoint{barEndpoint}))
+		})
+
 		Context("when route unregistration message is received", func() {
 			BeforeEach(func() {
 				r.Register("a.route", fooEndpoint)
@@ -418,6 +457,123 @@ var _ = Describe("RouteRegistry", func() {
 		})
 	})
 
+	Context("Apply", func() {
+		It("makes the changes in order", func() {
+			r.Apply([]Change{
+				{Register: true, Uri: "foo", Endpoint: fooEndpoint},
+				{Register: true, Uri: "bar", Endpoint: barEndpoint},
+				{Uri: "foo", Endpoint: fooEndpoint},
+				{Register: true, RouterGroup: "default-tcp", Port: 1024, Endpoint: bar2Endpoint},
+			})
+
+			Expect(r.Lookup("foo")).To(BeNil())
+			Expect(r.Lookup("bar").Endpoints("", "").Next()).To(Equal(barEndpoint))
+			Expect(r.LookupTcp("default-tcp", 1024).Endpoints("", "").Next()).To(Equal(bar2Endpoint))
+			Expect(reporter.CaptureRegistryMessageCallCount()).To(Equal(4))
+		})
+
+		It("keeps the endpoint with the latest modification tag", func() {
+			older := route.NewEndpoint("12345", "192.168.1.1", 1234, "id1", "0", nil, -1, "", models.ModificationTag{Guid: "abc", Index: 1})
+			newer := route.NewEndpoint("12345", "192.168.1.1", 1234, "id1", "0", nil, -1, "", models.ModificationTag{Guid: "abc", Index: 2})
+
+			r.Apply([]Change{
+				{Register: true, Uri: "foo", Endpoint: newer},
+				{Register: true, Uri: "foo", Endpoint: older},
+			})
+
+			Expect(r.Lookup("foo").Endpoints("", "").Next()).To(BeIdenticalTo(newer))
+		})
+
+		It("updates the time of the last update when it registers endpoints", func() {
+			r.Apply([]Change{{Uri: "foo", Endpoint: fooEndpoint}})
+			Expect(r.TimeOfLastUpdate()).To(BeZero())
+
+			r.Apply([]Change{{Register: true, Uri: "foo", Endpoint: fooEndpoint}})
+			Expect(r.TimeOfLastUpdate()).ToNot(BeZero())
+		})
+	})
+
+	Context("SetWeights", func() {
+		It("applies the weights to a registered route", func() {
+			r.Register("foo", fooEndpoint)
+			r.SetWeights("FOO", map[string]int{"12345": 100})
+
+			Expect(r.Lookup("foo").Weights()).To(Equal(map[string]int{"12345": 100}))
+		})
+
+		It("applies the weights to routes registered later", func() {
+			r.SetWeights("foo", map[string]int{"12345": 100})
+			r.Register("foo", fooEndpoint)
+
+			Expect(r.Lookup("foo").Weights()).To(Equal(map[string]int{"12345": 100}))
+		})
+
+		It("keeps the weights when the route is unregistered", func() {
+			r.SetWeights("foo", map[string]int{"12345": 100})
+			r.Register("foo", fooEndpoint)
+			r.Unregister("foo", fooEndpoint)
+			r.Register("foo", fooEndpoint)
+
+			Expect(r.Lookup("foo").Weights()).To(Equal(map[string]int{"12345": 100}))
+		})
+
+		It("removes the weights when they are empty", func() {
+			r.SetWeights("foo", map[string]int{"12345": 100})
+			r.Register("foo", fooEndpoint)
+			r.SetWeights("foo", map[string]int{})
+
+			Expect(r.Lookup("foo").Weights()).To(BeNil())
+		})
+	})
+
+	Context("TCP routes", func() {
+		It("looks up the endpoints registered for a port in a router group", func() {
+			r.RegisterTcp("default-tcp", 1024, fooEndpoint)
+			r.RegisterTcp("default-tcp", 1024, bar2Endpoint)
+
+			pool := r.LookupTcp("default-tcp", 1024)
+			Expect(pool).NotTo(BeNil())
+			Expect(pool.Endpoints("", "").Next()).To(Or(Equal(fooEndpoint), Equal(bar2Endpoint)))
+			Expect(r.LookupTcp("default-tcp", 1025)).To(BeNil())
+			Expect(r.LookupTcp("other-tcp", 1024)).To(BeNil())
+		})
+
+		It("does not add the endpoints to the HTTP routes", func() {
+			r.RegisterTcp("default-tcp", 1024, fooEndpoint)
+
+			Expect(r.NumUris()).To(Equal(0))
+			Expect(r.NumEndpoints()).To(Equal(0))
+		})
+
+		It("removes the route when its last endpoint is unregistered", func() {
+			removed := []*route.Endpoint{}
+			r.OnEndpointRemoved(func(e *route.Endpoint) {
+				removed = append(removed, e)
+			})
+
+			r.RegisterTcp("default-tcp", 1024, fooEndpoint)
+			r.RegisterTcp("default-tcp", 1024, bar2Endpoint)
+
+			r.UnregisterTcp("default-tcp", 1024, fooEndpoint)
+			Expect(r.LookupTcp("default-tcp", 1024)).NotTo(BeNil())
+
+			r.UnregisterTcp("default-tcp", 1024, bar2Endpoint)
+			Expect(r.LookupTcp("default-tcp", 1024)).To(BeNil())
+			Expect(removed).To(Equal([]*route.Endpoint{fooEndpoint, bar2Endpoint}))
+		})
+
+		It("prunes stale routes", func() {
+			r.RegisterTcp("default-tcp", 1024, fooEndpoint)
+
+			r.StartPruningCycle()
+			defer r.StopPruningCycle()
+
+			Eventually(func() *route.Pool {
+				return r.LookupTcp("default-tcp", 1024)
+			}, configObj.PruneStaleDropletsInterval+configObj.DropletStaleThreshold+time.Second).Should(BeNil())
+		})
+	})
+
 	Context("Lookup", func() {
 		It("case insensitive lookup", func() {
 			m := route.NewEndpoint("", "192.168.1.1", 1234, "", "", nil, -1, "", modTag)
@@ -432,6 +588,20 @@ var _ = Describe("RouteRegistry", func() {
 			Expect(iter.Next().CanonicalAddr()).To(Equal("192.168.1.1:1234"))
 		})
 
+		It("does not wait for writers", func() {
+			m := route.NewEndpoint("", "192.168.1.1", 1234, "", "", nil, -1, "", modTag)
+			r.Register("foo", m)
+
+			r.Lock()
+			defer r.Unlock()
+
+			done := make(chan *route.Pool)
+			go func() {
+				done <- r.Lookup("foo")
+			}()
+			Eventually(done).Should(Receive(Not(BeNil())))
+		})
+
 		It("selects one of the routes", func() {
 			m1 := route.NewEndpoint("", "192.168.1.1", 1234, "", "", nil, -1, "", modTag)
 			m2 := route.NewEndpoint("", "192.168.1.1", 1235, "", "", nil, -1, "", modTag)
@@ -486,6 +656,24 @@ var _ = Describe("RouteRegistry", func() {
 			Expect(e.CanonicalAddr()).To(Equal("192.168.1.1:1234"))
 		})
 
+		It("matches routes with wildcard and regular expression segments", func() {
+			app1 := route.NewEndpoint("", "192.168.1.1", 1234, "", "", nil, -1, "", modTag)
+			app2 := route.NewEndpoint("", "192.168.1.2", 1234, "", "", nil, -1, "", modTag)
+
+			r.Register("dora.app.com/users/*/avatar", app1)
+			r.Register("*.app.com/orders/{[0-9]+}", app2)
+
+			p := r.Lookup("dora.app.com/users/42/avatar")
+			Expect(p).ToNot(BeNil())
+			Expect(p.Endpoints("", "").Next().CanonicalAddr()).To(Equal("192.168.1.1:1234"))
+
+			p = r.Lookup("foo.app.com/orders/42")
+			Expect(p).ToNot(BeNil())
+			Expect(p.Endpoints("", "").Next().CanonicalAddr()).To(Equal("192.168.1.2:1234"))
+
+			Expect(r.Lookup("foo.app.com/orders/latest")).To(BeNil())
+		})
+
 		It("sends lookup metrics to the reporter", func() {
 			app1 := route.NewEndpoint("", "192.168.1.1", 1234, "", "", nil, -1, "", modTag)
 			app2 := route.NewEndpoint("", "192.168.1.2", 1234, "", "", nil, -1, "", modTag)
@@ -628,6 +816,18 @@ var _ = Describe("RouteRegistry", func() {
 			Expect(string(marshalled)).To(Equal(`{}`))
 		})
 
+		It("reports pruned endpoints", func() {
+			removed := make(chan *route.Endpoint, 1)
+			r.OnEndpointRemoved(func(e *route.Endpoint) {
+				removed <- e
+			})
+
+			r.Register("foo", fooEndpoint)
+			r.StartPruningCycle()
+
+			Eventually(removed, configObj.PruneStaleDropletsInterval+configObj.DropletStaleThreshold+time.Second).Should(Receive(Equal(fooEndpoint)))
+		})
+
 		It("removes stale droplets that have children", func() {
 			doneChan := make(chan struct{})
 			defer close(doneChan)
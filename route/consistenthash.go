@@ -0,0 +1,157 @@
+package route
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ringReplicas is the number of points of each endpoint on the hash ring, which
+// spreads the keys evenly between the endpoints.
+const ringReplicas = 160
+
+type ringPoint struct {
+	hash uint64
+	elem *endpointElem
+}
+
+// hashRing places the endpoints of a pool on a ring by address. A key belongs
+// to the first endpoint after it on the ring, so adding or removing an endpoint
+// only moves the keys of its own share.
+type hashRing []ringPoint
+
+func newHashRing(endpoints []*endpointElem) hashRing {
+	ring := make(hashRing, 0, len(endpoints)*ringReplicas)
+	for _, e := range endpoints {
+		addr := e.endpoint.CanonicalAddr()
+		for i := 0; i < ringReplicas; i++ {
+			ring = append(ring, ringPoint{hash: hashString(addr + "#" + strconv.Itoa(i)), elem: e})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+	return ring
+}
+
+// search returns the index of the first point at or after a hash.
+func (r hashRing) search(hash uint64) int {
+	i := sort.Search(len(r), func(i int) bool { return r[i].hash >= hash })
+	if i == len(r) {
+		return 0
+	}
+	return i
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+
+	// finalize as in splitmix64, as FNV barely mixes the last bytes
+	x := h.Sum64()
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// ConsistentHash sends the requests with the same key to the same endpoint. The
+// endpoints after it on the hash ring are tried when it fails, and requests
+// without a key are spread at random.
+type ConsistentHash struct {
+	pool *Pool
+	key  string
+
+	initialEndpoint string
+	lastEndpoint    *Endpoint
+	tried           map[*endpointElem]bool
+
+	// when set, only endpoints of this application are returned
+	applicationId string
+	// when set, only endpoints in this zone are returned
+	zone string
+}
+
+func NewConsistentHash(p *Pool, key string, initial string) EndpointIterator {
+	return &ConsistentHash{
+		pool:            p,
+		key:             key,
+		initialEndpoint: initial,
+	}
+}
+
+func (r *ConsistentHash) Next() *Endpoint {
+	var e *Endpoint
+	if r.initialEndpoint != "" {
+		e = r.pool.findById(r.initialEndpoint)
+		r.initialEndpoint = ""
+	}
+
+	if e == nil {
+		e = r.next()
+	}
+
+	r.lastEndpoint = e
+	return e
+}
+
+func (r *ConsistentHash) next() *Endpoint {
+	if r.key == "" {
+		rr := &RoundRobin{pool: r.pool, applicationId: r.applicationId, zone: r.zone}
+		return rr.next()
+	}
+
+	r.pool.lock.Lock()
+	defer r.pool.lock.Unlock()
+
+	if len(r.pool.endpoints) == 0 {
+		return nil
+	}
+	if r.pool.ring == nil {
+		r.pool.ring = newHashRing(r.pool.endpoints)
+	}
+	if r.tried == nil {
+		r.tried = make(map[*endpointElem]bool)
+	}
+
+	ring := r.pool.ring
+	start := ring.search(hashString(r.key))
+
+	var fallback *endpointElem
+	for i := 0; i < len(ring); i++ {
+		e := ring[(start+i)%len(ring)].elem
+		if r.tried[e] || !selects(e.endpoint, r.applicationId, r.zone) {
+			continue
+		}
+
+		if e.failedAt != nil && time.Since(*e.failedAt) > r.pool.retryAfterFailure {
+			// expired failure window
+			e.failedAt = nil
+		}
+		if e.failedAt == nil {
+			r.tried[e] = true
+			return e.endpoint
+		}
+		if fallback == nil {
+			fallback = e
+		}
+	}
+
+	// all endpoints left have failed recently, so try the one owning the key
+	if fallback != nil {
+		fallback.failedAt = nil
+		r.tried[fallback] = true
+		return fallback.endpoint
+	}
+	return nil
+}
+
+func (r *ConsistentHash) EndpointFailed() {
+	if r.lastEndpoint != nil {
+		r.pool.endpointFailed(r.lastEndpoint)
+	}
+}
+
+func (r *ConsistentHash) PreRequest(e *Endpoint) {
+}
+
+func (r *ConsistentHash) PostRequest(e *Endpoint) {
+}
@@ -0,0 +1,131 @@
+package route_test
+
+import (
+	"fmt"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ConsistentHash", func() {
+	var (
+		pool      *route.Pool
+		endpoints []*route.Endpoint
+	)
+
+	owners := func(keys int) map[string]*route.Endpoint {
+		owned := make(map[string]*route.Endpoint)
+		for i := 0; i < keys; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			owned[key] = route.NewConsistentHash(pool, key, "").Next()
+		}
+		return owned
+	}
+
+	BeforeEach(func() {
+		pool = route.NewPool(2*time.Minute, "")
+		endpoints = nil
+		for i := 0; i < 5; i++ {
+			e := route.NewEndpoint("", fmt.Sprintf("10.0.1.%d", i), 60000, fmt.Sprintf("id-%d", i), "", nil, -1, "", models.ModificationTag{})
+			endpoints = append(endpoints, e)
+			pool.Put(e)
+		}
+	})
+
+	Describe("Next", func() {
+		It("does not select an endpoint when the pool is empty", func() {
+			iter := route.NewConsistentHash(route.NewPool(time.Minute, ""), "key", "")
+			Expect(iter.Next()).To(BeNil())
+		})
+
+		It("selects the same endpoint for the same key", func() {
+			e := route.NewConsistentHash(pool, "key", "").Next()
+			for i := 0; i < 10; i++ {
+				Expect(route.NewConsistentHash(pool, "key", "").Next()).To(BeIdenticalTo(e))
+			}
+		})
+
+		It("spreads the keys between the endpoints", func() {
+			counts := make(map[*route.Endpoint]int)
+			for _, e := range owners(5000) {
+				counts[e]++
+			}
+
+			Expect(counts).To(HaveLen(5))
+			for _, count := range counts {
+				Expect(count).To(BeNumerically("~", 1000, 300))
+			}
+		})
+
+		It("only moves the keys of an endpoint that is removed", func() {
+			before := owners(1000)
+			pool.Remove(endpoints[2])
+			after := owners(1000)
+
+			for key, e := range before {
+				if e == endpoints[2] {
+					Expect(after[key]).ToNot(BeIdenticalTo(endpoints[2]))
+				} else {
+					Expect(after[key]).To(BeIdenticalTo(e))
+				}
+			}
+		})
+
+		It("only moves a share of the keys to an endpoint that is added", func() {
+			before := owners(1000)
+			added := route.NewEndpoint("", "10.0.1.9", 60000, "id-9", "", nil, -1, "", models.ModificationTag{})
+			pool.Put(added)
+			after := owners(1000)
+
+			moved := 0
+			for key, e := range before {
+				if after[key] != e {
+					Expect(after[key]).To(BeIdenticalTo(added))
+					moved++
+				}
+			}
+			Expect(moved).To(BeNumerically("~", 1000/6, 80))
+		})
+
+		It("tries the other endpoints when the endpoint of the key fails", func() {
+			iter := route.NewConsistentHash(pool, "key", "")
+			seen := make(map[*route.Endpoint]bool)
+			for i := 0; i < 5; i++ {
+				e := iter.Next()
+				Expect(seen[e]).To(BeFalse())
+				seen[e] = true
+				iter.EndpointFailed()
+			}
+			Expect(iter.Next()).To(BeNil())
+
+			first := route.NewConsistentHash(pool, "other-key", "").Next()
+			Expect(first).ToNot(BeNil())
+		})
+
+		It("skips endpoints that failed recently", func() {
+			iter := route.NewConsistentHash(pool, "key", "")
+			failed := iter.Next()
+			iter.EndpointFailed()
+
+			Expect(route.NewConsistentHash(pool, "key", "").Next()).ToNot(BeIdenticalTo(failed))
+		})
+
+		It("selects the initial endpoint", func() {
+			e := route.NewConsistentHash(pool, "key", "").Next()
+			other := endpoints[0]
+			if other == e {
+				other = endpoints[1]
+			}
+
+			Expect(route.NewConsistentHash(pool, "key", other.PrivateInstanceId).Next()).To(BeIdenticalTo(other))
+		})
+
+		It("selects endpoints without a key", func() {
+			iter := route.NewConsistentHash(pool, "", "")
+			Expect(iter.Next()).ToNot(BeNil())
+		})
+	})
+})
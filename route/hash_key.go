@@ -0,0 +1,94 @@
+package route
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// HashKey selects the part of a request that consistent hashing keeps on one
+// endpoint. It is written as header:<name>, cookie:<name>, client_ip or
+// path:<segment>, where the segments of the path count from 1.
+type HashKey struct {
+	Header      string
+	Cookie      string
+	ClientIP    bool
+	PathSegment int
+}
+
+// ParseHashKey parses a hash key as it is configured or registered.
+func ParseHashKey(s string) (*HashKey, error) {
+	kind, arg := s, ""
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		kind, arg = s[:i], s[i+1:]
+	}
+
+	switch {
+	case kind == "header" && arg != "":
+		return &HashKey{Header: arg}, nil
+	case kind == "cookie" && arg != "":
+		return &HashKey{Cookie: arg}, nil
+	case kind == "client_ip" && arg == "":
+		return &HashKey{ClientIP: true}, nil
+	case kind == "path":
+		if n, err := strconv.Atoi(arg); err == nil && n > 0 {
+			return &HashKey{PathSegment: n}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid hash key %q: must be header:<name>, cookie:<name>, client_ip or path:<segment>", s)
+}
+
+func (k HashKey) String() string {
+	switch {
+	case k.Header != "":
+		return "header:" + k.Header
+	case k.Cookie != "":
+		return "cookie:" + k.Cookie
+	case k.ClientIP:
+		return "client_ip"
+	default:
+		return "path:" + strconv.Itoa(k.PathSegment)
+	}
+}
+
+func (k HashKey) MarshalJSON() ([]byte, error) {
+	return json.Marshal(k.String())
+}
+
+func (k *HashKey) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	parsed, err := ParseHashKey(s)
+	if err != nil {
+		return err
+	}
+	*k = *parsed
+	return nil
+}
+
+// Of returns the key of a request, or the empty string if the request does not
+// have one.
+func (k *HashKey) Of(request *http.Request, clientIP string) string {
+	switch {
+	case k.Header != "":
+		return request.Header.Get(k.Header)
+	case k.Cookie != "":
+		if cookie, err := request.Cookie(k.Cookie); err == nil {
+			return cookie.Value
+		}
+		return ""
+	case k.ClientIP:
+		return clientIP
+	default:
+		segments := strings.Split(strings.Trim(request.URL.Path, "/"), "/")
+		if k.PathSegment <= len(segments) {
+			return segments[k.PathSegment-1]
+		}
+		return ""
+	}
+}
@@ -0,0 +1,61 @@
+package route_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/route"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HashKey", func() {
+	var request *http.Request
+
+	BeforeEach(func() {
+		var err error
+		request, err = http.NewRequest("GET", "http://example.com/tenants/acme/items", nil)
+		Expect(err).ToNot(HaveOccurred())
+		request.Header.Set("X-User-Id", "user-1")
+		request.AddCookie(&http.Cookie{Name: "session", Value: "session-1"})
+	})
+
+	keyOf := func(s string) string {
+		key, err := route.ParseHashKey(s)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(key.String()).To(Equal(s))
+		return key.Of(request, "10.0.0.1")
+	}
+
+	It("takes the key of a request from a header, a cookie, the client IP or a path segment", func() {
+		Expect(keyOf("header:X-User-Id")).To(Equal("user-1"))
+		Expect(keyOf("cookie:session")).To(Equal("session-1"))
+		Expect(keyOf("client_ip")).To(Equal("10.0.0.1"))
+		Expect(keyOf("path:2")).To(Equal("acme"))
+	})
+
+	It("returns the empty string for requests without the key", func() {
+		Expect(keyOf("header:X-Other")).To(BeEmpty())
+		Expect(keyOf("cookie:other")).To(BeEmpty())
+		Expect(keyOf("path:4")).To(BeEmpty())
+	})
+
+	It("rejects invalid keys", func() {
+		for _, s := range []string{"", "header:", "cookie", "client_ip:x", "path:0", "path:a", "query:q"} {
+			_, err := route.ParseHashKey(s)
+			Expect(err).To(HaveOccurred(), s)
+		}
+	})
+
+	It("is registered as a string", func() {
+		var key route.HashKey
+		Expect(json.Unmarshal([]byte(`"cookie:session"`), &key)).To(Succeed())
+		Expect(key).To(Equal(route.HashKey{Cookie: "session"}))
+
+		b, err := json.Marshal(key)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(b)).To(Equal(`"cookie:session"`))
+
+		Expect(json.Unmarshal([]byte(`"cookie"`), &key)).ToNot(Succeed())
+	})
+})
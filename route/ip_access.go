@@ -0,0 +1,164 @@
+package route
+
+import (
+	"encoding/json"
+	"net"
+)
+
+// IPAccess restricts the clients of a route by IP. A client in the deny list is
+// refused. When the allow list is not empty, a client must also be in it.
+type IPAccess struct {
+	Allow []*net.IPNet
+	Deny  []*net.IPNet
+}
+
+// ipAccessJSON holds the lists as CIDRs, as they are registered.
+type ipAccessJSON struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+func (a IPAccess) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ipAccessJSON{
+		Allow: formatCIDRs(a.Allow),
+		Deny:  formatCIDRs(a.Deny),
+	})
+}
+
+func (a *IPAccess) UnmarshalJSON(data []byte) error {
+	var j ipAccessJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	allow, err := parseCIDRs(j.Allow)
+	if err != nil {
+		return err
+	}
+	deny, err := parseCIDRs(j.Deny)
+	if err != nil {
+		return err
+	}
+
+	*a = IPAccess{Allow: allow, Deny: deny}
+	return nil
+}
+
+// parseCIDRs parses a list of networks in CIDR notation.
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func formatCIDRs(nets []*net.IPNet) []string {
+	var cidrs []string
+	for _, n := range nets {
+		cidrs = append(cidrs, n.String())
+	}
+	return cidrs
+}
+
+// Permits reports whether a client IP may use the route. Routes without lists
+// permit every client, and a client whose IP is not known is only refused by
+// an allow list.
+func (a *IPAccess) Permits(ip net.IP) bool {
+	if a == nil {
+		return true
+	}
+
+	if ip != nil && contains(a.Deny, ip) {
+		return false
+	}
+	return len(a.Allow) == 0 || (ip != nil && contains(a.Allow, ip))
+}
+
+// Restrict returns the lists that permit only the clients permitted by both
+// these and the other lists, either of which may be nil.
+func (a *IPAccess) Restrict(other *IPAccess) *IPAccess {
+	if a == nil {
+		return other
+	}
+	if other == nil {
+		return a
+	}
+
+	r := &IPAccess{Deny: union(a.Deny, other.Deny)}
+	switch {
+	case len(a.Allow) == 0:
+		r.Allow = other.Allow
+	case len(other.Allow) == 0:
+		r.Allow = a.Allow
+	default:
+		r.Allow = intersect(a.Allow, other.Allow)
+		if len(r.Allow) == 0 {
+			// the allow lists have no client in common
+			r.Allow = a.Allow
+			r.Deny = union(r.Deny, everyone)
+		}
+	}
+	return r
+}
+
+// everyone holds the networks of every IPv4 and IPv6 client.
+var everyone = []*net.IPNet{
+	{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)},
+	{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)},
+}
+
+// union returns the networks of either list, without repeating those in both.
+func union(a, b []*net.IPNet) []*net.IPNet {
+	nets := append([]*net.IPNet(nil), a...)
+	for _, y := range b {
+		found := false
+		for _, x := range a {
+			if covers(x, y) && covers(y, x) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			nets = append(nets, y)
+		}
+	}
+	return nets
+}
+
+// intersect returns the networks in both lists. Two networks are either nested
+// or apart, so the clients in both are those of the inner of each nested pair.
+func intersect(a, b []*net.IPNet) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, x := range a {
+		for _, y := range b {
+			switch {
+			case covers(x, y):
+				nets = append(nets, y)
+			case covers(y, x):
+				nets = append(nets, x)
+			}
+		}
+	}
+	return nets
+}
+
+// covers reports whether the network a holds all of the network b.
+func covers(a, b *net.IPNet) bool {
+	aOnes, aBits := a.Mask.Size()
+	bOnes, bBits := b.Mask.Size()
+	return aBits == bBits && aOnes <= bOnes && a.Contains(b.IP)
+}
+
+func contains(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
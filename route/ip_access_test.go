@@ -0,0 +1,109 @@
+package route_test
+
+import (
+	"encoding/json"
+	"net"
+
+	"code.cloudfoundry.org/gorouter/route"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("IPAccess", func() {
+	parse := func(data string) *route.IPAccess {
+		var a route.IPAccess
+		err := json.Unmarshal([]byte(data), &a)
+		Expect(err).ToNot(HaveOccurred())
+		return &a
+	}
+
+	It("reads the lists as CIDRs", func() {
+		a := parse(`{"allow":["10.0.0.0/8"],"deny":["10.1.0.0/16","2001:db8::/32"]}`)
+		Expect(a.Allow).To(HaveLen(1))
+		Expect(a.Deny).To(HaveLen(2))
+
+		b, err := json.Marshal(a)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(b)).To(MatchJSON(`{"allow":["10.0.0.0/8"],"deny":["10.1.0.0/16","2001:db8::/32"]}`))
+	})
+
+	It("rejects invalid CIDRs", func() {
+		var a route.IPAccess
+		err := json.Unmarshal([]byte(`{"allow":["10.0.0.1"]}`), &a)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("permits the clients in the allow list only", func() {
+		a := parse(`{"allow":["10.0.0.0/8"]}`)
+		Expect(a.Permits(net.ParseIP("10.1.2.3"))).To(BeTrue())
+		Expect(a.Permits(net.ParseIP("192.168.1.1"))).To(BeFalse())
+		Expect(a.Permits(nil)).To(BeFalse())
+	})
+
+	It("refuses the clients in the deny list, even when they are allowed", func() {
+		a := parse(`{"allow":["10.0.0.0/8"],"deny":["10.1.0.0/16"]}`)
+		Expect(a.Permits(net.ParseIP("10.2.0.1"))).To(BeTrue())
+		Expect(a.Permits(net.ParseIP("10.1.0.1"))).To(BeFalse())
+	})
+
+	It("permits other clients with only a deny list", func() {
+		a := parse(`{"deny":["10.1.0.0/16"]}`)
+		Expect(a.Permits(net.ParseIP("192.168.1.1"))).To(BeTrue())
+		Expect(a.Permits(nil)).To(BeTrue())
+	})
+
+	Describe("Restrict", func() {
+		It("permits the clients both lists permit", func() {
+			a := parse(`{"allow":["10.0.0.0/8","192.168.0.0/16"],"deny":["10.1.0.0/16"]}`)
+			b := parse(`{"allow":["10.2.0.0/16","172.16.0.0/12"],"deny":["10.2.3.0/24"]}`)
+
+			r := a.Restrict(b)
+			Expect(r.Permits(net.ParseIP("10.2.0.1"))).To(BeTrue())
+			Expect(r.Permits(net.ParseIP("10.2.3.1"))).To(BeFalse())
+			Expect(r.Permits(net.ParseIP("10.3.0.1"))).To(BeFalse())
+			Expect(r.Permits(net.ParseIP("172.16.0.1"))).To(BeFalse())
+			Expect(r.Permits(net.ParseIP("192.168.1.1"))).To(BeFalse())
+		})
+
+		It("keeps the allow list of either when the other has none", func() {
+			a := parse(`{"allow":["10.0.0.0/8"]}`)
+			b := parse(`{"deny":["10.1.0.0/16"]}`)
+
+			r := b.Restrict(a)
+			Expect(r.Permits(net.ParseIP("10.2.0.1"))).To(BeTrue())
+			Expect(r.Permits(net.ParseIP("10.1.0.1"))).To(BeFalse())
+			Expect(r.Permits(net.ParseIP("192.168.1.1"))).To(BeFalse())
+		})
+
+		It("refuses every client when the allow lists have none in common", func() {
+			a := parse(`{"allow":["10.0.0.0/8"]}`)
+			b := parse(`{"allow":["192.168.0.0/16"]}`)
+
+			r := a.Restrict(b)
+			Expect(r.Permits(net.ParseIP("10.2.0.1"))).To(BeFalse())
+			Expect(r.Permits(net.ParseIP("192.168.1.1"))).To(BeFalse())
+			Expect(r.Permits(net.ParseIP("2001:db8::1"))).To(BeFalse())
+			Expect(r.Permits(nil)).To(BeFalse())
+		})
+
+		It("does not repeat the networks in both lists", func() {
+			a := parse(`{"allow":["10.0.0.0/8"],"deny":["10.1.0.0/16"]}`)
+
+			r := a.Restrict(parse(`{"allow":["10.0.0.0/8"],"deny":["10.1.0.0/16"]}`))
+			Expect(r.Allow).To(HaveLen(1))
+			Expect(r.Deny).To(HaveLen(1))
+		})
+
+		It("returns the other lists when there are none", func() {
+			var a *route.IPAccess
+			b := parse(`{"allow":["10.0.0.0/8"]}`)
+			Expect(a.Restrict(b)).To(BeIdenticalTo(b))
+			Expect(b.Restrict(nil)).To(BeIdenticalTo(b))
+		})
+	})
+
+	It("permits every client of routes without lists", func() {
+		var a *route.IPAccess
+		Expect(a.Permits(net.ParseIP("192.168.1.1"))).To(BeTrue())
+	})
+})
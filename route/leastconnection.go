@@ -11,6 +11,11 @@ type LeastConnection struct {
 	pool            *Pool
 	initialEndpoint string
 	lastEndpoint    *Endpoint
+
+	// when set, only endpoints of this application are returned
+	applicationId string
+	// when set, only endpoints in this zone are returned
+	zone string
 }
 
 func NewLeastConnection(p *Pool, initial string) EndpointIterator {
@@ -48,6 +53,7 @@ func (r *LeastConnection) next() *Endpoint {
 	defer r.pool.lock.Unlock()
 
 	var selected *Endpoint
+	var selectedLoad float64
 
 	// none
 	total := len(r.pool.endpoints)
@@ -56,7 +62,7 @@ func (r *LeastConnection) next() *Endpoint {
 	}
 
 	// single endpoint
-	if total == 1 {
+	if total == 1 && r.applicationId == "" && r.zone == "" {
 		return r.pool.endpoints[0].endpoint
 	}
 
@@ -67,16 +73,24 @@ func (r *LeastConnection) next() *Endpoint {
 
 	for i := 0; i < total; i++ {
 		randIdx := randIndices[i]
-		cur := r.pool.endpoints[randIdx].endpoint
+		elem := r.pool.endpoints[randIdx]
+		cur := elem.endpoint
+
+		if !selects(cur, r.applicationId, r.zone) {
+			continue
+		}
+
+		// endpoints in slow start count as busier than they are
+		load := float64(cur.Stats.NumberConnections.Count()+1) / r.pool.weight(elem)
 
 		// our first is the least
-		if i == 0 {
-			selected = cur
+		if selected == nil {
+			selected, selectedLoad = cur, load
 			continue
 		}
 
-		if cur.Stats.NumberConnections.Count() < selected.Stats.NumberConnections.Count() {
-			selected = cur
+		if load < selectedLoad {
+			selected, selectedLoad = cur, load
 		}
 	}
 	return selected
@@ -15,13 +15,12 @@ func loadBalance(lb route.EndpointIterator) {
 	lb.PostRequest(e)
 }
 
-func loadBalanceFor(strategy string, b *testing.B) {
+func loadBalanceFor(strategy string, total int, b *testing.B) {
 
 	pool := route.NewPool(2*time.Minute, "")
-	total := 5
 	endpoints := make([]*route.Endpoint, 0)
 	for i := 0; i < total; i++ {
-		ip := fmt.Sprintf("10.0.1.%d", i)
+		ip := fmt.Sprintf("10.0.%d.%d", i/256, i%256)
 		e := route.NewEndpoint("", ip, 60000, "", "", nil, -1, "", models.ModificationTag{})
 		endpoints = append(endpoints, e)
 		pool.Put(e)
@@ -33,19 +32,38 @@ func loadBalanceFor(strategy string, b *testing.B) {
 		lb = route.NewRoundRobin(pool, "")
 	case "least-connection":
 		lb = route.NewLeastConnection(pool, "")
+	case "p2c-ewma":
+		lb = route.NewP2CEWMA(pool, "")
 	default:
 		panic("invalid load balancing strategy")
 	}
 
+	b.ResetTimer()
 	for n := 0; n < b.N; n++ {
 		loadBalance(lb)
 	}
 }
 
 func BenchmarkLeastConnection(b *testing.B) {
-	loadBalanceFor("least-connection", b)
+	loadBalanceFor("least-connection", 5, b)
 }
 
 func BenchmarkRoundRobin(b *testing.B) {
-	loadBalanceFor("round-robin", b)
+	loadBalanceFor("round-robin", 5, b)
+}
+
+func BenchmarkP2CEWMA(b *testing.B) {
+	loadBalanceFor("p2c-ewma", 5, b)
+}
+
+func BenchmarkLeastConnectionLargePool(b *testing.B) {
+	loadBalanceFor("least-connection", 1000, b)
+}
+
+func BenchmarkRoundRobinLargePool(b *testing.B) {
+	loadBalanceFor("round-robin", 1000, b)
+}
+
+func BenchmarkP2CEWMALargePool(b *testing.B) {
+	loadBalanceFor("p2c-ewma", 1000, b)
 }
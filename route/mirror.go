@@ -0,0 +1,25 @@
+package route
+
+import "math/rand"
+
+// Mirror copies a sample of the requests of a route to a shadow target. The target is
+// another route, the endpoints of an application, or the endpoints of an application on
+// another route.
+type Mirror struct {
+	Uri        Uri     `json:"uri,omitempty"`
+	App        string  `json:"app,omitempty"`
+	SampleRate float64 `json:"sample_rate"`
+}
+
+// IsValid returns true if the mirror has a target and a sample rate between 0 and 1.
+func (m *Mirror) IsValid() bool {
+	return (m.Uri != "" || m.App != "") && m.SampleRate > 0 && m.SampleRate <= 1
+}
+
+// Sample decides whether a request is copied to the shadow target.
+func (m *Mirror) Sample() bool {
+	if m == nil {
+		return false
+	}
+	return m.SampleRate >= 1 || rand.Float64() < m.SampleRate
+}
@@ -0,0 +1,138 @@
+package route
+
+import (
+	"math/rand"
+	"time"
+)
+
+// P2CEWMA samples two endpoints at random and picks the one with the lower
+// average latency times requests in flight. Unlike least-connection it does not
+// scan the pool, and it moves traffic away from slow endpoints.
+type P2CEWMA struct {
+	pool            *Pool
+	initialEndpoint string
+	lastEndpoint    *Endpoint
+
+	// when set, only endpoints of this application are returned
+	applicationId string
+	// when set, only endpoints in this zone are returned
+	zone string
+}
+
+func NewP2CEWMA(p *Pool, initial string) EndpointIterator {
+	return &P2CEWMA{
+		pool:            p,
+		initialEndpoint: initial,
+	}
+}
+
+func (r *P2CEWMA) Next() *Endpoint {
+	var e *Endpoint
+	if r.initialEndpoint != "" {
+		e = r.pool.findById(r.initialEndpoint)
+		r.initialEndpoint = ""
+	}
+
+	if e == nil {
+		e = r.next()
+	}
+
+	r.lastEndpoint = e
+	return e
+}
+
+func (r *P2CEWMA) next() *Endpoint {
+	r.pool.lock.Lock()
+	defer r.pool.lock.Unlock()
+
+	total := len(r.pool.endpoints)
+	if total == 0 {
+		return nil
+	}
+
+	a := rand.Intn(total)
+	b := a
+	if total > 1 {
+		b = (a + 1 + rand.Intn(total-1)) % total
+	}
+	x, y := r.available(a), r.available(b)
+
+	// when a sample is not available, as when most endpoints belong to another
+	// application, take the next available endpoints instead
+	for i := 0; i < total && (x == nil || y == nil); i++ {
+		e := r.available((a + i) % total)
+		if e == nil || e == x || e == y {
+			continue
+		}
+		if x == nil {
+			x = e
+		} else {
+			y = e
+		}
+	}
+
+	switch {
+	case x == nil && y == nil:
+		return r.reset(a)
+	case x == nil:
+		return y.endpoint
+	case y == nil || r.cost(x) <= r.cost(y):
+		return x.endpoint
+	default:
+		return y.endpoint
+	}
+}
+
+// available returns the endpoint at an index if it may be picked.
+func (r *P2CEWMA) available(i int) *endpointElem {
+	e := r.pool.endpoints[i]
+	if !selects(e.endpoint, r.applicationId, r.zone) {
+		return nil
+	}
+
+	if e.failedAt != nil && time.Since(*e.failedAt) > r.pool.retryAfterFailure {
+		// expired failure window
+		e.failedAt = nil
+	}
+	if e.failedAt != nil {
+		return nil
+	}
+	return e
+}
+
+// reset marks all endpoints available again when all of them failed recently,
+// and returns the first one that may be picked from an index.
+func (r *P2CEWMA) reset(start int) *Endpoint {
+	var selected *Endpoint
+	total := len(r.pool.endpoints)
+	for i := 0; i < total; i++ {
+		e := r.pool.endpoints[(start+i)%total]
+		e.failedAt = nil
+		if selected == nil && selects(e.endpoint, r.applicationId, r.zone) {
+			selected = e.endpoint
+		}
+	}
+	return selected
+}
+
+// cost estimates the time to serve a request on an endpoint. Endpoints without
+// responses yet count as the fastest, so they are tried early, and endpoints in
+// slow start as slower than they are.
+func (r *P2CEWMA) cost(e *endpointElem) float64 {
+	stats := e.endpoint.Stats
+	return float64(stats.Latency.Value()+1) * float64(stats.NumberConnections.Count()+1) / r.pool.weight(e)
+}
+
+func (r *P2CEWMA) EndpointFailed() {
+	if r.lastEndpoint != nil {
+		r.pool.endpointFailed(r.lastEndpoint)
+	}
+}
+
+func (r *P2CEWMA) PreRequest(e *Endpoint) {
+	e.Stats.NumberConnections.Increment()
+}
+
+func (r *P2CEWMA) PostRequest(e *Endpoint) {
+	e.Stats.NumberConnections.Decrement()
+}
@@ -0,0 +1,112 @@
+package route_test
+
+import (
+	"fmt"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("P2CEWMA", func() {
+	var (
+		pool      *route.Pool
+		endpoints []*route.Endpoint
+	)
+
+	BeforeEach(func() {
+		pool = route.NewPool(2*time.Minute, "")
+		endpoints = nil
+		for i := 0; i < 5; i++ {
+			e := route.NewEndpoint("", fmt.Sprintf("10.0.1.%d", i), 60000, fmt.Sprintf("id-%d", i), "", nil, -1, "", models.ModificationTag{})
+			endpoints = append(endpoints, e)
+			pool.Put(e)
+		}
+	})
+
+	Describe("Next", func() {
+		It("does not select an endpoint when the pool is empty", func() {
+			iter := route.NewP2CEWMA(route.NewPool(time.Minute, ""), "")
+			Expect(iter.Next()).To(BeNil())
+		})
+
+		It("selects every endpoint without statistics", func() {
+			seen := make(map[*route.Endpoint]bool)
+			for i := 0; i < 200; i++ {
+				seen[route.NewP2CEWMA(pool, "").Next()] = true
+			}
+			Expect(seen).To(HaveLen(5))
+		})
+
+		It("does not select the slowest endpoint", func() {
+			for _, e := range endpoints {
+				e.Stats.Latency.Observe(10 * time.Millisecond)
+			}
+			endpoints[2].Stats.Latency.Observe(time.Second)
+			endpoints[2].Stats.Latency.Observe(time.Second)
+
+			for i := 0; i < 200; i++ {
+				Expect(route.NewP2CEWMA(pool, "").Next()).ToNot(BeIdenticalTo(endpoints[2]))
+			}
+		})
+
+		It("does not select the endpoint with the most requests in flight", func() {
+			iter := route.NewP2CEWMA(pool, "")
+			for i := 0; i < 3; i++ {
+				iter.PreRequest(endpoints[3])
+			}
+			Expect(endpoints[3].Stats.NumberConnections.Count()).To(Equal(int64(3)))
+
+			for i := 0; i < 200; i++ {
+				Expect(route.NewP2CEWMA(pool, "").Next()).ToNot(BeIdenticalTo(endpoints[3]))
+			}
+
+			for i := 0; i < 3; i++ {
+				iter.PostRequest(endpoints[3])
+			}
+			Expect(endpoints[3].Stats.NumberConnections.Count()).To(Equal(int64(0)))
+		})
+
+		It("skips endpoints that failed recently", func() {
+			iter := route.NewP2CEWMA(pool, "")
+			failed := iter.Next()
+			iter.EndpointFailed()
+
+			for i := 0; i < 200; i++ {
+				Expect(route.NewP2CEWMA(pool, "").Next()).ToNot(BeIdenticalTo(failed))
+			}
+		})
+
+		It("selects an endpoint when all of them failed recently", func() {
+			iter := route.NewP2CEWMA(pool, "")
+			for i := 0; i < 5; i++ {
+				Expect(iter.Next()).ToNot(BeNil())
+				iter.EndpointFailed()
+			}
+			Expect(iter.Next()).ToNot(BeNil())
+		})
+
+		It("selects the initial endpoint", func() {
+			for i := 0; i < 20; i++ {
+				Expect(route.NewP2CEWMA(pool, "id-4").Next()).To(BeIdenticalTo(endpoints[4]))
+			}
+		})
+	})
+
+	Describe("EWMA", func() {
+		It("starts at the first latency and moves towards later ones", func() {
+			ewma := &route.EWMA{}
+			Expect(ewma.Value()).To(BeZero())
+
+			ewma.Observe(100 * time.Millisecond)
+			Expect(ewma.Value()).To(Equal(100 * time.Millisecond))
+
+			time.Sleep(10 * time.Millisecond)
+			ewma.Observe(time.Second)
+			Expect(ewma.Value()).To(BeNumerically(">", 100*time.Millisecond))
+			Expect(ewma.Value()).To(BeNumerically("<", time.Second))
+		})
+	})
+})
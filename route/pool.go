@@ -3,6 +3,11 @@ package route
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -29,13 +34,49 @@ func (c *Counter) Count() int64 {
 	return atomic.LoadInt64(&c.value)
 }
 
+// latencyDecay is the time over which the latency of an endpoint decays, so
+// its average follows the recent responses.
+const latencyDecay = 10 * time.Second
+
+// EWMA is an exponentially weighted moving average of latency. Each response
+// is weighed by the time since the previous one, so the average follows idle
+// and busy endpoints alike.
+type EWMA struct {
+	lock  sync.Mutex
+	value float64
+	last  time.Time
+}
+
+func (e *EWMA) Observe(latency time.Duration) {
+	now := time.Now()
+
+	e.lock.Lock()
+	if e.last.IsZero() {
+		e.value = float64(latency)
+	} else {
+		w := math.Exp(-float64(now.Sub(e.last)) / float64(latencyDecay))
+		e.value = e.value*w + float64(latency)*(1-w)
+	}
+	e.last = now
+	e.lock.Unlock()
+}
+
+// Value returns the average latency, or zero before the first response.
+func (e *EWMA) Value() time.Duration {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return time.Duration(e.value)
+}
+
 type Stats struct {
 	NumberConnections *Counter
+	Latency           *EWMA
 }
 
 func NewStats() *Stats {
 	return &Stats{
 		NumberConnections: &Counter{},
+		Latency:           &EWMA{},
 	}
 }
 
@@ -49,6 +90,15 @@ type Endpoint struct {
 	PrivateInstanceIndex string
 	ModificationTag      models.ModificationTag
 	Stats                *Stats
+	Predicates           *Predicates
+	Mirror               *Mirror
+	DisableCompression   bool
+	EnableCache          bool
+	Timeouts             *Timeouts
+	TLSPassthrough       bool
+	IPAccess             *IPAccess
+	StickyCookieNames    []string
+	HashKey              *HashKey
 }
 
 //go:generate counterfeiter -o fakes/fake_endpoint_iterator.go . EndpointIterator
@@ -62,6 +112,7 @@ type EndpointIterator interface {
 type endpointElem struct {
 	endpoint *Endpoint
 	index    int
+	added    time.Time
 	updated  time.Time
 	failedAt *time.Time
 }
@@ -76,6 +127,38 @@ type Pool struct {
 
 	retryAfterFailure time.Duration
 	nextIdx           int
+
+	// ring places the endpoints for consistent hashing, built when first needed
+	ring hashRing
+
+	// variants hold the endpoints registered with match predicates, keyed by Predicates.Key
+	variants   map[string]*Pool
+	predicates *Predicates
+
+	// weights split the traffic between the applications of the pool, keyed by application id
+	weights map[string]int
+
+	// slowStart ramps up the traffic of the endpoints added to the pool
+	slowStart config.SlowStartConfig
+
+	// settings are resolved from all the endpoints of the route when they change
+	settings routeSettings
+}
+
+// routeSettings hold the settings the endpoints of a route register for the
+// whole route. Endpoints may disagree while the route is updated, so the
+// settings are the strictest of theirs or, for those that cannot be combined,
+// the ones they all agree on.
+type routeSettings struct {
+	routeServiceUrl    string
+	disableCompression bool
+	enableCache        bool
+	tlsPassthrough     bool
+	ipAccess           *IPAccess
+	stickyCookieNames  []string
+	hashKey            *HashKey
+	timeouts           *Timeouts
+	mirror             *Mirror
 }
 
 func NewEndpoint(appId, host string, port uint16, privateInstanceId string, privateInstanceIndex string,
@@ -112,6 +195,125 @@ func (p *Pool) Put(endpoint *Endpoint) bool {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
+	if p.conflicts(endpoint) {
+		return false
+	}
+
+	key := endpoint.Predicates.Key()
+
+	// an endpoint whose predicates changed leaves the pool it was registered in
+	if key != "" {
+		p.removeIfSucceeded(endpoint)
+	}
+	for k, v := range p.variants {
+		if k != key {
+			v.lock.Lock()
+			v.removeIfSucceeded(endpoint)
+			v.lock.Unlock()
+		}
+	}
+
+	var updated bool
+	if key == "" {
+		updated = p.put(endpoint)
+	} else {
+		v := p.variant(key, endpoint.Predicates)
+		v.lock.Lock()
+		updated = v.put(endpoint)
+		v.lock.Unlock()
+	}
+
+	p.resolve()
+	return updated
+}
+
+// variant returns the pool of the endpoints registered with the predicates,
+// which it adds if there is none. lock must be held
+func (p *Pool) variant(key string, predicates *Predicates) *Pool {
+	v, ok := p.variants[key]
+	if !ok {
+		v = NewPool(p.retryAfterFailure, p.contextPath)
+		v.predicates = predicates
+		v.weights = p.weights
+		v.slowStart = p.slowStart
+		if p.variants == nil {
+			p.variants = make(map[string]*Pool)
+		}
+		p.variants[key] = v
+	}
+	return v
+}
+
+// Conflicts reports whether an endpoint disagrees with the other endpoints of
+// the route on whether TLS is terminated by them, which they must agree on.
+// Such endpoints are not put in the pool.
+func (p *Pool) Conflicts(endpoint *Endpoint) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.conflicts(endpoint)
+}
+
+// lock must be held
+func (p *Pool) conflicts(endpoint *Endpoint) bool {
+	conflicts := false
+	p.forEach(func(e *Endpoint) {
+		if e.CanonicalAddr() != endpoint.CanonicalAddr() && e.TLSPassthrough != endpoint.TLSPassthrough {
+			conflicts = true
+		}
+	})
+	return conflicts
+}
+
+// resolve settles the settings of the route from all of its endpoints. Traffic
+// is only compressed and cached when no endpoint objects, clients must be
+// permitted by the ip access lists of every endpoint, and the settings the
+// endpoints register as a whole are kept only while they all agree on them.
+// lock must be held
+func (p *Pool) resolve() {
+	var s routeSettings
+	first := true
+	p.forEach(func(e *Endpoint) {
+		if first {
+			first = false
+			s = routeSettings{
+				routeServiceUrl:    e.RouteServiceUrl,
+				disableCompression: e.DisableCompression,
+				enableCache:        e.EnableCache,
+				tlsPassthrough:     e.TLSPassthrough,
+				ipAccess:           e.IPAccess,
+				stickyCookieNames:  e.StickyCookieNames,
+				hashKey:            e.HashKey,
+				timeouts:           e.Timeouts,
+				mirror:             e.Mirror,
+			}
+			return
+		}
+
+		if s.routeServiceUrl == "" {
+			s.routeServiceUrl = e.RouteServiceUrl
+		}
+		s.disableCompression = s.disableCompression || e.DisableCompression
+		s.enableCache = s.enableCache && e.EnableCache
+		s.ipAccess = s.ipAccess.Restrict(e.IPAccess)
+		if !reflect.DeepEqual(s.stickyCookieNames, e.StickyCookieNames) {
+			s.stickyCookieNames = nil
+		}
+		if !reflect.DeepEqual(s.hashKey, e.HashKey) {
+			s.hashKey = nil
+		}
+		if !reflect.DeepEqual(s.timeouts, e.Timeouts) {
+			s.timeouts = nil
+		}
+		if !reflect.DeepEqual(s.mirror, e.Mirror) {
+			s.mirror = nil
+		}
+	})
+	p.settings = s
+}
+
+// lock must be held
+func (p *Pool) put(endpoint *Endpoint) bool {
 	e, found := p.index[endpoint.CanonicalAddr()]
 	if found {
 		if e.endpoint != endpoint {
@@ -125,15 +327,19 @@ func (p *Pool) Put(endpoint *Endpoint) bool {
 			if oldEndpoint.PrivateInstanceId != endpoint.PrivateInstanceId {
 				delete(p.index, oldEndpoint.PrivateInstanceId)
 				p.index[endpoint.PrivateInstanceId] = e
+				// a new instance at the address starts slowly again
+				e.added = time.Now()
 			}
 		}
 	} else {
 		e = &endpointElem{
 			endpoint: endpoint,
 			index:    len(p.endpoints),
+			added:    time.Now(),
 		}
 
 		p.endpoints = append(p.endpoints, e)
+		p.ring = nil
 
 		p.index[endpoint.CanonicalAddr()] = e
 		p.index[endpoint.PrivateInstanceId] = e
@@ -148,22 +354,88 @@ func (p *Pool) RouteServiceUrl() string {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
-	if len(p.endpoints) > 0 {
-		endpt := p.endpoints[0]
-		return endpt.endpoint.RouteServiceUrl
-	} else {
-		return ""
-	}
+	return p.settings.routeServiceUrl
+}
+
+func (p *Pool) DisableCompression() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.settings.disableCompression
+}
+
+func (p *Pool) CacheEnabled() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.settings.enableCache
+}
+
+// TLSPassthrough reports whether the endpoints of the route terminate TLS
+// themselves.
+func (p *Pool) TLSPassthrough() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.settings.tlsPassthrough
+}
+
+// IPAccess returns the lists restricting the clients of the route, which
+// permit only the clients the lists of every endpoint permit, or nil.
+func (p *Pool) IPAccess() *IPAccess {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.settings.ipAccess
+}
+
+// StickyCookieNames returns the session cookies that make the route sticky in
+// place of those of the config, or nil.
+func (p *Pool) StickyCookieNames() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.settings.stickyCookieNames
+}
+
+// HashKey returns the key the route is balanced on by consistent hashing, or
+// nil.
+func (p *Pool) HashKey() *HashKey {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.settings.hashKey
+}
+
+func (p *Pool) Timeouts() *Timeouts {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.settings.timeouts
+}
+
+func (p *Pool) Mirror() *Mirror {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.settings.mirror
 }
 
 func (p *Pool) PruneEndpoints(defaultThreshold time.Duration) []*Endpoint {
 	p.lock.Lock()
 
+	prunedEndpoints := []*Endpoint{}
+
+	for key, v := range p.variants {
+		prunedEndpoints = append(prunedEndpoints, v.PruneEndpoints(defaultThreshold)...)
+		if v.IsEmpty() {
+			delete(p.variants, key)
+		}
+	}
+
 	last := len(p.endpoints)
 	now := time.Now()
 
-	prunedEndpoints := []*Endpoint{}
-
 	for i := 0; i < last; {
 		e := p.endpoints[i]
 
@@ -181,19 +453,44 @@ func (p *Pool) PruneEndpoints(defaultThreshold time.Duration) []*Endpoint {
 		}
 	}
 
+	if len(prunedEndpoints) > 0 {
+		p.resolve()
+	}
+
 	p.lock.Unlock()
 	return prunedEndpoints
 }
 
 // Returns true if the endpoint was removed from the Pool, false otherwise.
 func (p *Pool) Remove(endpoint *Endpoint) bool {
-	var e *endpointElem
-
 	p.lock.Lock()
 	defer p.lock.Unlock()
-	l := len(p.endpoints)
-	if l > 0 {
-		e = p.index[endpoint.CanonicalAddr()]
+
+	removed := p.removeIfSucceeded(endpoint)
+
+	for key, v := range p.variants {
+		v.lock.Lock()
+		if v.removeIfSucceeded(endpoint) {
+			removed = true
+		}
+		empty := len(v.endpoints) == 0
+		v.lock.Unlock()
+
+		if empty {
+			delete(p.variants, key)
+		}
+	}
+
+	if removed {
+		p.resolve()
+	}
+	return removed
+}
+
+// lock must be held
+func (p *Pool) removeIfSucceeded(endpoint *Endpoint) bool {
+	if len(p.endpoints) > 0 {
+		e := p.index[endpoint.CanonicalAddr()]
 		if e != nil && e.endpoint.modificationTagSameOrNewer(endpoint) {
 			p.removeEndpoint(e)
 			return true
@@ -203,6 +500,52 @@ func (p *Pool) Remove(endpoint *Endpoint) bool {
 	return false
 }
 
+// Match returns the pool with the most specific predicates satisfied by the request.
+// Requests that satisfy none of them are served by the endpoints registered without
+// predicates, or by no pool at all if there are none.
+func (p *Pool) Match(request *http.Request) *Pool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if len(p.variants) == 0 {
+		return p
+	}
+
+	selected := p
+	selectedKey := ""
+	specificity := 0
+	for key, v := range p.variants {
+		if v.IsEmpty() || !v.predicates.Matches(request) {
+			continue
+		}
+
+		s := v.predicates.Specificity()
+		if s > specificity || (s == specificity && key < selectedKey) {
+			selected, selectedKey, specificity = v, key, s
+		}
+	}
+
+	if selected == p && len(p.endpoints) == 0 {
+		return nil
+	}
+
+	return selected
+}
+
+// Splits reports whether the requests of the route are split between endpoints
+// by predicates or by the weights of applications.
+func (p *Pool) Splits() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return len(p.variants) > 0 || len(p.weights) > 0
+}
+
+// Predicates returns the predicates shared by the endpoints of the pool, nil for the default pool.
+func (p *Pool) Predicates() *Predicates {
+	return p.predicates
+}
+
 func (p *Pool) removeEndpoint(e *endpointElem) {
 	i := e.index
 	es := p.endpoints
@@ -213,18 +556,212 @@ func (p *Pool) removeEndpoint(e *endpointElem) {
 		es[i].index = i
 	}
 	p.endpoints = es
+	p.ring = nil
 
 	delete(p.index, e.endpoint.CanonicalAddr())
 	delete(p.index, e.endpoint.PrivateInstanceId)
 }
 
 func (p *Pool) Endpoints(defaultLoadBalance, initial string) EndpointIterator {
+	return p.iterator(defaultLoadBalance, initial, p.selectApplication(initial), "")
+}
+
+// ZoneEndpoints returns an iterator over the endpoints in a zone while at least
+// healthyPercentage of them are available, and over the endpoints in all zones
+// otherwise. It reports whether it kept to the zone.
+func (p *Pool) ZoneEndpoints(defaultLoadBalance, initial, zone string, healthyPercentage int) (EndpointIterator, bool) {
+	applicationId := p.selectApplication(initial)
+	zone = p.localZone(zone, applicationId, healthyPercentage)
+	return p.iterator(defaultLoadBalance, initial, applicationId, zone), zone != ""
+}
+
+// HashEndpoints returns an iterator that sends the requests with the same key to
+// the same endpoint while it is available. It prefers a zone as ZoneEndpoints.
+func (p *Pool) HashEndpoints(key, initial, zone string, healthyPercentage int) (EndpointIterator, bool) {
+	applicationId := p.selectApplication(initial)
+	zone = p.localZone(zone, applicationId, healthyPercentage)
+	return &ConsistentHash{pool: p, key: key, initialEndpoint: initial, applicationId: applicationId, zone: zone}, zone != ""
+}
+
+// SampleEndpoint returns an endpoint picked at random among those of the
+// application, or of the application picked by the weights of the pool when
+// none is given, that have not failed recently. Unlike the iterators it leaves
+// the load balancing of the pool as it is.
+func (p *Pool) SampleEndpoint(applicationId string) *Endpoint {
+	if applicationId == "" {
+		applicationId = p.selectApplication("")
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var available []*Endpoint
+	for _, e := range p.endpoints {
+		if !selects(e.endpoint, applicationId, "") {
+			continue
+		}
+		if e.failedAt != nil && time.Since(*e.failedAt) <= p.retryAfterFailure {
+			continue
+		}
+		available = append(available, e.endpoint)
+	}
+
+	if len(available) == 0 {
+		return nil
+	}
+	return available[rand.Intn(len(available))]
+}
+
+func (p *Pool) iterator(defaultLoadBalance, initial, applicationId, zone string) EndpointIterator {
 	switch defaultLoadBalance {
 	case config.LOAD_BALANCE_LC:
-		return NewLeastConnection(p, initial)
+		return &LeastConnection{pool: p, initialEndpoint: initial, applicationId: applicationId, zone: zone}
+	case config.LOAD_BALANCE_CH:
+		// without the key of a request, endpoints are picked as by round-robin
+		return &ConsistentHash{pool: p, initialEndpoint: initial, applicationId: applicationId, zone: zone}
+	case config.LOAD_BALANCE_P2C:
+		return &P2CEWMA{pool: p, initialEndpoint: initial, applicationId: applicationId, zone: zone}
 	default:
-		return NewRoundRobin(p, initial)
+		return &RoundRobin{pool: p, initialEndpoint: initial, applicationId: applicationId, zone: zone}
+	}
+}
+
+// localZone returns the zone to keep the requests of an application in, or the
+// empty string when too few of its endpoints in the zone are available.
+func (p *Pool) localZone(zone, applicationId string, healthyPercentage int) string {
+	if zone == "" {
+		return ""
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	local, healthy := 0, 0
+	for _, e := range p.endpoints {
+		if !selects(e.endpoint, applicationId, zone) {
+			continue
+		}
+		local++
+		if e.failedAt == nil || time.Since(*e.failedAt) > p.retryAfterFailure {
+			healthy++
+		}
+	}
+
+	if healthy == 0 || healthy*100 < local*healthyPercentage {
+		return ""
+	}
+	return zone
+}
+
+// selects reports whether an iterator restricted to an application or a zone
+// may return an endpoint.
+func selects(e *Endpoint, applicationId, zone string) bool {
+	return (applicationId == "" || e.ApplicationId == applicationId) && (zone == "" || e.Zone() == zone)
+}
+
+// SetWeights splits the traffic of the pool between applications in proportion to their weights.
+// Applications without a weight receive no new clients. A nil map removes the split.
+func (p *Pool) SetWeights(weights map[string]int) {
+	p.lock.Lock()
+	p.weights = weights
+	for _, v := range p.variants {
+		v.SetWeights(weights)
 	}
+	p.lock.Unlock()
+}
+
+// SetSlowStart ramps up the traffic of the endpoints added to the pool from now on.
+func (p *Pool) SetSlowStart(slowStart config.SlowStartConfig) {
+	p.lock.Lock()
+	p.slowStart = slowStart
+	for _, v := range p.variants {
+		v.SetSlowStart(slowStart)
+	}
+	p.lock.Unlock()
+}
+
+// weight returns the share of a full share of traffic an endpoint is given. It
+// ramps up from the min weight when the endpoint is added to a full share at
+// the end of the slow start window. lock must be held
+func (p *Pool) weight(e *endpointElem) float64 {
+	window := p.slowStart.Window
+	if window <= 0 {
+		return 1
+	}
+
+	elapsed := time.Since(e.added)
+	if elapsed >= window {
+		return 1
+	}
+
+	w := math.Pow(float64(elapsed)/float64(window), 1/p.slowStart.Aggression)
+	return math.Max(w, float64(p.slowStart.MinWeightPercent)/100)
+}
+
+// passOver reports whether an iterator passes over an endpoint in slow start,
+// which it does as often as the endpoint is short of a full share of traffic.
+// lock must be held
+func (p *Pool) passOver(e *endpointElem) bool {
+	w := p.weight(e)
+	return w < 1 && rand.Float64() >= w
+}
+
+func (p *Pool) Weights() map[string]int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.weights
+}
+
+// selectApplication picks the application serving a request according to the weights of the pool.
+// Sticky requests stay with the application of their endpoint.
+func (p *Pool) selectApplication(initial string) string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if len(p.weights) == 0 {
+		return ""
+	}
+
+	if initial != "" {
+		if e := p.index[initial]; e != nil {
+			return e.endpoint.ApplicationId
+		}
+	}
+
+	// applications whose endpoints are in slow start ramp up with them
+	registered := make(map[string]int)
+	warmth := make(map[string]float64)
+	for _, e := range p.endpoints {
+		registered[e.endpoint.ApplicationId]++
+		warmth[e.endpoint.ApplicationId] += p.weight(e)
+	}
+
+	total := 0.0
+	effective := make(map[string]float64, len(p.weights))
+	applications := make([]string, 0, len(p.weights))
+	for app, weight := range p.weights {
+		if weight > 0 && registered[app] > 0 {
+			effective[app] = float64(weight) * warmth[app] / float64(registered[app])
+			total += effective[app]
+			applications = append(applications, app)
+		}
+	}
+
+	if total == 0 {
+		return ""
+	}
+
+	sort.Strings(applications)
+	n := random.Float64() * total
+	for _, app := range applications {
+		n -= effective[app]
+		if n < 0 {
+			return app
+		}
+	}
+
+	return applications[len(applications)-1]
 }
 
 func (p *Pool) findById(id string) *Endpoint {
@@ -241,10 +778,19 @@ func (p *Pool) findById(id string) *Endpoint {
 
 func (p *Pool) IsEmpty() bool {
 	p.lock.Lock()
-	l := len(p.endpoints)
-	p.lock.Unlock()
+	defer p.lock.Unlock()
 
-	return l == 0
+	if len(p.endpoints) > 0 {
+		return false
+	}
+
+	for _, v := range p.variants {
+		if !v.IsEmpty() {
+			return false
+		}
+	}
+
+	return true
 }
 
 func (p *Pool) MarkUpdated(t time.Time) {
@@ -252,6 +798,9 @@ func (p *Pool) MarkUpdated(t time.Time) {
 	for _, e := range p.endpoints {
 		e.updated = t
 	}
+	for _, v := range p.variants {
+		v.MarkUpdated(t)
+	}
 	p.lock.Unlock()
 }
 
@@ -266,19 +815,34 @@ func (p *Pool) endpointFailed(endpoint *Endpoint) {
 
 func (p *Pool) Each(f func(endpoint *Endpoint)) {
 	p.lock.Lock()
+	p.forEach(f)
+	p.lock.Unlock()
+}
+
+// forEach calls f with the endpoints of the pool and of its variants.
+// lock must be held
+func (p *Pool) forEach(f func(endpoint *Endpoint)) {
 	for _, e := range p.endpoints {
 		f(e.endpoint)
 	}
-	p.lock.Unlock()
+	for _, v := range p.variants {
+		v.Each(f)
+	}
 }
 
 func (p *Pool) MarshalJSON() ([]byte, error) {
-	p.lock.Lock()
-	endpoints := make([]Endpoint, 0, len(p.endpoints))
-	for _, e := range p.endpoints {
-		endpoints = append(endpoints, *e.endpoint)
-	}
-	p.lock.Unlock()
+	weights := p.Weights()
+
+	endpoints := make([]endpointJSON, 0, 1)
+	p.Each(func(e *Endpoint) {
+		j := e.toJSON()
+		if weights != nil {
+			weight := weights[e.ApplicationId]
+			j.App = e.ApplicationId
+			j.Weight = &weight
+		}
+		endpoints = append(endpoints, j)
+	})
 
 	return json.Marshal(endpoints)
 }
@@ -288,19 +852,47 @@ func (e *endpointElem) failed() {
 	e.failedAt = &t
 }
 
-func (e *Endpoint) MarshalJSON() ([]byte, error) {
-	var jsonObj struct {
-		Address         string            `json:"address"`
-		TTL             int               `json:"ttl"`
-		RouteServiceUrl string            `json:"route_service_url,omitempty"`
-		Tags            map[string]string `json:"tags"`
+type endpointJSON struct {
+	Address            string            `json:"address"`
+	TTL                int               `json:"ttl"`
+	RouteServiceUrl    string            `json:"route_service_url,omitempty"`
+	Tags               map[string]string `json:"tags"`
+	Match              *Predicates       `json:"match,omitempty"`
+	Mirror             *Mirror           `json:"mirror,omitempty"`
+	DisableCompression bool              `json:"disable_compression,omitempty"`
+	EnableCache        bool              `json:"enable_cache,omitempty"`
+	Timeouts           *Timeouts         `json:"timeouts,omitempty"`
+	TLSPassthrough     bool              `json:"tls_passthrough,omitempty"`
+	IPAccess           *IPAccess         `json:"ip_access,omitempty"`
+	StickyCookieNames  []string          `json:"sticky_cookie_names,omitempty"`
+	HashOn             *HashKey          `json:"hash_on,omitempty"`
+	App                string            `json:"app,omitempty"`
+	Weight             *int              `json:"weight,omitempty"`
+}
+
+func (e *Endpoint) toJSON() endpointJSON {
+	jsonObj := endpointJSON{
+		Address:            e.addr,
+		RouteServiceUrl:    e.RouteServiceUrl,
+		TTL:                int(e.staleThreshold.Seconds()),
+		Tags:               e.Tags,
+		Mirror:             e.Mirror,
+		DisableCompression: e.DisableCompression,
+		EnableCache:        e.EnableCache,
+		Timeouts:           e.Timeouts,
+		TLSPassthrough:     e.TLSPassthrough,
+		IPAccess:           e.IPAccess,
+		StickyCookieNames:  e.StickyCookieNames,
+		HashOn:             e.HashKey,
 	}
+	if !e.Predicates.IsEmpty() {
+		jsonObj.Match = e.Predicates
+	}
+	return jsonObj
+}
 
-	jsonObj.Address = e.addr
-	jsonObj.RouteServiceUrl = e.RouteServiceUrl
-	jsonObj.TTL = int(e.staleThreshold.Seconds())
-	jsonObj.Tags = e.Tags
-	return json.Marshal(jsonObj)
+func (e *Endpoint) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.toJSON())
 }
 
 func (e *Endpoint) CanonicalAddr() string {
@@ -311,6 +903,11 @@ func (rm *Endpoint) Component() string {
 	return rm.Tags["component"]
 }
 
+// Zone returns the availability zone the endpoint advertises in its tags.
+func (e *Endpoint) Zone() string {
+	return e.Tags["zone"]
+}
+
 func (e *Endpoint) ToLogData() interface{} {
 	return struct {
 		ApplicationId   string
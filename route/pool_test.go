@@ -2,8 +2,11 @@ package route_test
 
 import (
 	"fmt"
+	"net"
+	"net/http"
 	"time"
 
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/routing-api/models"
 	. "github.com/onsi/ginkgo"
@@ -104,6 +107,173 @@ var _ = Describe("Pool", func() {
 		})
 	})
 
+	Context("TLSPassthrough", func() {
+		It("returns whether the endpoints of the pool terminate TLS", func() {
+			Expect(pool.TLSPassthrough()).To(BeFalse())
+
+			pool.Put(route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", models.ModificationTag{}))
+			Expect(pool.TLSPassthrough()).To(BeFalse())
+
+			pool = route.NewPool(2*time.Minute, "")
+			pool.Put(&route.Endpoint{TLSPassthrough: true})
+			Expect(pool.TLSPassthrough()).To(BeTrue())
+		})
+
+		It("does not put endpoints that disagree with the others", func() {
+			passthrough := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", models.ModificationTag{})
+			passthrough.TLSPassthrough = true
+			Expect(pool.Put(passthrough)).To(BeTrue())
+
+			terminated := route.NewEndpoint("", "1.2.3.5", 5678, "", "", nil, -1, "", models.ModificationTag{})
+			Expect(pool.Conflicts(terminated)).To(BeTrue())
+			Expect(pool.Put(terminated)).To(BeFalse())
+			Expect(pool.TLSPassthrough()).To(BeTrue())
+
+			variant := route.NewEndpoint("", "1.2.3.6", 5678, "", "", nil, -1, "", models.ModificationTag{})
+			variant.Predicates = &route.Predicates{Method: "POST"}
+			Expect(pool.Put(variant)).To(BeFalse())
+
+			Expect(pool.Remove(passthrough)).To(BeTrue())
+			Expect(pool.Put(terminated)).To(BeTrue())
+			Expect(pool.TLSPassthrough()).To(BeFalse())
+		})
+
+		It("lets an endpoint change its mind when it is the only one", func() {
+			endpoint := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", models.ModificationTag{})
+			pool.Put(endpoint)
+
+			endpoint = route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", models.ModificationTag{})
+			endpoint.TLSPassthrough = true
+			Expect(pool.Put(endpoint)).To(BeTrue())
+			Expect(pool.TLSPassthrough()).To(BeTrue())
+		})
+	})
+
+	Context("when the endpoints of the route disagree on its settings", func() {
+		var a, b *route.Endpoint
+
+		BeforeEach(func() {
+			a = route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", models.ModificationTag{})
+			b = route.NewEndpoint("", "1.2.3.5", 5678, "", "", nil, -1, "", models.ModificationTag{})
+		})
+
+		It("does not compress the responses if any endpoint disables it", func() {
+			b.DisableCompression = true
+			pool.Put(a)
+			pool.Put(b)
+			Expect(pool.DisableCompression()).To(BeTrue())
+
+			pool.Remove(b)
+			Expect(pool.DisableCompression()).To(BeFalse())
+		})
+
+		It("caches the responses only if every endpoint enables it", func() {
+			a.EnableCache = true
+			pool.Put(a)
+			Expect(pool.CacheEnabled()).To(BeTrue())
+
+			pool.Put(b)
+			Expect(pool.CacheEnabled()).To(BeFalse())
+		})
+
+		It("keeps the settings of the route only while every endpoint agrees on them", func() {
+			a.Timeouts = &route.Timeouts{Dial: time.Second}
+			a.HashKey = &route.HashKey{ClientIP: true}
+			a.StickyCookieNames = []string{"PHPSESSID"}
+			a.Mirror = &route.Mirror{Uri: "shadow", SampleRate: 1}
+			b.Timeouts = &route.Timeouts{Dial: time.Second}
+			b.HashKey = &route.HashKey{ClientIP: true}
+			b.StickyCookieNames = []string{"PHPSESSID"}
+			b.Mirror = &route.Mirror{Uri: "shadow", SampleRate: 1}
+			pool.Put(a)
+			pool.Put(b)
+
+			Expect(pool.Timeouts()).To(Equal(&route.Timeouts{Dial: time.Second}))
+			Expect(pool.HashKey()).To(Equal(&route.HashKey{ClientIP: true}))
+			Expect(pool.StickyCookieNames()).To(Equal([]string{"PHPSESSID"}))
+			Expect(pool.Mirror()).To(Equal(&route.Mirror{Uri: "shadow", SampleRate: 1}))
+
+			c := route.NewEndpoint("", "1.2.3.6", 5678, "", "", nil, -1, "", models.ModificationTag{})
+			c.Timeouts = &route.Timeouts{Dial: time.Minute}
+			c.Predicates = &route.Predicates{Method: "POST"}
+			pool.Put(c)
+
+			Expect(pool.Timeouts()).To(BeNil())
+			Expect(pool.HashKey()).To(BeNil())
+			Expect(pool.StickyCookieNames()).To(BeNil())
+			Expect(pool.Mirror()).To(BeNil())
+
+			pool.Remove(c)
+			Expect(pool.Timeouts()).To(Equal(&route.Timeouts{Dial: time.Second}))
+		})
+
+		It("settles the settings again when endpoints are pruned", func() {
+			b.DisableCompression = true
+			pool.Put(a)
+			pool.Put(b)
+			pool.MarkUpdated(time.Now().Add(-time.Hour))
+			pool.Put(a)
+
+			pool.PruneEndpoints(time.Minute)
+			Expect(pool.DisableCompression()).To(BeFalse())
+		})
+	})
+
+	Context("IPAccess", func() {
+		It("returns the ip access lists of the endpoints of the pool", func() {
+			Expect(pool.IPAccess()).To(BeNil())
+
+			access := &route.IPAccess{}
+			pool.Put(&route.Endpoint{IPAccess: access})
+			Expect(pool.IPAccess()).To(BeIdenticalTo(access))
+		})
+
+		It("restricts the clients by the lists of every endpoint, whatever their order", func() {
+			_, allowed, _ := net.ParseCIDR("10.0.0.0/8")
+			_, denied, _ := net.ParseCIDR("10.1.0.0/16")
+			open := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", models.ModificationTag{})
+			restricted := route.NewEndpoint("", "1.2.3.5", 5678, "", "", nil, -1, "", models.ModificationTag{})
+			restricted.IPAccess = &route.IPAccess{Allow: []*net.IPNet{allowed}}
+			variant := route.NewEndpoint("", "1.2.3.6", 5678, "", "", nil, -1, "", models.ModificationTag{})
+			variant.IPAccess = &route.IPAccess{Deny: []*net.IPNet{denied}}
+			variant.Predicates = &route.Predicates{Method: "POST"}
+
+			pool.Put(open)
+			pool.Put(restricted)
+			pool.Put(variant)
+			Expect(pool.IPAccess().Permits(net.ParseIP("10.2.0.1"))).To(BeTrue())
+			Expect(pool.IPAccess().Permits(net.ParseIP("10.1.0.1"))).To(BeFalse())
+			Expect(pool.IPAccess().Permits(net.ParseIP("192.168.1.1"))).To(BeFalse())
+
+			pool.Remove(open)
+			pool.Put(open)
+			Expect(pool.IPAccess().Permits(net.ParseIP("192.168.1.1"))).To(BeFalse())
+
+			pool.Remove(restricted)
+			pool.Remove(variant)
+			Expect(pool.IPAccess()).To(BeNil())
+		})
+	})
+
+	Context("StickyCookieNames", func() {
+		It("returns the sticky session cookies of the endpoints of the pool", func() {
+			Expect(pool.StickyCookieNames()).To(BeNil())
+
+			pool.Put(&route.Endpoint{StickyCookieNames: []string{"PHPSESSID"}})
+			Expect(pool.StickyCookieNames()).To(Equal([]string{"PHPSESSID"}))
+		})
+	})
+
+	Context("HashKey", func() {
+		It("returns the hash key of the endpoints of the pool", func() {
+			Expect(pool.HashKey()).To(BeNil())
+
+			key := &route.HashKey{ClientIP: true}
+			pool.Put(&route.Endpoint{HashKey: key})
+			Expect(pool.HashKey()).To(BeIdenticalTo(key))
+		})
+	})
+
 	Context("Remove", func() {
 		It("removes endpoints", func() {
 			endpoint := &route.Endpoint{}
@@ -323,6 +493,368 @@ var _ = Describe("Pool", func() {
 		})
 	})
 
+	Context("Match", func() {
+		var (
+			defaultEndpoint, canaryEndpoint *route.Endpoint
+			request                         *http.Request
+		)
+
+		BeforeEach(func() {
+			defaultEndpoint = route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag)
+			canaryEndpoint = route.NewEndpoint("", "5.6.7.8", 5678, "", "", nil, -1, "", modTag)
+			canaryEndpoint.Predicates = &route.Predicates{Headers: map[string]string{"X-Canary": "true"}}
+
+			pool.Put(defaultEndpoint)
+			pool.Put(canaryEndpoint)
+
+			var err error
+			request, err = http.NewRequest("GET", "http://example.com/", nil)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns the pool itself when no predicates are registered", func() {
+			p := route.NewPool(2*time.Minute, "")
+			p.Put(defaultEndpoint)
+			Expect(p.Match(request)).To(BeIdenticalTo(p))
+		})
+
+		It("selects the endpoints whose predicates match the request", func() {
+			request.Header.Set("X-Canary", "true")
+
+			matched := pool.Match(request)
+			Expect(matched).ToNot(BeNil())
+			Expect(matched.Predicates()).To(Equal(canaryEndpoint.Predicates))
+			Expect(matched.Endpoints("", "").Next()).To(Equal(canaryEndpoint))
+		})
+
+		It("falls back to the endpoints without predicates", func() {
+			request.Header.Set("X-Canary", "false")
+
+			matched := pool.Match(request)
+			Expect(matched).To(BeIdenticalTo(pool))
+			Expect(matched.Endpoints("", "").Next()).To(Equal(defaultEndpoint))
+		})
+
+		It("prefers the most specific predicates", func() {
+			specific := route.NewEndpoint("", "9.9.9.9", 5678, "", "", nil, -1, "", modTag)
+			specific.Predicates = &route.Predicates{Method: "POST", Headers: map[string]string{"X-Canary": "true"}}
+			pool.Put(specific)
+
+			request.Method = "POST"
+			request.Header.Set("X-Canary", "true")
+			Expect(pool.Match(request).Endpoints("", "").Next()).To(Equal(specific))
+
+			request.Method = "GET"
+			Expect(pool.Match(request).Endpoints("", "").Next()).To(Equal(canaryEndpoint))
+		})
+
+		It("returns nil when nothing matches and there are no default endpoints", func() {
+			pool.Remove(defaultEndpoint)
+			Expect(pool.IsEmpty()).To(BeFalse())
+			Expect(pool.Match(request)).To(BeNil())
+		})
+
+		It("moves an endpoint when its predicates change", func() {
+			moved := route.NewEndpoint("", "5.6.7.8", 5678, "", "", nil, -1, "", modTag)
+			pool.Put(moved)
+
+			request.Header.Set("X-Canary", "true")
+			Expect(pool.Match(request)).To(BeIdenticalTo(pool))
+
+			count := 0
+			pool.Each(func(e *route.Endpoint) { count++ })
+			Expect(count).To(Equal(2))
+		})
+
+		It("removes and prunes endpoints with predicates", func() {
+			Expect(pool.Remove(canaryEndpoint)).To(BeTrue())
+
+			request.Header.Set("X-Canary", "true")
+			Expect(pool.Match(request)).To(BeIdenticalTo(pool))
+
+			pool.Put(canaryEndpoint)
+			pool.MarkUpdated(time.Now().Add(-10 * time.Minute))
+			Expect(pool.PruneEndpoints(time.Minute)).To(ConsistOf(defaultEndpoint, canaryEndpoint))
+			Expect(pool.IsEmpty()).To(BeTrue())
+		})
+	})
+
+	Context("ZoneEndpoints", func() {
+		var local []*route.Endpoint
+
+		BeforeEach(func() {
+			local = nil
+			for i := 0; i < 4; i++ {
+				e := route.NewEndpoint("", fmt.Sprintf("10.0.1.%d", i), 5678, "", "", map[string]string{"zone": "z1"}, -1, "", modTag)
+				local = append(local, e)
+				pool.Put(e)
+			}
+			pool.Put(route.NewEndpoint("", "10.0.2.1", 5678, "", "", map[string]string{"zone": "z2"}, -1, "", modTag))
+		})
+
+		zones := func(lb string) map[string]int {
+			counts := make(map[string]int)
+			for i := 0; i < 100; i++ {
+				iter, _ := pool.ZoneEndpoints(lb, "", "z1", 50)
+				counts[iter.Next().Zone()]++
+			}
+			return counts
+		}
+
+		fail := func(e *route.Endpoint) {
+			iter := pool.Endpoints(config.LOAD_BALANCE_RR, "")
+			for iter.Next() != e {
+			}
+			iter.EndpointFailed()
+		}
+
+		It("keeps to the endpoints in the zone", func() {
+			_, kept := pool.ZoneEndpoints(config.LOAD_BALANCE_RR, "", "z1", 50)
+			Expect(kept).To(BeTrue())
+
+			for _, lb := range config.LoadBalancingStrategies {
+				Expect(zones(lb)).To(Equal(map[string]int{"z1": 100}), lb)
+			}
+		})
+
+		It("keeps to the zone while enough endpoints in it are available", func() {
+			fail(local[0])
+			fail(local[1])
+
+			_, kept := pool.ZoneEndpoints(config.LOAD_BALANCE_RR, "", "z1", 50)
+			Expect(kept).To(BeTrue())
+			Expect(zones(config.LOAD_BALANCE_RR)).To(Equal(map[string]int{"z1": 100}))
+		})
+
+		It("spills over to all zones when too few endpoints in the zone are available", func() {
+			fail(local[0])
+			fail(local[1])
+			fail(local[2])
+
+			_, kept := pool.ZoneEndpoints(config.LOAD_BALANCE_RR, "", "z1", 50)
+			Expect(kept).To(BeFalse())
+			Expect(zones(config.LOAD_BALANCE_RR)).To(HaveKey("z2"))
+		})
+
+		It("spills over to all zones without endpoints in the zone", func() {
+			_, kept := pool.ZoneEndpoints(config.LOAD_BALANCE_RR, "", "z3", 50)
+			Expect(kept).To(BeFalse())
+		})
+
+		It("does not keep to a zone without one", func() {
+			_, kept := pool.ZoneEndpoints(config.LOAD_BALANCE_RR, "", "", 50)
+			Expect(kept).To(BeFalse())
+		})
+	})
+
+	Context("SlowStart", func() {
+		var (
+			old   []*route.Endpoint
+			added *route.Endpoint
+		)
+
+		BeforeEach(func() {
+			pool.SetSlowStart(config.SlowStartConfig{Window: 500 * time.Millisecond, Aggression: 1, MinWeightPercent: 10})
+
+			old = nil
+			for i := 0; i < 4; i++ {
+				e := route.NewEndpoint("app-a", fmt.Sprintf("10.0.1.%d", i), 5678, "", "", nil, -1, "", modTag)
+				old = append(old, e)
+				pool.Put(e)
+			}
+			time.Sleep(500 * time.Millisecond)
+
+			added = route.NewEndpoint("app-b", "10.0.2.1", 5678, "", "", nil, -1, "", modTag)
+			pool.Put(added)
+		})
+
+		countAdded := func(lb string) int {
+			count := 0
+			for i := 0; i < 1000; i++ {
+				if pool.Endpoints(lb, "").Next() == added {
+					count++
+				}
+			}
+			return count
+		}
+
+		It("ramps up the traffic of a new endpoint with round-robin", func() {
+			Expect(countAdded(config.LOAD_BALANCE_RR)).To(BeNumerically("<", 80))
+
+			time.Sleep(500 * time.Millisecond)
+			Expect(countAdded(config.LOAD_BALANCE_RR)).To(BeNumerically("~", 200, 20))
+		})
+
+		It("ramps up the traffic of a new endpoint with least-connection", func() {
+			Expect(countAdded(config.LOAD_BALANCE_LC)).To(BeZero())
+
+			for _, e := range old {
+				for i := 0; i < 10; i++ {
+					e.Stats.NumberConnections.Increment()
+				}
+			}
+			Expect(countAdded(config.LOAD_BALANCE_LC)).To(Equal(1000))
+		})
+
+		It("ramps up the traffic of a new application with weights", func() {
+			pool.SetWeights(map[string]int{"app-a": 50, "app-b": 50})
+			Expect(countAdded(config.LOAD_BALANCE_RR)).To(BeNumerically("<", 200))
+
+			time.Sleep(500 * time.Millisecond)
+			Expect(countAdded(config.LOAD_BALANCE_RR)).To(BeNumerically("~", 500, 60))
+		})
+
+		It("does not restart the slow start of endpoints that register again", func() {
+			modTag.Increment()
+			pool.Put(route.NewEndpoint("app-a", "10.0.1.0", 5678, "", "", nil, -1, "", modTag))
+
+			Expect(countAdded(config.LOAD_BALANCE_LC)).To(BeZero())
+			for i := 0; i < 10; i++ {
+				old[1].Stats.NumberConnections.Increment()
+				old[2].Stats.NumberConnections.Increment()
+				old[3].Stats.NumberConnections.Increment()
+			}
+			for i := 0; i < 20; i++ {
+				Expect(pool.Endpoints(config.LOAD_BALANCE_LC, "").Next().CanonicalAddr()).To(Equal("10.0.1.0:5678"))
+			}
+		})
+	})
+
+	Context("Splits", func() {
+		It("reports whether requests are split by predicates or weights", func() {
+			pool.Put(route.NewEndpoint("app-a", "1.2.3.4", 5678, "a-0", "", nil, -1, "", modTag))
+			Expect(pool.Splits()).To(BeFalse())
+
+			pool.SetWeights(map[string]int{"app-a": 1})
+			Expect(pool.Splits()).To(BeTrue())
+			pool.SetWeights(nil)
+
+			variant := route.NewEndpoint("app-a", "1.2.3.5", 5678, "a-1", "", nil, -1, "", modTag)
+			variant.Predicates = &route.Predicates{Method: "POST"}
+			pool.Put(variant)
+			Expect(pool.Splits()).To(BeTrue())
+		})
+	})
+
+	Context("Weights", func() {
+		var appA, appB *route.Endpoint
+
+		BeforeEach(func() {
+			appA = route.NewEndpoint("app-a", "1.2.3.4", 5678, "a-0", "", nil, -1, "", modTag)
+			appB = route.NewEndpoint("app-b", "5.6.7.8", 5678, "b-0", "", nil, -1, "", modTag)
+			pool.Put(appA)
+			pool.Put(appB)
+		})
+
+		countByApp := func(lb string, initial string) map[string]int {
+			counts := make(map[string]int)
+			for i := 0; i < 1000; i++ {
+				e := pool.Endpoints(lb, initial).Next()
+				counts[e.ApplicationId]++
+			}
+			return counts
+		}
+
+		It("splits the traffic between applications", func() {
+			pool.SetWeights(map[string]int{"app-a": 90, "app-b": 10})
+
+			counts := countByApp(config.LOAD_BALANCE_RR, "")
+			Expect(counts["app-a"]).To(BeNumerically("~", 900, 60))
+			Expect(counts["app-b"]).To(BeNumerically("~", 100, 60))
+		})
+
+		It("sends no traffic to applications without a weight", func() {
+			pool.SetWeights(map[string]int{"app-a": 1})
+
+			Expect(countByApp(config.LOAD_BALANCE_RR, "")).To(Equal(map[string]int{"app-a": 1000}))
+			Expect(countByApp(config.LOAD_BALANCE_LC, "")).To(Equal(map[string]int{"app-a": 1000}))
+			Expect(countByApp(config.LOAD_BALANCE_P2C, "")).To(Equal(map[string]int{"app-a": 1000}))
+		})
+
+		It("only fails over to endpoints of the selected application", func() {
+			appA2 := route.NewEndpoint("app-a", "1.2.3.5", 5678, "a-1", "", nil, -1, "", modTag)
+			pool.Put(appA2)
+			pool.SetWeights(map[string]int{"app-a": 1})
+
+			iter := pool.Endpoints(config.LOAD_BALANCE_RR, "")
+			first := iter.Next()
+			iter.EndpointFailed()
+			second := iter.Next()
+
+			Expect([]*route.Endpoint{first, second}).To(ConsistOf(appA, appA2))
+		})
+
+		It("keeps sticky sessions on their application", func() {
+			pool.SetWeights(map[string]int{"app-a": 100, "app-b": 0})
+
+			Expect(countByApp(config.LOAD_BALANCE_RR, "b-0")).To(Equal(map[string]int{"app-b": 1000}))
+		})
+
+		It("ignores the weights when no weighted application is registered", func() {
+			pool.SetWeights(map[string]int{"app-c": 100})
+
+			counts := countByApp(config.LOAD_BALANCE_RR, "")
+			Expect(counts["app-a"]).To(BeNumerically(">", 0))
+			Expect(counts["app-b"]).To(BeNumerically(">", 0))
+		})
+
+		It("includes the weights in json", func() {
+			pool.SetWeights(map[string]int{"app-a": 100})
+
+			json, err := pool.MarshalJSON()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(json)).To(ContainSubstring(`"app":"app-a","weight":100`))
+			Expect(string(json)).To(ContainSubstring(`"app":"app-b","weight":0`))
+		})
+	})
+
+	Context("SampleEndpoint", func() {
+		var appA, appA2, appB *route.Endpoint
+
+		BeforeEach(func() {
+			appA = route.NewEndpoint("app-a", "1.2.3.4", 5678, "a-0", "", nil, -1, "", modTag)
+			appA2 = route.NewEndpoint("app-a", "1.2.3.5", 5678, "a-1", "", nil, -1, "", modTag)
+			appB = route.NewEndpoint("app-b", "5.6.7.8", 5678, "b-0", "", nil, -1, "", modTag)
+			pool.Put(appA)
+			pool.Put(appA2)
+			pool.Put(appB)
+		})
+
+		It("picks an endpoint of the application", func() {
+			for i := 0; i < 100; i++ {
+				Expect(pool.SampleEndpoint("app-b")).To(Equal(appB))
+			}
+			Expect(pool.SampleEndpoint("app-c")).To(BeNil())
+		})
+
+		It("picks an endpoint of the application of the weights without an application", func() {
+			pool.SetWeights(map[string]int{"app-a": 1})
+
+			for i := 0; i < 100; i++ {
+				Expect(pool.SampleEndpoint("")).To(Or(Equal(appA), Equal(appA2)))
+			}
+		})
+
+		It("does not pick endpoints that failed recently", func() {
+			iter := pool.Endpoints(config.LOAD_BALANCE_RR, appA.PrivateInstanceId)
+			Expect(iter.Next()).To(Equal(appA))
+			iter.EndpointFailed()
+
+			for i := 0; i < 100; i++ {
+				Expect(pool.SampleEndpoint("app-a")).To(Equal(appA2))
+			}
+		})
+
+		It("does not move the round robin of the pool", func() {
+			seen := make(map[*route.Endpoint]bool)
+			for i := 0; i < 3; i++ {
+				seen[pool.Endpoints(config.LOAD_BALANCE_RR, "").Next()] = true
+				pool.SampleEndpoint("app-a")
+			}
+			Expect(seen).To(HaveLen(3))
+		})
+	})
+
 	Context("Each", func() {
 		It("applies a function to each endpoint", func() {
 			e1 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag)
@@ -0,0 +1,108 @@
+package route
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Predicates restrict the requests that are routed to an endpoint beyond its URI.
+// An empty value matches any request that carries the header, cookie or query parameter.
+type Predicates struct {
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Cookies map[string]string `json:"cookies,omitempty"`
+	Query   map[string]string `json:"query,omitempty"`
+}
+
+// IsEmpty returns true if the predicates match every request.
+func (p *Predicates) IsEmpty() bool {
+	return p == nil || (p.Method == "" && len(p.Headers) == 0 && len(p.Cookies) == 0 && len(p.Query) == 0)
+}
+
+// Specificity is the number of conditions a request has to satisfy.
+func (p *Predicates) Specificity() int {
+	if p.IsEmpty() {
+		return 0
+	}
+
+	s := len(p.Headers) + len(p.Cookies) + len(p.Query)
+	if p.Method != "" {
+		s++
+	}
+	return s
+}
+
+// Key returns a canonical representation used to group endpoints with equal predicates.
+func (p *Predicates) Key() string {
+	if p.IsEmpty() {
+		return ""
+	}
+
+	conditions := []string{}
+	if p.Method != "" {
+		conditions = append(conditions, "method="+strings.ToUpper(p.Method))
+	}
+	for name, value := range p.Headers {
+		conditions = append(conditions, "header:"+http.CanonicalHeaderKey(name)+"="+value)
+	}
+	for name, value := range p.Cookies {
+		conditions = append(conditions, "cookie:"+name+"="+value)
+	}
+	for name, value := range p.Query {
+		conditions = append(conditions, "query:"+name+"="+value)
+	}
+	sort.Strings(conditions)
+
+	return strings.Join(conditions, ";")
+}
+
+// Matches returns true if the request satisfies all of the predicates.
+func (p *Predicates) Matches(request *http.Request) bool {
+	if p.IsEmpty() {
+		return true
+	}
+
+	if p.Method != "" && !strings.EqualFold(p.Method, request.Method) {
+		return false
+	}
+
+	for name, value := range p.Headers {
+		values, ok := request.Header[http.CanonicalHeaderKey(name)]
+		if !ok || !matchesValue(values, value) {
+			return false
+		}
+	}
+
+	for name, value := range p.Cookies {
+		cookie, err := request.Cookie(name)
+		if err != nil || (value != "" && cookie.Value != value) {
+			return false
+		}
+	}
+
+	if len(p.Query) > 0 {
+		query := request.URL.Query()
+		for name, value := range p.Query {
+			values, ok := query[name]
+			if !ok || !matchesValue(values, value) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func matchesValue(values []string, expected string) bool {
+	if expected == "" {
+		return true
+	}
+
+	for _, v := range values {
+		if v == expected {
+			return true
+		}
+	}
+	return false
+}
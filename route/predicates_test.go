@@ -0,0 +1,84 @@
+package route_test
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/route"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Predicates", func() {
+	var request *http.Request
+
+	BeforeEach(func() {
+		var err error
+		request, err = http.NewRequest("GET", "http://example.com/foo?version=2", nil)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("matches every request when empty", func() {
+		var p *route.Predicates
+		Expect(p.IsEmpty()).To(BeTrue())
+		Expect(p.Matches(request)).To(BeTrue())
+		Expect(p.Key()).To(BeEmpty())
+		Expect((&route.Predicates{}).IsEmpty()).To(BeTrue())
+	})
+
+	It("matches the method case insensitively", func() {
+		p := &route.Predicates{Method: "get"}
+		Expect(p.Matches(request)).To(BeTrue())
+
+		request.Method = "POST"
+		Expect(p.Matches(request)).To(BeFalse())
+	})
+
+	It("matches header values", func() {
+		p := &route.Predicates{Headers: map[string]string{"x-canary": "true"}}
+		Expect(p.Matches(request)).To(BeFalse())
+
+		request.Header.Set("X-Canary", "true")
+		Expect(p.Matches(request)).To(BeTrue())
+
+		request.Header.Set("X-Canary", "false")
+		Expect(p.Matches(request)).To(BeFalse())
+	})
+
+	It("matches the presence of a header when no value is given", func() {
+		p := &route.Predicates{Headers: map[string]string{"X-Beta": ""}}
+		Expect(p.Matches(request)).To(BeFalse())
+
+		request.Header.Set("X-Beta", "anything")
+		Expect(p.Matches(request)).To(BeTrue())
+	})
+
+	It("matches cookies", func() {
+		p := &route.Predicates{Cookies: map[string]string{"group": "beta"}}
+		Expect(p.Matches(request)).To(BeFalse())
+
+		request.AddCookie(&http.Cookie{Name: "group", Value: "beta"})
+		Expect(p.Matches(request)).To(BeTrue())
+	})
+
+	It("matches query parameters", func() {
+		Expect((&route.Predicates{Query: map[string]string{"version": "2"}}).Matches(request)).To(BeTrue())
+		Expect((&route.Predicates{Query: map[string]string{"version": "1"}}).Matches(request)).To(BeFalse())
+		Expect((&route.Predicates{Query: map[string]string{"debug": ""}}).Matches(request)).To(BeFalse())
+	})
+
+	It("requires all conditions to match", func() {
+		p := &route.Predicates{Method: "GET", Headers: map[string]string{"X-Canary": "true"}}
+		Expect(p.Specificity()).To(Equal(2))
+		Expect(p.Matches(request)).To(BeFalse())
+
+		request.Header.Set("X-Canary", "true")
+		Expect(p.Matches(request)).To(BeTrue())
+	})
+
+	It("has a canonical key", func() {
+		p1 := &route.Predicates{Method: "get", Headers: map[string]string{"x-canary": "true", "X-Beta": ""}}
+		p2 := &route.Predicates{Method: "GET", Headers: map[string]string{"X-Beta": "", "X-Canary": "true"}}
+		Expect(p1.Key()).To(Equal(p2.Key()))
+		Expect(p1.Key()).ToNot(Equal((&route.Predicates{Method: "GET"}).Key()))
+	})
+})
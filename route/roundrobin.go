@@ -12,6 +12,11 @@ type RoundRobin struct {
 
 	initialEndpoint string
 	lastEndpoint    *Endpoint
+
+	// when set, only endpoints of this application are returned
+	applicationId string
+	// when set, only endpoints in this zone are returned
+	zone string
 }
 
 func NewRoundRobin(p *Pool, initial string) EndpointIterator {
@@ -54,6 +59,9 @@ func (r *RoundRobin) next() *Endpoint {
 
 	startIdx := r.pool.nextIdx
 	curIdx := startIdx
+	eligible := false
+	var passed *endpointElem
+	passedIdx := 0
 	for {
 		e := r.pool.endpoints[curIdx]
 
@@ -62,20 +70,40 @@ func (r *RoundRobin) next() *Endpoint {
 			curIdx = 0
 		}
 
-		if e.failedAt != nil {
-			curTime := time.Now()
-			if curTime.Sub(*e.failedAt) > r.pool.retryAfterFailure {
-				// exipired failure window
-				e.failedAt = nil
+		if !selects(e.endpoint, r.applicationId, r.zone) {
+			if curIdx == startIdx && !eligible {
+				// no endpoints of the application or zone are left
+				return nil
+			}
+		} else {
+			eligible = true
+
+			if e.failedAt != nil {
+				curTime := time.Now()
+				if curTime.Sub(*e.failedAt) > r.pool.retryAfterFailure {
+					// exipired failure window
+					e.failedAt = nil
+				}
 			}
-		}
 
-		if e.failedAt == nil {
-			r.pool.nextIdx = curIdx
-			return e.endpoint
+			if e.failedAt == nil {
+				if !r.pool.passOver(e) {
+					r.pool.nextIdx = curIdx
+					return e.endpoint
+				}
+				if passed == nil {
+					passed, passedIdx = e, curIdx
+				}
+			}
 		}
 
 		if curIdx == startIdx {
+			if passed != nil {
+				// all endpoints available were passed over in slow start
+				r.pool.nextIdx = passedIdx
+				return passed.endpoint
+			}
+
 			// all endpoints are marked failed so reset everything to available
 			for _, e2 := range r.pool.endpoints {
 				e2.failedAt = nil
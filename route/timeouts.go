@@ -0,0 +1,78 @@
+package route
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Timeouts bound the phases of a request to an endpoint. A zero timeout is not
+// set and falls back to the timeout configured for the router.
+type Timeouts struct {
+	Dial           time.Duration
+	TLSHandshake   time.Duration
+	ResponseHeader time.Duration
+	IdleBody       time.Duration
+}
+
+// timeoutsJSON holds the timeouts in seconds, as they are registered.
+type timeoutsJSON struct {
+	Dial           float64 `json:"dial,omitempty"`
+	TLSHandshake   float64 `json:"tls_handshake,omitempty"`
+	ResponseHeader float64 `json:"response_header,omitempty"`
+	IdleBody       float64 `json:"idle_body,omitempty"`
+}
+
+func (t Timeouts) MarshalJSON() ([]byte, error) {
+	return json.Marshal(timeoutsJSON{
+		Dial:           t.Dial.Seconds(),
+		TLSHandshake:   t.TLSHandshake.Seconds(),
+		ResponseHeader: t.ResponseHeader.Seconds(),
+		IdleBody:       t.IdleBody.Seconds(),
+	})
+}
+
+func (t *Timeouts) UnmarshalJSON(data []byte) error {
+	var j timeoutsJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	*t = Timeouts{
+		Dial:           seconds(j.Dial),
+		TLSHandshake:   seconds(j.TLSHandshake),
+		ResponseHeader: seconds(j.ResponseHeader),
+		IdleBody:       seconds(j.IdleBody),
+	}
+	return nil
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// IsValid returns true if no timeout is negative.
+func (t *Timeouts) IsValid() bool {
+	return t.Dial >= 0 && t.TLSHandshake >= 0 && t.ResponseHeader >= 0 && t.IdleBody >= 0
+}
+
+// Merge returns the timeouts with the unset ones taken from the defaults.
+func (t *Timeouts) Merge(defaults Timeouts) Timeouts {
+	if t == nil {
+		return defaults
+	}
+
+	merged := *t
+	if merged.Dial == 0 {
+		merged.Dial = defaults.Dial
+	}
+	if merged.TLSHandshake == 0 {
+		merged.TLSHandshake = defaults.TLSHandshake
+	}
+	if merged.ResponseHeader == 0 {
+		merged.ResponseHeader = defaults.ResponseHeader
+	}
+	if merged.IdleBody == 0 {
+		merged.IdleBody = defaults.IdleBody
+	}
+	return merged
+}
@@ -0,0 +1,50 @@
+package route_test
+
+import (
+	"encoding/json"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Timeouts", func() {
+	defaults := route.Timeouts{
+		Dial:           5 * time.Second,
+		TLSHandshake:   10 * time.Second,
+		ResponseHeader: 60 * time.Second,
+		IdleBody:       60 * time.Second,
+	}
+
+	It("reads the timeouts in seconds", func() {
+		var t route.Timeouts
+		err := json.Unmarshal([]byte(`{"dial":0.5,"response_header":300}`), &t)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(t).To(Equal(route.Timeouts{Dial: 500 * time.Millisecond, ResponseHeader: 300 * time.Second}))
+
+		b, err := json.Marshal(t)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(b)).To(MatchJSON(`{"dial":0.5,"response_header":300}`))
+	})
+
+	It("takes unset timeouts from the defaults", func() {
+		t := &route.Timeouts{ResponseHeader: 300 * time.Second}
+		Expect(t.Merge(defaults)).To(Equal(route.Timeouts{
+			Dial:           5 * time.Second,
+			TLSHandshake:   10 * time.Second,
+			ResponseHeader: 300 * time.Second,
+			IdleBody:       60 * time.Second,
+		}))
+	})
+
+	It("uses the defaults for routes without timeouts", func() {
+		var t *route.Timeouts
+		Expect(t.Merge(defaults)).To(Equal(defaults))
+	})
+
+	It("rejects negative timeouts", func() {
+		Expect((&route.Timeouts{IdleBody: time.Second}).IsValid()).To(BeTrue())
+		Expect((&route.Timeouts{Dial: -time.Second}).IsValid()).To(BeFalse())
+	})
+})
@@ -25,6 +25,7 @@ type RouteFetcher struct {
 
 	logger          lager.Logger
 	endpoints       []models.Route
+	tcpEndpoints    []tcpEndpoint
 	client          routing_api.Client
 	stopEventSource int32
 	eventSource     atomic.Value
@@ -33,6 +34,13 @@ type RouteFetcher struct {
 	clock clock.Clock
 }
 
+// tcpEndpoint is a TCP route mapping with the name of its router group, which
+// the registry keys TCP routes by.
+type tcpEndpoint struct {
+	routerGroup string
+	mapping     models.TcpRouteMapping
+}
+
 const (
 	TokenFetchErrors      = "token_fetch_errors"
 	SubscribeEventsErrors = "subscribe_events_errors"
@@ -187,9 +195,49 @@ func (r *RouteFetcher) FetchRoutes() error {
 
 	r.logger.Debug("syncer-refreshing-endpoints", lager.Data{"number-of-routes": len(routes)})
 	r.refreshEndpoints(routes)
+
+	tcpEndpoints, err := r.fetchTcpEndpoints()
+	if err != nil {
+		return err
+	}
+
+	r.logger.Debug("syncer-refreshing-tcp-endpoints", lager.Data{"number-of-routes": len(tcpEndpoints)})
+	r.refreshTcpEndpoints(tcpEndpoints)
 	return nil
 }
 
+// fetchTcpEndpoints fetches the TCP route mappings with the token set while
+// fetching the HTTP routes.
+func (r *RouteFetcher) fetchTcpEndpoints() ([]tcpEndpoint, error) {
+	r.logger.Debug("syncer-fetching-router-groups")
+	routerGroups, err := r.client.RouterGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string, len(routerGroups))
+	for _, group := range routerGroups {
+		names[group.Guid] = group.Name
+	}
+
+	r.logger.Debug("syncer-fetching-tcp-routes")
+	mappings, err := r.client.TcpRouteMappings()
+	if err != nil {
+		return nil, err
+	}
+
+	tcpEndpoints := make([]tcpEndpoint, 0, len(mappings))
+	for _, mapping := range mappings {
+		name, ok := names[mapping.RouterGroupGuid]
+		if !ok {
+			r.logger.Info("syncer-unknown-router-group", lager.Data{"router_group_guid": mapping.RouterGroupGuid})
+			continue
+		}
+		tcpEndpoints = append(tcpEndpoints, tcpEndpoint{routerGroup: name, mapping: mapping})
+	}
+	return tcpEndpoints, nil
+}
+
 func (r *RouteFetcher) fetchRoutesWithTokenRefresh() ([]models.Route, error) {
 	forceUpdate := false
 	var err error
@@ -275,6 +323,59 @@ func (r *RouteFetcher) deleteEndpoints(validRoutes []models.Route) {
 	}
 }
 
+func (r *RouteFetcher) refreshTcpEndpoints(validEndpoints []tcpEndpoint) {
+	r.deleteTcpEndpoints(validEndpoints)
+
+	r.tcpEndpoints = validEndpoints
+
+	for _, e := range r.tcpEndpoints {
+		r.RouteRegistry.RegisterTcp(e.routerGroup, e.mapping.ExternalPort, makeTcpEndpoint(e.mapping))
+	}
+}
+
+func (r *RouteFetcher) deleteTcpEndpoints(validEndpoints []tcpEndpoint) {
+	for _, cur := range r.tcpEndpoints {
+		found := false
+
+		for _, valid := range validEndpoints {
+			if tcpEndpointEquals(cur, valid) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			r.RouteRegistry.UnregisterTcp(cur.routerGroup, cur.mapping.ExternalPort, makeTcpEndpoint(cur.mapping))
+		}
+	}
+}
+
+func makeTcpEndpoint(mapping models.TcpRouteMapping) *route.Endpoint {
+	ttl := 0
+	if mapping.TTL != nil {
+		ttl = *mapping.TTL
+	}
+
+	return route.NewEndpoint(
+		"",
+		mapping.HostIP,
+		mapping.HostPort,
+		"",
+		"",
+		nil,
+		ttl,
+		"",
+		mapping.ModificationTag,
+	)
+}
+
+func tcpEndpointEquals(current, desired tcpEndpoint) bool {
+	return current.routerGroup == desired.routerGroup &&
+		current.mapping.ExternalPort == desired.mapping.ExternalPort &&
+		current.mapping.HostIP == desired.mapping.HostIP &&
+		current.mapping.HostPort == desired.mapping.HostPort
+}
+
 func routeEquals(current, desired models.Route) bool {
 	if current.Route == desired.Route && current.IP == desired.IP && current.Port == desired.Port {
 		return true
@@ -258,6 +258,61 @@ var _ = Describe("RouteFetcher", func() {
 			})
 		})
 
+		Context("when the routing api has tcp routes", func() {
+			var mappings []models.TcpRouteMapping
+
+			BeforeEach(func() {
+				client.RouterGroupsReturns([]models.RouterGroup{
+					{Guid: "group-guid", Name: "default-tcp", Type: "tcp"},
+				}, nil)
+
+				mappings = []models.TcpRouteMapping{
+					models.NewTcpRouteMapping("group-guid", 1024, "1.1.1.1", 61000, 120),
+					models.NewTcpRouteMapping("group-guid", 1025, "2.2.2.2", 61001, 120),
+					models.NewTcpRouteMapping("unknown-guid", 1026, "3.3.3.3", 61002, 120),
+				}
+				client.TcpRouteMappingsReturns(mappings, nil)
+			})
+
+			It("registers the tcp routes of known router groups", func() {
+				err := fetcher.FetchRoutes()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(registry.RegisterTcpCallCount()).To(Equal(2))
+				for i := 0; i < 2; i++ {
+					routerGroup, port, endpoint := registry.RegisterTcpArgsForCall(i)
+					Expect(routerGroup).To(Equal("default-tcp"))
+					Expect(port).To(Equal(mappings[i].ExternalPort))
+					Expect(endpoint).To(Equal(
+						route.NewEndpoint("", mappings[i].HostIP, mappings[i].HostPort, "", "", nil, 120, "", mappings[i].ModificationTag)))
+				}
+			})
+
+			It("removes unregistered tcp routes", func() {
+				err := fetcher.FetchRoutes()
+				Expect(err).ToNot(HaveOccurred())
+
+				client.TcpRouteMappingsReturns(mappings[:1], nil)
+
+				err = fetcher.FetchRoutes()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(registry.UnregisterTcpCallCount()).To(Equal(1))
+
+				routerGroup, port, endpoint := registry.UnregisterTcpArgsForCall(0)
+				Expect(routerGroup).To(Equal("default-tcp"))
+				Expect(port).To(Equal(uint16(1025)))
+				Expect(endpoint.CanonicalAddr()).To(Equal("2.2.2.2:61001"))
+			})
+
+			It("returns the error of the routing api", func() {
+				client.TcpRouteMappingsReturns(nil, errors.New("Oops!"))
+
+				err := fetcher.FetchRoutes()
+				Expect(err).To(MatchError("Oops!"))
+				Expect(registry.RegisterTcpCallCount()).To(BeZero())
+			})
+		})
+
 		Context("When the token fetcher returns an error", func() {
 			BeforeEach(func() {
 				uaaClient.FetchTokenReturns(nil, errors.New("token fetcher error"))
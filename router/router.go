@@ -21,6 +21,7 @@ import (
 	"code.cloudfoundry.org/gorouter/common"
 	"code.cloudfoundry.org/gorouter/common/health"
 	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/common/proxyproto"
 	"code.cloudfoundry.org/gorouter/common/schema"
 	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/handlers"
@@ -29,7 +30,6 @@ import (
 	"code.cloudfoundry.org/gorouter/registry"
 	"code.cloudfoundry.org/gorouter/varz"
 	"code.cloudfoundry.org/lager"
-	"github.com/armon/go-proxyproto"
 	"github.com/cloudfoundry/dropsonde"
 	"github.com/nats-io/nats"
 )
@@ -53,6 +53,7 @@ type Router struct {
 
 	listener         net.Listener
 	tlsListener      net.Listener
+	tcpListeners     []net.Listener
 	closeConnections bool
 	connLock         sync.Mutex
 	idleConns        map[net.Conn]struct{}
@@ -60,6 +61,7 @@ type Router struct {
 	drainDone        chan struct{}
 	serveDone        chan struct{}
 	tlsServeDone     chan struct{}
+	tcpServeDone     sync.WaitGroup
 	stopping         bool
 	stopLock         sync.Mutex
 	uptimeMonitor    *monitor.Uptime
@@ -96,11 +98,22 @@ func NewRouter(logger lager.Logger, cfg *config.Config, p proxy.Proxy, mbusClien
 		Healthz: healthz,
 		Health:  health,
 		InfoRoutes: map[string]json.Marshaler{
-			"/routes": r,
+			"/routes":      r,
+			"/connections": p.Connections(),
 		},
 		Logger: logger,
 	}
 
+	// connections to removed endpoints must not be reused by an application
+	// registered later at the same address
+	r.OnEndpointRemoved(p.Connections().Evict)
+
+	if c := p.Cache(); c != nil {
+		component.InfoHandlers = map[string]http.Handler{
+			"/cache": c,
+		}
+	}
+
 	routerErrChan := errChan
 	if routerErrChan == nil {
 		routerErrChan = make(chan error, 2)
@@ -179,6 +192,8 @@ func (r *Router) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 	server := &http.Server{
 		Handler:   &handler,
 		ConnState: r.HandleConnState,
+		// lets the proxy pass the PROXY protocol headers of clients on to endpoints
+		ConnContext: proxyproto.NewContext,
 	}
 
 	err := r.serveHTTP(server, r.errChan)
@@ -191,6 +206,11 @@ func (r *Router) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 		r.errChan <- err
 		return err
 	}
+	err = r.serveTCP(r.errChan)
+	if err != nil {
+		r.errChan <- err
+		return err
+	}
 
 	// create pid file
 	err = r.writePidFile(r.config.PidFile)
@@ -259,6 +279,12 @@ func (r *Router) DrainAndStop() {
 
 	r.Drain(drainWait, drainTimeout)
 
+	drainHijackedTimeout := r.config.DrainHijackedTimeout
+	if drainHijackedTimeout == 0 {
+		drainHijackedTimeout = r.config.EndpointTimeout
+	}
+	r.DrainHijacked(drainHijackedTimeout)
+
 	r.Stop()
 }
 
@@ -276,10 +302,11 @@ func (r *Router) serveHTTPS(server *http.Server, errChan chan error) error {
 		}
 
 		if r.config.EnablePROXY {
-			listener = &proxyproto.Listener{
-				Listener:           listener,
-				ProxyHeaderTimeout: proxyProtocolHeaderTimeout,
-			}
+			listener = r.proxyProtocolListener(listener)
+		}
+
+		if r.config.TLSPassthrough.Enabled {
+			listener = r.proxy.Tcp().PassthroughListener(listener)
 		}
 
 		r.tlsListener = tls.NewListener(listener, tlsConfig)
@@ -308,10 +335,7 @@ func (r *Router) serveHTTP(server *http.Server, errChan chan error) error {
 
 	r.listener = listener
 	if r.config.EnablePROXY {
-		r.listener = &proxyproto.Listener{
-			Listener:           listener,
-			ProxyHeaderTimeout: proxyProtocolHeaderTimeout,
-		}
+		r.listener = r.proxyProtocolListener(listener)
 	}
 
 	r.logger.Info("tcp-listener-started", lager.Data{"address": r.listener.Addr()})
@@ -329,6 +353,52 @@ func (r *Router) serveHTTP(server *http.Server, errChan chan error) error {
 	return nil
 }
 
+// proxyProtocolListener reads the PROXY protocol headers of the connections of
+// a listener from the trusted sources.
+func (r *Router) proxyProtocolListener(listener net.Listener) net.Listener {
+	return &proxyproto.Listener{
+		Listener:           listener,
+		ProxyHeaderTimeout: proxyProtocolHeaderTimeout,
+		TrustedNets:        r.config.ProxyProtocol.TrustedNets,
+		Strict:             r.config.ProxyProtocol.Strict,
+	}
+}
+
+// serveTCP starts the TCP listeners, which route connections to the endpoints
+// of the TCP routes of their ports.
+func (r *Router) serveTCP(errChan chan error) error {
+	for _, listenerConfig := range r.config.TCPListeners {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", listenerConfig.Port))
+		if err != nil {
+			r.logger.Fatal("tcp-listener-error", err)
+			return err
+		}
+
+		if r.config.EnablePROXY {
+			listener = r.proxyProtocolListener(listener)
+		}
+
+		r.tcpListeners = append(r.tcpListeners, listener)
+
+		r.logger.Info("tcp-route-listener-started", lager.Data{
+			"address":      listener.Addr(),
+			"router_group": listenerConfig.RouterGroup,
+		})
+
+		r.tcpServeDone.Add(1)
+		go func(listener net.Listener, listenerConfig config.TCPListenerConfig) {
+			err := r.proxy.Tcp().Serve(listener, listenerConfig)
+			r.stopLock.Lock()
+			if !r.stopping {
+				errChan <- err
+			}
+			r.stopLock.Unlock()
+			r.tcpServeDone.Done()
+		}(listener, listenerConfig)
+	}
+	return nil
+}
+
 func (r *Router) Drain(drainWait, drainTimeout time.Duration) error {
 	atomic.StoreInt32(r.HeartbeatOK, 0)
 
@@ -362,6 +432,22 @@ func (r *Router) Drain(drainWait, drainTimeout time.Duration) error {
 	return nil
 }
 
+// DrainHijacked waits up to the timeout for the websocket and TCP connections
+// hijacked by the proxy to close, and then closes those that remain. It returns
+// the number of connections it closed.
+func (r *Router) DrainHijacked(timeout time.Duration) int {
+	hijacked := r.proxy.Hijacked()
+
+	r.logger.Info(fmt.Sprintf("Draining with %d outstanding hijacked connections", hijacked.Len()))
+	if hijacked.Wait(timeout) {
+		return 0
+	}
+
+	closed := hijacked.Close()
+	r.logger.Info("router.drain.hijacked-closed", lager.Data{"count": closed})
+	return closed
+}
+
 func (r *Router) Stop() {
 	stoppingAt := time.Now()
 
@@ -404,6 +490,11 @@ func (r *Router) stopListening() {
 		<-r.tlsServeDone
 	}
 
+	for _, listener := range r.tcpListeners {
+		listener.Close()
+	}
+	r.tcpServeDone.Wait()
+
 	<-r.serveDone
 }
 
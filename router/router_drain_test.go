@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"sync/atomic"
@@ -21,6 +22,7 @@ import (
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/gorouter/router"
 	"code.cloudfoundry.org/gorouter/routeservice"
+	"code.cloudfoundry.org/gorouter/test"
 	"code.cloudfoundry.org/gorouter/test/common"
 	"code.cloudfoundry.org/gorouter/test_util"
 	vvarz "code.cloudfoundry.org/gorouter/varz"
@@ -225,7 +227,7 @@ var _ = Describe("Router", func() {
 			MinimumRegisterIntervalInSeconds: int(config.StartResponseDelayInterval.Seconds()),
 			PruneThresholdInSeconds:          int(config.DropletStaleThreshold.Seconds()),
 		}
-		subscriber = ifrit.Background(mbus.NewSubscriber(logger.Session("subscriber"), mbusClient, registry, nil, opts))
+		subscriber = ifrit.Background(mbus.NewSubscriber(logger.Session("subscriber"), mbusClient, registry, new(fakes.FakeRouteRegistryReporter), nil, opts))
 		<-subscriber.Ready()
 	})
 
@@ -352,6 +354,40 @@ var _ = Describe("Router", func() {
 			Expect(result).To(Equal(router.DrainTimeout))
 		})
 
+		It("closes the hijacked connections that outlive the hijacked drain timeout", func() {
+			app := test.NewWebSocketApp([]route.Uri{"ws-drain.vcap.me"}, config.Port, mbusClient, 0)
+			app.Listen()
+
+			Eventually(func() bool {
+				return appRegistered(registry, app)
+			}).Should(BeTrue())
+
+			conn, err := net.Dial("tcp", fmt.Sprintf("ws-drain.vcap.me:%d", config.Port))
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+
+			x := test_util.NewHttpConn(conn)
+
+			req := test_util.NewRequest("GET", "ws-drain.vcap.me", "/chat", nil)
+			req.Header.Set("Upgrade", "websocket")
+			req.Header.Set("Connection", "upgrade")
+			x.WriteRequest(req)
+
+			resp, _ := x.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusSwitchingProtocols))
+
+			x.WriteLine("hello from client")
+			x.CheckLine("hello from server")
+
+			err = rtr.Drain(0, time.Second)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(rtr.DrainHijacked(100 * time.Millisecond)).To(Equal(1))
+
+			_, err = x.Reader.ReadString('\n')
+			Expect(err).To(HaveOccurred())
+		})
+
 		Context("with http and https servers", func() {
 			It("it drains and stops the router", func() {
 				app := common.NewTestApp([]route.Uri{"drain.vcap.me"}, config.Port, mbusClient, nil, "")
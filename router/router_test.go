@@ -6,6 +6,7 @@ import (
 
 	"code.cloudfoundry.org/gorouter/access_log"
 	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/common/proxyproto"
 	"code.cloudfoundry.org/gorouter/common/schema"
 	cfg "code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/mbus"
@@ -103,7 +104,7 @@ var _ = Describe("Router", func() {
 			MinimumRegisterIntervalInSeconds: int(config.StartResponseDelayInterval.Seconds()),
 			PruneThresholdInSeconds:          int(config.DropletStaleThreshold.Seconds()),
 		}
-		subscriber := mbus.NewSubscriber(logger.Session("subscriber"), mbusClient, registry, nil, opts)
+		subscriber := mbus.NewSubscriber(logger.Session("subscriber"), mbusClient, registry, new(fakes.FakeRouteRegistryReporter), nil, opts)
 
 		members := grouper.Members{
 			{Name: "subscriber", Runner: subscriber},
@@ -495,6 +496,62 @@ var _ = Describe("Router", func() {
 			Expect(rr).To(Equal("192.168.0.1"))
 		})
 
+		It("sets the X-Forwarded-For header from a version 2 header", func() {
+			app := testcommon.NewTestApp([]route.Uri{"proxy.vcap.me"}, config.Port, mbusClient, nil, "")
+
+			rCh := make(chan string)
+			app.AddHandler("/", func(w http.ResponseWriter, r *http.Request) {
+				rCh <- r.Header.Get("X-Forwarded-For")
+			})
+			app.Listen()
+			Eventually(func() bool {
+				return appRegistered(registry, app)
+			}).Should(BeTrue())
+
+			host := fmt.Sprintf("proxy.vcap.me:%d", config.Port)
+			conn, err := net.DialTimeout("tcp", host, 10*time.Second)
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+
+			header := &proxyproto.Header{
+				Source:      &net.TCPAddr{IP: net.ParseIP("192.168.0.1"), Port: 12345},
+				Destination: &net.TCPAddr{IP: net.ParseIP("192.168.0.2"), Port: 80},
+			}
+			Expect(header.Write(conn, 2)).To(Succeed())
+			fmt.Fprintf(conn, "GET / HTTP/1.0\r\n"+
+				"Host: %s\r\n"+
+				"\r\n", host)
+
+			var rr string
+			Eventually(rCh).Should(Receive(&rr))
+			Expect(rr).To(Equal("192.168.0.1"))
+		})
+
+		Context("when the header comes from an untrusted source in strict mode", func() {
+			BeforeEach(func() {
+				_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
+				config.ProxyProtocol.TrustedNets = []*net.IPNet{trusted}
+				config.ProxyProtocol.Strict = true
+			})
+
+			It("closes the connection", func() {
+				host := fmt.Sprintf("proxy.vcap.me:%d", config.Port)
+				conn, err := net.DialTimeout("tcp", host, 10*time.Second)
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+
+				fmt.Fprintf(conn, "PROXY TCP4 192.168.0.1 192.168.0.2 12345 80\r\n"+
+					"GET / HTTP/1.0\r\n"+
+					"Host: %s\r\n"+
+					"\r\n", host)
+
+				conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+				body, err := ioutil.ReadAll(conn)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(body).To(BeEmpty())
+			})
+		})
+
 		It("sets the x-Forwarded-Proto header to https", func() {
 			app := test.NewGreetApp([]route.Uri{"test.vcap.me"}, config.Port, mbusClient, nil)
 			app.Listen()
@@ -521,6 +578,49 @@ var _ = Describe("Router", func() {
 		})
 	})
 
+	Context("when tcp listeners are configured", func() {
+		var tcpPort uint16
+
+		BeforeEach(func() {
+			tcpPort = test_util.NextAvailPort()
+			config.TCPListeners = []cfg.TCPListenerConfig{
+				{Port: tcpPort, RouterGroup: "default-tcp"},
+			}
+		})
+
+		It("routes connections to the endpoints registered for the port", func() {
+			backend, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			defer backend.Close()
+
+			go func() {
+				conn, err := backend.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				line, _ := bufio.NewReader(conn).ReadString('\n')
+				conn.Write([]byte("echo: " + line))
+			}()
+
+			msg := fmt.Sprintf(`{"host":"127.0.0.1","port":%d,"router_group":"default-tcp","external_port":%d}`,
+				backend.Addr().(*net.TCPAddr).Port, tcpPort)
+			Expect(mbusClient.Publish("router.register", []byte(msg))).To(Succeed())
+			Eventually(func() *route.Pool {
+				return registry.LookupTcp("default-tcp", tcpPort)
+			}).ShouldNot(BeNil())
+
+			conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", tcpPort))
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+
+			fmt.Fprintf(conn, "hello\n")
+			line, err := bufio.NewReader(conn).ReadString('\n')
+			Expect(err).ToNot(HaveOccurred())
+			Expect(line).To(Equal("echo: hello\n"))
+		})
+	})
+
 	Context("HTTP keep-alive", func() {
 		It("reuses the same connection on subsequent calls", func() {
 			app := test.NewGreetApp([]route.Uri{"keepalive.vcap.me"}, config.Port, mbusClient, nil)
@@ -661,7 +761,7 @@ var _ = Describe("Router", func() {
 				resp, err := client.Do(req)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(resp).ToNot(BeNil())
-				Expect(resp.StatusCode).To(Equal(http.StatusBadGateway))
+				Expect(resp.StatusCode).To(Equal(http.StatusGatewayTimeout))
 				defer resp.Body.Close()
 
 				_, err = ioutil.ReadAll(resp.Body)
@@ -833,6 +933,64 @@ var _ = Describe("Router", func() {
 			Expect(err).To(HaveOccurred())
 		})
 
+		Context("when tls passthrough is enabled", func() {
+			BeforeEach(func() {
+				config.TLSPassthrough.Enabled = true
+			})
+
+			It("passes connections for routes with tls passthrough through to their endpoints", func() {
+				backend, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{config.SSLCertificate}})
+				Expect(err).ToNot(HaveOccurred())
+				defer backend.Close()
+
+				go func() {
+					conn, err := backend.Accept()
+					if err != nil {
+						return
+					}
+					defer conn.Close()
+					conn.Write([]byte("terminated by the endpoint"))
+				}()
+
+				msg := fmt.Sprintf(`{"host":"127.0.0.1","port":%d,"uris":["passthrough.vcap.me"],"tls_passthrough":true}`,
+					backend.Addr().(*net.TCPAddr).Port)
+				Expect(mbusClient.Publish("router.register", []byte(msg))).To(Succeed())
+				Eventually(func() *route.Pool {
+					return registry.Lookup("passthrough.vcap.me")
+				}).ShouldNot(BeNil())
+
+				conn, err := tls.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", config.SSLPort), &tls.Config{
+					ServerName:         "passthrough.vcap.me",
+					InsecureSkipVerify: true,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+
+				bytes, err := ioutil.ReadAll(conn)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(bytes)).To(Equal("terminated by the endpoint"))
+			})
+
+			It("terminates tls for other routes on the same port", func() {
+				app := test.NewGreetApp([]route.Uri{"test.vcap.me"}, config.Port, mbusClient, nil)
+				app.Listen()
+				Eventually(func() bool {
+					return appRegistered(registry, app)
+				}).Should(BeTrue())
+
+				uri := fmt.Sprintf("https://test.vcap.me:%d/", config.SSLPort)
+				tr := &http.Transport{
+					TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+				}
+				client := http.Client{Transport: tr}
+
+				resp, err := client.Get(uri)
+				Expect(err).ToNot(HaveOccurred())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			})
+		})
+
 		It("sets the x-Forwarded-Proto header to https", func() {
 			app := test.NewGreetApp([]route.Uri{"test.vcap.me"}, config.Port, mbusClient, nil)
 			app.Listen()
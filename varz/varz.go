@@ -30,6 +30,7 @@ type varz struct {
 
 	BadRequests    int     `json:"bad_requests"`
 	BadGateways    int     `json:"bad_gateways"`
+	IPDenied       int     `json:"ip_denied"`
 	RequestsPerSec float64 `json:"requests_per_sec"`
 
 	TopApps []topAppsEntry `json:"top10_app_requests"`
@@ -164,9 +165,19 @@ type Varz interface {
 
 	CaptureBadRequest(req *http.Request)
 	CaptureBadGateway(req *http.Request)
+	CaptureIPDenied(req *http.Request)
+	CaptureBackendError(req *http.Request, errorType string)
 	CaptureRoutingRequest(b *route.Endpoint, req *http.Request)
 	CaptureRoutingResponse(b *route.Endpoint, res *http.Response, startedAt time.Time, d time.Duration)
 	CaptureRouteServiceResponse(b *route.Endpoint, res *http.Response, startedAt time.Time, d time.Duration)
+	CaptureMirrorResponse(b *route.Endpoint, res *http.Response, d time.Duration)
+	CaptureMirrorSkipped(req *http.Request)
+	CaptureCacheHit(req *http.Request)
+	CaptureCacheMiss(req *http.Request)
+	CaptureBackendConnection(b *route.Endpoint, reused bool)
+	CaptureActiveWebSockets(active int64)
+	CaptureTcpConnection(b *route.Endpoint, bytesReceived, bytesSent int64)
+	CaptureTLSPassthroughConnection(b *route.Endpoint, bytesReceived, bytesSent int64)
 }
 
 type RealVarz struct {
@@ -240,6 +251,16 @@ func (x *RealVarz) CaptureBadGateway(*http.Request) {
 	x.Unlock()
 }
 
+func (x *RealVarz) CaptureIPDenied(*http.Request) {
+	x.Lock()
+	x.IPDenied++
+	x.Unlock()
+}
+
+// backend errors are counted in bad_gateways, their types are reported as metrics only
+func (x *RealVarz) CaptureBackendError(*http.Request, string) {
+}
+
 func (x *RealVarz) CaptureAppStats(b *route.Endpoint, t time.Time) {
 	if b.ApplicationId != "" {
 		x.activeApps.Mark(b.ApplicationId, t)
@@ -267,6 +288,31 @@ func (x *RealVarz) CaptureRoutingRequest(b *route.Endpoint, req *http.Request) {
 func (x *RealVarz) CaptureRouteServiceResponse(endpoint *route.Endpoint, response *http.Response, startedAt time.Time, duration time.Duration) {
 }
 
+// do not emit mirrored requests through varz
+func (x *RealVarz) CaptureMirrorResponse(endpoint *route.Endpoint, response *http.Response, duration time.Duration) {
+}
+
+func (x *RealVarz) CaptureMirrorSkipped(*http.Request) {
+}
+
+func (x *RealVarz) CaptureCacheHit(*http.Request) {
+}
+
+func (x *RealVarz) CaptureCacheMiss(*http.Request) {
+}
+
+func (x *RealVarz) CaptureBackendConnection(*route.Endpoint, bool) {
+}
+
+func (x *RealVarz) CaptureActiveWebSockets(int64) {
+}
+
+func (x *RealVarz) CaptureTcpConnection(*route.Endpoint, int64, int64) {
+}
+
+func (x *RealVarz) CaptureTLSPassthroughConnection(*route.Endpoint, int64, int64) {
+}
+
 func (x *RealVarz) CaptureRoutingResponse(endpoint *route.Endpoint, response *http.Response, startedAt time.Time, duration time.Duration) {
 	x.Lock()
 
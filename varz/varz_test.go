@@ -46,6 +46,7 @@ var _ = Describe("Varz", func() {
 			"requests",
 			"bad_requests",
 			"bad_gateways",
+			"ip_denied",
 			"requests_per_sec",
 			"top10_app_requests",
 			"ms_since_last_registry_update",
@@ -96,6 +97,16 @@ var _ = Describe("Varz", func() {
 		Expect(findValue(Varz, "bad_requests")).To(Equal(float64(2)))
 	})
 
+	It("updates ip denials", func() {
+		r := &http.Request{}
+
+		Varz.CaptureIPDenied(r)
+		Expect(findValue(Varz, "ip_denied")).To(Equal(float64(1)))
+
+		Varz.CaptureIPDenied(r)
+		Expect(findValue(Varz, "ip_denied")).To(Equal(float64(2)))
+	})
+
 	It("updates bad gateways", func() {
 		r := &http.Request{}
 